@@ -0,0 +1,36 @@
+// Локальная заглушка push-провайдеров. Для работы с ней укажите в .env:
+//
+//	PUSH_EXPO_URL=http://localhost:9092/--/api/v2/push/send
+//	PUSH_FCM_URL=http://localhost:9092
+//	PUSH_FCM_CREDENTIALS_FILE=fcm-stub.json
+//
+// Ключ сервисного аккаунта для заглушки: curl localhost:9092/credentials > fcm-stub.json
+// (после перезапуска заглушки его нужно получить заново).
+//
+// Полученные уведомления доступны на http://localhost:9092/messages.
+// Ошибки провайдеров имитируются флагом -fail.
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"domofon/internal/config"
+	"domofon/internal/push"
+
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	addr := flag.String("addr", ":9092", "адрес для прослушивания")
+	fail := flag.Bool("fail", false, "отвечать временной ошибкой")
+	flag.Parse()
+
+	config.SetupLogger()
+
+	stub := &push.StubServer{Fail: *fail}
+	log.Info().Str("addr", *addr).Msg("Push stub started")
+	if err := http.ListenAndServe(*addr, stub.Handler()); err != nil {
+		log.Fatal().Err(err).Msg("Push stub stopped")
+	}
+}
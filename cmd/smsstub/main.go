@@ -0,0 +1,32 @@
+// Локальная заглушка SMS-шлюзов. Для работы с ней укажите в .env:
+//
+//	SMSRU_API_URL=http://localhost:9090/sms/send
+//	SMSC_API_URL=http://localhost:9090/sys/send.php
+//
+// Ошибки провайдеров имитируются флагами -fail-smsru и -fail-smsc.
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"domofon/internal/config"
+	"domofon/internal/sms"
+
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "адрес для прослушивания")
+	failSMSRu := flag.Int("fail-smsru", 0, "код ошибки sms.ru (0 — отвечать успехом)")
+	failSMSC := flag.Int("fail-smsc", 0, "код ошибки smsc.ru (0 — отвечать успехом)")
+	flag.Parse()
+
+	config.SetupLogger()
+
+	stub := &sms.StubServer{FailSMSRu: *failSMSRu, FailSMSC: *failSMSC}
+	log.Info().Str("addr", *addr).Msg("SMS stub started")
+	if err := http.ListenAndServe(*addr, stub.Handler()); err != nil {
+		log.Fatal().Err(err).Msg("SMS stub stopped")
+	}
+}
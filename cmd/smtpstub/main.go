@@ -0,0 +1,44 @@
+// Локальная заглушка SMTP-сервера. Для работы с ней укажите в .env:
+//
+//	MAIL_DRIVER=smtp
+//	SMTP_HOST=localhost
+//	SMTP_PORT=2525
+//	SMTP_TLS=none
+//
+// Полученные письма доступны на http://localhost:9091/messages.
+package main
+
+import (
+	"flag"
+	"net"
+	"net/http"
+
+	"domofon/internal/config"
+	"domofon/internal/mail"
+
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	smtpAddr := flag.String("smtp-addr", ":2525", "адрес SMTP")
+	httpAddr := flag.String("http-addr", ":9091", "адрес для просмотра писем")
+	flag.Parse()
+
+	config.SetupLogger()
+
+	stub := &mail.StubServer{}
+	l, err := net.Listen("tcp", *smtpAddr)
+	if err != nil {
+		log.Fatal().Err(err).Msg("SMTP stub: не удалось открыть порт")
+	}
+	go func() {
+		if err := stub.Serve(l); err != nil {
+			log.Fatal().Err(err).Msg("SMTP stub stopped")
+		}
+	}()
+
+	log.Info().Str("smtp", *smtpAddr).Str("http", *httpAddr).Msg("SMTP stub started")
+	if err := http.ListenAndServe(*httpAddr, stub.Handler()); err != nil {
+		log.Fatal().Err(err).Msg("SMTP stub stopped")
+	}
+}
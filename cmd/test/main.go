@@ -7,7 +7,17 @@ package main
 
 import (
 	"net/http"
+	"domofon/internal/announcement"
+	"domofon/internal/anomaly"
 	"domofon/internal/config"
+	"domofon/internal/db"
+	"domofon/internal/jwt"
+	"domofon/internal/keylist"
+	"domofon/internal/mail"
+	"domofon/internal/outbox"
+	"domofon/internal/push"
+	"domofon/internal/schedule"
+	"domofon/internal/sms"
 	"github.com/rs/zerolog/log"
 	serverhttp "domofon/server/http"
 	"context"
@@ -27,7 +37,48 @@ func main() {
 	}
 	defer pool.Close()
 
-	router := serverhttp.NewRouter(pool)
+	// Ключи подписи JWT; ротацию выполняет один экземпляр под блокировкой в базе
+	jwtCfg := config.LoadJWTConfig()
+	keySet, err := jwt.Init(jwtCfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Не удалось загрузить ключи подписи JWT")
+	}
+	go keySet.RunRotation(ctx, jwtCfg.RotationInterval, jwtCfg.ReloadInterval, db.NewAdvisoryLock(pool, jwt.RotationLockKey))
+
+	// Очередь исходящих сообщений и её воркеры
+	outboxCfg := config.LoadOutboxConfig()
+	outboxRepo := outbox.NewRepository(db.New(pool))
+	smsGateway, err := sms.NewGatewayFromConfig(config.LoadSMSConfig())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Не удалось настроить отправку SMS")
+	}
+	mailer, err := mail.NewMailer(config.LoadMailConfig())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Не удалось настроить отправку почты")
+	}
+	outboxWorker := outbox.NewWorker(outboxRepo, outboxCfg)
+	outboxWorker.Register(outbox.ChannelSMS, outbox.NewSMSSender(smsGateway))
+	outboxWorker.Register(outbox.ChannelEmail, outbox.NewEmailSender(mailer))
+	outboxWorker.Register(outbox.ChannelPush, push.NewSender(push.NewPushRepository(pool), push.NewProviders(config.LoadPushConfig())))
+	go outboxWorker.Run(ctx)
+
+	// Плановая пересборка офлайн-списков ключей домофонов
+	scheduleService := schedule.NewScheduleService(schedule.NewScheduleRepository(pool), config.LoadScheduleConfig())
+	keyListService := keylist.NewKeyListService(keylist.NewKeyListRepository(db.New(pool)), scheduleService, config.LoadKeyListConfig(), config.LoadDeviceConfig())
+	go keyListService.RunRebuild(ctx)
+
+	// Поиск аномалий в журнале доступа
+	anomalyService := anomaly.NewAnomalyService(anomaly.NewAnomalyRepository(pool), config.LoadAnomalyConfig())
+	go anomalyService.RunScanner(ctx)
+
+	queue := outbox.NewQueue(outboxRepo, outboxCfg.MaxAttempts)
+
+	// Push о запланированных объявлениях в момент публикации
+	pushService := push.NewPushService(push.NewPushRepository(pool), queue, config.LoadPushConfig())
+	announcementService := announcement.NewAnnouncementService(announcement.NewAnnouncementRepository(pool), pushService, config.LoadAnnouncementConfig())
+	go announcementService.RunNotifier(ctx)
+
+	router := serverhttp.NewRouter(pool, queue)
 router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 	log.Info().Msg("Server started at :8080")
 	if err := http.ListenAndServe(":8080", router); err != nil {
@@ -0,0 +1,67 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"domofon/internal/push"
+
+	"github.com/rs/zerolog/log"
+)
+
+const maxApartmentNumberLength = 32
+
+var (
+	ErrEmptyApartment    = errors.New("не указан номер квартиры")
+	ErrApartmentNotFound = errors.New("квартира не найдена")
+)
+
+// Call — вызов квартиры с панели: владелец и жители получают push типа call
+// (высокий приоритет, живёт минуту). Возвращает, скольким жителям он ушёл.
+func (s *AccessService) Call(ctx context.Context, deviceID int64, req CallRequest) (*CallResponse, error) {
+	number := strings.TrimSpace(req.Apartment)
+	if number == "" || len(number) > maxApartmentNumberLength {
+		return nil, ErrEmptyApartment
+	}
+	address, err := s.repo.DeviceAddress(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if address == "" {
+		return nil, ErrDeviceNotFound
+	}
+	callees, err := s.repo.ApartmentCallees(ctx, address, number)
+	if err != nil {
+		return nil, err
+	}
+	if len(callees) == 0 {
+		return nil, ErrApartmentNotFound
+	}
+
+	resp := &CallResponse{}
+	if s.notifier == nil {
+		return resp, nil
+	}
+	n := push.Notification{
+		Event: push.EventCall,
+		Title: "Звонок в домофон",
+		Body:  address + ", кв. " + number,
+		Data: map[string]string{
+			"device_id": strconv.FormatInt(deviceID, 10),
+			"apartment": number,
+		},
+	}
+	for _, userID := range callees {
+		queued, err := s.notifier.Notify(ctx, userID, n)
+		if err != nil {
+			log.Error().Err(err).Int64("user_id", userID).Int64("device_id", deviceID).Msg("[access] Не удалось отправить уведомление о звонке")
+			continue
+		}
+		if queued > 0 {
+			resp.Notified++
+		}
+	}
+	return resp, nil
+}
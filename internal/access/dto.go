@@ -0,0 +1,145 @@
+package access
+
+import "time"
+
+type CreateGuestPassRequest struct {
+	ApartmentID int64  `json:"apartment_id"`
+	GuestName   string `json:"guest_name"`
+	// numeric — цифровой код для ввода на панели, qr — строка для QR-кода
+	Format string `json:"format"`
+	// По умолчанию — с момента создания на GUEST_PASS_DEFAULT_VALIDITY
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+	ValidTo   *time.Time `json:"valid_to,omitempty"`
+	// По умолчанию — одно использование
+	MaxUses int `json:"max_uses"`
+	// Если задано — пропуск действует только на этом домофоне; он должен стоять в доме квартиры
+	DeviceID *int64 `json:"device_id,omitempty"`
+	// Расписание доступа (GET /access-schedules), например «будни 9–18»
+	ScheduleID *int64 `json:"schedule_id,omitempty"`
+}
+
+type GuestPassResponse struct {
+	ID          int64     `json:"id"`
+	ApartmentID int64     `json:"apartment_id"`
+	GuestName   string    `json:"guest_name,omitempty"`
+	Format      string    `json:"format"`
+	ValidFrom   time.Time `json:"valid_from"`
+	ValidTo     time.Time `json:"valid_to"`
+	MaxUses     int       `json:"max_uses"`
+	UseCount    int       `json:"use_count"`
+	DeviceID    *int64    `json:"device_id,omitempty"`
+	ScheduleID  *int64    `json:"schedule_id,omitempty"`
+	// scheduled, active, used, expired, revoked
+	Status    string     `json:"status"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type CreatedGuestPassResponse struct {
+	GuestPassResponse
+	// Показывается один раз, в базе хранится только хеш
+	Code string `json:"code"`
+}
+
+type AccessEventResponse struct {
+	ID          int64     `json:"id"`
+	Time        time.Time `json:"time"`
+	DeviceID    *int64    `json:"device_id,omitempty"`
+	Result      string    `json:"result"`
+	Description string    `json:"description,omitempty"`
+}
+
+type CheckRequest struct {
+	// Введённый на панели код или содержимое считанного QR-кода
+	Code string `json:"code"`
+}
+
+type CheckResponse struct {
+	Granted bool `json:"granted"`
+	// Причина отказа: unknown_code, not_yet_valid, wrong_device, wrong_building, exhausted,
+	// holiday, outside_schedule, key_lost; для QR-кодов жителей — invalid_qr, expired, key_revoked
+	Reason      string `json:"reason,omitempty"`
+	KeyID       int64  `json:"key_id,omitempty"`
+	ApartmentID int64  `json:"apartment_id,omitempty"`
+	// Житель, чей QR-код предъявлен
+	UserID        int64 `json:"user_id,omitempty"`
+	RemainingUses int   `json:"remaining_uses,omitempty"`
+}
+
+type QRCodeResponse struct {
+	// Содержимое QR-кода: JWT, подписанный ключом из /.well-known/jwks.json
+	Payload   string    `json:"payload"`
+	KeyID     int64     `json:"key_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Через сколько секунд запросить новый код
+	RefreshIn int `json:"refresh_in"`
+}
+
+// Решение, принятое панелью без связи с сервером
+type OfflineRecord struct {
+	// Идентификатор записи на панели, уникальный в пределах устройства
+	ID    string `json:"id"`
+	KeyID int64  `json:"key_id,omitempty"`
+	// Отказ с причиной — как в CheckResponse
+	Granted bool   `json:"granted"`
+	Reason  string `json:"reason,omitempty"`
+	// Время прохода по часам панели
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+type OfflineUploadRequest struct {
+	// Текущее время по часам панели в момент выгрузки; по нему оценивается расхождение часов
+	DeviceTime *time.Time      `json:"device_time,omitempty"`
+	Records    []OfflineRecord `json:"records"`
+}
+
+type OfflineRecordResult struct {
+	ID string `json:"id"`
+	// accepted, duplicate (уже выгружалась) или rejected
+	Status string `json:"status"`
+	// clock_skew, future_time, too_old
+	Anomaly string `json:"anomaly,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type OfflineUploadResponse struct {
+	Accepted   int `json:"accepted"`
+	Duplicates int `json:"duplicates"`
+	Rejected   int `json:"rejected"`
+	// Часы панели минус часы сервера
+	ClockSkewSeconds int64                 `json:"clock_skew_seconds"`
+	ClockSkewFlagged bool                  `json:"clock_skew_flagged"`
+	Records          []OfflineRecordResult `json:"records"`
+}
+
+// Ключ жителя (брелок, карта и т.п.)
+type KeyResponse struct {
+	ID          int64      `json:"id"`
+	Code        string     `json:"code"`
+	Type        string     `json:"type"`
+	IsActive    bool       `json:"is_active"`
+	IssuedAt    *time.Time `json:"issued_at,omitempty"`
+	ValidFrom   *time.Time `json:"valid_from,omitempty"`
+	ValidTo     *time.Time `json:"valid_to,omitempty"`
+	Description string     `json:"description,omitempty"`
+	ScheduleID  *int64     `json:"schedule_id,omitempty"`
+	// Когда ключ заявлен как потерянный
+	LostAt *time.Time `json:"lost_at,omitempty"`
+	// Ключ, выпущенный взамен потерянного
+	ReplacementKeyID *int64 `json:"replacement_key_id,omitempty"`
+}
+
+type ReplaceKeyRequest struct {
+	// Код нового брелока или карты
+	KeyCode string `json:"key_code"`
+}
+
+type CallRequest struct {
+	// Номер квартиры, набранный на панели
+	Apartment string `json:"apartment"`
+}
+
+type CallResponse struct {
+	// Сколько жителей получили уведомление хотя бы на одно устройство
+	Notified int `json:"notified"`
+}
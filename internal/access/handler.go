@@ -0,0 +1,362 @@
+package access
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"domofon/internal/middleware"
+)
+
+type AccessHandler struct {
+	access *AccessService
+}
+
+func NewAccessHandler(s *AccessService) *AccessHandler {
+	return &AccessHandler{access: s}
+}
+
+// CreateGuestPass godoc
+// @Summary Выпустить гостевой пропуск
+// @Description Пропуск в квартиру жителя: цифровой код или строка для QR-кода, срок действия и число проходов. device_id — только домофон того же дома, что и квартира. Код показывается один раз.
+// @Tags access
+// @Accept json
+// @Produce json
+// @Param input body CreateGuestPassRequest true "Параметры пропуска"
+// @Success 201 {object} CreatedGuestPassResponse
+// @Failure 400 {string} string "Некорректные параметры"
+// @Failure 403 {string} string "Нет доступа к квартире или расписание шире своего"
+// @Security BearerAuth
+// @Router /guest-passes [post]
+func (h *AccessHandler) CreateGuestPass(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req CreateGuestPassRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	pass, err := h.access.CreateGuestPass(r.Context(), userID, claims.ApartmentIDs, req)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, pass)
+}
+
+// ListGuestPasses godoc
+// @Summary Мои гостевые пропуска
+// @Description Действующие пропуска и истёкшие за GUEST_PASS_HISTORY_WINDOW, от новых к старым.
+// @Tags access
+// @Produce json
+// @Success 200 {array} GuestPassResponse
+// @Security BearerAuth
+// @Router /guest-passes [get]
+func (h *AccessHandler) ListGuestPasses(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	passes, err := h.access.ListGuestPasses(r.Context(), userID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, passes)
+}
+
+// RevokeGuestPass godoc
+// @Summary Отозвать гостевой пропуск
+// @Tags access
+// @Param id path int true "ID пропуска"
+// @Success 204
+// @Failure 404 {string} string "Пропуск не найден"
+// @Failure 409 {string} string "Пропуск уже отозван"
+// @Security BearerAuth
+// @Router /guest-passes/{id} [delete]
+func (h *AccessHandler) RevokeGuestPass(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	keyID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.access.RevokeGuestPass(r.Context(), userID, keyID); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// History godoc
+// @Summary Журнал проходов по пропуску
+// @Tags access
+// @Produce json
+// @Param id path int true "ID пропуска"
+// @Success 200 {array} AccessEventResponse
+// @Failure 404 {string} string "Пропуск не найден"
+// @Security BearerAuth
+// @Router /guest-passes/{id}/history [get]
+func (h *AccessHandler) History(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	keyID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	events, err := h.access.History(r.Context(), userID, keyID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// QRCode godoc
+// @Summary Текущий QR-код жителя
+// @Description Подписанный код для входа по QR. Живёт QR_TOKEN_TTL, приложение запрашивает новый через refresh_in секунд — скриншот быстро становится бесполезен. Панель проверяет код офлайн по /.well-known/jwks.json или онлайн через /device/access/check.
+// @Tags access
+// @Produce json
+// @Success 200 {object} QRCodeResponse
+// @Failure 403 {string} string "У пользователя нет квартир"
+// @Security BearerAuth
+// @Router /access/qr [get]
+func (h *AccessHandler) QRCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	code, err := h.access.IssueQR(r.Context(), userID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, code)
+}
+
+// Check godoc
+// @Summary Проверка кода домофоном
+// @Description Для устройств (Authorization: Device <key>). Отказ — тоже 200 с granted=false и причиной; попытка пишется в журнал доступа.
+// @Tags access
+// @Accept json
+// @Produce json
+// @Param input body CheckRequest true "Код с панели, гостевой QR или QR-код жителя"
+// @Success 200 {object} CheckResponse
+// @Failure 400 {string} string "Пустой код"
+// @Failure 401 {string} string "Неверный ключ устройства"
+// @Failure 429 {string} string "Слишком много запросов"
+// @Router /device/access/check [post]
+func (h *AccessHandler) Check(w http.ResponseWriter, r *http.Request) {
+	deviceID, ok := middleware.DeviceIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req CheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	result, err := h.access.Check(r.Context(), deviceID, req.Code)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// UploadOffline godoc
+// @Summary Выгрузка офлайн-журнала панели
+// @Description Для устройств (Authorization: Device <key>). Записи с уже выгруженным id не дублируются (status=duplicate), поэтому пачку можно отправлять повторно. Если device_time расходится с часами сервера больше ACCESS_OFFLINE_MAX_CLOCK_SKEW, время записей поправляется и помечается clock_skew.
+// @Tags access
+// @Accept json
+// @Produce json
+// @Param input body OfflineUploadRequest true "Записи, принятые панелью без связи"
+// @Success 200 {object} OfflineUploadResponse
+// @Failure 400 {string} string "Пустая или слишком большая выгрузка"
+// @Failure 401 {string} string "Неверный ключ устройства"
+// @Router /device/access/offline [post]
+func (h *AccessHandler) UploadOffline(w http.ResponseWriter, r *http.Request) {
+	deviceID, ok := middleware.DeviceIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req OfflineUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	result, err := h.access.UploadOffline(r.Context(), deviceID, req)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Call godoc
+// @Summary Вызов квартиры с панели
+// @Description Для устройств (Authorization: Device <key>). Владелец и активные жители квартиры в доме панели получают push-уведомление call (высокий приоритет, в тихие часы — по их настройкам).
+// @Tags access
+// @Accept json
+// @Produce json
+// @Param input body CallRequest true "Номер квартиры"
+// @Success 200 {object} CallResponse
+// @Failure 400 {string} string "Не указан номер квартиры"
+// @Failure 401 {string} string "Неверный ключ устройства"
+// @Failure 404 {string} string "Квартира не найдена"
+// @Router /device/calls [post]
+func (h *AccessHandler) Call(w http.ResponseWriter, r *http.Request) {
+	deviceID, ok := middleware.DeviceIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req CallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	result, err := h.access.Call(r.Context(), deviceID, req)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// ListKeys godoc
+// @Summary Мои ключи
+// @Description Брелоки и карты жителя, включая заблокированные. Гостевые пропуска и QR-коды не показываются.
+// @Tags keys
+// @Produce json
+// @Success 200 {array} KeyResponse
+// @Security BearerAuth
+// @Router /keys [get]
+func (h *AccessHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	keys, err := h.access.ListKeys(r.Context(), userID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// ReportLost godoc
+// @Summary Сообщить о потере ключа
+// @Description Ключ сразу отключается, списки ключей на домофонах дома пересобираются. Если ключ приложат к домофону позже, владелец получит уведомление security. Роли из KEY_ADMIN_ROLES могут блокировать ключи любых жителей.
+// @Tags keys
+// @Produce json
+// @Param id path int true "ID ключа"
+// @Success 200 {object} KeyResponse
+// @Failure 400 {string} string "Ключ этого типа не блокируется"
+// @Failure 404 {string} string "Ключ не найден"
+// @Failure 409 {string} string "О потере уже сообщено"
+// @Security BearerAuth
+// @Router /keys/{id}/lost [post]
+func (h *AccessHandler) ReportLost(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	keyID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	key, err := h.access.ReportLost(r.Context(), userID, role, keyID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
+
+// Reissue godoc
+// @Summary Выпустить ключ взамен потерянного
+// @Description Новый ключ получает тип, владельца, срок действия и расписание потерянного. Замена выпускается один раз и только ролями из KEY_ADMIN_ROLES.
+// @Tags keys
+// @Accept json
+// @Produce json
+// @Param id path int true "ID потерянного ключа"
+// @Param input body ReplaceKeyRequest true "Код нового ключа"
+// @Success 201 {object} KeyResponse
+// @Failure 400 {string} string "Ключ не заявлен как потерянный или некорректный код"
+// @Failure 403 {string} string "Замену выпускает администрация"
+// @Failure 404 {string} string "Ключ не найден"
+// @Failure 409 {string} string "Замена уже выпущена или код занят"
+// @Security BearerAuth
+// @Router /keys/{id}/replacement [post]
+func (h *AccessHandler) Reissue(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	keyID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req ReplaceKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	key, err := h.access.Reissue(r.Context(), userID, role, keyID, req)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, key)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAccessError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrApartmentDenied), errors.Is(err, ErrNoApartments), errors.Is(err, ErrReissueForbidden),
+		errors.Is(err, ErrScheduleDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrGuestPassNotFound), errors.Is(err, ErrKeyNotFound), errors.Is(err, ErrApartmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrAlreadyRevoked), errors.Is(err, ErrAlreadyReported), errors.Is(err, ErrAlreadyReplaced),
+		errors.Is(err, ErrKeyCodeInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvalidFormat), errors.Is(err, ErrInvalidValidity), errors.Is(err, ErrInvalidMaxUses),
+		errors.Is(err, ErrGuestNameTooLong), errors.Is(err, ErrDeviceNotFound), errors.Is(err, ErrDeviceElsewhere), errors.Is(err, ErrScheduleNotFound),
+		errors.Is(err, ErrEmptyCode), errors.Is(err, ErrEmptyBatch), errors.Is(err, ErrBatchTooLarge),
+		errors.Is(err, ErrKeyNotReportable), errors.Is(err, ErrNotReportedLost), errors.Is(err, ErrInvalidKeyCode),
+		errors.Is(err, ErrEmptyApartment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
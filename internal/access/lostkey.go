@@ -0,0 +1,245 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"domofon/internal/db"
+	"domofon/internal/push"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	KeyTypeRFID = "rfid"
+
+	ReasonKeyLost = "key_lost"
+
+	// Типы записей в events
+	EventKeyLost          = "key_lost"
+	EventLostKeyPresented = "lost_key_presented"
+	EventKeyReplaced      = "key_replaced"
+
+	maxKeyCodeLength = 64
+)
+
+var (
+	ErrKeyNotFound      = errors.New("ключ не найден")
+	ErrKeyNotReportable = errors.New("гостевые пропуска и QR-коды не блокируются как потерянные")
+	ErrAlreadyReported  = errors.New("о потере ключа уже сообщено")
+	ErrNotReportedLost  = errors.New("ключ не заявлен как потерянный")
+	ErrAlreadyReplaced  = errors.New("замена ключа уже выпущена")
+	ErrInvalidKeyCode   = errors.New("некорректный код ключа")
+	ErrKeyCodeInUse     = errors.New("ключ с таким кодом уже существует")
+	ErrReissueForbidden = errors.New("замену ключа выпускает администрация дома")
+)
+
+// Ключи пользователя, включая отключённые и потерянные
+func (s *AccessService) ListKeys(ctx context.Context, userID int64) ([]KeyResponse, error) {
+	rows, err := s.repo.ListOwnerKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]KeyResponse, len(rows))
+	for i, row := range rows {
+		result[i] = keyResponse(row)
+	}
+	return result, nil
+}
+
+// ReportLost сразу отключает ключ и пересобирает списки на домофонах его домов.
+// Свои ключи блокирует сам житель, ключи любых жителей — роли из KEY_ADMIN_ROLES.
+func (s *AccessService) ReportLost(ctx context.Context, userID int64, role string, keyID int64) (*KeyResponse, error) {
+	key, err := s.ownKey(ctx, userID, role, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.KeyType.String == KeyTypeGuest || key.KeyType.String == KeyTypeQR {
+		return nil, ErrKeyNotReportable
+	}
+
+	now := time.Now()
+	ok, err := s.repo.ReportLostKey(ctx, keyID, db.CreateEventParams{
+		EventType:   EventKeyLost,
+		UserID:      pgtype.Int4{Int32: int32(userID), Valid: true},
+		Description: pgtype.Text{String: fmt.Sprintf("key_id=%d", keyID), Valid: true},
+	}, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrAlreadyReported
+	}
+	log.Info().Int64("user_id", userID).Int64("key_id", keyID).Msg("[access] Ключ заблокирован как потерянный")
+
+	s.syncPanels(ctx, key.OwnerID)
+	s.notifyOwner(ctx, key.OwnerID, push.Notification{
+		Event: push.EventSecurity,
+		Title: "Ключ заблокирован",
+		Body:  fmt.Sprintf("%s заблокирован как потерянный и больше не откроет дверь", keyLabel(key)),
+		Data:  map[string]string{"key_id": strconv.FormatInt(keyID, 10)},
+	})
+
+	row := ownerKeyRow(key)
+	row.IsActive = pgtype.Bool{Bool: false, Valid: true}
+	row.LostAt = pgtype.Timestamp{Time: now, Valid: true}
+	resp := keyResponse(row)
+	return &resp, nil
+}
+
+// Reissue выпускает ключ взамен потерянного: тот же тип, владелец, срок и расписание.
+// Код нового ключа записывает тот, кто выдаёт брелок, поэтому выпуск доступен
+// только ролям из KEY_ADMIN_ROLES; житель сообщает о потере и ждёт замену.
+func (s *AccessService) Reissue(ctx context.Context, userID int64, role string, keyID int64, req ReplaceKeyRequest) (*KeyResponse, error) {
+	if !slices.Contains(s.cfg.KeyAdminRoles, role) {
+		return nil, ErrReissueForbidden
+	}
+	key, err := s.ownKey(ctx, userID, role, keyID)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.repo.LostKeyReport(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrNotReportedLost
+	}
+	if report.ReplacementKeyID.Valid {
+		return nil, ErrAlreadyReplaced
+	}
+	code := strings.TrimSpace(req.KeyCode)
+	if code == "" || len(code) > maxKeyCodeLength {
+		return nil, ErrInvalidKeyCode
+	}
+
+	replacement, err := s.repo.ReplaceLostKey(ctx, keyID, db.CreateKeyParams{
+		KeyCode:     code,
+		KeyType:     key.KeyType,
+		OwnerID:     key.OwnerID,
+		ValidTo:     key.ValidTo,
+		Description: key.Description,
+		ScheduleID:  key.ScheduleID,
+	}, userID)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Int64("user_id", userID).Int64("key_id", keyID).Int32("replacement_key_id", replacement.ID).
+		Msg("[access] Выпущен ключ взамен потерянного")
+
+	s.syncPanels(ctx, replacement.OwnerID)
+	resp := keyResponse(ownerKeyRow(replacement))
+	return &resp, nil
+}
+
+// Потерянный ключ приложили к домофону: отмечаем в заявлении, пишем событие
+// и срочно уведомляем владельца (security приходит и в тихие часы)
+func (s *AccessService) lostKeyPresented(ctx context.Context, deviceID, keyID int64, ownerID pgtype.Int4, at time.Time) {
+	log.Warn().Int64("device_id", deviceID).Int64("key_id", keyID).Msg("[access] Потерянный ключ приложен к домофону")
+	err := s.repo.MarkLostKeyPresented(ctx, keyID, db.CreateEventParams{
+		DeviceID:    pgtype.Int4{Int32: int32(deviceID), Valid: true},
+		EventType:   EventLostKeyPresented,
+		UserID:      ownerID,
+		Description: pgtype.Text{String: fmt.Sprintf("key_id=%d at=%s", keyID, at.Format(time.RFC3339)), Valid: true},
+	}, at)
+	if err != nil {
+		log.Error().Err(err).Int64("key_id", keyID).Msg("[access] Не удалось записать предъявление потерянного ключа")
+	}
+	s.notifyOwner(ctx, ownerID, push.Notification{
+		Event: push.EventSecurity,
+		Title: "Потерянный ключ у домофона",
+		Body:  "Ваш заблокированный ключ приложили к домофону. Дверь не открылась.",
+		Data: map[string]string{
+			"key_id":    strconv.FormatInt(keyID, 10),
+			"device_id": strconv.FormatInt(deviceID, 10),
+		},
+	})
+}
+
+// Ошибки здесь не возвращаются: ключ уже заблокирован в базе,
+// а плановая пересборка всё равно доставит списки на панели
+func (s *AccessService) syncPanels(ctx context.Context, ownerID pgtype.Int4) {
+	if s.panels == nil || !ownerID.Valid {
+		return
+	}
+	if err := s.panels.RebuildForUser(ctx, int64(ownerID.Int32)); err != nil {
+		log.Error().Err(err).Int32("user_id", ownerID.Int32).Msg("[access] Не удалось пересобрать списки ключей на домофонах")
+	}
+}
+
+func (s *AccessService) notifyOwner(ctx context.Context, ownerID pgtype.Int4, n push.Notification) {
+	if s.notifier == nil || !ownerID.Valid {
+		return
+	}
+	if _, err := s.notifier.Notify(ctx, int64(ownerID.Int32), n); err != nil {
+		log.Error().Err(err).Int32("user_id", ownerID.Int32).Msg("[access] Не удалось отправить уведомление о ключе")
+	}
+}
+
+// Ключ владельца; чужой ключ для жителя неотличим от несуществующего
+func (s *AccessService) ownKey(ctx context.Context, userID int64, role string, keyID int64) (*db.Key, error) {
+	key, err := s.repo.GetKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrKeyNotFound
+	}
+	if !slices.Contains(s.cfg.KeyAdminRoles, role) && (!key.OwnerID.Valid || int64(key.OwnerID.Int32) != userID) {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func keyLabel(k *db.Key) string {
+	if k.Description.Valid && k.Description.String != "" {
+		return "Ключ «" + k.Description.String + "»"
+	}
+	return "Ключ"
+}
+
+func ownerKeyRow(k *db.Key) db.ListOwnerKeysRow {
+	return db.ListOwnerKeysRow{
+		ID:          k.ID,
+		KeyCode:     k.KeyCode,
+		KeyType:     k.KeyType,
+		IsActive:    k.IsActive,
+		IssuedAt:    k.IssuedAt,
+		ValidFrom:   k.ValidFrom,
+		ValidTo:     k.ValidTo,
+		Description: k.Description,
+		ScheduleID:  k.ScheduleID,
+	}
+}
+
+func keyResponse(row db.ListOwnerKeysRow) KeyResponse {
+	resp := KeyResponse{
+		ID:          int64(row.ID),
+		Code:        row.KeyCode,
+		Type:        row.KeyType.String,
+		IsActive:    row.IsActive.Bool,
+		IssuedAt:    db.TimePtr(row.IssuedAt),
+		ValidFrom:   db.TimePtr(row.ValidFrom),
+		ValidTo:     db.TimePtr(row.ValidTo),
+		Description: row.Description.String,
+		LostAt:      db.TimePtr(row.LostAt),
+	}
+	if resp.Type == "" {
+		resp.Type = KeyTypeRFID
+	}
+	if row.ScheduleID.Valid {
+		id := int64(row.ScheduleID.Int32)
+		resp.ScheduleID = &id
+	}
+	if row.ReplacementKeyID.Valid {
+		id := int64(row.ReplacementKeyID.Int32)
+		resp.ReplacementKeyID = &id
+	}
+	return resp
+}
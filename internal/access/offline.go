@@ -0,0 +1,209 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	OfflineAccepted  = "accepted"
+	OfflineDuplicate = "duplicate"
+	OfflineRejected  = "rejected"
+
+	// Часы панели расходились с серверными больше ACCESS_OFFLINE_MAX_CLOCK_SKEW
+	AnomalyClockSkew = "clock_skew"
+	// Время записи позже момента выгрузки даже после поправки часов
+	AnomalyFutureTime = "future_time"
+	// Запись старше ACCESS_OFFLINE_MAX_AGE
+	AnomalyTooOld = "too_old"
+
+	maxRecordIDLength = 64
+	maxReasonLength   = 32
+)
+
+var (
+	ErrEmptyBatch    = errors.New("нет записей для выгрузки")
+	ErrBatchTooLarge = errors.New("слишком много записей в одной выгрузке")
+)
+
+// Запись офлайн-журнала в том виде, в каком её сохраняет репозиторий
+type offlineEntry struct {
+	record  db.CreateOfflineAccessRecordParams
+	history db.CreateAccessHistoryParams
+	// Разрешённый проход по гостевому пропуску — занимает одно использование
+	guestPassUse bool
+	// Ключ приложили после заявления о потере
+	lostKey bool
+}
+
+// UploadOffline принимает журнал, накопленный панелью без связи. Повторная
+// выгрузка тех же записей ничего не дублирует: они отмечаются как duplicate.
+func (s *AccessService) UploadOffline(ctx context.Context, deviceID int64, req OfflineUploadRequest) (*OfflineUploadResponse, error) {
+	if len(req.Records) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(req.Records) > s.cfg.OfflineMaxBatch {
+		return nil, ErrBatchTooLarge
+	}
+
+	now := time.Now()
+	var skew time.Duration
+	if req.DeviceTime != nil {
+		skew = req.DeviceTime.Sub(now).Round(time.Second)
+	}
+	skewed := skew > s.cfg.OfflineMaxClockSkew || -skew > s.cfg.OfflineMaxClockSkew
+	if skewed {
+		log.Warn().Int64("device_id", deviceID).Dur("skew", skew).
+			Msg("[access] Часы панели расходятся с сервером, время офлайн-записей поправлено")
+	}
+
+	resp := &OfflineUploadResponse{
+		ClockSkewSeconds: int64(skew / time.Second),
+		ClockSkewFlagged: skewed,
+		Records:          make([]OfflineRecordResult, len(req.Records)),
+	}
+	keys := make(map[int64]*db.Key)
+	seen := make(map[string]bool, len(req.Records))
+	entries := make([]offlineEntry, 0, len(req.Records))
+	// Позиция записи в ответе для каждой записи из entries
+	positions := make([]int, 0, len(req.Records))
+
+	for i, rec := range req.Records {
+		result := &resp.Records[i]
+		result.ID = rec.ID
+		if msg := validateOfflineRecord(rec); msg != "" {
+			result.Status = OfflineRejected
+			result.Error = msg
+			resp.Rejected++
+			continue
+		}
+		if seen[rec.ID] {
+			result.Status = OfflineDuplicate
+			resp.Duplicates++
+			continue
+		}
+		seen[rec.ID] = true
+
+		accessTime := rec.OccurredAt
+		if skewed {
+			accessTime = accessTime.Add(-skew)
+			result.Anomaly = AnomalyClockSkew
+		}
+		switch {
+		case accessTime.After(now.Add(s.cfg.OfflineMaxClockSkew)):
+			accessTime = now
+			result.Anomaly = AnomalyFutureTime
+		case now.Sub(accessTime) > s.cfg.OfflineMaxAge:
+			result.Anomaly = AnomalyTooOld
+		}
+
+		entry, err := s.offlineEntry(ctx, deviceID, rec, accessTime, keys)
+		if err != nil {
+			return nil, err
+		}
+		entry.record.ClockSkewSeconds = int32(skew / time.Second)
+		entry.record.Anomaly = pgtype.Text{String: result.Anomaly, Valid: result.Anomaly != ""}
+		entry.record.ReceivedAt = pgtype.Timestamp{Time: now, Valid: true}
+		entries = append(entries, entry)
+		positions = append(positions, i)
+	}
+
+	if len(entries) > 0 {
+		saved, err := s.repo.SaveOfflineRecords(ctx, entries)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range positions {
+			if saved[j] {
+				resp.Records[i].Status = OfflineAccepted
+				resp.Accepted++
+				if e := entries[j]; e.lostKey {
+					s.lostKeyPresented(ctx, deviceID, int64(e.history.KeyID.Int32), e.history.UserID, e.history.AccessTime.Time)
+				}
+			} else {
+				resp.Records[i].Status = OfflineDuplicate
+				resp.Records[i].Anomaly = ""
+				resp.Duplicates++
+			}
+		}
+	}
+
+	log.Info().Int64("device_id", deviceID).Int("accepted", resp.Accepted).Int("duplicates", resp.Duplicates).
+		Int("rejected", resp.Rejected).Msg("[access] Выгружен офлайн-журнал панели")
+	return resp, nil
+}
+
+func (s *AccessService) offlineEntry(ctx context.Context, deviceID int64, rec OfflineRecord, accessTime time.Time, keys map[int64]*db.Key) (offlineEntry, error) {
+	entry := offlineEntry{
+		record: db.CreateOfflineAccessRecordParams{
+			DeviceID:   int32(deviceID),
+			RecordID:   rec.ID,
+			RecordedAt: pgtype.Timestamp{Time: rec.OccurredAt, Valid: true},
+			AccessTime: pgtype.Timestamp{Time: accessTime, Valid: true},
+		},
+		history: db.CreateAccessHistoryParams{
+			DeviceID:   pgtype.Int4{Int32: int32(deviceID), Valid: true},
+			AccessTime: pgtype.Timestamp{Time: accessTime, Valid: true},
+			Result:     pgtype.Text{String: ResultDenied, Valid: true},
+		},
+	}
+	description := "Офлайн-проверка панели"
+	if rec.Granted {
+		entry.history.Result.String = ResultGranted
+	} else if rec.Reason != "" {
+		description += ": " + reasonText(rec.Reason)
+	}
+
+	if rec.KeyID > 0 {
+		key, ok := keys[rec.KeyID]
+		if !ok {
+			var err error
+			if key, err = s.repo.GetKey(ctx, rec.KeyID); err != nil {
+				return entry, err
+			}
+			keys[rec.KeyID] = key
+		}
+		if key != nil {
+			entry.history.KeyID = pgtype.Int4{Int32: key.ID, Valid: true}
+			entry.history.UserID = key.OwnerID
+			entry.guestPassUse = rec.Granted && key.KeyType.String == KeyTypeGuest
+			if !key.IsActive.Bool {
+				report, err := s.repo.LostKeyReport(ctx, rec.KeyID)
+				if err != nil {
+					return entry, err
+				}
+				if report != nil && accessTime.After(report.ReportedAt.Time) {
+					entry.lostKey = true
+					description += " (ключ заявлен как потерянный)"
+				}
+			}
+		} else {
+			// Ключ успели удалить — запись всё равно сохраняем
+			description += fmt.Sprintf(" (ключ %d не найден)", rec.KeyID)
+		}
+	}
+	entry.history.Description = pgtype.Text{String: description, Valid: true}
+	return entry, nil
+}
+
+func validateOfflineRecord(rec OfflineRecord) string {
+	switch {
+	case rec.ID == "" || len(rec.ID) > maxRecordIDLength:
+		return "Некорректный id записи"
+	case rec.OccurredAt.IsZero():
+		return "Не указано время прохода"
+	case rec.KeyID < 0:
+		return "Некорректный key_id"
+	case utf8.RuneCountInString(rec.Reason) > maxReasonLength:
+		return "Слишком длинная причина отказа"
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package access
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"domofon/internal/db"
+	"domofon/internal/jwt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	KeyTypeQR = "qr"
+
+	ReasonInvalidQR  = "invalid_qr"
+	ReasonExpired    = "expired"
+	ReasonKeyRevoked = "key_revoked"
+)
+
+var ErrNoApartments = errors.New("у пользователя нет квартир")
+
+// Текущий QR-код жителя. Ключ key_type = qr создаётся при первом запросе
+// и действует, пока его не отключат; сам код живёт QR_TOKEN_TTL.
+func (s *AccessService) IssueQR(ctx context.Context, userID int64) (*QRCodeResponse, error) {
+	apartmentIDs, err := s.repo.UserApartmentIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(apartmentIDs) == 0 {
+		return nil, ErrNoApartments
+	}
+
+	key, err := s.repo.ActiveKeyByOwner(ctx, userID, KeyTypeQR)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		if key, err = s.createQRKey(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	payload, claims, err := jwt.GenerateDoorQRToken(userID, int64(key.ID), apartmentIDs, s.cfg.QRTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &QRCodeResponse{
+		Payload:   payload,
+		KeyID:     int64(key.ID),
+		ExpiresAt: claims.ExpiresAt.Time,
+		// Обновляем чуть раньше истечения, чтобы на экране не было просроченного кода
+		RefreshIn: int((s.cfg.QRTokenTTL - s.cfg.QRTokenTTL/6).Seconds()),
+	}, nil
+}
+
+func (s *AccessService) createQRKey(ctx context.Context, userID int64) (*db.Key, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	key, err := s.repo.CreateKey(ctx, db.CreateKeyParams{
+		KeyCode:     "qr:" + hex.EncodeToString(b),
+		KeyType:     pgtype.Text{String: KeyTypeQR, Valid: true},
+		OwnerID:     pgtype.Int4{Int32: int32(userID), Valid: true},
+		Description: pgtype.Text{String: "QR-код жителя", Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Int64("user_id", userID).Int32("key_id", key.ID).Msg("[access] Выпущен QR-ключ")
+	return key, nil
+}
+
+// Подписанный QR-код — это JWT (три части через точку); гостевые коды точек не содержат
+func isQRToken(code string) bool {
+	return strings.Count(code, ".") == 2
+}
+
+// Онлайн-проверка QR-кода жителя: подпись и срок, ключ не отключён,
+// у жителя по-прежнему есть квартира в доме устройства.
+func (s *AccessService) checkQR(ctx context.Context, deviceID int64, code string) (*CheckResponse, error) {
+	now := time.Now()
+	claims, err := jwt.ParseDoorQRToken(code, s.cfg.QRClockSkew)
+	if err != nil {
+		reason, text := ReasonInvalidQR, "Недействительный QR-код"
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			reason, text = ReasonExpired, "Просроченный QR-код"
+		}
+		s.logAccess(ctx, deviceID, pgtype.Int4{}, pgtype.Int4{}, ResultDenied, text, now)
+		return &CheckResponse{Reason: reason}, nil
+	}
+	userID, _ := claims.UserID()
+	keyID := pgtype.Int4{Int32: int32(claims.KeyID), Valid: true}
+	user := pgtype.Int4{Int32: int32(userID), Valid: true}
+
+	key, err := s.repo.GetKey(ctx, claims.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || !key.IsActive.Bool || key.KeyType.String != KeyTypeQR ||
+		!key.OwnerID.Valid || int64(key.OwnerID.Int32) != userID {
+		s.logAccess(ctx, deviceID, keyID, user, ResultDenied, "QR-код жителя: ключ отключён", now)
+		return &CheckResponse{Reason: ReasonKeyRevoked, KeyID: claims.KeyID}, nil
+	}
+
+	reason, err := s.scheduleReason(ctx, claims.KeyID, now)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		s.logAccess(ctx, deviceID, keyID, user, ResultDenied, "QR-код жителя: "+reasonText(reason), now)
+		return &CheckResponse{Reason: reason, KeyID: claims.KeyID}, nil
+	}
+
+	address, err := s.repo.DeviceAddress(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	if address != "" {
+		if allowed, err = s.repo.UserHasApartmentAtAddress(ctx, userID, address); err != nil {
+			return nil, err
+		}
+	}
+	if !allowed {
+		s.logAccess(ctx, deviceID, keyID, user, ResultDenied, "QR-код жителя: "+reasonText(ReasonWrongBuilding), now)
+		return &CheckResponse{Reason: ReasonWrongBuilding, KeyID: claims.KeyID}, nil
+	}
+
+	s.logAccess(ctx, deviceID, keyID, user, ResultGranted, "QR-код жителя", now)
+	return &CheckResponse{Granted: true, KeyID: claims.KeyID, UserID: userID}, nil
+}
@@ -0,0 +1,416 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Код совпал с кодом другого действующего пропуска — нужно сгенерировать новый
+var errCodeInUse = errors.New("код уже используется")
+
+type Repository interface {
+	CreateGuestPass(ctx context.Context, key db.CreateKeyParams, pass db.CreateGuestPassParams, now time.Time) (*db.GuestPass, error)
+	GetGuestPass(ctx context.Context, keyID int64) (*db.GetGuestPassRow, error)
+	ListGuestPasses(ctx context.Context, issuedBy int64, since time.Time) ([]db.ListGuestPassesByIssuerRow, error)
+	RevokeGuestPass(ctx context.Context, keyID int64, now time.Time) (bool, error)
+	FindGuestPasses(ctx context.Context, codeHash string, now time.Time) ([]db.FindGuestPassesByCodeRow, error)
+	UseGuestPass(ctx context.Context, keyID int64) (*db.UseGuestPassRow, error)
+
+	GetKey(ctx context.Context, keyID int64) (*db.Key, error)
+	ActiveKeyByOwner(ctx context.Context, ownerID int64, keyType string) (*db.Key, error)
+	CreateKey(ctx context.Context, params db.CreateKeyParams) (*db.Key, error)
+	UserApartmentIDs(ctx context.Context, userID int64) ([]int64, error)
+	UserHasApartmentAtAddress(ctx context.Context, userID int64, address string) (bool, error)
+
+	DeviceAddress(ctx context.Context, deviceID int64) (string, error)
+	ApartmentAddress(ctx context.Context, apartmentID int64) (string, error)
+	ApartmentCallees(ctx context.Context, address, number string) ([]int64, error)
+	LogAccess(ctx context.Context, params db.CreateAccessHistoryParams) error
+	KeyHistory(ctx context.Context, keyID int64, limit int) ([]db.AccessHistory, error)
+	SaveOfflineRecords(ctx context.Context, records []offlineEntry) ([]bool, error)
+
+	ListOwnerKeys(ctx context.Context, ownerID int64) ([]db.ListOwnerKeysRow, error)
+	ReportLostKey(ctx context.Context, keyID int64, event db.CreateEventParams, now time.Time) (bool, error)
+	LostKeyReport(ctx context.Context, keyID int64) (*db.LostKeyReport, error)
+	FindLostKey(ctx context.Context, code string) (*db.FindLostKeyByCodeRow, error)
+	MarkLostKeyPresented(ctx context.Context, keyID int64, event db.CreateEventParams, now time.Time) error
+	ReplaceLostKey(ctx context.Context, lostKeyID int64, key db.CreateKeyParams, replacedBy int64) (*db.Key, error)
+}
+
+type AccessRepository struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+func NewAccessRepository(pool *pgxpool.Pool) *AccessRepository {
+	return &AccessRepository{pool: pool, queries: db.New(pool)}
+}
+
+// Ключ и пропуск создаются в одной транзакции. Если код совпал с кодом
+// действующего пропуска — errCodeInUse, несуществующие устройство или расписание —
+// ErrDeviceNotFound и ErrScheduleNotFound.
+func (r *AccessRepository) CreateGuestPass(ctx context.Context, key db.CreateKeyParams, pass db.CreateGuestPassParams, now time.Time) (*db.GuestPass, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	existing, err := q.FindGuestPassesByCode(ctx, db.FindGuestPassesByCodeParams{
+		CodeHash: pass.CodeHash,
+		Now:      pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, errCodeInUse
+	}
+
+	k, err := q.CreateKey(ctx, key)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == "keys_schedule_id_fkey" {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	pass.KeyID = k.ID
+	gp, err := q.CreateGuestPass(ctx, pass)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == "guest_passes_device_id_fkey" {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &gp, nil
+}
+
+// nil, nil — пропуска нет
+func (r *AccessRepository) GetGuestPass(ctx context.Context, keyID int64) (*db.GetGuestPassRow, error) {
+	gp, err := r.queries.GetGuestPass(ctx, int32(keyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &gp, nil
+}
+
+func (r *AccessRepository) ListGuestPasses(ctx context.Context, issuedBy int64, since time.Time) ([]db.ListGuestPassesByIssuerRow, error) {
+	return r.queries.ListGuestPassesByIssuer(ctx, db.ListGuestPassesByIssuerParams{
+		IssuedBy: pgtype.Int4{Int32: int32(issuedBy), Valid: true},
+		Since:    pgtype.Timestamp{Time: since, Valid: true},
+	})
+}
+
+// Отзыв пропуска заодно выключает его ключ. false — уже отозван.
+func (r *AccessRepository) RevokeGuestPass(ctx context.Context, keyID int64, now time.Time) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	n, err := q.RevokeGuestPass(ctx, db.RevokeGuestPassParams{
+		KeyID:     int32(keyID),
+		RevokedAt: pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if err != nil || n == 0 {
+		return false, err
+	}
+	if err := q.DeactivateKey(ctx, int32(keyID)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (r *AccessRepository) FindGuestPasses(ctx context.Context, codeHash string, now time.Time) ([]db.FindGuestPassesByCodeRow, error) {
+	return r.queries.FindGuestPassesByCode(ctx, db.FindGuestPassesByCodeParams{
+		CodeHash: codeHash,
+		Now:      pgtype.Timestamp{Time: now, Valid: true},
+	})
+}
+
+// nil, nil — использования закончились или пропуск отозван
+func (r *AccessRepository) UseGuestPass(ctx context.Context, keyID int64) (*db.UseGuestPassRow, error) {
+	row, err := r.queries.UseGuestPass(ctx, int32(keyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &row, nil
+}
+
+// nil, nil — ключа нет
+func (r *AccessRepository) GetKey(ctx context.Context, keyID int64) (*db.Key, error) {
+	k, err := r.queries.GetKey(ctx, int32(keyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// nil, nil — действующего ключа этого типа у пользователя нет
+func (r *AccessRepository) ActiveKeyByOwner(ctx context.Context, ownerID int64, keyType string) (*db.Key, error) {
+	k, err := r.queries.GetActiveKeyByOwner(ctx, db.GetActiveKeyByOwnerParams{
+		OwnerID: pgtype.Int4{Int32: int32(ownerID), Valid: true},
+		KeyType: pgtype.Text{String: keyType, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *AccessRepository) CreateKey(ctx context.Context, params db.CreateKeyParams) (*db.Key, error) {
+	k, err := r.queries.CreateKey(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *AccessRepository) UserApartmentIDs(ctx context.Context, userID int64) ([]int64, error) {
+	ids, err := r.queries.GetUserApartmentIDs(ctx, pgtype.Int4{Int32: int32(userID), Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int64, len(ids))
+	for i, id := range ids {
+		result[i] = int64(id)
+	}
+	return result, nil
+}
+
+func (r *AccessRepository) UserHasApartmentAtAddress(ctx context.Context, userID int64, address string) (bool, error) {
+	return r.queries.UserHasApartmentAtAddress(ctx, db.UserHasApartmentAtAddressParams{
+		Address: address,
+		UserID:  pgtype.Int4{Int32: int32(userID), Valid: true},
+	})
+}
+
+// Пустая строка — устройство не привязано к дому
+func (r *AccessRepository) DeviceAddress(ctx context.Context, deviceID int64) (string, error) {
+	addr, err := r.queries.GetDeviceAddress(ctx, int32(deviceID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return addr, err
+}
+
+// "" — квартиры нет
+func (r *AccessRepository) ApartmentAddress(ctx context.Context, apartmentID int64) (string, error) {
+	addr, err := r.queries.GetApartmentAddress(ctx, int32(apartmentID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return addr, err
+}
+
+func (r *AccessRepository) ApartmentCallees(ctx context.Context, address, number string) ([]int64, error) {
+	ids, err := r.queries.ListApartmentCallees(ctx, db.ListApartmentCalleesParams{
+		Address: address,
+		Number:  pgtype.Text{String: number, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int64, len(ids))
+	for i, id := range ids {
+		result[i] = int64(id)
+	}
+	return result, nil
+}
+
+func (r *AccessRepository) LogAccess(ctx context.Context, params db.CreateAccessHistoryParams) error {
+	_, err := r.queries.CreateAccessHistory(ctx, params)
+	return err
+}
+
+func (r *AccessRepository) KeyHistory(ctx context.Context, keyID int64, limit int) ([]db.AccessHistory, error) {
+	return r.queries.ListAccessHistoryByKey(ctx, db.ListAccessHistoryByKeyParams{
+		KeyID: pgtype.Int4{Int32: int32(keyID), Valid: true},
+		Limit: int32(limit),
+	})
+}
+
+// Сохраняет выгрузку офлайн-журнала одной транзакцией. Для каждой записи
+// возвращает, была ли она новой (false — уже выгружалась раньше).
+func (r *AccessRepository) SaveOfflineRecords(ctx context.Context, records []offlineEntry) ([]bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	saved := make([]bool, len(records))
+	for i, rec := range records {
+		n, err := q.CreateOfflineAccessRecord(ctx, rec.record)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			continue
+		}
+		historyID, err := q.CreateAccessHistory(ctx, rec.history)
+		if err != nil {
+			return nil, err
+		}
+		err = q.SetOfflineAccessRecordHistory(ctx, db.SetOfflineAccessRecordHistoryParams{
+			DeviceID:        rec.record.DeviceID,
+			RecordID:        rec.record.RecordID,
+			AccessHistoryID: pgtype.Int4{Int32: historyID, Valid: true},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if rec.guestPassUse {
+			if err := q.RecordOfflineGuestPassUse(ctx, rec.history.KeyID.Int32); err != nil {
+				return nil, err
+			}
+		}
+		saved[i] = true
+	}
+	return saved, tx.Commit(ctx)
+}
+
+func (r *AccessRepository) ListOwnerKeys(ctx context.Context, ownerID int64) ([]db.ListOwnerKeysRow, error) {
+	return r.queries.ListOwnerKeys(ctx, pgtype.Int4{Int32: int32(ownerID), Valid: true})
+}
+
+// Записывает заявление о потере и сразу отключает ключ. false — о потере уже сообщали.
+func (r *AccessRepository) ReportLostKey(ctx context.Context, keyID int64, event db.CreateEventParams, now time.Time) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	n, err := q.CreateLostKeyReport(ctx, db.CreateLostKeyReportParams{
+		KeyID:      int32(keyID),
+		ReportedBy: event.UserID,
+		ReportedAt: pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if err != nil || n == 0 {
+		return false, err
+	}
+	if err := q.DeactivateKey(ctx, int32(keyID)); err != nil {
+		return false, err
+	}
+	if err := q.CreateEvent(ctx, event); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (r *AccessRepository) LostKeyReport(ctx context.Context, keyID int64) (*db.LostKeyReport, error) {
+	report, err := r.queries.GetLostKeyReport(ctx, int32(keyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Потерянный ключ с этим кодом; nil — код не принадлежит потерянному ключу
+func (r *AccessRepository) FindLostKey(ctx context.Context, code string) (*db.FindLostKeyByCodeRow, error) {
+	lost, err := r.queries.FindLostKeyByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &lost, nil
+}
+
+func (r *AccessRepository) MarkLostKeyPresented(ctx context.Context, keyID int64, event db.CreateEventParams, now time.Time) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	err = q.MarkLostKeyPresented(ctx, db.MarkLostKeyPresentedParams{
+		KeyID:           int32(keyID),
+		LastPresentedAt: pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if err := q.CreateEvent(ctx, event); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Выпускает новый ключ взамен потерянного. Замена выпускается только одна.
+func (r *AccessRepository) ReplaceLostKey(ctx context.Context, lostKeyID int64, key db.CreateKeyParams, replacedBy int64) (*db.Key, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	k, err := q.CreateKey(ctx, key)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "keys_key_code_key" {
+			return nil, ErrKeyCodeInUse
+		}
+		return nil, err
+	}
+	n, err := q.SetLostKeyReplacement(ctx, db.SetLostKeyReplacementParams{
+		KeyID:            int32(lostKeyID),
+		ReplacementKeyID: pgtype.Int4{Int32: k.ID, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrAlreadyReplaced
+	}
+	err = q.CreateEvent(ctx, db.CreateEventParams{
+		EventType:   EventKeyReplaced,
+		UserID:      pgtype.Int4{Int32: int32(replacedBy), Valid: true},
+		Description: pgtype.Text{String: fmt.Sprintf("key_id=%d replacement_key_id=%d", lostKeyID, k.ID), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
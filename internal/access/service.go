@@ -0,0 +1,511 @@
+package access
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"domofon/internal/config"
+	"domofon/internal/db"
+	"domofon/internal/push"
+	"domofon/internal/schedule"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	FormatNumeric = "numeric"
+	FormatQR      = "qr"
+
+	KeyTypeGuest = "guest"
+
+	ResultGranted = "granted"
+	ResultDenied  = "denied"
+
+	ReasonUnknownCode   = "unknown_code"
+	ReasonNotYetValid   = "not_yet_valid"
+	ReasonWrongDevice   = "wrong_device"
+	ReasonWrongBuilding = "wrong_building"
+	ReasonExhausted     = "exhausted"
+
+	maxGuestNameLength = 100
+	historyLimit       = 100
+	// Попыток подобрать код, не совпадающий с действующими пропусками
+	codeAttempts = 5
+)
+
+var (
+	ErrGuestPassNotFound = errors.New("пропуск не найден")
+	ErrDeviceNotFound    = errors.New("устройство не найдено")
+	ErrDeviceElsewhere   = errors.New("устройство установлено в другом доме")
+	ErrScheduleNotFound  = errors.New("расписание не найдено")
+	ErrScheduleDenied    = errors.New("пропуск можно выдать только по своему расписанию доступа")
+	ErrApartmentDenied   = errors.New("нет доступа к квартире")
+	ErrInvalidFormat     = errors.New("формат кода: numeric или qr")
+	ErrInvalidValidity   = errors.New("некорректный срок действия пропуска")
+	ErrInvalidMaxUses    = errors.New("некорректное число использований")
+	ErrGuestNameTooLong  = errors.New("имя гостя слишком длинное")
+	ErrAlreadyRevoked    = errors.New("пропуск уже отозван")
+	ErrEmptyCode         = errors.New("код не может быть пустым")
+)
+
+var qrEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Проверка ключа по расписанию доступа (реализует schedule.ScheduleService)
+type KeyScheduler interface {
+	KeyAllowed(ctx context.Context, keyID int64, at time.Time) (*schedule.Decision, error)
+	Get(ctx context.Context, id int64) (*schedule.ScheduleResponse, error)
+	ResidentScheduleIDs(ctx context.Context, userID int64) ([]int64, error)
+}
+
+// Push-уведомления владельцам ключей (реализует push.PushService)
+type Notifier interface {
+	Notify(ctx context.Context, userID int64, n push.Notification) (int, error)
+}
+
+// Пересборка офлайн-списков ключей на домофонах (реализует keylist.KeyListService)
+type PanelSync interface {
+	RebuildForUser(ctx context.Context, userID int64) error
+}
+
+type AccessService struct {
+	repo      Repository
+	schedules KeyScheduler
+	notifier  Notifier
+	panels    PanelSync
+	cfg       *config.AccessConfig
+}
+
+func NewAccessService(repo Repository, schedules KeyScheduler, notifier Notifier, panels PanelSync, cfg *config.AccessConfig) *AccessService {
+	return &AccessService{repo: repo, schedules: schedules, notifier: notifier, panels: panels, cfg: cfg}
+}
+
+// Выпуск гостевого пропуска в квартиру жителя. apartmentIDs — квартиры из токена.
+// Код возвращается только здесь.
+func (s *AccessService) CreateGuestPass(ctx context.Context, userID int64, apartmentIDs []int64, req CreateGuestPassRequest) (*CreatedGuestPassResponse, error) {
+	if !slices.Contains(apartmentIDs, req.ApartmentID) {
+		return nil, ErrApartmentDenied
+	}
+	format := req.Format
+	if format == "" {
+		format = FormatNumeric
+	}
+	if format != FormatNumeric && format != FormatQR {
+		return nil, ErrInvalidFormat
+	}
+	guestName := strings.TrimSpace(req.GuestName)
+	if utf8.RuneCountInString(guestName) > maxGuestNameLength {
+		return nil, ErrGuestNameTooLong
+	}
+
+	now := time.Now()
+	validFrom := now
+	if req.ValidFrom != nil && req.ValidFrom.After(now) {
+		validFrom = *req.ValidFrom
+	}
+	validTo := validFrom.Add(s.cfg.GuestDefaultValidity)
+	if req.ValidTo != nil {
+		validTo = *req.ValidTo
+	}
+	if !validTo.After(validFrom) || validTo.Sub(now) > s.cfg.GuestMaxValidity {
+		return nil, ErrInvalidValidity
+	}
+
+	maxUses := req.MaxUses
+	if maxUses == 0 {
+		maxUses = 1
+	}
+	if maxUses < 1 || maxUses > s.cfg.GuestMaxUses {
+		return nil, ErrInvalidMaxUses
+	}
+
+	var deviceID, scheduleID pgtype.Int4
+	if req.DeviceID != nil {
+		if err := s.checkPassDevice(ctx, *req.DeviceID, req.ApartmentID); err != nil {
+			return nil, err
+		}
+		deviceID = pgtype.Int4{Int32: int32(*req.DeviceID), Valid: true}
+	}
+	if req.ScheduleID != nil {
+		if _, err := s.schedules.Get(ctx, *req.ScheduleID); err != nil {
+			if errors.Is(err, schedule.ErrScheduleNotFound) {
+				return nil, ErrScheduleNotFound
+			}
+			return nil, err
+		}
+		// Житель с ограниченным доступом не может пустить гостя шире, чем ходит сам.
+		// Без расписания пропуск и так проверяется по расписаниям выдавшего.
+		own, err := s.schedules.ResidentScheduleIDs(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(own) > 0 && !slices.Contains(own, *req.ScheduleID) {
+			return nil, ErrScheduleDenied
+		}
+		scheduleID = pgtype.Int4{Int32: int32(*req.ScheduleID), Valid: true}
+	}
+	description := "Гостевой пропуск"
+	if guestName != "" {
+		description += ": " + guestName
+	}
+
+	for range codeAttempts {
+		code, err := s.generateCode(format)
+		if err != nil {
+			return nil, err
+		}
+		keyCode, err := randomKeyCode()
+		if err != nil {
+			return nil, err
+		}
+		gp, err := s.repo.CreateGuestPass(ctx, db.CreateKeyParams{
+			KeyCode:     keyCode,
+			KeyType:     pgtype.Text{String: KeyTypeGuest, Valid: true},
+			OwnerID:     pgtype.Int4{Int32: int32(userID), Valid: true},
+			ValidFrom:   pgtype.Timestamp{Time: validFrom, Valid: true},
+			ValidTo:     pgtype.Timestamp{Time: validTo, Valid: true},
+			Description: pgtype.Text{String: description, Valid: true},
+			ScheduleID:  scheduleID,
+		}, db.CreateGuestPassParams{
+			ApartmentID: int32(req.ApartmentID),
+			IssuedBy:    pgtype.Int4{Int32: int32(userID), Valid: true},
+			GuestName:   pgtype.Text{String: guestName, Valid: guestName != ""},
+			CodeFormat:  format,
+			CodeHash:    hashCode(code),
+			MaxUses:     int32(maxUses),
+			DeviceID:    deviceID,
+		}, now)
+		if errors.Is(err, errCodeInUse) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		log.Info().Int64("user_id", userID).Int32("key_id", gp.KeyID).Int64("apartment_id", req.ApartmentID).
+			Str("format", format).Msg("[access] Выпущен гостевой пропуск")
+		resp := guestPassResponse(guestPassView{
+			KeyID:       gp.KeyID,
+			ApartmentID: gp.ApartmentID,
+			GuestName:   gp.GuestName,
+			CodeFormat:  gp.CodeFormat,
+			MaxUses:     gp.MaxUses,
+			UseCount:    gp.UseCount,
+			DeviceID:    gp.DeviceID,
+			IsActive:    pgtype.Bool{Bool: true, Valid: true},
+			IssuedAt:    pgtype.Timestamp{Time: now, Valid: true},
+			ValidFrom:   pgtype.Timestamp{Time: validFrom, Valid: true},
+			ValidTo:     pgtype.Timestamp{Time: validTo, Valid: true},
+			ScheduleID:  scheduleID,
+		}, now)
+		return &CreatedGuestPassResponse{GuestPassResponse: resp, Code: code}, nil
+	}
+	return nil, fmt.Errorf("не удалось подобрать свободный гостевой код за %d попыток", codeAttempts)
+}
+
+// Пропуск можно привязать только к домофону того дома, где квартира
+func (s *AccessService) checkPassDevice(ctx context.Context, deviceID, apartmentID int64) error {
+	deviceAddress, err := s.repo.DeviceAddress(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if deviceAddress == "" {
+		return ErrDeviceNotFound
+	}
+	apartmentAddress, err := s.repo.ApartmentAddress(ctx, apartmentID)
+	if err != nil {
+		return err
+	}
+	if deviceAddress != apartmentAddress {
+		return ErrDeviceElsewhere
+	}
+	return nil
+}
+
+// Пропуска, выпущенные жителем: действующие и истёкшие за GUEST_PASS_HISTORY_WINDOW
+func (s *AccessService) ListGuestPasses(ctx context.Context, userID int64) ([]GuestPassResponse, error) {
+	now := time.Now()
+	rows, err := s.repo.ListGuestPasses(ctx, userID, now.Add(-s.cfg.GuestHistoryWindow))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]GuestPassResponse, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, guestPassResponse(guestPassView(row), now))
+	}
+	return result, nil
+}
+
+// Отзыв пропуска. Чужие пропуска для жителя не существуют.
+func (s *AccessService) RevokeGuestPass(ctx context.Context, userID, keyID int64) error {
+	if _, err := s.ownGuestPass(ctx, userID, keyID); err != nil {
+		return err
+	}
+	ok, err := s.repo.RevokeGuestPass(ctx, keyID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAlreadyRevoked
+	}
+	log.Info().Int64("user_id", userID).Int64("key_id", keyID).Msg("[access] Гостевой пропуск отозван")
+	return nil
+}
+
+// Проходы и отказы по пропуску, от новых к старым
+func (s *AccessService) History(ctx context.Context, userID, keyID int64) ([]AccessEventResponse, error) {
+	if _, err := s.ownGuestPass(ctx, userID, keyID); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.KeyHistory(ctx, keyID, historyLimit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]AccessEventResponse, 0, len(rows))
+	for _, h := range rows {
+		ev := AccessEventResponse{
+			ID:          int64(h.ID),
+			Time:        h.AccessTime.Time,
+			Result:      h.Result.String,
+			Description: h.Description.String,
+		}
+		if h.DeviceID.Valid {
+			id := int64(h.DeviceID.Int32)
+			ev.DeviceID = &id
+		}
+		result = append(result, ev)
+	}
+	return result, nil
+}
+
+// Проверка кода, считанного домофоном. Каждая попытка пишется в access_history;
+// при успехе списывается одно использование.
+func (s *AccessService) Check(ctx context.Context, deviceID int64, code string) (*CheckResponse, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, ErrEmptyCode
+	}
+	if isQRToken(code) {
+		return s.checkQR(ctx, deviceID, code)
+	}
+	now := time.Now()
+
+	candidates, err := s.repo.FindGuestPasses(ctx, hashCode(code), now)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		lost, err := s.repo.FindLostKey(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		if lost != nil {
+			keyID := pgtype.Int4{Int32: lost.KeyID, Valid: true}
+			s.logAccess(ctx, deviceID, keyID, lost.OwnerID, ResultDenied, "Ключ заявлен как потерянный", now)
+			s.lostKeyPresented(ctx, deviceID, int64(lost.KeyID), lost.OwnerID, now)
+			return &CheckResponse{Reason: ReasonKeyLost, KeyID: int64(lost.KeyID)}, nil
+		}
+		s.logAccess(ctx, deviceID, pgtype.Int4{}, pgtype.Int4{}, ResultDenied, "Неизвестный код", now)
+		return &CheckResponse{Reason: ReasonUnknownCode}, nil
+	}
+	address, err := s.repo.DeviceAddress(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var denied *db.FindGuestPassesByCodeRow
+	reason := ""
+	for i := range candidates {
+		c := &candidates[i]
+		r := checkReason(c, deviceID, address, now)
+		if r == "" {
+			if r, err = s.scheduleReason(ctx, int64(c.KeyID), now); err != nil {
+				return nil, err
+			}
+		}
+		if r == "" {
+			used, err := s.repo.UseGuestPass(ctx, int64(c.KeyID))
+			if err != nil {
+				return nil, err
+			}
+			if used == nil {
+				r = ReasonExhausted
+			} else {
+				s.logAccess(ctx, deviceID, pgtype.Int4{Int32: c.KeyID, Valid: true}, c.IssuedBy, ResultGranted,
+					fmt.Sprintf("%s (использование %d из %d)", passLabel(c), used.UseCount, used.MaxUses), now)
+				return &CheckResponse{
+					Granted:       true,
+					KeyID:         int64(c.KeyID),
+					ApartmentID:   int64(c.ApartmentID),
+					RemainingUses: int(used.MaxUses - used.UseCount),
+				}, nil
+			}
+		}
+		if denied == nil {
+			denied, reason = c, r
+		}
+	}
+
+	s.logAccess(ctx, deviceID, pgtype.Int4{Int32: denied.KeyID, Valid: true}, denied.IssuedBy, ResultDenied, fmt.Sprintf("%s: %s", passLabel(denied), reasonText(reason)), now)
+	return &CheckResponse{Reason: reason, KeyID: int64(denied.KeyID), ApartmentID: int64(denied.ApartmentID)}, nil
+}
+
+// Почему пропуск не подходит для этого устройства; пустая строка — подходит
+func checkReason(c *db.FindGuestPassesByCodeRow, deviceID int64, address string, now time.Time) string {
+	switch {
+	case c.ValidFrom.Valid && c.ValidFrom.Time.After(now):
+		return ReasonNotYetValid
+	case c.DeviceID.Valid && int64(c.DeviceID.Int32) != deviceID:
+		return ReasonWrongDevice
+	case address != c.Address:
+		return ReasonWrongBuilding
+	}
+	return ""
+}
+
+// Причина отказа по расписанию доступа; пустая строка — пускать
+func (s *AccessService) scheduleReason(ctx context.Context, keyID int64, now time.Time) (string, error) {
+	d, err := s.schedules.KeyAllowed(ctx, keyID, now)
+	if err != nil || d.Allowed {
+		return "", err
+	}
+	return d.Reason, nil
+}
+
+func reasonText(reason string) string {
+	switch reason {
+	case schedule.ReasonHoliday:
+		return "праздничный день по расписанию"
+	case schedule.ReasonOutsideSchedule:
+		return "вне расписания доступа"
+	case ReasonNotYetValid:
+		return "срок действия ещё не начался"
+	case ReasonWrongDevice:
+		return "пропуск выдан для другого домофона"
+	case ReasonWrongBuilding:
+		return "пропуск выдан в другой дом"
+	case ReasonExhausted:
+		return "использования закончились"
+	case ReasonKeyLost:
+		return "ключ заявлен как потерянный"
+	}
+	return reason
+}
+
+func passLabel(c *db.FindGuestPassesByCodeRow) string {
+	if c.GuestName.Valid {
+		return "Гостевой пропуск: " + c.GuestName.String
+	}
+	return "Гостевой пропуск"
+}
+
+// Ошибка записи в журнал не должна мешать открыть дверь
+func (s *AccessService) logAccess(ctx context.Context, deviceID int64, keyID, userID pgtype.Int4, result, description string, now time.Time) {
+	err := s.repo.LogAccess(ctx, db.CreateAccessHistoryParams{
+		KeyID:       keyID,
+		DeviceID:    pgtype.Int4{Int32: int32(deviceID), Valid: true},
+		UserID:      userID,
+		AccessTime:  pgtype.Timestamp{Time: now, Valid: true},
+		Result:      pgtype.Text{String: result, Valid: true},
+		Description: pgtype.Text{String: description, Valid: true},
+	})
+	if err != nil {
+		log.Error().Err(err).Int64("device_id", deviceID).Msg("[access] Не удалось записать проход в журнал")
+	}
+}
+
+func (s *AccessService) ownGuestPass(ctx context.Context, userID, keyID int64) (*db.GetGuestPassRow, error) {
+	gp, err := s.repo.GetGuestPass(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if gp == nil || !gp.IssuedBy.Valid || int64(gp.IssuedBy.Int32) != userID {
+		return nil, ErrGuestPassNotFound
+	}
+	return gp, nil
+}
+
+func (s *AccessService) generateCode(format string) (string, error) {
+	if format == FormatQR {
+		b := make([]byte, 20)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		return qrEncoding.EncodeToString(b), nil
+	}
+	var sb strings.Builder
+	for range s.cfg.GuestCodeLength {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(byte('0' + n.Int64()))
+	}
+	return sb.String(), nil
+}
+
+// key_code гостевого ключа — случайный идентификатор, не сам код
+func randomKeyCode() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "guest:" + hex.EncodeToString(b), nil
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Общий вид строк GetGuestPass и ListGuestPassesByIssuer
+type guestPassView db.ListGuestPassesByIssuerRow
+
+func guestPassResponse(gp guestPassView, now time.Time) GuestPassResponse {
+	resp := GuestPassResponse{
+		ID:          int64(gp.KeyID),
+		ApartmentID: int64(gp.ApartmentID),
+		GuestName:   gp.GuestName.String,
+		Format:      gp.CodeFormat,
+		ValidFrom:   gp.ValidFrom.Time,
+		ValidTo:     gp.ValidTo.Time,
+		MaxUses:     int(gp.MaxUses),
+		UseCount:    int(gp.UseCount),
+		IssuedAt:    gp.IssuedAt.Time,
+	}
+	if gp.DeviceID.Valid {
+		id := int64(gp.DeviceID.Int32)
+		resp.DeviceID = &id
+	}
+	if gp.ScheduleID.Valid {
+		id := int64(gp.ScheduleID.Int32)
+		resp.ScheduleID = &id
+	}
+	if gp.RevokedAt.Valid {
+		t := gp.RevokedAt.Time
+		resp.RevokedAt = &t
+	}
+	switch {
+	case gp.RevokedAt.Valid || !gp.IsActive.Bool:
+		resp.Status = "revoked"
+	case gp.UseCount >= gp.MaxUses:
+		resp.Status = "used"
+	case gp.ValidTo.Valid && !gp.ValidTo.Time.After(now):
+		resp.Status = "expired"
+	case gp.ValidFrom.Valid && gp.ValidFrom.Time.After(now):
+		resp.Status = "scheduled"
+	default:
+		resp.Status = "active"
+	}
+	return resp
+}
@@ -0,0 +1,68 @@
+package announcement
+
+import "time"
+
+type CreateAnnouncementRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// address — весь дом, entrance — подъезд дома, apartments — список квартир
+	TargetType   string  `json:"target_type"`
+	Address      string  `json:"address,omitempty"`
+	Entrance     string  `json:"entrance,omitempty"`
+	ApartmentIDs []int64 `json:"apartment_ids,omitempty"`
+	// Не задано — публикуется сразу
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Не задано — объявление действует, пока его не отменят
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type AttachmentResponse struct {
+	ID          int64  `json:"id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
+
+// Объявление в ленте жителя
+type AnnouncementResponse struct {
+	ID          int64                `json:"id"`
+	Title       string               `json:"title"`
+	Body        string               `json:"body"`
+	PublishAt   time.Time            `json:"publish_at"`
+	ExpiresAt   *time.Time           `json:"expires_at,omitempty"`
+	Attachments []AttachmentResponse `json:"attachments"`
+	IsRead      bool                 `json:"is_read"`
+	ReadAt      *time.Time           `json:"read_at,omitempty"`
+}
+
+type TargetResponse struct {
+	Type         string  `json:"type"`
+	Address      string  `json:"address,omitempty"`
+	Entrance     string  `json:"entrance,omitempty"`
+	ApartmentIDs []int64 `json:"apartment_ids,omitempty"`
+}
+
+// Объявление в списке управляющей компании
+type ManagedAnnouncementResponse struct {
+	ID          int64          `json:"id"`
+	AuthorID    int64          `json:"author_id,omitempty"`
+	Title       string         `json:"title"`
+	Body        string         `json:"body"`
+	Target      TargetResponse `json:"target"`
+	PublishAt   time.Time      `json:"publish_at"`
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty"`
+	CancelledAt *time.Time     `json:"cancelled_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	// Сколько жителей прочитали объявление
+	ReadCount   int64                `json:"read_count"`
+	Attachments []AttachmentResponse `json:"attachments"`
+}
+
+type ReadResponse struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"first_name,omitempty"`
+	LastName  string    `json:"last_name,omitempty"`
+	ReadAt    time.Time `json:"read_at"`
+}
@@ -0,0 +1,292 @@
+package announcement
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"domofon/internal/middleware"
+)
+
+type AnnouncementHandler struct {
+	announcements *AnnouncementService
+}
+
+func NewAnnouncementHandler(s *AnnouncementService) *AnnouncementHandler {
+	return &AnnouncementHandler{announcements: s}
+}
+
+// ListActive godoc
+// @Summary Объявления для моих квартир
+// @Description Действующие объявления управляющей компании, адресованные дому, подъезду или квартирам пользователя. Новые сверху.
+// @Tags announcements
+// @Produce json
+// @Success 200 {array} AnnouncementResponse
+// @Security BearerAuth
+// @Router /announcements [get]
+func (h *AnnouncementHandler) ListActive(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	items, err := h.announcements.ListActive(r.Context(), userID)
+	if err != nil {
+		writeAnnouncementError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// MarkRead godoc
+// @Summary Отметить объявление прочитанным
+// @Tags announcements
+// @Param id path int true "ID объявления"
+// @Success 204 {string} string "Отмечено"
+// @Failure 404 {string} string "Объявление не найдено"
+// @Security BearerAuth
+// @Router /announcements/{id}/read [post]
+func (h *AnnouncementHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.announcements.MarkRead(r.Context(), userID, id); err != nil {
+		writeAnnouncementError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Create godoc
+// @Summary Опубликовать объявление
+// @Description Для ролей из ANNOUNCEMENT_PUBLISHER_ROLES. Адресаты: target_type=address (address), entrance (address и entrance) или apartments (apartment_ids). publish_at в будущем — отложенная публикация.
+// @Tags announcements
+// @Accept json
+// @Produce json
+// @Param input body CreateAnnouncementRequest true "Объявление"
+// @Success 201 {object} ManagedAnnouncementResponse
+// @Failure 400 {string} string "Некорректные данные"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Security BearerAuth
+// @Router /announcements [post]
+func (h *AnnouncementHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req CreateAnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	a, err := h.announcements.Create(r.Context(), userID, role, req)
+	if err != nil {
+		writeAnnouncementError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, a)
+}
+
+// List godoc
+// @Summary Все объявления
+// @Description Для управляющей компании: включая запланированные, истёкшие и отменённые, с числом прочтений.
+// @Tags announcements
+// @Produce json
+// @Param limit query int false "Размер страницы"
+// @Param offset query int false "Смещение"
+// @Success 200 {array} ManagedAnnouncementResponse
+// @Failure 403 {string} string "Недостаточно прав"
+// @Security BearerAuth
+// @Router /announcements/manage [get]
+func (h *AnnouncementHandler) List(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	items, err := h.announcements.List(r.Context(), role, limit, offset)
+	if err != nil {
+		writeAnnouncementError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// Cancel godoc
+// @Summary Отменить объявление
+// @Description Объявление пропадает из лент жителей, отметки о прочтении сохраняются.
+// @Tags announcements
+// @Param id path int true "ID объявления"
+// @Success 204 {string} string "Отменено"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Объявление не найдено"
+// @Failure 409 {string} string "Объявление уже отменено"
+// @Security BearerAuth
+// @Router /announcements/{id} [delete]
+func (h *AnnouncementHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.announcements.Cancel(r.Context(), userID, role, id); err != nil {
+		writeAnnouncementError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Reads godoc
+// @Summary Кто прочитал объявление
+// @Tags announcements
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Success 200 {array} ReadResponse
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Объявление не найдено"
+// @Security BearerAuth
+// @Router /announcements/{id}/reads [get]
+func (h *AnnouncementHandler) Reads(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	reads, err := h.announcements.Reads(r.Context(), role, id)
+	if err != nil {
+		writeAnnouncementError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reads)
+}
+
+// UploadAttachment godoc
+// @Summary Прикрепить файл к объявлению
+// @Description Файл передаётся в поле 'file' формы. Размер ограничен ANNOUNCEMENT_MAX_ATTACHMENT_SIZE, тип определяется по содержимому и должен входить в ANNOUNCEMENT_ATTACHMENT_TYPES.
+// @Tags announcements
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Param file formData file true "Файл"
+// @Success 201 {object} AttachmentResponse
+// @Failure 400 {string} string "Некорректные данные или недопустимый тип файла"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Объявление не найдено"
+// @Failure 413 {string} string "Файл слишком большой"
+// @Security BearerAuth
+// @Router /announcements/{id}/attachments [post]
+func (h *AnnouncementHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	// Запас на служебные части multipart сверх самого файла
+	r.Body = http.MaxBytesReader(w, r.Body, h.announcements.MaxUploadSize()+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, ErrAttachmentTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Не удалось прочитать файл", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	att, err := h.announcements.AddAttachment(r.Context(), role, id, header.Filename, file)
+	if err != nil {
+		writeAnnouncementError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, att)
+}
+
+// DownloadAttachment godoc
+// @Summary Скачать вложение
+// @Description Доступно адресатам действующего объявления и управляющей компании.
+// @Tags announcements
+// @Produce octet-stream
+// @Param id path int true "ID объявления"
+// @Param attachment_id path int true "ID вложения"
+// @Success 200 {file} file "Файл"
+// @Failure 404 {string} string "Вложение не найдено"
+// @Security BearerAuth
+// @Router /announcements/{id}/attachments/{attachment_id} [get]
+func (h *AnnouncementHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	attachmentID, ok := middleware.PathID(w, r, "attachment_id")
+	if !ok {
+		return
+	}
+	att, f, err := h.announcements.OpenAttachment(r.Context(), userID, role, id, attachmentID)
+	if err != nil {
+		writeAnnouncementError(w, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", att.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": att.FileName}))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, "", att.CreatedAt.Time, f)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAnnouncementError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrAttachmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrAlreadyCancelled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrAttachmentTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, ErrEmptyTitle), errors.Is(err, ErrTitleTooLong), errors.Is(err, ErrEmptyBody),
+		errors.Is(err, ErrInvalidTarget), errors.Is(err, ErrInvalidSchedule), errors.Is(err, ErrApartmentNotFound),
+		errors.Is(err, ErrAttachmentType):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,88 @@
+package announcement
+
+import (
+	"context"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"domofon/internal/db"
+	"domofon/internal/push"
+
+	"github.com/rs/zerolog/log"
+)
+
+// NotifyPublished рассылает push announcement по наступившим объявлениям.
+// Объявление помечается разосланным до отправки, поэтому несколько экземпляров
+// сервиса не дублируют рассылку; если процесс упал посередине, часть адресатов
+// уведомления не получит, но объявление останется в их ленте.
+// Возвращает число разосланных объявлений.
+func (s *AnnouncementService) NotifyPublished(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		now := time.Now()
+		batch, err := s.repo.ClaimToNotify(ctx, now, notifyBatchSize)
+		if err != nil {
+			return total, err
+		}
+		for i := range batch {
+			a := &batch[i]
+			// Отменено или истекло до наступления публикации — рассылать нечего
+			if a.CancelledAt.Valid || (a.ExpiresAt.Valid && !a.ExpiresAt.Time.After(now)) {
+				continue
+			}
+			s.notifyRecipients(ctx, a)
+			total++
+		}
+		if len(batch) < notifyBatchSize || ctx.Err() != nil {
+			return total, nil
+		}
+	}
+}
+
+func (s *AnnouncementService) notifyRecipients(ctx context.Context, a *db.Announcement) {
+	if s.notifier == nil {
+		return
+	}
+	recipients, err := s.repo.Recipients(ctx, a.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("announcement_id", a.ID).Msg("[announcement] Не удалось получить адресатов объявления")
+		return
+	}
+	preview := a.Body
+	if utf8.RuneCountInString(preview) > pushPreviewLength {
+		preview = string([]rune(preview)[:pushPreviewLength]) + "…"
+	}
+	n := push.Notification{
+		Event: push.EventAnnouncement,
+		Title: a.Title,
+		Body:  preview,
+		Data:  map[string]string{"announcement_id": strconv.FormatInt(a.ID, 10)},
+	}
+	for _, userID := range recipients {
+		if _, err := s.notifier.Notify(ctx, userID, n); err != nil {
+			log.Error().Err(err).Int64("announcement_id", a.ID).Int64("user_id", userID).Msg("[announcement] Не удалось отправить уведомление")
+		}
+	}
+	log.Info().Int64("announcement_id", a.ID).Int("recipients", len(recipients)).Msg("[announcement] Разосланы уведомления об объявлении")
+}
+
+// Плановая рассылка по объявлениям, у которых наступило время публикации.
+// Блокирует до отмены ctx, запускать в горутине.
+func (s *AnnouncementService) RunNotifier(ctx context.Context) {
+	if s.cfg.NotifyInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.NotifyInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.NotifyPublished(ctx); err != nil {
+			log.Error().Err(err).Msg("[announcement] Не удалось разослать уведомления об объявлениях")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
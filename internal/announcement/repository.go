@@ -0,0 +1,182 @@
+package announcement
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	Create(ctx context.Context, params db.CreateAnnouncementParams, apartmentIDs []int64) (*db.Announcement, error)
+	Get(ctx context.Context, id int64) (*db.Announcement, error)
+	ApartmentIDs(ctx context.Context, id int64) ([]int32, error)
+	Cancel(ctx context.Context, id int64, now time.Time) (bool, error)
+	List(ctx context.Context, limit, offset int) ([]db.ListAnnouncementsRow, error)
+
+	ListActiveForUser(ctx context.Context, userID int64, now time.Time) ([]db.ListActiveAnnouncementsForUserRow, error)
+	IsVisibleToUser(ctx context.Context, id, userID int64, now time.Time) (bool, error)
+	MarkRead(ctx context.Context, id, userID int64, now time.Time) error
+	ListReads(ctx context.Context, id int64) ([]db.ListAnnouncementReadsRow, error)
+
+	CreateAttachment(ctx context.Context, params db.CreateAnnouncementAttachmentParams) (*db.AnnouncementAttachment, error)
+	GetAttachment(ctx context.Context, id int64) (*db.AnnouncementAttachment, error)
+	ListAttachments(ctx context.Context, announcementIDs []int64) ([]db.AnnouncementAttachment, error)
+
+	ClaimToNotify(ctx context.Context, now time.Time, limit int) ([]db.Announcement, error)
+	Recipients(ctx context.Context, id int64) ([]int64, error)
+}
+
+type AnnouncementRepository struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+func NewAnnouncementRepository(pool *pgxpool.Pool) *AnnouncementRepository {
+	return &AnnouncementRepository{pool: pool, queries: db.New(pool)}
+}
+
+// Объявление и список квартир-адресатов сохраняются в одной транзакции.
+// Несуществующая квартира — ErrApartmentNotFound.
+func (r *AnnouncementRepository) Create(ctx context.Context, params db.CreateAnnouncementParams, apartmentIDs []int64) (*db.Announcement, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	a, err := q.CreateAnnouncement(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range apartmentIDs {
+		err := q.AddAnnouncementApartment(ctx, db.AddAnnouncementApartmentParams{
+			AnnouncementID: a.ID,
+			ApartmentID:    int32(id),
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				return nil, ErrApartmentNotFound
+			}
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// nil, nil — объявления нет
+func (r *AnnouncementRepository) Get(ctx context.Context, id int64) (*db.Announcement, error) {
+	a, err := r.queries.GetAnnouncement(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AnnouncementRepository) ApartmentIDs(ctx context.Context, id int64) ([]int32, error) {
+	return r.queries.ListAnnouncementApartmentIDs(ctx, id)
+}
+
+// false — объявление не найдено или уже отменено
+func (r *AnnouncementRepository) Cancel(ctx context.Context, id int64, now time.Time) (bool, error) {
+	n, err := r.queries.CancelAnnouncement(ctx, db.CancelAnnouncementParams{
+		ID:          id,
+		CancelledAt: pgtype.Timestamp{Time: now, Valid: true},
+	})
+	return n > 0, err
+}
+
+func (r *AnnouncementRepository) List(ctx context.Context, limit, offset int) ([]db.ListAnnouncementsRow, error) {
+	return r.queries.ListAnnouncements(ctx, db.ListAnnouncementsParams{
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+}
+
+func (r *AnnouncementRepository) ListActiveForUser(ctx context.Context, userID int64, now time.Time) ([]db.ListActiveAnnouncementsForUserRow, error) {
+	return r.queries.ListActiveAnnouncementsForUser(ctx, db.ListActiveAnnouncementsForUserParams{
+		UserID: pgtype.Int4{Int32: int32(userID), Valid: true},
+		Now:    pgtype.Timestamp{Time: now, Valid: true},
+	})
+}
+
+func (r *AnnouncementRepository) IsVisibleToUser(ctx context.Context, id, userID int64, now time.Time) (bool, error) {
+	return r.queries.IsAnnouncementVisibleToUser(ctx, db.IsAnnouncementVisibleToUserParams{
+		UserID:         pgtype.Int4{Int32: int32(userID), Valid: true},
+		AnnouncementID: id,
+		Now:            pgtype.Timestamp{Time: now, Valid: true},
+	})
+}
+
+// Повторная отметка не меняет время первого прочтения
+func (r *AnnouncementRepository) MarkRead(ctx context.Context, id, userID int64, now time.Time) error {
+	return r.queries.MarkAnnouncementRead(ctx, db.MarkAnnouncementReadParams{
+		AnnouncementID: id,
+		UserID:         int32(userID),
+		ReadAt:         pgtype.Timestamp{Time: now, Valid: true},
+	})
+}
+
+func (r *AnnouncementRepository) ListReads(ctx context.Context, id int64) ([]db.ListAnnouncementReadsRow, error) {
+	return r.queries.ListAnnouncementReads(ctx, id)
+}
+
+func (r *AnnouncementRepository) CreateAttachment(ctx context.Context, params db.CreateAnnouncementAttachmentParams) (*db.AnnouncementAttachment, error) {
+	a, err := r.queries.CreateAnnouncementAttachment(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// nil, nil — вложения нет
+func (r *AnnouncementRepository) GetAttachment(ctx context.Context, id int64) (*db.AnnouncementAttachment, error) {
+	a, err := r.queries.GetAnnouncementAttachment(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AnnouncementRepository) ListAttachments(ctx context.Context, announcementIDs []int64) ([]db.AnnouncementAttachment, error) {
+	if len(announcementIDs) == 0 {
+		return nil, nil
+	}
+	return r.queries.ListAnnouncementAttachments(ctx, announcementIDs)
+}
+
+func (r *AnnouncementRepository) ClaimToNotify(ctx context.Context, now time.Time, limit int) ([]db.Announcement, error) {
+	return r.queries.ClaimAnnouncementsToNotify(ctx, db.ClaimAnnouncementsToNotifyParams{
+		Now:       pgtype.Timestamp{Time: now, Valid: true},
+		BatchSize: int32(limit),
+	})
+}
+
+func (r *AnnouncementRepository) Recipients(ctx context.Context, id int64) ([]int64, error) {
+	ids, err := r.queries.ListAnnouncementRecipients(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int64, len(ids))
+	for i, id := range ids {
+		result[i] = int64(id)
+	}
+	return result, nil
+}
@@ -0,0 +1,483 @@
+package announcement
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"domofon/internal/config"
+	"domofon/internal/db"
+	"domofon/internal/push"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	TargetAddress    = "address"
+	TargetEntrance   = "entrance"
+	TargetApartments = "apartments"
+
+	maxTitleLength = 255
+
+	// Сколько символов текста показывать в push-уведомлении
+	pushPreviewLength = 200
+	// Сколько наступивших объявлений рассылать за один запрос к базе
+	notifyBatchSize = 50
+)
+
+var (
+	ErrForbidden          = errors.New("недостаточно прав")
+	ErrNotFound           = errors.New("объявление не найдено")
+	ErrAttachmentNotFound = errors.New("вложение не найдено")
+	ErrApartmentNotFound  = errors.New("квартира не найдена")
+	ErrEmptyTitle         = errors.New("заголовок не может быть пустым")
+	ErrTitleTooLong       = errors.New("заголовок слишком длинный")
+	ErrEmptyBody          = errors.New("текст объявления не может быть пустым")
+	ErrInvalidTarget      = errors.New("некорректные адресаты объявления")
+	ErrInvalidSchedule    = errors.New("срок действия должен заканчиваться позже публикации")
+	ErrAlreadyCancelled   = errors.New("объявление уже отменено")
+	ErrAttachmentTooLarge = errors.New("файл слишком большой")
+	ErrAttachmentType     = errors.New("недопустимый тип файла")
+)
+
+// Push-уведомления адресатам (реализует push.PushService)
+type Notifier interface {
+	Notify(ctx context.Context, userID int64, n push.Notification) (int, error)
+}
+
+type AnnouncementService struct {
+	repo     Repository
+	notifier Notifier
+	cfg      *config.AnnouncementConfig
+}
+
+func NewAnnouncementService(repo Repository, notifier Notifier, cfg *config.AnnouncementConfig) *AnnouncementService {
+	return &AnnouncementService{repo: repo, notifier: notifier, cfg: cfg}
+}
+
+func (s *AnnouncementService) canPublish(role string) bool {
+	return slices.Contains(s.cfg.PublisherRoles, role)
+}
+
+// Публикация объявления. Время публикации может быть в будущем:
+// до него объявление видно только в списке управляющей компании.
+func (s *AnnouncementService) Create(ctx context.Context, userID int64, role string, req CreateAnnouncementRequest) (*ManagedAnnouncementResponse, error) {
+	if !s.canPublish(role) {
+		return nil, ErrForbidden
+	}
+
+	title := strings.TrimSpace(req.Title)
+	body := strings.TrimSpace(req.Body)
+	switch {
+	case title == "":
+		return nil, ErrEmptyTitle
+	case utf8.RuneCountInString(title) > maxTitleLength:
+		return nil, ErrTitleTooLong
+	case body == "":
+		return nil, ErrEmptyBody
+	}
+
+	address := strings.TrimSpace(req.Address)
+	entrance := strings.TrimSpace(req.Entrance)
+	var apartmentIDs []int64
+	switch req.TargetType {
+	case TargetAddress:
+		if address == "" {
+			return nil, ErrInvalidTarget
+		}
+		entrance = ""
+	case TargetEntrance:
+		if address == "" || entrance == "" {
+			return nil, ErrInvalidTarget
+		}
+	case TargetApartments:
+		for _, id := range req.ApartmentIDs {
+			if id <= 0 {
+				return nil, ErrInvalidTarget
+			}
+			if !slices.Contains(apartmentIDs, id) {
+				apartmentIDs = append(apartmentIDs, id)
+			}
+		}
+		if len(apartmentIDs) == 0 {
+			return nil, ErrInvalidTarget
+		}
+		address, entrance = "", ""
+	default:
+		return nil, ErrInvalidTarget
+	}
+
+	publishAt := time.Now()
+	if req.PublishAt != nil {
+		publishAt = *req.PublishAt
+	}
+	var expiresAt pgtype.Timestamp
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(publishAt) {
+			return nil, ErrInvalidSchedule
+		}
+		expiresAt = pgtype.Timestamp{Time: *req.ExpiresAt, Valid: true}
+	}
+
+	a, err := s.repo.Create(ctx, db.CreateAnnouncementParams{
+		AuthorID:       pgtype.Int4{Int32: int32(userID), Valid: true},
+		Title:          title,
+		Body:           body,
+		TargetType:     req.TargetType,
+		TargetAddress:  pgtype.Text{String: address, Valid: address != ""},
+		TargetEntrance: pgtype.Text{String: entrance, Valid: entrance != ""},
+		PublishAt:      pgtype.Timestamp{Time: publishAt, Valid: true},
+		ExpiresAt:      expiresAt,
+	}, apartmentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Int64("announcement_id", a.ID).
+		Int64("user_id", userID).
+		Str("target_type", a.TargetType).
+		Time("publish_at", publishAt).
+		Msg("[announcement] Объявление создано")
+	// Объявление уже видно — рассылаем сразу, не дожидаясь планового прохода
+	if !publishAt.After(time.Now()) {
+		go func() {
+			if _, err := s.NotifyPublished(context.WithoutCancel(ctx)); err != nil {
+				log.Error().Err(err).Msg("[announcement] Не удалось разослать уведомления об объявлениях")
+			}
+		}()
+	}
+
+	resp := managedResponse(db.ListAnnouncementsRow{
+		ID:             a.ID,
+		AuthorID:       a.AuthorID,
+		Title:          a.Title,
+		Body:           a.Body,
+		TargetType:     a.TargetType,
+		TargetAddress:  a.TargetAddress,
+		TargetEntrance: a.TargetEntrance,
+		PublishAt:      a.PublishAt,
+		ExpiresAt:      a.ExpiresAt,
+		CancelledAt:    a.CancelledAt,
+		CreatedAt:      a.CreatedAt,
+	})
+	resp.Target.ApartmentIDs = apartmentIDs
+	return &resp, nil
+}
+
+// Отмена снимает объявление из лент жителей; история прочтений сохраняется
+func (s *AnnouncementService) Cancel(ctx context.Context, userID int64, role string, id int64) error {
+	if !s.canPublish(role) {
+		return ErrForbidden
+	}
+	ok, err := s.repo.Cancel(ctx, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		a, err := s.repo.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if a == nil {
+			return ErrNotFound
+		}
+		return ErrAlreadyCancelled
+	}
+	log.Info().Int64("announcement_id", id).Int64("user_id", userID).Msg("[announcement] Объявление отменено")
+	return nil
+}
+
+// Все объявления, включая запланированные, истёкшие и отменённые
+func (s *AnnouncementService) List(ctx context.Context, role string, limit, offset int) ([]ManagedAnnouncementResponse, error) {
+	if !s.canPublish(role) {
+		return nil, ErrForbidden
+	}
+	if limit <= 0 {
+		limit = s.cfg.PageSize
+	}
+	if limit > s.cfg.MaxPageSize {
+		limit = s.cfg.MaxPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := s.repo.List(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	attachments, err := s.attachmentsByAnnouncement(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ManagedAnnouncementResponse, 0, len(rows))
+	for _, row := range rows {
+		resp := managedResponse(row)
+		if row.TargetType == TargetApartments {
+			aptIDs, err := s.repo.ApartmentIDs(ctx, row.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range aptIDs {
+				resp.Target.ApartmentIDs = append(resp.Target.ApartmentIDs, int64(id))
+			}
+		}
+		if att := attachments[row.ID]; att != nil {
+			resp.Attachments = att
+		}
+		out = append(out, resp)
+	}
+	return out, nil
+}
+
+// Кто из жителей прочитал объявление
+func (s *AnnouncementService) Reads(ctx context.Context, role string, id int64) ([]ReadResponse, error) {
+	if !s.canPublish(role) {
+		return nil, ErrForbidden
+	}
+	a, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrNotFound
+	}
+	rows, err := s.repo.ListReads(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ReadResponse, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, ReadResponse{
+			UserID:    int64(row.UserID),
+			Username:  row.Username,
+			FirstName: row.FirstName.String,
+			LastName:  row.LastName.String,
+			ReadAt:    row.ReadAt.Time,
+		})
+	}
+	return out, nil
+}
+
+// Действующие объявления для квартир пользователя, новые сверху
+func (s *AnnouncementService) ListActive(ctx context.Context, userID int64) ([]AnnouncementResponse, error) {
+	rows, err := s.repo.ListActiveForUser(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	attachments, err := s.attachmentsByAnnouncement(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]AnnouncementResponse, 0, len(rows))
+	for _, row := range rows {
+		resp := AnnouncementResponse{
+			ID:          row.ID,
+			Title:       row.Title,
+			Body:        row.Body,
+			PublishAt:   row.PublishAt.Time,
+			ExpiresAt:   db.TimePtr(row.ExpiresAt),
+			Attachments: attachments[row.ID],
+			IsRead:      row.ReadAt.Valid,
+			ReadAt:      db.TimePtr(row.ReadAt),
+		}
+		if resp.Attachments == nil {
+			resp.Attachments = []AttachmentResponse{}
+		}
+		out = append(out, resp)
+	}
+	return out, nil
+}
+
+// Отметка о прочтении. Объявление должно быть адресовано пользователю и действовать.
+func (s *AnnouncementService) MarkRead(ctx context.Context, userID, id int64) error {
+	now := time.Now()
+	visible, err := s.repo.IsVisibleToUser(ctx, id, userID, now)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return ErrNotFound
+	}
+	return s.repo.MarkRead(ctx, id, userID, now)
+}
+
+// Предельный размер тела запроса с вложением
+func (s *AnnouncementService) MaxUploadSize() int64 {
+	return s.cfg.MaxAttachmentSize
+}
+
+// Сохраняет файл в каталоге вложений под случайным именем.
+// Тип берётся из содержимого, а не из заголовка клиента, и должен быть в ANNOUNCEMENT_ATTACHMENT_TYPES.
+func (s *AnnouncementService) AddAttachment(ctx context.Context, role string, id int64, fileName string, src io.Reader) (*AttachmentResponse, error) {
+	if !s.canPublish(role) {
+		return nil, ErrForbidden
+	}
+	a, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrNotFound
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !slices.Contains(s.cfg.AttachmentTypes, contentType) {
+		return nil, ErrAttachmentType
+	}
+	src = io.MultiReader(bytes.NewReader(head), src)
+
+	name, err := randomName(filepath.Ext(fileName))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.cfg.StorageDir, 0o750); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.cfg.StorageDir, name)
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(out, io.LimitReader(src, s.cfg.MaxAttachmentSize+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && size > s.cfg.MaxAttachmentSize {
+		err = ErrAttachmentTooLarge
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	att, err := s.repo.CreateAttachment(ctx, db.CreateAnnouncementAttachmentParams{
+		AnnouncementID: id,
+		FileName:       filepath.Base(fileName),
+		StoragePath:    name,
+		ContentType:    contentType,
+		SizeBytes:      size,
+	})
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	resp := attachmentResponse(*att)
+	return &resp, nil
+}
+
+// Файл вложения. Управляющей компании доступны все вложения,
+// жителю — только вложения действующих объявлений, адресованных ему.
+// Файл закрывает вызывающий.
+func (s *AnnouncementService) OpenAttachment(ctx context.Context, userID int64, role string, id, attachmentID int64) (*db.AnnouncementAttachment, *os.File, error) {
+	att, err := s.repo.GetAttachment(ctx, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if att == nil || att.AnnouncementID != id {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if !s.canPublish(role) {
+		visible, err := s.repo.IsVisibleToUser(ctx, id, userID, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		if !visible {
+			return nil, nil, ErrAttachmentNotFound
+		}
+	}
+
+	f, err := os.Open(filepath.Join(s.cfg.StorageDir, filepath.Base(att.StoragePath)))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Error().Int64("attachment_id", att.ID).Msg("[announcement] Файл вложения отсутствует в хранилище")
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+	return att, f, nil
+}
+
+func (s *AnnouncementService) attachmentsByAnnouncement(ctx context.Context, ids []int64) (map[int64][]AttachmentResponse, error) {
+	rows, err := s.repo.ListAttachments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int64][]AttachmentResponse, len(ids))
+	for _, row := range rows {
+		out[row.AnnouncementID] = append(out[row.AnnouncementID], attachmentResponse(row))
+	}
+	return out, nil
+}
+
+func managedResponse(row db.ListAnnouncementsRow) ManagedAnnouncementResponse {
+	return ManagedAnnouncementResponse{
+		ID:       row.ID,
+		AuthorID: int64(row.AuthorID.Int32),
+		Title:    row.Title,
+		Body:     row.Body,
+		Target: TargetResponse{
+			Type:     row.TargetType,
+			Address:  row.TargetAddress.String,
+			Entrance: row.TargetEntrance.String,
+		},
+		PublishAt:   row.PublishAt.Time,
+		ExpiresAt:   db.TimePtr(row.ExpiresAt),
+		CancelledAt: db.TimePtr(row.CancelledAt),
+		CreatedAt:   row.CreatedAt.Time,
+		ReadCount:   row.ReadCount,
+		Attachments: []AttachmentResponse{},
+	}
+}
+
+func attachmentResponse(a db.AnnouncementAttachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:          a.ID,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Size:        a.SizeBytes,
+		URL:         fmt.Sprintf("/announcements/%d/attachments/%d", a.AnnouncementID, a.ID),
+	}
+}
+
+func randomName(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// Расширение сохраняется, только если похоже на настоящее
+	if len(ext) > 10 || strings.ContainsAny(ext, " \\") {
+		ext = ""
+	}
+	return hex.EncodeToString(b) + strings.ToLower(ext), nil
+}
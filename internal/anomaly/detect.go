@@ -0,0 +1,201 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	KindImpossibleTravel = "impossible_travel"
+	KindRepeatedDenials  = "repeated_denials"
+	KindUnusualHour      = "unusual_hour"
+	KindRevokedKey       = "revoked_key"
+
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+
+	resultGranted = "granted"
+	resultDenied  = "denied"
+
+	// Сколько прошлых проходов брать для оценки обычных часов
+	baselineLimit = 1000
+)
+
+var Kinds = []string{KindImpossibleTravel, KindRepeatedDenials, KindUnusualHour, KindRevokedKey}
+
+// Проверяет пачку записей журнала. Каждая проверка задаёт dedup_key так, чтобы
+// повторная проверка тех же записей не поднимала тревогу снова.
+func (s *AnomalyService) detect(ctx context.Context, lookup Lookup, records []db.ListAccessHistoryAfterRow) ([]db.CreateSecurityAlertParams, error) {
+	checks := []func(context.Context, Lookup, *db.ListAccessHistoryAfterRow) (*db.CreateSecurityAlertParams, error){
+		s.revokedKey, s.impossibleTravel, s.deviceDenials, s.keyDenials, s.unusualHour,
+	}
+	var alerts []db.CreateSecurityAlertParams
+	for i := range records {
+		rec := &records[i]
+		for _, check := range checks {
+			alert, err := check(ctx, lookup, rec)
+			if err != nil {
+				return nil, err
+			}
+			if alert != nil {
+				alerts = append(alerts, *alert)
+			}
+		}
+	}
+	return alerts, nil
+}
+
+// Ключ предъявлен после заявления о потере или отзыва гостевого пропуска
+func (s *AnomalyService) revokedKey(_ context.Context, _ Lookup, rec *db.ListAccessHistoryAfterRow) (*db.CreateSecurityAlertParams, error) {
+	if !rec.KeyID.Valid {
+		return nil, nil
+	}
+	revokedAt := rec.LostAt
+	if rec.RevokedAt.Valid && (!revokedAt.Valid || rec.RevokedAt.Time.Before(revokedAt.Time)) {
+		revokedAt = rec.RevokedAt
+	}
+	if !revokedAt.Valid || rec.AccessTime.Time.Before(revokedAt.Time) {
+		return nil, nil
+	}
+	alert := s.alert(rec, KindRevokedKey, SeverityMedium, fmt.Sprintf("%s:%d", KindRevokedKey, rec.ID),
+		fmt.Sprintf("Предъявлен отозванный ключ %d", rec.KeyID.Int32))
+	if rec.Result.String == resultGranted {
+		// Панель пустила по отозванному ключу — у неё устаревший список
+		alert.Severity = SeverityHigh
+		alert.Description = fmt.Sprintf("Отозванный ключ %d открыл дверь", rec.KeyID.Int32)
+	}
+	return alert, nil
+}
+
+// Тот же ключ за несколько минут прошёл в другом доме
+func (s *AnomalyService) impossibleTravel(ctx context.Context, lookup Lookup, rec *db.ListAccessHistoryAfterRow) (*db.CreateSecurityAlertParams, error) {
+	if !rec.KeyID.Valid || !rec.Address.Valid || rec.Result.String != resultGranted {
+		return nil, nil
+	}
+	at := rec.AccessTime.Time
+	other, err := lookup.KeyUseElsewhere(ctx, db.FindKeyUseElsewhereParams{
+		KeyID:    rec.KeyID,
+		ID:       rec.ID,
+		Address:  rec.Address.String,
+		FromTime: pgtype.Timestamp{Time: at.Add(-s.cfg.TravelWindow), Valid: true},
+		ToTime:   pgtype.Timestamp{Time: at.Add(s.cfg.TravelWindow), Valid: true},
+	})
+	if err != nil || other == nil {
+		return nil, err
+	}
+	first, second := other.ID, rec.ID
+	if first > second {
+		first, second = second, first
+	}
+	gap := at.Sub(other.AccessTime.Time).Abs().Round(time.Second)
+	return s.alert(rec, KindImpossibleTravel, SeverityHigh, fmt.Sprintf("%s:%d:%d", KindImpossibleTravel, first, second),
+		fmt.Sprintf("Ключ %d прошёл в разных домах с разницей %s (устройства %d и %d)",
+			rec.KeyID.Int32, gap, other.DeviceID.Int32, rec.DeviceID.Int32)), nil
+}
+
+// Серия отказов на одном устройстве — например, подбор кода
+func (s *AnomalyService) deviceDenials(ctx context.Context, lookup Lookup, rec *db.ListAccessHistoryAfterRow) (*db.CreateSecurityAlertParams, error) {
+	if !rec.DeviceID.Valid || rec.Result.String != resultDenied {
+		return nil, nil
+	}
+	at := rec.AccessTime.Time
+	n, err := lookup.DeviceDenials(ctx, db.CountDeviceDenialsParams{
+		DeviceID: rec.DeviceID,
+		FromTime: pgtype.Timestamp{Time: at.Add(-s.cfg.DenialWindow), Valid: true},
+		ToTime:   pgtype.Timestamp{Time: at, Valid: true},
+	})
+	if err != nil || n < int64(s.cfg.DenialThreshold) {
+		return nil, err
+	}
+	alert := s.alert(rec, KindRepeatedDenials, SeverityMedium,
+		fmt.Sprintf("%s:device:%d:%d", KindRepeatedDenials, rec.DeviceID.Int32, at.Truncate(s.cfg.DenialWindow).Unix()),
+		fmt.Sprintf("%d отказов на устройстве %d за %s", n, rec.DeviceID.Int32, s.cfg.DenialWindow))
+	// Тревога относится к устройству, а не к последнему ключу в серии
+	alert.KeyID = pgtype.Int4{}
+	alert.UserID = pgtype.Int4{}
+	return alert, nil
+}
+
+// Серия отказов по одному ключу, в том числе на разных устройствах
+func (s *AnomalyService) keyDenials(ctx context.Context, lookup Lookup, rec *db.ListAccessHistoryAfterRow) (*db.CreateSecurityAlertParams, error) {
+	if !rec.KeyID.Valid || rec.Result.String != resultDenied {
+		return nil, nil
+	}
+	at := rec.AccessTime.Time
+	n, err := lookup.KeyDenials(ctx, db.CountKeyDenialsParams{
+		KeyID:    rec.KeyID,
+		FromTime: pgtype.Timestamp{Time: at.Add(-s.cfg.DenialWindow), Valid: true},
+		ToTime:   pgtype.Timestamp{Time: at, Valid: true},
+	})
+	if err != nil || n < int64(s.cfg.DenialThreshold) {
+		return nil, err
+	}
+	return s.alert(rec, KindRepeatedDenials, SeverityMedium,
+		fmt.Sprintf("%s:key:%d:%d", KindRepeatedDenials, rec.KeyID.Int32, at.Truncate(s.cfg.DenialWindow).Unix()),
+		fmt.Sprintf("%d отказов по ключу %d за %s", n, rec.KeyID.Int32, s.cfg.DenialWindow)), nil
+}
+
+// Проход в час, когда этим ключом обычно не пользуются
+func (s *AnomalyService) unusualHour(ctx context.Context, lookup Lookup, rec *db.ListAccessHistoryAfterRow) (*db.CreateSecurityAlertParams, error) {
+	if !rec.KeyID.Valid || rec.Result.String != resultGranted {
+		return nil, nil
+	}
+	at := rec.AccessTime.Time
+	times, err := lookup.KeyGrantedTimes(ctx, db.ListKeyGrantedTimesParams{
+		KeyID: rec.KeyID,
+		ID:    rec.ID,
+		Since: pgtype.Timestamp{Time: at.Add(-s.cfg.BaselinePeriod), Valid: true},
+		Lim:   baselineLimit,
+	})
+	if err != nil || len(times) < s.cfg.BaselineMinUses {
+		return nil, err
+	}
+	hours := make([]int, len(times))
+	for i, t := range times {
+		hours[i] = s.hour(t.Time)
+	}
+	hour := s.hour(at)
+	if !unusualHour(hour, hours) {
+		return nil, nil
+	}
+	return s.alert(rec, KindUnusualHour, SeverityLow, fmt.Sprintf("%s:%d", KindUnusualHour, rec.ID),
+		fmt.Sprintf("Ключ %d использован около %02d:00 — за %d проходов в это время им не пользовались",
+			rec.KeyID.Int32, hour, len(times))), nil
+}
+
+// Необычный час — ни одного прошлого прохода в этот час и в соседние
+func unusualHour(hour int, history []int) bool {
+	for _, h := range history {
+		d := (h - hour + 24) % 24
+		if d <= 1 || d == 23 {
+			return false
+		}
+	}
+	return true
+}
+
+// Время в журнале записано по часам сервера без пояса; час считаем в ANOMALY_TIMEZONE
+func (s *AnomalyService) hour(t time.Time) int {
+	local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+	return local.In(s.loc).Hour()
+}
+
+func (s *AnomalyService) alert(rec *db.ListAccessHistoryAfterRow, kind, severity, dedupKey, description string) *db.CreateSecurityAlertParams {
+	return &db.CreateSecurityAlertParams{
+		Kind:            kind,
+		Severity:        severity,
+		KeyID:           rec.KeyID,
+		DeviceID:        rec.DeviceID,
+		UserID:          rec.UserID,
+		AccessHistoryID: pgtype.Int4{Int32: rec.ID, Valid: true},
+		DedupKey:        dedupKey,
+		Description:     description,
+		DetectedAt:      pgtype.Timestamp{Time: time.Now(), Valid: true},
+	}
+}
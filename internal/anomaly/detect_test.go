@@ -0,0 +1,31 @@
+package anomaly
+
+import "testing"
+
+func TestUnusualHour(t *testing.T) {
+	tests := []struct {
+		name    string
+		hour    int
+		history []int
+		want    bool
+	}{
+		{"empty history", 3, nil, true},
+		{"same hour", 8, []int{8}, false},
+		{"previous hour", 8, []int{7}, false},
+		{"next hour", 8, []int{9}, false},
+		{"two hours away", 8, []int{6, 10}, true},
+		{"midnight after 23", 0, []int{23}, false},
+		{"23 before midnight", 23, []int{0}, false},
+		{"1 after 23 is two hours", 1, []int{23}, true},
+		{"22 before 0 is two hours", 22, []int{0}, true},
+		{"one close hour among many", 3, []int{12, 13, 18, 4}, false},
+		{"daytime history, night pass", 3, []int{9, 12, 13, 18, 19}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unusualHour(tt.hour, tt.history); got != tt.want {
+				t.Errorf("unusualHour(%d, %v) = %v, want %v", tt.hour, tt.history, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package anomaly
+
+import "time"
+
+type AlertResponse struct {
+	ID int64 `json:"id"`
+	// impossible_travel, repeated_denials, unusual_hour, revoked_key
+	Kind string `json:"kind"`
+	// low, medium, high
+	Severity        string    `json:"severity"`
+	KeyID           *int64    `json:"key_id,omitempty"`
+	DeviceID        *int64    `json:"device_id,omitempty"`
+	UserID          *int64    `json:"user_id,omitempty"`
+	AccessHistoryID *int64    `json:"access_history_id,omitempty"`
+	Description     string    `json:"description"`
+	DetectedAt      time.Time `json:"detected_at"`
+	// open, acknowledged, dismissed
+	Status     string     `json:"status"`
+	ReviewedBy *int64     `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote string     `json:"review_note,omitempty"`
+}
+
+type ReviewRequest struct {
+	// acknowledged — принято в работу, dismissed — ложная тревога, open — вернуть в список
+	Status string `json:"status"`
+	Note   string `json:"note,omitempty"`
+}
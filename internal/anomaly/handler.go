@@ -0,0 +1,140 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"domofon/internal/middleware"
+)
+
+type AnomalyHandler struct {
+	anomalies *AnomalyService
+}
+
+func NewAnomalyHandler(s *AnomalyService) *AnomalyHandler {
+	return &AnomalyHandler{anomalies: s}
+}
+
+// List godoc
+// @Summary Тревоги по журналу доступа
+// @Description Для ролей из ANOMALY_ADMIN_ROLES. Тревоги находит фоновая проверка журнала, от новых к старым.
+// @Tags security
+// @Produce json
+// @Param status query string false "open, acknowledged или dismissed"
+// @Param kind query string false "impossible_travel, repeated_denials, unusual_hour или revoked_key"
+// @Param limit query int false "Сколько тревог вернуть (по умолчанию 100, не больше 500)"
+// @Success 200 {array} AlertResponse
+// @Failure 400 {string} string "Некорректный фильтр"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Security BearerAuth
+// @Router /security-alerts [get]
+func (h *AnomalyHandler) List(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query()
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Некорректный limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	alerts, err := h.anomalies.List(r.Context(), role, q.Get("status"), q.Get("kind"), limit)
+	if err != nil {
+		writeAnomalyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+// Get godoc
+// @Summary Тревога по журналу доступа
+// @Description Для ролей из ANOMALY_ADMIN_ROLES.
+// @Tags security
+// @Produce json
+// @Param id path int true "ID тревоги"
+// @Success 200 {object} AlertResponse
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Тревога не найдена"
+// @Security BearerAuth
+// @Router /security-alerts/{id} [get]
+func (h *AnomalyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	alert, err := h.anomalies.Get(r.Context(), role, id)
+	if err != nil {
+		writeAnomalyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, alert)
+}
+
+// Review godoc
+// @Summary Отметить результат разбора тревоги
+// @Description Для ролей из ANOMALY_ADMIN_ROLES.
+// @Tags security
+// @Accept json
+// @Produce json
+// @Param id path int true "ID тревоги"
+// @Param input body ReviewRequest true "Новый статус и комментарий"
+// @Success 200 {object} AlertResponse
+// @Failure 400 {string} string "Некорректный статус"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Тревога не найдена"
+// @Security BearerAuth
+// @Router /security-alerts/{id}/review [post]
+func (h *AnomalyHandler) Review(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	alert, err := h.anomalies.Review(r.Context(), userID, role, id, req)
+	if err != nil {
+		writeAnomalyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, alert)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAnomalyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrAlertNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidStatus), errors.Is(err, ErrInvalidKind), errors.Is(err, ErrNoteTooLong):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,173 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Запросы, которые нужны проверкам; выполняются в транзакции проверки
+type Lookup interface {
+	KeyUseElsewhere(ctx context.Context, params db.FindKeyUseElsewhereParams) (*db.FindKeyUseElsewhereRow, error)
+	DeviceDenials(ctx context.Context, params db.CountDeviceDenialsParams) (int64, error)
+	KeyDenials(ctx context.Context, params db.CountKeyDenialsParams) (int64, error)
+	KeyGrantedTimes(ctx context.Context, params db.ListKeyGrantedTimesParams) ([]pgtype.Timestamp, error)
+}
+
+// Находит тревоги в пачке записей журнала
+type DetectFunc func(ctx context.Context, lookup Lookup, records []db.ListAccessHistoryAfterRow) ([]db.CreateSecurityAlertParams, error)
+
+type Repository interface {
+	ScanBatch(ctx context.Context, cursor string, limit int, settledBefore time.Time, detect DetectFunc, now time.Time) (advanced, created int, err error)
+	List(ctx context.Context, params db.ListSecurityAlertsParams) ([]db.SecurityAlert, error)
+	Get(ctx context.Context, id int64) (*db.SecurityAlert, error)
+	Review(ctx context.Context, params db.ReviewSecurityAlertParams) (*db.SecurityAlert, error)
+}
+
+type AnomalyRepository struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+func NewAnomalyRepository(pool *pgxpool.Pool) *AnomalyRepository {
+	return &AnomalyRepository{pool: pool, queries: db.New(pool)}
+}
+
+// ScanBatch проверяет следующую пачку записей после курсора. Курсор блокируется
+// на время транзакции, поэтому несколько экземпляров сервиса не проверяют одно
+// и то же; тревоги и события security_alert пишутся вместе со сдвигом курсора.
+//
+// id выдаётся при вставке, а видна запись только после коммита, поэтому запись
+// с меньшим id может появиться позже соседних. Курсор сдвигается только по
+// записям, вставленным раньше settledBefore: всё, что старше, уже закоммичено.
+// Более свежие записи проверяются сразу и ещё раз на следующих проходах,
+// повторных тревог не будет благодаря dedup_key. Возвращает, на сколько записей
+// сдвинулся курсор.
+func (r *AnomalyRepository) ScanBatch(ctx context.Context, cursor string, limit int, settledBefore time.Time, detect DetectFunc, now time.Time) (int, int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	if err := q.EnsureAnomalyScanCursor(ctx, cursor); err != nil {
+		return 0, 0, err
+	}
+	last, err := q.GetAnomalyScanCursorForUpdate(ctx, cursor)
+	if err != nil {
+		return 0, 0, err
+	}
+	records, err := q.ListAccessHistoryAfter(ctx, db.ListAccessHistoryAfterParams{ID: last, Limit: int32(limit)})
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(records) == 0 {
+		return 0, 0, nil
+	}
+
+	alerts, err := detect(ctx, &txLookup{q: q}, records)
+	if err != nil {
+		return 0, 0, err
+	}
+	created := 0
+	for _, a := range alerts {
+		id, err := q.CreateSecurityAlert(ctx, a)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Такая тревога уже поднята
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		err = q.CreateEvent(ctx, db.CreateEventParams{
+			DeviceID:    a.DeviceID,
+			EventType:   EventSecurityAlert,
+			UserID:      a.UserID,
+			Description: pgtype.Text{String: fmt.Sprintf("alert_id=%d kind=%s severity=%s", id, a.Kind, a.Severity), Valid: true},
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		created++
+	}
+
+	advanced := 0
+	for _, rec := range records {
+		// У записей до миграции created_at пуст — они давно закоммичены
+		if rec.CreatedAt.Valid && !rec.CreatedAt.Time.Before(settledBefore) {
+			break
+		}
+		last = rec.ID
+		advanced++
+	}
+	err = q.UpdateAnomalyScanCursor(ctx, db.UpdateAnomalyScanCursorParams{
+		Name:          cursor,
+		LastHistoryID: last,
+		ScannedAt:     pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return advanced, created, tx.Commit(ctx)
+}
+
+func (r *AnomalyRepository) List(ctx context.Context, params db.ListSecurityAlertsParams) ([]db.SecurityAlert, error) {
+	return r.queries.ListSecurityAlerts(ctx, params)
+}
+
+func (r *AnomalyRepository) Get(ctx context.Context, id int64) (*db.SecurityAlert, error) {
+	a, err := r.queries.GetSecurityAlert(ctx, int32(id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AnomalyRepository) Review(ctx context.Context, params db.ReviewSecurityAlertParams) (*db.SecurityAlert, error) {
+	a, err := r.queries.ReviewSecurityAlert(ctx, params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+type txLookup struct {
+	q *db.Queries
+}
+
+func (l *txLookup) KeyUseElsewhere(ctx context.Context, params db.FindKeyUseElsewhereParams) (*db.FindKeyUseElsewhereRow, error) {
+	row, err := l.q.FindKeyUseElsewhere(ctx, params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &row, nil
+}
+
+func (l *txLookup) DeviceDenials(ctx context.Context, params db.CountDeviceDenialsParams) (int64, error) {
+	return l.q.CountDeviceDenials(ctx, params)
+}
+
+func (l *txLookup) KeyDenials(ctx context.Context, params db.CountKeyDenialsParams) (int64, error) {
+	return l.q.CountKeyDenials(ctx, params)
+}
+
+func (l *txLookup) KeyGrantedTimes(ctx context.Context, params db.ListKeyGrantedTimesParams) ([]pgtype.Timestamp, error) {
+	return l.q.ListKeyGrantedTimes(ctx, params)
+}
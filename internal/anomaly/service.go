@@ -0,0 +1,198 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"domofon/internal/config"
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+	StatusDismissed    = "dismissed"
+
+	// Тип записи в events для каждой новой тревоги
+	EventSecurityAlert = "security_alert"
+
+	scanCursor       = "access_history"
+	defaultListLimit = 100
+	maxListLimit     = 500
+	maxNoteLength    = 255
+)
+
+var (
+	ErrForbidden     = errors.New("недостаточно прав")
+	ErrAlertNotFound = errors.New("тревога не найдена")
+	ErrInvalidStatus = errors.New("статус: open, acknowledged или dismissed")
+	ErrInvalidKind   = errors.New("неизвестный тип тревоги")
+	ErrNoteTooLong   = errors.New("комментарий слишком длинный")
+)
+
+// Фоновая проверка журнала доступа и список найденных тревог
+type AnomalyService struct {
+	repo Repository
+	cfg  *config.AnomalyConfig
+	loc  *time.Location
+}
+
+func NewAnomalyService(repo Repository, cfg *config.AnomalyConfig) *AnomalyService {
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		log.Error().Err(err).Str("timezone", cfg.Timezone).Msg("[anomaly] Неизвестный часовой пояс, используется UTC")
+		loc = time.UTC
+	}
+	return &AnomalyService{repo: repo, cfg: cfg, loc: loc}
+}
+
+// Scan проверяет все записи журнала, появившиеся с прошлой проверки.
+// Записи моложе ANOMALY_COMMIT_LAG проверяются ещё раз на следующем проходе.
+// Возвращает число новых тревог.
+func (s *AnomalyService) Scan(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		now := time.Now()
+		advanced, created, err := s.repo.ScanBatch(ctx, scanCursor, s.cfg.BatchSize, now.Add(-s.cfg.CommitLag), s.detect, now)
+		if err != nil {
+			return total, err
+		}
+		total += created
+		if advanced < s.cfg.BatchSize || ctx.Err() != nil {
+			return total, nil
+		}
+	}
+}
+
+// Плановая проверка журнала. Блокирует до отмены ctx, запускать в горутине.
+func (s *AnomalyService) RunScanner(ctx context.Context) {
+	if s.cfg.ScanInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.ScanInterval)
+	defer ticker.Stop()
+	for {
+		created, err := s.Scan(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("[anomaly] Не удалось проверить журнал доступа")
+		} else if created > 0 {
+			log.Warn().Int("alerts", created).Msg("[anomaly] Найдены аномалии доступа")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Тревоги от новых к старым; пустые status и kind — без фильтра
+func (s *AnomalyService) List(ctx context.Context, role, status, kind string, limit int) ([]AlertResponse, error) {
+	if !slices.Contains(s.cfg.AdminRoles, role) {
+		return nil, ErrForbidden
+	}
+	if status != "" && !validStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+	if kind != "" && !slices.Contains(Kinds, kind) {
+		return nil, ErrInvalidKind
+	}
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+
+	alerts, err := s.repo.List(ctx, db.ListSecurityAlertsParams{Status: status, Kind: kind, Lim: int32(limit)})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]AlertResponse, len(alerts))
+	for i := range alerts {
+		result[i] = alertResponse(&alerts[i])
+	}
+	return result, nil
+}
+
+func (s *AnomalyService) Get(ctx context.Context, role string, id int64) (*AlertResponse, error) {
+	if !slices.Contains(s.cfg.AdminRoles, role) {
+		return nil, ErrForbidden
+	}
+	alert, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, ErrAlertNotFound
+	}
+	resp := alertResponse(alert)
+	return &resp, nil
+}
+
+// Review отмечает результат разбора тревоги
+func (s *AnomalyService) Review(ctx context.Context, userID int64, role string, id int64, req ReviewRequest) (*AlertResponse, error) {
+	if !slices.Contains(s.cfg.AdminRoles, role) {
+		return nil, ErrForbidden
+	}
+	if !validStatus(req.Status) {
+		return nil, ErrInvalidStatus
+	}
+	if utf8.RuneCountInString(req.Note) > maxNoteLength {
+		return nil, ErrNoteTooLong
+	}
+	alert, err := s.repo.Review(ctx, db.ReviewSecurityAlertParams{
+		ID:         int32(id),
+		Status:     req.Status,
+		ReviewedBy: pgtype.Int4{Int32: int32(userID), Valid: true},
+		ReviewedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+		ReviewNote: pgtype.Text{String: req.Note, Valid: req.Note != ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, ErrAlertNotFound
+	}
+	log.Info().Int64("user_id", userID).Int64("alert_id", id).Str("status", req.Status).Msg("[anomaly] Тревога разобрана")
+	resp := alertResponse(alert)
+	return &resp, nil
+}
+
+func validStatus(status string) bool {
+	return status == StatusOpen || status == StatusAcknowledged || status == StatusDismissed
+}
+
+func alertResponse(a *db.SecurityAlert) AlertResponse {
+	resp := AlertResponse{
+		ID:              int64(a.ID),
+		Kind:            a.Kind,
+		Severity:        a.Severity,
+		KeyID:           int64Ptr(a.KeyID),
+		DeviceID:        int64Ptr(a.DeviceID),
+		UserID:          int64Ptr(a.UserID),
+		AccessHistoryID: int64Ptr(a.AccessHistoryID),
+		Description:     a.Description,
+		DetectedAt:      a.DetectedAt.Time,
+		Status:          a.Status,
+		ReviewedBy:      int64Ptr(a.ReviewedBy),
+		ReviewNote:      a.ReviewNote.String,
+	}
+	if a.ReviewedAt.Valid {
+		t := a.ReviewedAt.Time
+		resp.ReviewedAt = &t
+	}
+	return resp
+}
+
+func int64Ptr(v pgtype.Int4) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	id := int64(v.Int32)
+	return &id
+}
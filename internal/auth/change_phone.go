@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"domofon/internal/outbox"
+	"domofon/internal/verification"
+
+	"github.com/rs/zerolog/log"
+)
+
+const EventPhoneChanged = "phone_changed"
+
+var (
+	ErrPhoneUnchanged           = errors.New("новый номер совпадает с текущим")
+	ErrOldPhoneNotConfirmed     = errors.New("подтвердите текущий номер кодом из SMS или паролем")
+	ErrPhoneChangedConcurrently = errors.New("номер уже был изменён, повторите попытку")
+)
+
+// Отправка кода подтверждения на новый номер
+func (s *AuthService) RequestNewPhoneCode(ctx context.Context, userID int64, newPhone string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Phone == newPhone {
+		return ErrPhoneUnchanged
+	}
+	taken, err := s.repo.IsPhoneTaken(ctx, newPhone)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrPhoneTaken
+	}
+	return s.verification.SendVerificationCode(ctx, newPhone, verification.PurposeChangePhone)
+}
+
+// Отправка кода подтверждения на текущий номер (если пароль не используется)
+func (s *AuthService) RequestCurrentPhoneCode(ctx context.Context, userID int64) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.verification.SendVerificationCode(ctx, user.Phone, verification.PurposeChangePhone)
+}
+
+// Смена номера телефона. Новый номер подтверждается билетом newTicket
+// (/auth/verify-phone, purpose=change_phone), текущий — билетом oldTicket
+// или паролем. После смены все сессии пользователя отзываются,
+// на старый номер уходит уведомление.
+func (s *AuthService) ChangePhone(ctx context.Context, userID int64, newPhone, newTicket, oldTicket, password, ip string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Phone == newPhone {
+		return ErrPhoneUnchanged
+	}
+
+	newClaims, err := s.parseTicket(newTicket, newPhone, verification.PurposeChangePhone)
+	if err != nil {
+		return err
+	}
+	change := PhoneChange{
+		UserID:   userID,
+		OldPhone: user.Phone,
+		NewPhone: newPhone,
+	}
+	change.Tickets = append(change.Tickets, newClaims)
+
+	switch {
+	case oldTicket != "":
+		oldClaims, err := s.parseTicket(oldTicket, user.Phone, verification.PurposeChangePhone)
+		if err != nil {
+			return err
+		}
+		change.Tickets = append(change.Tickets, oldClaims)
+		change.Audit = fmt.Sprintf("old=%s new=%s by=sms ip=%s", user.Phone, newPhone, ip)
+	case password != "":
+		// Подбор пароля здесь считается так же, как при входе
+		if err := s.checkLockout(ctx, phoneAttemptKey(user.Phone)); err != nil {
+			return err
+		}
+		if !s.CheckPasswordHash(password, user.PasswordHash) {
+			s.registerLoginFailure(ctx, user.Phone, ip, user)
+			return ErrInvalidOldPassword
+		}
+		change.Audit = fmt.Sprintf("old=%s new=%s by=password ip=%s", user.Phone, newPhone, ip)
+	default:
+		return ErrOldPhoneNotConfirmed
+	}
+
+	taken, err := s.repo.IsPhoneTaken(ctx, newPhone)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrPhoneTaken
+	}
+
+	if err := s.repo.ChangePhone(ctx, change); err != nil {
+		return err
+	}
+
+	_, err = s.queue.Enqueue(ctx, outbox.Message{
+		Channel:   outbox.ChannelSMS,
+		Recipient: user.Phone,
+		Body:      "Номер для входа в Домофон изменён. Если это были не вы, обратитесь в поддержку.",
+	})
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("[auth] Не удалось уведомить старый номер о смене")
+	}
+	return nil
+}
@@ -1,14 +1,21 @@
 package auth
 
+import (
+	"time"
+
+	"domofon/internal/password"
+)
+
 // --- Регистрация ---
 type RegisterRequest struct {
 	Username  string `json:"username"`
 	Password  string `json:"password"`
 	Email     string `json:"email"`
 	Phone     string `json:"phone"`
-	Role      string `json:"role"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
+	// Билет из /auth/verify-phone (purpose=register)
+	VerificationTicket string `json:"verification_ticket"`
 }
 
 // --- Логин ---
@@ -17,9 +24,14 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// Вход по коду из SMS, код запрашивается через /auth/login/sms/request-code
+type SMSLoginRequest struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+}
+
 // --- Смена пароля ---
 type ChangePasswordRequest struct {
-	Phone       string `json:"phone"`
 	OldPassword string `json:"old_password"`
 	NewPassword string `json:"new_password"`
 }
@@ -32,6 +44,29 @@ type ForgotPasswordRequest struct {
 type ResetPasswordRequest struct {
     Phone       string `json:"phone"`
     NewPassword string `json:"newPassword"`
+    // Билет из /auth/verify-phone (purpose=reset)
+    VerificationTicket string `json:"verification_ticket"`
+}
+
+// --- Смена номера ---
+type ChangePhoneRequest struct {
+	NewPhone string `json:"new_phone"`
+	// Билет из /auth/verify-phone (purpose=change_phone) для нового номера
+	NewPhoneTicket string `json:"new_phone_ticket"`
+	// Подтверждение текущего номера: билет для него или пароль
+	OldPhoneTicket string `json:"old_phone_ticket,omitempty"`
+	Password       string `json:"password,omitempty"`
+}
+
+// --- Email ---
+type ForgotPasswordEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordEmailRequest struct {
+	// Токен из ссылки в письме
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
 }
 
 // --- Верификация номера ---
@@ -42,6 +77,14 @@ type RequestPhoneVerificationRequest struct {
 type VerifyPhoneRequest struct {
 	Phone string `json:"phone"`
 	Code  string `json:"code"`
+	// register (по умолчанию), reset, change_phone, login
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// VerifyPhoneResponse — одноразовый билет подтверждения номера
+type VerifyPhoneResponse struct {
+	VerificationTicket string    `json:"verification_ticket"`
+	ExpiresAt          time.Time `json:"expires_at"`
 }
 
 // UserResponse описывает структуру JSON-ответа при успешной авторизации
@@ -49,6 +92,7 @@ type UserResponse struct {
     ID        int64  `json:"id"`
     Username  string `json:"username"`
     Email     string `json:"email"`
+    EmailVerified bool `json:"email_verified"`
     Phone     string `json:"phone"`
     Role      string `json:"role"`
     FirstName string `json:"first_name"`
@@ -61,6 +105,68 @@ type LoginResponse struct {
 	User         UserResponse `json:"user"`
 }
 
+// Ответ 400 на пароль, не прошедший политику
+type PasswordPolicyErrorResponse struct {
+	Error      string               `json:"error"`
+	Violations []password.Violation `json:"violations"`
+}
+
+// --- Второй фактор ---
+
+// Ответ /auth/login, если нужен второй шаг. stage=verify — ввести код в
+// /auth/login/mfa, stage=enroll — сначала подключить второй фактор.
+type MFAChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	Stage       string    `json:"stage"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	// Код из приложения или код восстановления
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code,omitempty"`
+}
+
+type MFAEnrollStartRequest struct {
+	MFAToken string `json:"mfa_token"`
+	// Билет из /auth/verify-phone (purpose=mfa_enroll) для номера пользователя
+	VerificationTicket string `json:"verification_ticket"`
+}
+
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type MFAEnrollResponse struct {
+	Secret string `json:"secret"`
+	// otpauth:// URI для QR-кода
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Ответ на подключение второго фактора при входе: коды восстановления и токены
+type MFAEnrollLoginResponse struct {
+	LoginResponse
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAStatusResponse struct {
+	Enabled           bool  `json:"enabled"`
+	Required          bool  `json:"required"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
 // RefreshRequest — тело запроса для refresh/logout
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"domofon/internal/outbox"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	EventEmailConfirmed       = "email_confirmed"
+	EventPasswordResetByEmail = "password_reset_email"
+)
+
+var ErrInvalidEmailToken = errors.New("ссылка недействительна или устарела")
+
+// Случайный токен для ссылки в письме и его SHA-256. В базе хранится только хеш.
+func newLinkToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashLinkToken(token), nil
+}
+
+func hashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Отправляет ссылку подтверждения на email. Адрес становится основным
+// только после перехода по ссылке (ConfirmEmail).
+func (s *AuthService) RequestEmailConfirmation(ctx context.Context, userID int64, email string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == email && user.EmailVerified {
+		return nil
+	}
+	other, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if other != nil && int64(other.ID) != userID {
+		return ErrEmailTaken
+	}
+
+	token, hash, err := newLinkToken()
+	if err != nil {
+		return err
+	}
+	if err := s.repo.CreateEmailVerificationToken(ctx, hash, userID, email, time.Now().Add(s.emailConfirmTTL)); err != nil {
+		return err
+	}
+
+	link := s.publicURL + "/auth/email/confirm?token=" + url.QueryEscape(token)
+	_, err = s.queue.Enqueue(ctx, outbox.Message{
+		Channel:   outbox.ChannelEmail,
+		Recipient: email,
+		Subject:   "Подтверждение email",
+		Body: fmt.Sprintf("Чтобы подтвердить адрес %s, перейдите по ссылке:\n\n%s\n\n"+
+			"Ссылка действует %s. Если вы не указывали этот адрес, просто проигнорируйте письмо.",
+			email, link, s.emailConfirmTTL),
+	})
+	return err
+}
+
+// Подтверждение email по ссылке из письма
+func (s *AuthService) ConfirmEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidEmailToken
+	}
+	t, err := s.repo.GetEmailVerificationToken(ctx, hashLinkToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidEmailToken
+		}
+		return err
+	}
+	userID := int64(t.UserID)
+
+	// Пока письмо шло, адрес мог занять другой пользователь
+	other, err := s.repo.GetUserByEmail(ctx, t.Email)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if other != nil && int64(other.ID) != userID {
+		return ErrEmailTaken
+	}
+
+	if err := s.repo.ConfirmUserEmail(ctx, userID, t.Email); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteEmailVerificationTokens(ctx, userID); err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("[auth] Не удалось удалить ссылки подтверждения email")
+	}
+	s.logEvent(ctx, EventEmailConfirmed, userID, "email="+t.Email)
+	return nil
+}
+
+// Отправляет ссылку для сброса пароля на подтверждённый email.
+// Для неизвестных и неподтверждённых адресов ничего не делает и не сообщает об этом,
+// чтобы по ответу нельзя было проверить, зарегистрирован ли адрес.
+func (s *AuthService) RequestPasswordResetByEmail(ctx context.Context, email string) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if !user.EmailVerified {
+		log.Info().Int32("user_id", user.ID).Msg("[auth] Сброс пароля по неподтверждённому email отклонён")
+		return nil
+	}
+
+	token, hash, err := newLinkToken()
+	if err != nil {
+		return err
+	}
+	if err := s.repo.CreatePasswordResetToken(ctx, int64(user.ID), hash, time.Now().Add(s.passwordResetTTL)); err != nil {
+		return err
+	}
+
+	link := s.passwordResetURL + "?token=" + url.QueryEscape(token)
+	_, err = s.queue.Enqueue(ctx, outbox.Message{
+		Channel:   outbox.ChannelEmail,
+		Recipient: user.Email,
+		Subject:   "Сброс пароля",
+		Body: fmt.Sprintf("Для сброса пароля перейдите по ссылке:\n\n%s\n\n"+
+			"Ссылка действует %s. Если вы не запрашивали сброс, просто проигнорируйте письмо.",
+			link, s.passwordResetTTL),
+	})
+	return err
+}
+
+// Сброс пароля по токену из письма. Все ссылки сброса и все сессии пользователя
+// после этого становятся недействительными.
+func (s *AuthService) ResetPasswordByEmail(ctx context.Context, token, newPassword string) error {
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+	hash := hashLinkToken(token)
+	user, err := s.repo.GetUserByResetToken(ctx, hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	userID := int64(user.ID)
+
+	if err := s.ValidatePassword(newPassword, user.Phone, user.Username); err != nil {
+		return err
+	}
+	newHash, err := s.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	// Ссылка гасится в той же транзакции, что и смена пароля
+	if err := s.repo.ResetPassword(ctx, PasswordReset{UserID: userID, NewHash: newHash, ResetToken: hash}); err != nil {
+		return err
+	}
+	s.logEvent(ctx, EventPasswordResetByEmail, userID, "email="+user.Email)
+	return nil
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"domofon/internal/db"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (r *AuthRepository) GetUserByEmail(ctx context.Context, email string) (*db.User, error) {
+	user, err := r.queries.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *AuthRepository) CreateEmailVerificationToken(ctx context.Context, tokenHash string, userID int64, email string, expiresAt time.Time) error {
+	return r.queries.CreateEmailVerificationToken(ctx, db.CreateEmailVerificationTokenParams{
+		TokenHash: tokenHash,
+		UserID:    int32(userID),
+		Email:     email,
+		ExpiresAt: pgtype.Timestamp{Time: expiresAt, Valid: true},
+	})
+}
+
+func (r *AuthRepository) GetEmailVerificationToken(ctx context.Context, tokenHash string) (*db.EmailVerificationToken, error) {
+	t, err := r.queries.GetEmailVerificationToken(ctx, db.GetEmailVerificationTokenParams{
+		TokenHash: tokenHash,
+		Now:       pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *AuthRepository) ConfirmUserEmail(ctx context.Context, userID int64, email string) error {
+	return r.queries.ConfirmUserEmail(ctx, db.ConfirmUserEmailParams{ID: int32(userID), Email: email})
+}
+
+func (r *AuthRepository) DeleteEmailVerificationTokens(ctx context.Context, userID int64) error {
+	return r.queries.DeleteEmailVerificationTokens(ctx, int32(userID))
+}
+
+func (r *AuthRepository) DeleteAllResetTokensForUser(ctx context.Context, userID int64) error {
+	return r.queries.DeleteAllResetTokensForUser(ctx, pgtype.Int4{Int32: int32(userID), Valid: true})
+}
+
+func (r *AuthRepository) ChangePasswordByID(ctx context.Context, userID int64, newHash string) error {
+	return r.queries.ChangePasswordByID(ctx, db.ChangePasswordByIDParams{ID: int32(userID), PasswordHash: newHash})
+}
@@ -5,8 +5,16 @@ import (
 	"domofon/internal/db"
 	"encoding/json"
 	"errors"
+	"html/template"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
   "domofon/internal/jwt" // Импортируй свой jwt-пакет
+	"domofon/internal/middleware"
+	"domofon/internal/password"
+	"domofon/internal/phone"
+	"domofon/internal/verification"
 	"github.com/jackc/pgx/v5/pgtype"
 	"time"
 )
@@ -21,13 +29,13 @@ func NewAuthHandler(authService *AuthService) *AuthHandler {
 
 // Register godoc
 // @Summary Регистрация нового пользователя
-// @Description Создает нового пользователя по данным (телефон, имя, email и т.д.)
+// @Description Создает нового пользователя по данным (телефон, имя, email и т.д.) с ролью resident. Требуется verification_ticket из /auth/verify-phone с purpose=register для этого номера.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param input body RegisterRequest true "Данные для регистрации"
 // @Success 201 "Пользователь успешно создан"
-// @Failure 400 {string} string "Некорректный JSON/пароль/номер занят"
+// @Failure 400 {string} string "Некорректный JSON/пароль/номер занят/номер не подтверждён"
 // @Failure 500 {string} string "Ошибка сервера"
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -36,8 +44,11 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
 		return
 	}
-	if req.Password == "" {
-		http.Error(w, "Пароль не может быть пустым", http.StatusBadRequest)
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
+	if err := h.auth.ValidatePassword(req.Password, req.Phone, req.Username); err != nil {
+		writePasswordPolicyError(w, err)
 		return
 	}
 
@@ -52,16 +63,15 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		PasswordHash: hashedPassword,
     Email:        req.Email,
     Phone:        req.Phone,
-		Role:         pgtype.Text{String: req.Role, Valid: req.Role != ""},
 		IsActive:     pgtype.Bool{Bool: true, Valid: true},
 		FirstName:    pgtype.Text{String: req.FirstName, Valid: req.FirstName != ""},
 		LastName:     pgtype.Text{String: req.LastName, Valid: req.LastName != ""},
 	}
 
 	// вызов регистрационного потока с проверками
-	if err := h.auth.Register(r.Context(), params); err != nil {
+	if err := h.auth.Register(r.Context(), params, req.VerificationTicket); err != nil {
 		// бизнес-ошибки возвращаем 400
-		if errors.Is(err, ErrPhoneTaken) || errors.Is(err, ErrUsernameTaken) {
+		if errors.Is(err, ErrPhoneTaken) || errors.Is(err, ErrUsernameTaken) || errors.Is(err, ErrEmailTaken) || errors.Is(err, ErrInvalidTicket) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -80,8 +90,11 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param input body auth.LoginRequest true "Данные для входа"
 // @Success 200 {object} LoginResponse "Пользователь авторизован, токены выданы"
+// @Success 202 {object} MFAChallengeResponse "Нужен второй фактор: mfa_required=true, токены не выдаются"
 // @Failure 400 {string} string "Некорректный JSON"
 // @Failure 401 {string} string "Неверный телефон или пароль"
+// @Failure 423 {string} string "Аккаунт временно заблокирован, см. Retry-After"
+// @Failure 429 {string} string "Слишком много попыток, см. Retry-After"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
@@ -89,80 +102,271 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
 		return
 	}
-	if req.Phone == "" {
-		http.Error(w, "Требуется поле phone", http.StatusBadRequest)
+	if !normalizePhone(w, &req.Phone) {
 		return
 	}
 
-	user, ok := h.auth.AuthorizeByPhone(r.Context(), req.Phone, req.Password)
-	if !ok {
-		http.Error(w, "Неверный телефон или пароль", http.StatusUnauthorized)
+	user, err := h.auth.AuthorizeByPhone(r.Context(), req.Phone, req.Password, middleware.ClientIP(r))
+	if err != nil {
+		writeLoginError(w, err)
 		return
 	}
+	h.completeLogin(w, r, user)
+}
 
-	accessToken, jti, err := jwt.GenerateAccessToken(int64(user.ID))
+// RequestLoginCode godoc
+// @Summary Запросить код для входа без пароля
+// @Description Отправляет SMS-код для входа. Для незарегистрированного номера ответ тот же, но SMS не отправляется. Повторный запрос раньше VERIFICATION_RESEND_INTERVAL тоже отвечает 200 без SMS.
+// @Tags auth
+// @Accept json
+// @Param input body RequestPhoneVerificationRequest true "Номер телефона"
+// @Success 200 "Код отправлен"
+// @Failure 400 {string} string "Некорректный номер"
+// @Failure 423 {string} string "Аккаунт временно заблокирован, см. Retry-After"
+// @Failure 429 {string} string "Слишком много запросов, см. Retry-After"
+// @Router /auth/login/sms/request-code [post]
+func (h *AuthHandler) RequestLoginCode(w http.ResponseWriter, r *http.Request) {
+	var req RequestPhoneVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
+
+	err := h.auth.RequestLoginCode(r.Context(), req.Phone, middleware.ClientIP(r))
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.Is(err, verification.ErrResendTooSoon), errors.Is(err, verification.ErrTooManyAttempts):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		writeLoginError(w, err)
+	}
+}
+
+// LoginBySMS godoc
+// @Summary Вход по коду из SMS
+// @Description Обменивает телефон и код из SMS на пару access/refresh токенов. Неверный код считается неудачной попыткой входа.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body SMSLoginRequest true "Телефон и код"
+// @Success 200 {object} LoginResponse "Пользователь авторизован, токены выданы"
+// @Success 202 {object} MFAChallengeResponse "Нужен второй фактор"
+// @Failure 401 {string} string "Неверный или просроченный код"
+// @Failure 423 {string} string "Аккаунт временно заблокирован, см. Retry-After"
+// @Failure 429 {string} string "Слишком много попыток, см. Retry-After"
+// @Router /auth/login/sms [post]
+func (h *AuthHandler) LoginBySMS(w http.ResponseWriter, r *http.Request) {
+	var req SMSLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
+
+	user, err := h.auth.AuthorizeBySMSCode(r.Context(), req.Phone, req.Code, middleware.ClientIP(r))
 	if err != nil {
-		http.Error(w, "Ошибка генерации access token", http.StatusInternalServerError)
+		writeLoginError(w, err)
 		return
 	}
-	refreshToken, err := jwt.GenerateRefreshToken(int64(user.ID), jti)
+	h.completeLogin(w, r, user)
+}
+
+// Первый фактор пройден: либо второй шаг, либо сразу токены
+func (h *AuthHandler) completeLogin(w http.ResponseWriter, r *http.Request, user *db.User) {
+	challenge, err := h.auth.BeginLoginMFA(r.Context(), user)
 	if err != nil {
-		http.Error(w, "Ошибка генерации refresh token", http.StatusInternalServerError)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+	if challenge != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(challenge)
 		return
 	}
 
-	// Сохраняем refresh токен в БД
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
-	if err := h.auth.SaveRefreshToken(r.Context(), int64(user.ID), refreshToken, jti, expiresAt); err != nil {
-		http.Error(w, "Ошибка сохранения refresh токена", http.StatusInternalServerError)
+	h.writeLoginResponse(w, r, user)
+}
+
+// Выдаёт токены и отвечает LoginResponse
+func (h *AuthHandler) writeLoginResponse(w http.ResponseWriter, r *http.Request, user *db.User) {
+	resp, err := h.loginResponse(r, user)
+	if err != nil {
+		http.Error(w, "Ошибка выдачи токенов", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
 
-	resp := LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+func (h *AuthHandler) loginResponse(r *http.Request, user *db.User) (*LoginResponse, error) {
+	tokens, err := h.auth.IssueTokens(r.Context(), user, "")
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 		User: UserResponse{
-			ID:        int64(user.ID),
-			Username:  user.Username,
-			Email:     user.Email,
-			Phone:     user.Phone,
-			Role:      user.Role.String,
-			FirstName: user.FirstName.String,
-			LastName:  user.LastName.String,
+			ID:            int64(user.ID),
+			Username:      user.Username,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			Phone:         user.Phone,
+			Role:          user.Role.String,
+			FirstName:     user.FirstName.String,
+			LastName:      user.LastName.String,
 		},
+	}, nil
+}
+
+// Приводит номер из запроса к E.164. false — номер некорректен, ответ 400 уже отправлен.
+func normalizePhone(w http.ResponseWriter, p *string) bool {
+	n, err := phone.Normalize(*p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
 	}
+	*p = n
+	return true
+}
 
+// Отвечает 400 со списком нарушенных правил, если err — *password.PolicyError.
+// false — это другая ошибка, ответ не отправлен.
+func writePasswordPolicyError(w http.ResponseWriter, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(PasswordPolicyErrorResponse{
+		Error:      policyErr.Error(),
+		Violations: policyErr.Violations,
+	})
+	return true
+}
+
+func writeLoginError(w http.ResponseWriter, err error) {
+	var lockErr *LockoutError
+	switch {
+	case errors.As(err, &lockErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockErr.RetryAfter().Seconds()))))
+		if lockErr.Locked {
+			http.Error(w, "Аккаунт временно заблокирован. Снимите блокировку кодом из SMS или попробуйте позже", http.StatusLocked)
+			return
+		}
+		http.Error(w, lockErr.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, ErrInvalidCredentials):
+		http.Error(w, "Неверный телефон или пароль", http.StatusUnauthorized)
+	case errors.Is(err, ErrInvalidLoginCode), errors.Is(err, verification.ErrTooManyAttempts):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
+
+// RequestUnlockCode godoc
+// @Summary Запросить код для снятия блокировки входа
+// @Description Отправляет SMS-код, которым можно снять блокировку аккаунта после неудачных попыток входа
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body RequestPhoneVerificationRequest true "Номер телефона"
+// @Success 200 "Код отправлен"
+// @Failure 400 {string} string "Номер не найден"
+// @Router /auth/unlock/request-code [post]
+func (h *AuthHandler) RequestUnlockCode(w http.ResponseWriter, r *http.Request) {
+	var req RequestPhoneVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
+	if err := h.auth.RequestUnlockCode(r.Context(), req.Phone); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Unlock godoc
+// @Summary Снять блокировку входа
+// @Description Проверяет SMS-код и сбрасывает счётчик неудачных входов по номеру
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body VerifyPhoneRequest true "Телефон и код"
+// @Success 200 "Блокировка снята"
+// @Failure 400 {string} string "Неверный или истёкший код"
+// @Router /auth/unlock [post]
+func (h *AuthHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	var req VerifyPhoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
+	if req.Phone == "" || req.Code == "" {
+		http.Error(w, "Требуются поля phone и code", http.StatusBadRequest)
+		return
+	}
+	if err := h.auth.UnlockByPhone(r.Context(), req.Phone, req.Code); err != nil {
+		http.Error(w, "Неверный или истёкший код", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 // ChangePassword godoc
-// @Summary Смена пароля по телефону
-// @Description Смена пароля с проверкой старого пароля
+// @Summary Смена пароля
+// @Description Смена пароля текущего пользователя с проверкой старого пароля. Неверный старый пароль считается неудачной попыткой входа.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param input body ChangePasswordRequest true "Данные для смены пароля"
+// @Param input body ChangePasswordRequest true "Старый и новый пароль"
 // @Success 200 "Пароль успешно изменён"
-// @Failure 400 {string} string "Ошибка смены пароля"
+// @Failure 400 {object} PasswordPolicyErrorResponse "Новый пароль не соответствует политике"
+// @Failure 401 {string} string "Неверный пароль"
+// @Failure 423 {string} string "Аккаунт временно заблокирован, см. Retry-After"
+// @Failure 429 {string} string "Слишком много попыток, см. Retry-After"
+// @Security BearerAuth
 // @Router /auth/change-password [post]
 func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 	var req ChangePasswordRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
 		return
 	}
-	if req.Phone == "" {
-		http.Error(w, "Требуется поле phone", http.StatusBadRequest)
-		return
-	}
 
-	if err := h.auth.ChangePasswordByPhone(r.Context(), req.Phone, req.OldPassword, req.NewPassword); err != nil {
-		http.Error(w, "Ошибка смены пароля: "+err.Error(), http.StatusBadRequest)
-		return
+	err := h.auth.ChangePassword(r.Context(), userID, req.OldPassword, req.NewPassword, middleware.ClientIP(r))
+	var lockErr *LockoutError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case writePasswordPolicyError(w, err):
+	case errors.As(err, &lockErr):
+		writeLoginError(w, err)
+	case errors.Is(err, ErrInvalidOldPassword):
+		http.Error(w, "Неверный пароль", http.StatusUnauthorized)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 	}
-
-	w.WriteHeader(http.StatusOK)
 }
 
 // RequestRegistrationCode godoc
@@ -182,6 +386,9 @@ func (h *AuthHandler) RequestRegistrationCode(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
 		return
 	}
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
 
 	// Проверяем, что номер ещё не занят
 	taken, err := h.auth.IsPhoneTaken(r.Context(), req.Phone)
@@ -219,6 +426,9 @@ func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
 		return
 	}
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
 
 	// Отправляем код сброса, или 400 если номер не в базе
 	if err := h.auth.RequestPasswordResetByPhone(r.Context(), req.Phone); err != nil {
@@ -231,12 +441,12 @@ func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 
 // VerifyPhone godoc
 // @Summary Подтвердить номер телефона
-// @Description Проверка кода, отправленного на телефон (регистрация/восстановление)
+// @Description Проверка кода, отправленного на телефон. Поле purpose указывает сценарий (register по умолчанию, reset, change_phone, unlock, mfa_enroll) — код другого сценария не подойдёт. После нескольких неверных попыток код аннулируется.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param input body VerifyPhoneRequest true "Телефон и код"
-// @Success 200 "Код успешно подтверждён"
+// @Success 200 {object} VerifyPhoneResponse "Код подтверждён, выдан одноразовый билет для следующего шага"
 // @Failure 400 {string} string "Неверный или истёкший код"
 // @Router /auth/verify-phone [post]
 func (h *AuthHandler) VerifyPhone(w http.ResponseWriter, r *http.Request) {
@@ -245,28 +455,55 @@ func (h *AuthHandler) VerifyPhone(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
 		return
 	}
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
 	if req.Phone == "" || req.Code == "" {
 		http.Error(w, "Требуются поля phone и code", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.auth.VerifyPhoneCode(r.Context(), req.Phone, req.Code); err != nil {
-		http.Error(w, "Неверный или истёкший код", http.StatusBadRequest)
+	if req.Purpose == "" {
+		req.Purpose = string(verification.PurposeRegister)
+	}
+	purpose, err := verification.ParsePurpose(req.Purpose)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Код входа обменивается только в /auth/login/sms, билет для него не выдаётся
+	if purpose == verification.PurposeLogin {
+		http.Error(w, verification.ErrInvalidPurpose.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	resp, err := h.auth.VerifyPhoneCode(r.Context(), req.Phone, purpose, req.Code)
+	if err != nil {
+		if errors.Is(err, verification.ErrTooManyAttempts) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, verification.ErrInvalidCode) {
+			http.Error(w, "Неверный или истёкший код", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 // ResetPassword godoc
 // @Summary Сброс пароля по телефону
-// @Description Установка нового пароля по телефону после проверки кода
+// @Description Установка нового пароля по телефону. Требуется verification_ticket из /auth/verify-phone с purpose=reset для этого номера.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param input body ResetPasswordRequest true "Телефон и новый пароль"
 // @Success 200 "Пароль успешно сброшен"
-// @Failure 400 {string} string "Ошибка сброса пароля"
+// @Failure 400 {object} PasswordPolicyErrorResponse "Новый пароль не соответствует политике; иначе — текст ошибки"
 // @Router /auth/reset-password [post]
 func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	var req ResetPasswordRequest
@@ -274,8 +511,14 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
 		return
 	}
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
 
-	if err := h.auth.ResetPasswordByPhone(r.Context(), req.Phone, req.NewPassword); err != nil {
+	if err := h.auth.ResetPasswordByPhone(r.Context(), req.Phone, req.NewPassword, req.VerificationTicket); err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
 		http.Error(w, "Ошибка сброса пароля: "+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -307,8 +550,14 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userID, err := claims.UserID()
+	if err != nil {
+		http.Error(w, "Неверный refresh токен", http.StatusUnauthorized)
+		return
+	}
+
 	rt, err := h.auth.GetRefreshToken(r.Context(), req.RefreshToken)
-	if err != nil || rt.ExpiresAt.Time.Before(time.Now()) || int64(rt.UserID) != claims.UserID {
+	if err != nil || rt.ExpiresAt.Time.Before(time.Now()) || int64(rt.UserID) != userID {
 		http.Error(w, "Refresh токен не найден или истёк", http.StatusUnauthorized)
 		return
 	}
@@ -318,33 +567,25 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, jti, err := jwt.GenerateAccessToken(claims.UserID)
+	// Роль и список квартир могли измениться — перечитываем пользователя
+	user, err := h.auth.GetUserByID(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "Ошибка генерации access token", http.StatusInternalServerError)
+		http.Error(w, "Пользователь не найден", http.StatusUnauthorized)
 		return
 	}
-	newRefreshToken, err := jwt.GenerateRefreshToken(claims.UserID, jti)
+
+	resp, err := h.auth.IssueTokens(r.Context(), user, claims.SessionID)
 	if err != nil {
-		http.Error(w, "Ошибка генерации refresh token", http.StatusInternalServerError)
-		return
-	}
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
-	if err := h.auth.SaveRefreshToken(r.Context(), claims.UserID, newRefreshToken, jti, expiresAt); err != nil {
-		http.Error(w, "Ошибка сохранения refresh токена", http.StatusInternalServerError)
+		http.Error(w, "Ошибка выдачи токенов", http.StatusInternalServerError)
 		return
 	}
-
-	resp := RefreshResponse{
-		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
 // Logout godoc
 // @Summary Выйти из системы (logout)
-// @Description Удаляет refresh токен из БД, делая его невалидным. После этого все access токены с этим refresh станут неактуальны.
+// @Description Удаляет refresh токен из БД, делая его невалидным. После этого все access токены с этим refresh станут неактуальны. Push-уведомления на устройство этой сессии больше не приходят.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -358,9 +599,479 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
 		return
 	}
-	if err := h.auth.DeleteRefreshToken(r.Context(), req.RefreshToken); err != nil {
+	if err := h.auth.Logout(r.Context(), req.RefreshToken); err != nil {
 		http.Error(w, "Ошибка выхода", http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
+
+// Страница по ссылке из письма. Сам переход ничего не подтверждает: ссылки
+// открывают и почтовые сканеры, и предпросмотр — подтверждает только кнопка.
+var confirmEmailPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Подтверждение email</title></head>
+<body>
+<form method="post" action="/auth/email/confirm">
+<input type="hidden" name="token" value="{{.}}">
+<p>Нажмите кнопку, чтобы подтвердить адрес электронной почты.</p>
+<button type="submit">Подтвердить email</button>
+</form>
+</body>
+</html>
+`))
+
+// ConfirmEmailPage godoc
+// @Summary Страница подтверждения email
+// @Description Переход по ссылке из письма. Отдаёт страницу с кнопкой, которая отправляет токен в POST /auth/email/confirm; сам GET ничего не меняет.
+// @Tags auth
+// @Produce html
+// @Param token query string true "Токен из ссылки"
+// @Success 200 {string} string "Страница с кнопкой подтверждения"
+// @Router /auth/email/confirm [get]
+func (h *AuthHandler) ConfirmEmailPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	confirmEmailPage.Execute(w, r.URL.Query().Get("token"))
+}
+
+// ConfirmEmail godoc
+// @Summary Подтвердить email
+// @Description Отправка формы со страницы подтверждения. Адрес из ссылки становится основным и помечается подтверждённым.
+// @Tags auth
+// @Accept x-www-form-urlencoded
+// @Produce plain
+// @Param token formData string true "Токен из ссылки"
+// @Success 200 {string} string "Email подтверждён"
+// @Failure 400 {string} string "Ссылка недействительна или устарела"
+// @Failure 409 {string} string "Адрес уже занят другим пользователем"
+// @Router /auth/email/confirm [post]
+func (h *AuthHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	err := h.auth.ConfirmEmail(r.Context(), r.PostFormValue("token"))
+	switch {
+	case err == nil:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("Email подтверждён"))
+	case errors.Is(err, ErrInvalidEmailToken):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrEmailTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Не удалось подтвердить email", http.StatusInternalServerError)
+	}
+}
+
+// ForgotPasswordEmail godoc
+// @Summary Запросить сброс пароля по email
+// @Description Отправляет ссылку для сброса пароля, если адрес зарегистрирован и подтверждён. Ответ не зависит от того, найден ли адрес.
+// @Tags auth
+// @Accept json
+// @Param input body ForgotPasswordEmailRequest true "Email"
+// @Success 202 "Запрос принят"
+// @Failure 400 {string} string "Некорректный email"
+// @Router /auth/email/forgot-password [post]
+func (h *AuthHandler) ForgotPasswordEmail(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if !strings.Contains(req.Email, "@") {
+		http.Error(w, "Некорректный email", http.StatusBadRequest)
+		return
+	}
+	if err := h.auth.RequestPasswordResetByEmail(r.Context(), req.Email); err != nil {
+		http.Error(w, "Не удалось отправить письмо", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPasswordEmail godoc
+// @Summary Сброс пароля по ссылке из письма
+// @Description Устанавливает новый пароль по токену из письма. Все активные сессии пользователя завершаются.
+// @Tags auth
+// @Accept json
+// @Param input body ResetPasswordEmailRequest true "Токен и новый пароль"
+// @Success 200 "Пароль изменён"
+// @Failure 400 {string} string "Ссылка недействительна или устарела"
+// @Router /auth/email/reset-password [post]
+func (h *AuthHandler) ResetPasswordEmail(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.auth.ResetPasswordByEmail(r.Context(), req.Token, req.NewPassword); err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		if errors.Is(err, ErrInvalidResetToken) {
+			http.Error(w, ErrInvalidEmailToken.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Не удалось сменить пароль", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RequestNewPhoneCode godoc
+// @Summary Запросить код на новый номер
+// @Description Первый шаг смены номера: отправляет SMS-код на новый номер. Код подтверждается через /auth/verify-phone с purpose=change_phone.
+// @Tags auth
+// @Accept json
+// @Param input body RequestPhoneVerificationRequest true "Новый номер"
+// @Success 200 "Код отправлен"
+// @Failure 400 {string} string "Номер некорректен, занят или совпадает с текущим"
+// @Failure 429 {string} string "Код уже отправлен, попробуйте позже"
+// @Security BearerAuth
+// @Router /auth/change-phone/request-code [post]
+func (h *AuthHandler) RequestNewPhoneCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req RequestPhoneVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if !normalizePhone(w, &req.Phone) {
+		return
+	}
+	writeChangePhoneError(w, h.auth.RequestNewPhoneCode(r.Context(), userID, req.Phone))
+}
+
+// RequestCurrentPhoneCode godoc
+// @Summary Запросить код на текущий номер
+// @Description Для подтверждения текущего номера при смене без пароля. Код подтверждается через /auth/verify-phone с purpose=change_phone.
+// @Tags auth
+// @Success 200 "Код отправлен"
+// @Failure 429 {string} string "Код уже отправлен, попробуйте позже"
+// @Security BearerAuth
+// @Router /auth/change-phone/request-current-code [post]
+func (h *AuthHandler) RequestCurrentPhoneCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	writeChangePhoneError(w, h.auth.RequestCurrentPhoneCode(r.Context(), userID))
+}
+
+// ChangePhone godoc
+// @Summary Сменить номер телефона
+// @Description Меняет номер для входа. Нужен билет подтверждения нового номера и подтверждение текущего: билет для него или пароль. Все сессии завершаются, нужно войти заново.
+// @Tags auth
+// @Accept json
+// @Param input body ChangePhoneRequest true "Новый номер и подтверждения"
+// @Success 200 "Номер изменён"
+// @Failure 400 {string} string "Номер не подтверждён или некорректен"
+// @Failure 401 {string} string "Неверный пароль"
+// @Failure 409 {string} string "Номер занят"
+// @Security BearerAuth
+// @Router /auth/change-phone [post]
+func (h *AuthHandler) ChangePhone(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req ChangePhoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if !normalizePhone(w, &req.NewPhone) {
+		return
+	}
+	err := h.auth.ChangePhone(r.Context(), userID, req.NewPhone, req.NewPhoneTicket, req.OldPhoneTicket, req.Password, middleware.ClientIP(r))
+	writeChangePhoneError(w, err)
+}
+
+func writeChangePhoneError(w http.ResponseWriter, err error) {
+	var lockErr *LockoutError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.As(err, &lockErr):
+		writeLoginError(w, err)
+	case errors.Is(err, verification.ErrResendTooSoon), errors.Is(err, verification.ErrTooManyAttempts):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, ErrInvalidOldPassword):
+		http.Error(w, "Неверный пароль", http.StatusUnauthorized)
+	case errors.Is(err, ErrPhoneTaken), errors.Is(err, ErrPhoneChangedConcurrently):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrPhoneUnchanged), errors.Is(err, ErrInvalidTicket), errors.Is(err, ErrOldPhoneNotConfirmed):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
+
+// LoginMFA godoc
+// @Summary Второй шаг входа
+// @Description Принимает mfa_token из ответа /auth/login и код из приложения-аутентификатора или код восстановления. Возвращает пару токенов.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body MFALoginRequest true "Токен второго шага и код"
+// @Success 200 {object} LoginResponse "Пользователь авторизован, токены выданы"
+// @Failure 401 {string} string "Неверный код или токен второго шага"
+// @Failure 429 {string} string "Слишком много попыток, см. Retry-After"
+// @Router /auth/login/mfa [post]
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	user, err := h.auth.CompleteLoginMFA(r.Context(), req.MFAToken, req.Code, req.RecoveryCode)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	h.writeLoginResponse(w, r, user)
+}
+
+// LoginMFAEnrollRequestCode godoc
+// @Summary Запросить код для подключения второго фактора при входе
+// @Description По mfa_token со stage=enroll отправляет SMS-код на номер пользователя. Код подтверждается через /auth/verify-phone с purpose=mfa_enroll.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body MFATokenRequest true "Токен второго шага"
+// @Success 200 "Код отправлен"
+// @Failure 401 {string} string "Токен второго шага недействителен"
+// @Failure 429 {string} string "Код уже отправлен, попробуйте позже"
+// @Router /auth/login/mfa/enroll/request-code [post]
+func (h *AuthHandler) LoginMFAEnrollRequestCode(w http.ResponseWriter, r *http.Request) {
+	var req MFATokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.auth.RequestLoginMFAEnrollCode(r.Context(), req.MFAToken); err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// LoginMFAEnroll godoc
+// @Summary Подключить второй фактор при входе
+// @Description Для ролей, где второй фактор обязателен: по mfa_token со stage=enroll и verification_ticket из /auth/verify-phone (purpose=mfa_enroll) выдаёт секрет и otpauth:// URI для QR-кода.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body MFAEnrollStartRequest true "Токен второго шага и билет подтверждения номера"
+// @Success 200 {object} MFAEnrollResponse
+// @Failure 400 {string} string "Номер не подтверждён или подтверждение уже использовано"
+// @Failure 401 {string} string "Токен второго шага недействителен"
+// @Router /auth/login/mfa/enroll [post]
+func (h *AuthHandler) LoginMFAEnroll(w http.ResponseWriter, r *http.Request) {
+	var req MFAEnrollStartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	resp, err := h.auth.BeginLoginMFAEnrollment(r.Context(), req.MFAToken, req.VerificationTicket)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// LoginMFAEnrollConfirm godoc
+// @Summary Завершить подключение второго фактора при входе
+// @Description Принимает первый код из приложения, включает второй фактор и выдаёт токены вместе с кодами восстановления.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body MFATokenRequest true "Токен второго шага и код"
+// @Success 200 {object} MFAEnrollLoginResponse
+// @Failure 401 {string} string "Неверный код или токен второго шага"
+// @Router /auth/login/mfa/enroll/confirm [post]
+func (h *AuthHandler) LoginMFAEnrollConfirm(w http.ResponseWriter, r *http.Request) {
+	var req MFATokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	user, codes, err := h.auth.CompleteLoginMFAEnrollment(r.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	login, err := h.loginResponse(r, user)
+	if err != nil {
+		http.Error(w, "Ошибка выдачи токенов", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFAEnrollLoginResponse{LoginResponse: *login, RecoveryCodes: codes})
+}
+
+// MFAStatus godoc
+// @Summary Состояние второго фактора
+// @Tags auth
+// @Produce json
+// @Success 200 {object} MFAStatusResponse
+// @Security BearerAuth
+// @Router /auth/mfa [get]
+func (h *AuthHandler) MFAStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	resp, err := h.auth.MFAStatus(r.Context(), userID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// EnrollMFA godoc
+// @Summary Подключить второй фактор
+// @Description Выдаёт новый секрет и otpauth:// URI для QR-кода. Второй фактор включится после /auth/mfa/enroll/confirm.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} MFAEnrollResponse
+// @Failure 409 {string} string "Второй фактор уже подключён"
+// @Security BearerAuth
+// @Router /auth/mfa/enroll [post]
+func (h *AuthHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	resp, err := h.auth.BeginMFAEnrollment(r.Context(), userID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ConfirmMFAEnrollment godoc
+// @Summary Завершить подключение второго фактора
+// @Description Принимает первый код из приложения. Коды восстановления показываются только в этом ответе.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body MFACodeRequest true "Код из приложения"
+// @Success 200 {object} MFARecoveryCodesResponse
+// @Failure 401 {string} string "Неверный код"
+// @Security BearerAuth
+// @Router /auth/mfa/enroll/confirm [post]
+func (h *AuthHandler) ConfirmMFAEnrollment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	codes, err := h.auth.ConfirmMFAEnrollment(r.Context(), userID, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Новые коды восстановления
+// @Description Заменяет все коды восстановления новыми. Нужен код из приложения.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body MFACodeRequest true "Код из приложения"
+// @Success 200 {object} MFARecoveryCodesResponse
+// @Failure 401 {string} string "Неверный код"
+// @Security BearerAuth
+// @Router /auth/mfa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	codes, err := h.auth.RegenerateRecoveryCodes(r.Context(), userID, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableMFA godoc
+// @Summary Отключить второй фактор
+// @Description Нужен код из приложения или код восстановления. Для администраторов и монтажников отключение запрещено.
+// @Tags auth
+// @Accept json
+// @Param input body MFACodeRequest true "Код"
+// @Success 200 "Второй фактор отключён"
+// @Failure 401 {string} string "Неверный код"
+// @Failure 403 {string} string "Для роли второй фактор обязателен"
+// @Security BearerAuth
+// @Router /auth/mfa/disable [post]
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.auth.DisableMFA(r.Context(), userID, req.Code, req.RecoveryCode); err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeMFAError(w http.ResponseWriter, err error) {
+	var lockErr *LockoutError
+	switch {
+	case errors.As(err, &lockErr):
+		writeLoginError(w, err)
+	case errors.Is(err, ErrInvalidMFACode), errors.Is(err, ErrInvalidMFAToken):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrMFARequired):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrMFAAlreadyEnabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrMFANotEnabled), errors.Is(err, ErrInvalidTicket):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, verification.ErrResendTooSoon):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, ErrMFANotConfigured):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
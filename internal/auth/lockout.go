@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"domofon/internal/config"
+	"domofon/internal/db"
+	"domofon/internal/verification"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Типы событий в таблице events
+const (
+	EventLoginFailed     = "login_failed"
+	EventAccountLocked   = "account_locked"
+	EventAccountUnlocked = "account_unlocked"
+)
+
+var ErrInvalidCredentials = errors.New("неверный телефон или пароль")
+
+// Вход временно запрещён: либо задержка после нескольких неудач,
+// либо блокировка аккаунта/адреса
+type LockoutError struct {
+	Until  time.Time
+	Locked bool
+}
+
+func (e *LockoutError) Error() string {
+	if e.Locked {
+		return "аккаунт временно заблокирован"
+	}
+	return "слишком много попыток входа, попробуйте позже"
+}
+
+func (e *LockoutError) RetryAfter() time.Duration {
+	d := time.Until(e.Until)
+	if d < time.Second {
+		return time.Second
+	}
+	return d
+}
+
+func phoneAttemptKey(phone string) string { return "phone:" + phone }
+func ipAttemptKey(ip string) string       { return "ip:" + ip }
+
+// Задержка перед следующей попыткой: base * 2^(n - BackoffAfter), не больше BackoffMax
+func backoffDelay(cfg config.LockoutConfig, failures int) time.Duration {
+	if cfg.BackoffAfter <= 0 || failures < cfg.BackoffAfter {
+		return 0
+	}
+	delay := cfg.BackoffBase
+	for i := cfg.BackoffAfter; i < failures; i++ {
+		delay *= 2
+		if delay >= cfg.BackoffMax {
+			return cfg.BackoffMax
+		}
+	}
+	return delay
+}
+
+// Проверяет, можно ли сейчас пытаться войти по этому ключу
+func (s *AuthService) checkLockout(ctx context.Context, key string) error {
+	a, err := s.repo.GetLoginAttempt(ctx, key)
+	if err != nil || a == nil {
+		return err
+	}
+	now := time.Now()
+	if a.LockedUntil.Valid && a.LockedUntil.Time.After(now) {
+		return &LockoutError{Until: a.LockedUntil.Time, Locked: true}
+	}
+	if now.Sub(a.LastFailedAt.Time) > s.lockout.Window {
+		return nil
+	}
+	next := a.LastFailedAt.Time.Add(backoffDelay(s.lockout, int(a.FailedCount)))
+	if next.After(now) {
+		return &LockoutError{Until: next}
+	}
+	return nil
+}
+
+// Учитывает неудачную попытку по телефону и IP, при превышении порогов блокирует
+func (s *AuthService) registerLoginFailure(ctx context.Context, phone, ip string, user *db.User) {
+	now := time.Now()
+	windowStart := now.Add(-s.lockout.Window)
+
+	var userID int64
+	if user != nil {
+		userID = int64(user.ID)
+	}
+	s.logEvent(ctx, EventLoginFailed, userID, fmt.Sprintf("phone=%s ip=%s", phone, ip))
+
+	a, err := s.repo.RegisterLoginFailure(ctx, phoneAttemptKey(phone), now, windowStart)
+	if err != nil {
+		log.Error().Err(err).Str("phone", phone).Msg("[auth] Не удалось учесть неудачный вход")
+	} else if s.lockout.LockAfter > 0 && int(a.FailedCount) >= s.lockout.LockAfter {
+		until := now.Add(s.lockout.LockDuration)
+		if err := s.repo.LockLoginAttempt(ctx, a.AttemptKey, until); err != nil {
+			log.Error().Err(err).Str("phone", phone).Msg("[auth] Не удалось заблокировать вход")
+		}
+		s.logEvent(ctx, EventAccountLocked, userID, fmt.Sprintf("phone=%s until=%s", phone, until.Format(time.RFC3339)))
+	}
+
+	if ip == "" {
+		return
+	}
+	a, err = s.repo.RegisterLoginFailure(ctx, ipAttemptKey(ip), now, windowStart)
+	if err != nil {
+		log.Error().Err(err).Str("ip", ip).Msg("[auth] Не удалось учесть неудачный вход")
+	} else if s.lockout.IPLockAfter > 0 && int(a.FailedCount) >= s.lockout.IPLockAfter {
+		until := now.Add(s.lockout.LockDuration)
+		if err := s.repo.LockLoginAttempt(ctx, a.AttemptKey, until); err != nil {
+			log.Error().Err(err).Str("ip", ip).Msg("[auth] Не удалось заблокировать вход")
+		}
+		s.logEvent(ctx, EventAccountLocked, 0, fmt.Sprintf("ip=%s until=%s", ip, until.Format(time.RFC3339)))
+	}
+}
+
+func (s *AuthService) logEvent(ctx context.Context, eventType string, userID int64, description string) {
+	if err := s.repo.CreateEvent(ctx, eventType, userID, description); err != nil {
+		log.Error().Err(err).Str("event", eventType).Msg("[auth] Не удалось записать событие")
+	}
+}
+
+// Отправка SMS-кода для снятия блокировки входа
+func (s *AuthService) RequestUnlockCode(ctx context.Context, phone string) error {
+	user, err := s.repo.GetUserByPhone(ctx, phone)
+	if err != nil || user == nil {
+		return errors.New("номер не найден")
+	}
+	return s.verification.SendVerificationCode(ctx, phone, verification.PurposeUnlock)
+}
+
+// Снятие блокировки входа по коду из SMS. Блокировка по IP не снимается.
+func (s *AuthService) UnlockByPhone(ctx context.Context, phone, code string) error {
+	user, err := s.repo.GetUserByPhone(ctx, phone)
+	if err != nil || user == nil {
+		return errors.New("номер не найден")
+	}
+	if err := s.verification.VerifyCode(ctx, phone, verification.PurposeUnlock, code); err != nil {
+		return err
+	}
+	if err := s.repo.ResetLoginAttempts(ctx, phoneAttemptKey(phone)); err != nil {
+		return err
+	}
+	s.logEvent(ctx, EventAccountUnlocked, int64(user.ID), "phone="+phone)
+	return nil
+}
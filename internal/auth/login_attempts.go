@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"domofon/internal/db"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Счётчик неудач; nil, если неудач не было
+func (r *AuthRepository) GetLoginAttempt(ctx context.Context, key string) (*db.LoginAttempt, error) {
+	a, err := r.queries.GetLoginAttempt(ctx, key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AuthRepository) RegisterLoginFailure(ctx context.Context, key string, now, windowStart time.Time) (*db.LoginAttempt, error) {
+	a, err := r.queries.RegisterLoginFailure(ctx, db.RegisterLoginFailureParams{
+		AttemptKey:   key,
+		LastFailedAt: pgtype.Timestamp{Time: now, Valid: true},
+		WindowStart:  pgtype.Timestamp{Time: windowStart, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AuthRepository) LockLoginAttempt(ctx context.Context, key string, until time.Time) error {
+	return r.queries.LockLoginAttempt(ctx, db.LockLoginAttemptParams{
+		AttemptKey:  key,
+		LockedUntil: pgtype.Timestamp{Time: until, Valid: true},
+	})
+}
+
+func (r *AuthRepository) ResetLoginAttempts(ctx context.Context, key string) error {
+	return r.queries.ResetLoginAttempts(ctx, key)
+}
+
+// Запись в журнал событий. userID = 0 — событие без пользователя.
+func (r *AuthRepository) CreateEvent(ctx context.Context, eventType string, userID int64, description string) error {
+	return r.queries.CreateEvent(ctx, db.CreateEventParams{
+		EventType:   eventType,
+		UserID:      pgtype.Int4{Int32: int32(userID), Valid: userID != 0},
+		Description: pgtype.Text{String: description, Valid: description != ""},
+	})
+}
@@ -0,0 +1,406 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"domofon/internal/db"
+	"domofon/internal/jwt"
+	"domofon/internal/totp"
+	"domofon/internal/verification"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	EventMFAEnabled          = "mfa_enabled"
+	EventMFADisabled         = "mfa_disabled"
+	EventMFARecoveryCodeUsed = "mfa_recovery_code_used"
+)
+
+var (
+	ErrMFANotConfigured  = errors.New("второй фактор не настроен на сервере")
+	ErrMFAAlreadyEnabled = errors.New("второй фактор уже подключён")
+	ErrMFANotEnabled     = errors.New("второй фактор не подключён")
+	ErrMFARequired       = errors.New("для этой роли второй фактор обязателен")
+	ErrInvalidMFACode    = errors.New("неверный код")
+	ErrInvalidMFAToken   = errors.New("токен второго шага недействителен или истёк")
+)
+
+func mfaAttemptKey(userID int64) string { return "mfa:" + strconv.FormatInt(userID, 10) }
+
+func (s *AuthService) mfaRequired(role string) bool {
+	return slices.Contains(s.mfa.RequiredRoles, role)
+}
+
+// Нужен ли второй шаг входа. nil — не нужен, можно выдавать токены.
+func (s *AuthService) BeginLoginMFA(ctx context.Context, user *db.User) (*MFAChallengeResponse, error) {
+	m, err := s.repo.GetUserMFA(ctx, int64(user.ID))
+	if err != nil {
+		return nil, err
+	}
+	stage := ""
+	switch {
+	case m != nil && m.Enabled:
+		stage = jwt.MFAStageVerify
+	case s.mfaRequired(user.Role.String):
+		stage = jwt.MFAStageEnroll
+	default:
+		return nil, nil
+	}
+
+	token, claims, err := jwt.GenerateMFAToken(int64(user.ID), stage, s.mfa.ChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallengeResponse{
+		MFARequired: true,
+		Stage:       stage,
+		MFAToken:    token,
+		ExpiresAt:   claims.ExpiresAt.Time,
+	}, nil
+}
+
+// Пользователь из токена второго шага входа
+func (s *AuthService) MFATokenUser(token, stage string) (int64, error) {
+	claims, err := jwt.ParseMFAToken(token)
+	if err != nil || claims.Stage != stage {
+		return 0, ErrInvalidMFAToken
+	}
+	return claims.UserID()
+}
+
+// Второй шаг входа: код из приложения или код восстановления
+func (s *AuthService) CompleteLoginMFA(ctx context.Context, mfaToken, code, recoveryCode string) (*db.User, error) {
+	userID, err := s.MFATokenUser(mfaToken, jwt.MFAStageVerify)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSecondFactor(ctx, userID, code, recoveryCode); err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, userID)
+}
+
+// Подключение второго фактора по токену со stage=enroll: после него
+// пользователь входит без повторного ввода пароля
+func (s *AuthService) CompleteLoginMFAEnrollment(ctx context.Context, mfaToken, code string) (*db.User, []string, error) {
+	userID, err := s.MFATokenUser(mfaToken, jwt.MFAStageEnroll)
+	if err != nil {
+		return nil, nil, err
+	}
+	codes, err := s.ConfirmMFAEnrollment(ctx, userID, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, codes, nil
+}
+
+// Код на номер пользователя перед подключением второго фактора при входе.
+// Одного пароля мало: иначе укравший его привяжет к аккаунту своё приложение.
+func (s *AuthService) RequestLoginMFAEnrollCode(ctx context.Context, mfaToken string) error {
+	userID, err := s.MFATokenUser(mfaToken, jwt.MFAStageEnroll)
+	if err != nil {
+		return err
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.verification.SendVerificationCode(ctx, user.Phone, verification.PurposeMFAEnroll)
+}
+
+// Подключение второго фактора при входе: кроме токена со stage=enroll нужен
+// билет /auth/verify-phone (purpose=mfa_enroll) для номера пользователя
+func (s *AuthService) BeginLoginMFAEnrollment(ctx context.Context, mfaToken, ticket string) (*MFAEnrollResponse, error) {
+	userID, err := s.MFATokenUser(mfaToken, jwt.MFAStageEnroll)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.mfa.SecretKey) == 0 {
+		return nil, ErrMFANotConfigured
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.parseTicket(ticket, user.Phone, verification.PurposeMFAEnroll)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.consumeTicket(ctx, claims); err != nil {
+		return nil, err
+	}
+	return s.BeginMFAEnrollment(ctx, userID)
+}
+
+// Начало подключения: новый секрет и URI для QR-кода.
+// Второй фактор включится после ConfirmMFAEnrollment.
+func (s *AuthService) BeginMFAEnrollment(ctx context.Context, userID int64) (*MFAEnrollResponse, error) {
+	if len(s.mfa.SecretKey) == 0 {
+		return nil, ErrMFANotConfigured
+	}
+	m, err := s.repo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil && m.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.sealSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.StartMFAEnrollment(ctx, userID, sealed); err != nil {
+		return nil, err
+	}
+	return &MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.ProvisioningURI(s.mfa.Issuer, user.Phone, secret),
+	}, nil
+}
+
+// Завершение подключения: первый код из приложения. Возвращает коды
+// восстановления — они показываются один раз.
+func (s *AuthService) ConfirmMFAEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+	m, err := s.repo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMFANotEnabled
+	}
+	if m.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.checkLockout(ctx, mfaAttemptKey(userID)); err != nil {
+		return nil, err
+	}
+	secret, err := s.openSecret(m.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		s.registerMFAFailure(ctx, userID)
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableMFA(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	s.resetMFAFailures(ctx, userID)
+	s.logEvent(ctx, EventMFAEnabled, userID, "")
+	return codes, nil
+}
+
+// Новые коды восстановления взамен старых; нужен код из приложения
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	if err := s.checkSecondFactor(ctx, userID, code, ""); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Отключение второго фактора. Для ролей из MFA_REQUIRED_ROLES запрещено.
+func (s *AuthService) DisableMFA(ctx context.Context, userID int64, code, recoveryCode string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if s.mfaRequired(user.Role.String) {
+		return ErrMFARequired
+	}
+	if err := s.checkSecondFactor(ctx, userID, code, recoveryCode); err != nil {
+		return err
+	}
+	if err := s.repo.DisableMFA(ctx, userID); err != nil {
+		return err
+	}
+	s.logEvent(ctx, EventMFADisabled, userID, "")
+	return nil
+}
+
+func (s *AuthService) MFAStatus(ctx context.Context, userID int64) (*MFAStatusResponse, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.repo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &MFAStatusResponse{
+		Enabled:  m != nil && m.Enabled,
+		Required: s.mfaRequired(user.Role.String),
+	}
+	if resp.Enabled {
+		if resp.RecoveryCodesLeft, err = s.repo.CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// Проверка второго фактора с защитой от перебора: неудачи считаются
+// по ключу mfa:<id> с теми же порогами, что и вход по паролю
+func (s *AuthService) checkSecondFactor(ctx context.Context, userID int64, code, recoveryCode string) error {
+	m, err := s.repo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if m == nil || !m.Enabled {
+		return ErrMFANotEnabled
+	}
+	if err := s.checkLockout(ctx, mfaAttemptKey(userID)); err != nil {
+		return err
+	}
+
+	ok, err := s.verifySecondFactor(ctx, userID, m, code, recoveryCode)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.registerMFAFailure(ctx, userID)
+		return ErrInvalidMFACode
+	}
+	s.resetMFAFailures(ctx, userID)
+	return nil
+}
+
+func (s *AuthService) verifySecondFactor(ctx context.Context, userID int64, m *db.UserMfa, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		ok, err := s.repo.UseRecoveryCode(ctx, userID, hashLinkToken(normalizeRecoveryCode(recoveryCode)))
+		if err != nil || !ok {
+			return false, err
+		}
+		s.logEvent(ctx, EventMFARecoveryCodeUsed, userID, "")
+		return true, nil
+	}
+
+	secret, err := s.openSecret(m.Secret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	// Один и тот же код дважды не принимается
+	return s.repo.UseMFAStep(ctx, userID, step)
+}
+
+func (s *AuthService) registerMFAFailure(ctx context.Context, userID int64) {
+	now := time.Now()
+	a, err := s.repo.RegisterLoginFailure(ctx, mfaAttemptKey(userID), now, now.Add(-s.lockout.Window))
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("[auth] Не удалось учесть неверный код второго фактора")
+		return
+	}
+	if s.lockout.LockAfter > 0 && int(a.FailedCount) >= s.lockout.LockAfter {
+		until := now.Add(s.lockout.LockDuration)
+		if err := s.repo.LockLoginAttempt(ctx, a.AttemptKey, until); err != nil {
+			log.Error().Err(err).Int64("user_id", userID).Msg("[auth] Не удалось заблокировать второй фактор")
+		}
+		s.logEvent(ctx, EventAccountLocked, userID, "mfa until="+until.Format(time.RFC3339))
+	}
+}
+
+func (s *AuthService) resetMFAFailures(ctx context.Context, userID int64) {
+	if err := s.repo.ResetLoginAttempts(ctx, mfaAttemptKey(userID)); err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("[auth] Не удалось сбросить счётчик неверных кодов")
+	}
+}
+
+// Коды восстановления вида xxxxx-xxxxx и их хеши для базы
+func (s *AuthService) newRecoveryCodes() (codes, hashes []string, err error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < s.mfa.RecoveryCodes; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(enc.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashLinkToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// Секрет TOTP в базе: base64(nonce || AES-GCM(secret))
+func (s *AuthService) sealSecret(secret string) (string, error) {
+	gcm, err := s.mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func (s *AuthService) openSecret(sealed string) (string, error) {
+	gcm, err := s.mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errors.New("повреждён секрет второго фактора")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("повреждён секрет второго фактора")
+	}
+	return string(plain), nil
+}
+
+func (s *AuthService) mfaCipher() (cipher.AEAD, error) {
+	if len(s.mfa.SecretKey) == 0 {
+		return nil, ErrMFANotConfigured
+	}
+	block, err := aes.NewCipher(s.mfa.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"domofon/internal/db"
+	"domofon/internal/jwt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Сброс пароля одной транзакцией. Подтверждение — билет сценария reset
+// или ссылка из письма; гасится вместе со сменой пароля.
+type PasswordReset struct {
+	UserID  int64
+	NewHash string
+	// Билет сценария reset (сброс по SMS)
+	Ticket *jwt.TicketClaims
+	// Хеш токена из ссылки (сброс по email)
+	ResetToken string
+}
+
+// Гасит подтверждение, меняет пароль и отзывает все ссылки сброса и сессии
+// пользователя вместе с их push-устройствами. Подтверждение гасится условным
+// запросом до смены пароля: из двух параллельных сбросов по одной ссылке
+// пройдёт только один. Если что-то не удалось, не меняется ничего.
+func (r *AuthRepository) ResetPassword(ctx context.Context, p PasswordReset) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	userID := pgtype.Int4{Int32: int32(p.UserID), Valid: true}
+	switch {
+	case p.Ticket != nil:
+		n, err := q.ConsumeVerificationTicket(ctx, db.ConsumeVerificationTicketParams{
+			Jti:     p.Ticket.ID,
+			Phone:   p.Ticket.Phone,
+			Purpose: p.Ticket.Purpose,
+			Now:     pgtype.Timestamp{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			return ErrInvalidTicket
+		}
+	case p.ResetToken != "":
+		n, err := q.ConsumePasswordResetToken(ctx, db.ConsumePasswordResetTokenParams{Token: p.ResetToken, UserID: userID})
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			return ErrInvalidResetToken
+		}
+	default:
+		return ErrInvalidResetToken
+	}
+
+	if err := q.ChangePasswordByID(ctx, db.ChangePasswordByIDParams{ID: int32(p.UserID), PasswordHash: p.NewHash}); err != nil {
+		return err
+	}
+	if err := q.DeleteAllResetTokensForUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := q.DeleteUserRefreshTokens(ctx, int32(p.UserID)); err != nil {
+		return err
+	}
+	if err := q.DeleteUserPushDevices(ctx, int32(p.UserID)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
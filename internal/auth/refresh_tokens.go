@@ -25,3 +25,42 @@ func (r *AuthRepository) GetRefreshToken(ctx context.Context, token string) (*db
 func (r *AuthRepository) DeleteRefreshToken(ctx context.Context, token string) error {
     return r.queries.DeleteRefreshToken(ctx, token)
 }
+
+// Отзывает все сессии пользователя; push-уведомления на их устройства больше не уходят
+func (r *AuthRepository) DeleteUserRefreshTokens(ctx context.Context, userID int64) error {
+    tx, err := r.pool.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+    q := r.queries.WithTx(tx)
+
+    if err := q.DeleteUserRefreshTokens(ctx, int32(userID)); err != nil {
+        return err
+    }
+    if err := q.DeleteUserPushDevices(ctx, int32(userID)); err != nil {
+        return err
+    }
+    return tx.Commit(ctx)
+}
+
+// Выход из одной сессии: refresh-токен и push-устройство этой сессии
+func (r *AuthRepository) DeleteSession(ctx context.Context, token string, userID int64, sessionID string) error {
+    tx, err := r.pool.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+    q := r.queries.WithTx(tx)
+
+    if err := q.DeleteRefreshToken(ctx, token); err != nil {
+        return err
+    }
+    if _, err := q.DeletePushDeviceBySession(ctx, db.DeletePushDeviceBySessionParams{
+        UserID:    int32(userID),
+        SessionID: sessionID,
+    }); err != nil {
+        return err
+    }
+    return tx.Commit(ctx)
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"domofon/internal/db"
+	"domofon/internal/jwt"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Гасит билет регистрации и заводит пользователя одной транзакцией: если вставка
+// не удалась (номер, логин или почту заняли параллельно), билет остаётся в силе.
+func (r *AuthRepository) RegisterUser(ctx context.Context, params db.RegisterUserParams, ticket *jwt.TicketClaims) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	n, err := q.ConsumeVerificationTicket(ctx, db.ConsumeVerificationTicketParams{
+		Jti:     ticket.ID,
+		Phone:   ticket.Phone,
+		Purpose: ticket.Purpose,
+		Now:     pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrInvalidTicket
+	}
+
+	if err := q.RegisterUser(ctx, params); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			switch pgErr.ConstraintName {
+			case "users_phone_key":
+				return ErrPhoneTaken
+			case "users_username_key":
+				return ErrUsernameTaken
+			case "users_email_key":
+				return ErrEmailTaken
+			}
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
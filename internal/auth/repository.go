@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"domofon/internal/db"
+	"domofon/internal/jwt"
 	"time"
 "errors"
 
@@ -13,12 +14,14 @@ import (
 
 // Интерфейс для использования в AuthService
 type Auth interface {
-	RegisterUser(ctx context.Context, params db.RegisterUserParams) error
+	RegisterUser(ctx context.Context, params db.RegisterUserParams, ticket *jwt.TicketClaims) error
 	GetUserByUsername(ctx context.Context, username string) (*db.User, error)
 	CreatePasswordResetToken(ctx context.Context, userID int64, token string, expiresAt time.Time) error
 	GetUserByResetToken(ctx context.Context, token string) (*db.User, error)
 	InvalidateResetToken(ctx context.Context, token string) error
 	GetUserByPhone(ctx context.Context, phone string) (*db.User, error)
+	GetUserByID(ctx context.Context, id int64) (*db.User, error)
+	GetUserApartmentIDs(ctx context.Context, userID int64) ([]int64, error)
 	ChangePasswordByPhone(ctx context.Context, phone string, newHash string) error
 	IsPhoneTaken(ctx context.Context, phone string) (bool, error)
   IsUsernameTaken(ctx context.Context, username string) (bool, error)
@@ -28,19 +31,24 @@ type Auth interface {
   GetRefreshToken(ctx context.Context, token string) (*db.RefreshToken, error)
 	DeleteRefreshToken(ctx context.Context, token string) error
 
+	GetLoginAttempt(ctx context.Context, key string) (*db.LoginAttempt, error)
+	RegisterLoginFailure(ctx context.Context, key string, now, windowStart time.Time) (*db.LoginAttempt, error)
+	LockLoginAttempt(ctx context.Context, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+	CreateEvent(ctx context.Context, eventType string, userID int64, description string) error
+
+	CreateVerificationTicket(ctx context.Context, jti, phone, purpose string, expiresAt time.Time) error
+	ConsumeVerificationTicket(ctx context.Context, jti, phone, purpose string) (bool, error)
 }
 
 
 type AuthRepository struct {
+	pool    *pgxpool.Pool
 	queries *db.Queries
 }
 
 func NewAuthRepository(pool *pgxpool.Pool) *AuthRepository {
-	return &AuthRepository{queries: db.New(pool)}
-}
-
-func (r *AuthRepository) RegisterUser(ctx context.Context, params db.RegisterUserParams) error {
-	return r.queries.RegisterUser(ctx, params)
+	return &AuthRepository{pool: pool, queries: db.New(pool)}
 }
 
 func (r *AuthRepository) GetUserByUsername(ctx context.Context, username string) (*db.User, error) {
@@ -79,6 +87,27 @@ func (r *AuthRepository) GetUserByPhone(ctx context.Context, phone string) (*db.
 	return &user, nil
 }
 
+func (r *AuthRepository) GetUserByID(ctx context.Context, id int64) (*db.User, error) {
+	user, err := r.queries.GetUserByID(ctx, int32(id))
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Квартиры, где пользователь владелец или активный житель
+func (r *AuthRepository) GetUserApartmentIDs(ctx context.Context, userID int64) ([]int64, error) {
+	ids, err := r.queries.GetUserApartmentIDs(ctx, pgtype.Int4{Int32: int32(userID), Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, int64(id))
+	}
+	return out, nil
+}
+
 func (r *AuthRepository) ChangePasswordByPhone(ctx context.Context, phone, newHash string) error {
 	return r.queries.ChangePasswordByPhone(ctx, db.ChangePasswordByPhoneParams{
 		PasswordHash: newHash,
@@ -3,9 +3,15 @@ package auth
 import (
 	"context"
 	"errors"
+	"domofon/internal/config"
 	"domofon/internal/db"
+	"domofon/internal/jwt"
+	"domofon/internal/password"
+	"domofon/internal/outbox"
 	"domofon/internal/verification"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
 	"time"
 )
 
@@ -15,31 +21,102 @@ var (
 	ErrPhoneTaken         = errors.New("пользователь с таким номером телефона уже существует")
 	ErrUsernameTaken      = errors.New("пользователь с таким username уже существует")
 	ErrEmailTaken         = errors.New("пользователь с такой почтой уже зарегистрирован")
+	ErrInvalidTicket      = errors.New("номер не подтверждён или подтверждение уже использовано")
 )
 
+// Роль самостоятельно зарегистрированного пользователя; другие роли назначает администратор
+const RoleResident = "resident"
+
 type UserRepository interface {
-	RegisterUser(ctx context.Context, params db.RegisterUserParams) error
+	RegisterUser(ctx context.Context, params db.RegisterUserParams, ticket *jwt.TicketClaims) error
 	GetUserByPhone(ctx context.Context, phone string) (*db.User, error)
+	GetUserByID(ctx context.Context, id int64) (*db.User, error)
+	GetUserApartmentIDs(ctx context.Context, userID int64) ([]int64, error)
 	ChangePasswordByPhone(ctx context.Context, phone, newHash string) error
 	IsPhoneTaken(ctx context.Context, phone string) (bool, error)
 	IsUsernameTaken(ctx context.Context, username string) (bool, error)
 	IsEmailTaken(ctx context.Context, email string) (bool, error)
+
+	GetLoginAttempt(ctx context.Context, key string) (*db.LoginAttempt, error)
+	RegisterLoginFailure(ctx context.Context, key string, now, windowStart time.Time) (*db.LoginAttempt, error)
+	LockLoginAttempt(ctx context.Context, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+	CreateEvent(ctx context.Context, eventType string, userID int64, description string) error
+
+	CreateVerificationTicket(ctx context.Context, jti, phone, purpose string, expiresAt time.Time) error
+	ConsumeVerificationTicket(ctx context.Context, jti, phone, purpose string) (bool, error)
+
+	GetUserByEmail(ctx context.Context, email string) (*db.User, error)
+	CreateEmailVerificationToken(ctx context.Context, tokenHash string, userID int64, email string, expiresAt time.Time) error
+	GetEmailVerificationToken(ctx context.Context, tokenHash string) (*db.EmailVerificationToken, error)
+	ConfirmUserEmail(ctx context.Context, userID int64, email string) error
+	DeleteEmailVerificationTokens(ctx context.Context, userID int64) error
+
+	CreatePasswordResetToken(ctx context.Context, userID int64, token string, expiresAt time.Time) error
+	GetUserByResetToken(ctx context.Context, token string) (*db.User, error)
+	DeleteAllResetTokensForUser(ctx context.Context, userID int64) error
+	ChangePasswordByID(ctx context.Context, userID int64, newHash string) error
+	DeleteUserRefreshTokens(ctx context.Context, userID int64) error
+
+	ChangePhone(ctx context.Context, change PhoneChange) error
+	ResetPassword(ctx context.Context, reset PasswordReset) error
+
+	GetUserMFA(ctx context.Context, userID int64) (*db.UserMfa, error)
+	StartMFAEnrollment(ctx context.Context, userID int64, secret string) error
+	EnableMFA(ctx context.Context, userID int64, step int64, recoveryHashes []string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error
+	UseMFAStep(ctx context.Context, userID int64, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int64) (int64, error)
+	DisableMFA(ctx context.Context, userID int64) error
+}
+
+// Очередь исходящих сообщений (outbox.Queue)
+type MessageQueue interface {
+	Enqueue(ctx context.Context, msg outbox.Message) (int64, error)
 }
 
 type AuthService struct {
 	repo         UserRepository
 	verification verification.Service
+	queue        MessageQueue
+	lockout      config.LockoutConfig
+	ticketTTL    time.Duration
+
+	publicURL        string
+	passwordResetURL string
+	emailConfirmTTL  time.Duration
+	passwordResetTTL time.Duration
+
+	mfa       config.MFAConfig
+	passwords *password.Policy
+	hasher    password.Hasher
 }
 
-func NewAuthService(repo UserRepository, verification verification.Service) *AuthService {
+func NewAuthService(repo UserRepository, verification verification.Service, queue MessageQueue, cfg *config.AuthConfig) *AuthService {
 	return &AuthService{
-		repo:         repo,
-		verification: verification,
+		repo:             repo,
+		verification:     verification,
+		queue:            queue,
+		lockout:          cfg.Lockout,
+		ticketTTL:        cfg.VerificationTicketTTL,
+		publicURL:        cfg.PublicURL,
+		passwordResetURL: cfg.PasswordResetURL,
+		emailConfirmTTL:  cfg.EmailConfirmTTL,
+		passwordResetTTL: cfg.PasswordResetTTL,
+		mfa:              cfg.MFA,
+		passwords:        password.NewPolicy(cfg.Password),
+		hasher:           password.NewHasher(cfg.PasswordHash),
 	}
 }
 
-// Регистрация пользователя
-func (s *AuthService) Register(ctx context.Context, params db.RegisterUserParams) error {
+// Регистрация пользователя. ticket — билет, выданный VerifyPhoneCode для этого номера.
+func (s *AuthService) Register(ctx context.Context, params db.RegisterUserParams, ticket string) error {
+	params.Role = pgtype.Text{String: RoleResident, Valid: true}
+	claims, err := s.parseTicket(ticket, params.Phone, verification.PurposeRegister)
+	if err != nil {
+		return err
+	}
 	phoneTaken, err := s.repo.IsPhoneTaken(ctx, params.Phone)
 	if err != nil {
 		return err
@@ -61,19 +138,44 @@ func (s *AuthService) Register(ctx context.Context, params db.RegisterUserParams
 	if emailTaken {
 		return ErrEmailTaken
 	}
-	return s.repo.RegisterUser(ctx, params)
+	if err := s.repo.RegisterUser(ctx, params, claims); err != nil {
+		return err
+	}
+
+	// Почта при регистрации не подтверждена — отправляем ссылку
+	user, err := s.repo.GetUserByPhone(ctx, params.Phone)
+	if err != nil {
+		log.Error().Err(err).Str("phone", params.Phone).Msg("[auth] Не удалось найти пользователя после регистрации")
+		return nil
+	}
+	if err := s.RequestEmailConfirmation(ctx, int64(user.ID), user.Email); err != nil {
+		log.Error().Err(err).Int32("user_id", user.ID).Msg("[auth] Не удалось отправить подтверждение email")
+	}
+	return nil
 }
 
-// Авторизация по телефону
-func (s *AuthService) AuthorizeByPhone(ctx context.Context, phone, password string) (*db.User, bool) {
+// Авторизация по телефону. Неудачные попытки считаются по номеру и по IP;
+// при превышении порогов возвращается *LockoutError.
+func (s *AuthService) AuthorizeByPhone(ctx context.Context, phone, password, ip string) (*db.User, error) {
+	if err := s.checkLoginAllowed(ctx, phone, ip); err != nil {
+		return nil, err
+	}
+
 	user, err := s.repo.GetUserByPhone(ctx, phone)
 	if err != nil || user == nil {
-		return nil, false
+		s.registerLoginFailure(ctx, phone, ip, nil)
+		return nil, ErrInvalidCredentials
 	}
 	if !s.CheckPasswordHash(password, user.PasswordHash) {
-		return nil, false
+		s.registerLoginFailure(ctx, phone, ip, user)
+		return nil, ErrInvalidCredentials
 	}
-	return user, true
+	s.upgradePasswordHash(ctx, user, password)
+
+	if err := s.repo.ResetLoginAttempts(ctx, phoneAttemptKey(phone)); err != nil {
+		log.Error().Err(err).Str("phone", phone).Msg("[auth] Не удалось сбросить счётчик неудачных входов")
+	}
+	return user, nil
 }
 
 // Проверка, занят ли телефон
@@ -86,31 +188,69 @@ func (s *AuthService) IsUsernameTaken(ctx context.Context, username string) (boo
 	return s.repo.IsUsernameTaken(ctx, username)
 }
 
-// Смена пароля по телефону (с проверкой oldPassword)
-func (s *AuthService) ChangePasswordByPhone(ctx context.Context, phone, oldPassword, newPassword string) error {
-	user, err := s.repo.GetUserByPhone(ctx, phone)
-	if err != nil || user == nil {
-		return ErrInvalidOldPassword
+// Смена пароля текущим пользователем с проверкой oldPassword. Подбор старого
+// пароля учитывается так же, как при входе: те же счётчики по номеру и IP.
+func (s *AuthService) ChangePassword(ctx context.Context, userID int64, oldPassword, newPassword, ip string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkLoginAllowed(ctx, user.Phone, ip); err != nil {
+		return err
 	}
 	if !s.CheckPasswordHash(oldPassword, user.PasswordHash) {
+		s.registerLoginFailure(ctx, user.Phone, ip, user)
 		return ErrInvalidOldPassword
 	}
+	if err := s.repo.ResetLoginAttempts(ctx, phoneAttemptKey(user.Phone)); err != nil {
+		log.Error().Err(err).Str("phone", user.Phone).Msg("[auth] Не удалось сбросить счётчик неудачных входов")
+	}
+
+	if err := s.ValidatePassword(newPassword, user.Phone, user.Username); err != nil {
+		return err
+	}
 	newHash, err := s.HashPassword(newPassword)
 	if err != nil {
 		return err
 	}
-	return s.repo.ChangePasswordByPhone(ctx, phone, newHash)
+	return s.repo.ChangePasswordByID(ctx, userID, newHash)
+}
+
+// Проверка нового пароля по политике. Ошибка — *password.PolicyError со списком нарушений.
+func (s *AuthService) ValidatePassword(newPassword, phone, username string) error {
+	return s.passwords.Check(newPassword, phone, username)
 }
 
 // Хеширование пароля
 func (s *AuthService) HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 12)
-	return string(bytes), err
+	return s.hasher.Hash(password)
 }
 
 // Проверка пароля
 func (s *AuthService) CheckPasswordHash(password, hash string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	ok, err := s.hasher.Verify(password, hash)
+	if err != nil {
+		log.Error().Err(err).Msg("[auth] Не удалось проверить хеш пароля")
+	}
+	return ok
+}
+
+// Пересчёт хеша, если он сделан устаревшим алгоритмом или параметрами.
+// Вызывается после успешной проверки, когда пароль известен в открытом виде.
+func (s *AuthService) upgradePasswordHash(ctx context.Context, user *db.User, password string) {
+	if !s.hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
+	newHash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Error().Err(err).Int32("user_id", user.ID).Msg("[auth] Не удалось пересчитать хеш пароля")
+		return
+	}
+	if err := s.repo.ChangePasswordByID(ctx, int64(user.ID), newHash); err != nil {
+		log.Error().Err(err).Int32("user_id", user.ID).Msg("[auth] Не удалось сохранить новый хеш пароля")
+		return
+	}
+	user.PasswordHash = newHash
 }
 
 // Отправка SMS-кода для регистрации
@@ -122,7 +262,7 @@ func (s *AuthService) SendRegistrationCode(ctx context.Context, phone string) er
 	if phoneTaken {
 		return ErrPhoneTaken
 	}
-	return s.verification.SendVerificationCode(ctx, phone)
+	return s.verification.SendVerificationCode(ctx, phone, verification.PurposeRegister)
 }
 
 // Отправка кода сброса пароля
@@ -131,25 +271,104 @@ func (s *AuthService) RequestPasswordResetByPhone(ctx context.Context, phone str
 	if err != nil || user == nil {
 		return errors.New("номер не найден")
 	}
-	return s.verification.SendVerificationCode(ctx, phone)
+	return s.verification.SendVerificationCode(ctx, phone, verification.PurposeReset)
+}
+
+// Проверка SMS-кода для указанного сценария (регистрация, сброс пароля и т.д.).
+// Возвращает одноразовый билет, который нужно предъявить на следующем шаге.
+func (s *AuthService) VerifyPhoneCode(ctx context.Context, phone string, purpose verification.Purpose, code string) (*VerifyPhoneResponse, error) {
+	if err := s.verification.VerifyCode(ctx, phone, purpose, code); err != nil {
+		return nil, err
+	}
+	ticket, claims, err := jwt.GenerateVerificationTicket(phone, string(purpose), s.ticketTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateVerificationTicket(ctx, claims.ID, phone, string(purpose), claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+	return &VerifyPhoneResponse{VerificationTicket: ticket, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// Проверяет подпись билета и что он выдан для этого номера и сценария
+func (s *AuthService) parseTicket(ticket, phone string, purpose verification.Purpose) (*jwt.TicketClaims, error) {
+	if ticket == "" {
+		return nil, ErrInvalidTicket
+	}
+	claims, err := jwt.ParseVerificationTicket(ticket)
+	if err != nil || claims.Phone != phone || claims.Purpose != string(purpose) {
+		return nil, ErrInvalidTicket
+	}
+	return claims, nil
 }
 
-// Проверка SMS-кода (универсально — и для сброса, и для регистрации)
-func (s *AuthService) VerifyPhoneCode(ctx context.Context, phone, code string) error {
-	return s.verification.VerifyCode(ctx, phone, code)
+// Гасит билет; повторно его использовать нельзя
+func (s *AuthService) consumeTicket(ctx context.Context, claims *jwt.TicketClaims) error {
+	ok, err := s.repo.ConsumeVerificationTicket(ctx, claims.ID, claims.Phone, claims.Purpose)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTicket
+	}
+	return nil
 }
 
-// Сброс пароля после подтверждения по SMS
-func (s *AuthService) ResetPasswordByPhone(ctx context.Context, phone, newPassword string) error {
+// Сброс пароля после подтверждения по SMS. ticket — билет сценария reset для этого номера.
+// Все сессии пользователя отзываются вместе со сменой пароля.
+func (s *AuthService) ResetPasswordByPhone(ctx context.Context, phone, newPassword, ticket string) error {
+	claims, err := s.parseTicket(ticket, phone, verification.PurposeReset)
+	if err != nil {
+		return err
+	}
 	user, err := s.repo.GetUserByPhone(ctx, phone)
 	if err != nil || user == nil {
 		return errors.New("пользователь не найден")
 	}
+	if err := s.ValidatePassword(newPassword, user.Phone, user.Username); err != nil {
+		return err
+	}
 	newHash, err := s.HashPassword(newPassword)
 	if err != nil {
 		return err
 	}
-	return s.repo.ChangePasswordByPhone(ctx, phone, newHash)
+	return s.repo.ResetPassword(ctx, PasswordReset{UserID: int64(user.ID), NewHash: newHash, Ticket: claims})
+}
+
+// Выпуск пары access/refresh токенов. Пустой sessionID — новая сессия (логин),
+// иначе сессия продолжается (refresh).
+func (s *AuthService) IssueTokens(ctx context.Context, user *db.User, sessionID string) (*RefreshResponse, error) {
+	if sessionID == "" {
+		sessionID = uuid.NewString()
+	}
+	apartments, err := s.repo.GetUserApartmentIDs(ctx, int64(user.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, jti, err := jwt.GenerateAccessToken(jwt.Identity{
+		UserID:       int64(user.ID),
+		Role:         user.Role.String,
+		SessionID:    sessionID,
+		ApartmentIDs: apartments,
+	})
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := jwt.GenerateRefreshToken(int64(user.ID), sessionID, jti)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(jwt.RefreshTTL())
+	if err := s.SaveRefreshToken(ctx, int64(user.ID), refreshToken, jti, expiresAt); err != nil {
+		return nil, err
+	}
+	return &RefreshResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+func (s *AuthService) GetUserByID(ctx context.Context, id int64) (*db.User, error) {
+	return s.repo.GetUserByID(ctx, id)
 }
 
 func (s *AuthService) SaveRefreshToken(ctx context.Context, userID int64, token, jti string, expiresAt time.Time) error {
@@ -175,3 +394,21 @@ func (s *AuthService) DeleteRefreshToken(ctx context.Context, token string) erro
     }
     return repo.DeleteRefreshToken(ctx, token)
 }
+
+// Logout отзывает refresh-токен. Если токен разбирается, вместе с ним
+// удаляется push-устройство его сессии.
+func (s *AuthService) Logout(ctx context.Context, token string) error {
+    repo, ok := s.repo.(*AuthRepository)
+    if !ok {
+        return errors.New("репозиторий не поддерживает refresh-токены")
+    }
+    claims, err := jwt.ParseRefreshToken(token)
+    if err != nil {
+        return repo.DeleteRefreshToken(ctx, token)
+    }
+    userID, err := claims.UserID()
+    if err != nil || claims.SessionID == "" {
+        return repo.DeleteRefreshToken(ctx, token)
+    }
+    return repo.DeleteSession(ctx, token, userID, claims.SessionID)
+}
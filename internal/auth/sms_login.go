@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"domofon/internal/db"
+	"domofon/internal/verification"
+
+	"github.com/rs/zerolog/log"
+)
+
+var ErrInvalidLoginCode = errors.New("неверный или просроченный код")
+
+// Код для входа без пароля. Для неизвестного номера SMS не отправляется,
+// но ответ тот же, чтобы по нему нельзя было проверить регистрацию. Поэтому
+// и отказ из-за частой отправки или исчерпанных попыток наружу не отдаётся:
+// у неизвестного номера его не бывает. Частоту по номеру ограничивает лимит ручки.
+func (s *AuthService) RequestLoginCode(ctx context.Context, phone, ip string) error {
+	if err := s.checkLoginAllowed(ctx, phone, ip); err != nil {
+		return err
+	}
+	user, err := s.repo.GetUserByPhone(ctx, phone)
+	if err != nil || user == nil {
+		return nil
+	}
+	err = s.verification.SendVerificationCode(ctx, phone, verification.PurposeLogin)
+	if errors.Is(err, verification.ErrResendTooSoon) || errors.Is(err, verification.ErrTooManyAttempts) {
+		log.Debug().Err(err).Str("phone", phone).Msg("[auth] Код входа не отправлен")
+		return nil
+	}
+	return err
+}
+
+// Вход по коду из SMS. Неверный код считается неудачной попыткой входа
+// наравне с неверным паролем.
+func (s *AuthService) AuthorizeBySMSCode(ctx context.Context, phone, code, ip string) (*db.User, error) {
+	if err := s.checkLoginAllowed(ctx, phone, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByPhone(ctx, phone)
+	if err != nil || user == nil {
+		s.registerLoginFailure(ctx, phone, ip, nil)
+		return nil, ErrInvalidLoginCode
+	}
+	if err := s.verification.VerifyCode(ctx, phone, verification.PurposeLogin, code); err != nil {
+		// Исчерпанные попытки не отличаются от неверного кода: у неизвестного
+		// номера их не бывает, иначе по ответу видно, что номер зарегистрирован
+		s.registerLoginFailure(ctx, phone, ip, user)
+		return nil, ErrInvalidLoginCode
+	}
+
+	if err := s.repo.ResetLoginAttempts(ctx, phoneAttemptKey(phone)); err != nil {
+		log.Error().Err(err).Str("phone", phone).Msg("[auth] Не удалось сбросить счётчик неудачных входов")
+	}
+	return user, nil
+}
+
+// Общая проверка блокировок по номеру и IP
+func (s *AuthService) checkLoginAllowed(ctx context.Context, phone, ip string) error {
+	if err := s.checkLockout(ctx, phoneAttemptKey(phone)); err != nil {
+		return err
+	}
+	if ip != "" {
+		return s.checkLockout(ctx, ipAttemptKey(ip))
+	}
+	return nil
+}
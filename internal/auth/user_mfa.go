@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"domofon/internal/db"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Настройки второго фактора; nil, если пользователь его не подключал
+func (r *AuthRepository) GetUserMFA(ctx context.Context, userID int64) (*db.UserMfa, error) {
+	m, err := r.queries.GetUserMFA(ctx, int32(userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *AuthRepository) StartMFAEnrollment(ctx context.Context, userID int64, secret string) error {
+	return r.queries.UpsertUserMFA(ctx, db.UpsertUserMFAParams{
+		UserID:    int32(userID),
+		Secret:    secret,
+		CreatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+}
+
+// Включает второй фактор и заменяет коды восстановления одной транзакцией
+func (r *AuthRepository) EnableMFA(ctx context.Context, userID int64, step int64, recoveryHashes []string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	if err := q.EnableUserMFA(ctx, db.EnableUserMFAParams{
+		UserID:       int32(userID),
+		EnabledAt:    pgtype.Timestamp{Time: time.Now(), Valid: true},
+		LastUsedStep: pgtype.Int8{Int64: step, Valid: true},
+	}); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, q, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *AuthRepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := replaceRecoveryCodes(ctx, r.queries.WithTx(tx), userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, q *db.Queries, userID int64, hashes []string) error {
+	if err := q.DeleteMFARecoveryCodes(ctx, int32(userID)); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if err := q.CreateMFARecoveryCode(ctx, db.CreateMFARecoveryCodeParams{UserID: int32(userID), CodeHash: h}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Отмечает шаг TOTP использованным. false — код с этим шагом уже принимался.
+func (r *AuthRepository) UseMFAStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	n, err := r.queries.UseMFAStep(ctx, db.UseMFAStepParams{UserID: int32(userID), Step: step})
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Гасит код восстановления. false — кода нет или он уже использован.
+func (r *AuthRepository) UseRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error) {
+	n, err := r.queries.UseMFARecoveryCode(ctx, db.UseMFARecoveryCodeParams{
+		UserID:   int32(userID),
+		CodeHash: hash,
+		UsedAt:   pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *AuthRepository) CountRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	return r.queries.CountMFARecoveryCodes(ctx, int32(userID))
+}
+
+func (r *AuthRepository) DisableMFA(ctx context.Context, userID int64) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+	if err := q.DeleteUserMFA(ctx, int32(userID)); err != nil {
+		return err
+	}
+	if err := q.DeleteMFARecoveryCodes(ctx, int32(userID)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
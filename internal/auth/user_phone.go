@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"domofon/internal/db"
+	"domofon/internal/jwt"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Смена номера одной транзакцией
+type PhoneChange struct {
+	UserID   int64
+	OldPhone string
+	NewPhone string
+	// Билеты подтверждения номеров, гасятся вместе со сменой
+	Tickets []*jwt.TicketClaims
+	// Описание для журнала событий
+	Audit string
+}
+
+// Гасит билеты, меняет номер, отзывает все сессии и пишет событие в журнал.
+// Если что-то не удалось, не меняется ничего.
+func (r *AuthRepository) ChangePhone(ctx context.Context, c PhoneChange) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	now := pgtype.Timestamp{Time: time.Now(), Valid: true}
+	for _, t := range c.Tickets {
+		n, err := q.ConsumeVerificationTicket(ctx, db.ConsumeVerificationTicketParams{
+			Jti:     t.ID,
+			Phone:   t.Phone,
+			Purpose: t.Purpose,
+			Now:     now,
+		})
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			return ErrInvalidTicket
+		}
+	}
+
+	n, err := q.UpdateUserPhone(ctx, db.UpdateUserPhoneParams{
+		ID:       int32(c.UserID),
+		NewPhone: c.NewPhone,
+		OldPhone: c.OldPhone,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrPhoneTaken
+		}
+		return err
+	}
+	if n != 1 {
+		return ErrPhoneChangedConcurrently
+	}
+
+	if err := q.DeleteUserRefreshTokens(ctx, int32(c.UserID)); err != nil {
+		return err
+	}
+	if err := q.DeleteUserPushDevices(ctx, int32(c.UserID)); err != nil {
+		return err
+	}
+	if err := q.ResetLoginAttempts(ctx, phoneAttemptKey(c.OldPhone)); err != nil {
+		return err
+	}
+	if err := q.CreateEvent(ctx, db.CreateEventParams{
+		EventType:   EventPhoneChanged,
+		UserID:      pgtype.Int4{Int32: int32(c.UserID), Valid: true},
+		Description: pgtype.Text{String: c.Audit, Valid: true},
+	}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
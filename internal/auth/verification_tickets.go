@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"context"
+	"domofon/internal/db"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (r *AuthRepository) CreateVerificationTicket(ctx context.Context, jti, phone, purpose string, expiresAt time.Time) error {
+	return r.queries.CreateVerificationTicket(ctx, db.CreateVerificationTicketParams{
+		Jti:       jti,
+		Phone:     phone,
+		Purpose:   purpose,
+		ExpiresAt: pgtype.Timestamp{Time: expiresAt, Valid: true},
+	})
+}
+
+// Гасит билет. false — билет не найден, просрочен или уже использован.
+func (r *AuthRepository) ConsumeVerificationTicket(ctx context.Context, jti, phone, purpose string) (bool, error) {
+	n, err := r.queries.ConsumeVerificationTicket(ctx, db.ConsumeVerificationTicketParams{
+		Jti:     jti,
+		Phone:   phone,
+		Purpose: purpose,
+		Now:     pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,62 @@
+package chat
+
+import "time"
+
+type ChatResponse struct {
+	ID    int64  `json:"id"`
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	// Для личного чата — собеседник
+	Peer              *MemberResponse `json:"peer,omitempty"`
+	LastMessageID     int64           `json:"last_message_id,omitempty"`
+	LastMessageAt     *time.Time      `json:"last_message_at,omitempty"`
+	LastReadMessageID int64           `json:"last_read_message_id"`
+	UnreadCount       int64           `json:"unread_count"`
+}
+
+type MemberResponse struct {
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	// До какого сообщения участник прочитал чат
+	LastReadMessageID int64      `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at,omitempty"`
+}
+
+type MentionResponse struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+type MessageResponse struct {
+	ID       int64             `json:"id"`
+	ChatID   int64             `json:"chat_id"`
+	SenderID int64             `json:"sender_id,omitempty"`
+	Body     string            `json:"body"`
+	Mentions []MentionResponse `json:"mentions"`
+	// Сколько участников, кроме отправителя, прочитали сообщение
+	ReadCount int64      `json:"read_count"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	Deleted   bool       `json:"deleted"`
+}
+
+type ReadReceipt struct {
+	UserID            int64     `json:"user_id"`
+	LastReadMessageID int64     `json:"last_read_message_id"`
+	ReadAt            time.Time `json:"read_at"`
+}
+
+type OpenDirectRequest struct {
+	Username string `json:"username"`
+}
+
+type SendMessageRequest struct {
+	Body string `json:"body"`
+}
+
+type MarkReadRequest struct {
+	MessageID int64 `json:"message_id"`
+}
@@ -0,0 +1,342 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"domofon/internal/middleware"
+)
+
+type ChatHandler struct {
+	chat      *ChatService
+	keepAlive time.Duration
+}
+
+func NewChatHandler(s *ChatService) *ChatHandler {
+	return &ChatHandler{chat: s, keepAlive: s.cfg.StreamKeepAlive}
+}
+
+// ListChats godoc
+// @Summary Чаты пользователя
+// @Description Чаты домов, где у пользователя есть квартира, и личные переписки. Сортировка по последнему сообщению.
+// @Tags chat
+// @Produce json
+// @Success 200 {array} ChatResponse
+// @Security BearerAuth
+// @Router /chats [get]
+func (h *ChatHandler) ListChats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chats, err := h.chat.ListChats(r.Context(), userID)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, chats)
+}
+
+// OpenDirect godoc
+// @Summary Открыть личный чат
+// @Description Возвращает личный чат с пользователем по username, при необходимости создаёт его. Писать можно только соседям — жильцам тех же домов; для остальных ответ 404.
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Param input body OpenDirectRequest true "Username собеседника"
+// @Success 200 {object} ChatResponse
+// @Failure 404 {string} string "Пользователь не найден"
+// @Security BearerAuth
+// @Router /chats/direct [post]
+func (h *ChatHandler) OpenDirect(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req OpenDirectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	c, err := h.chat.OpenDirect(r.Context(), userID, req.Username)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// Members godoc
+// @Summary Участники чата
+// @Description Участники с отметками прочтения (last_read_message_id).
+// @Tags chat
+// @Produce json
+// @Param id path int true "ID чата"
+// @Success 200 {array} MemberResponse
+// @Failure 404 {string} string "Чат не найден"
+// @Security BearerAuth
+// @Router /chats/{id}/members [get]
+func (h *ChatHandler) Members(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	members, err := h.chat.Members(r.Context(), userID, chatID)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, members)
+}
+
+// ListMessages godoc
+// @Summary История сообщений
+// @Description Сообщения от новых к старым. Следующая страница — before_id = id последнего полученного.
+// @Tags chat
+// @Produce json
+// @Param id path int true "ID чата"
+// @Param before_id query int false "Сообщения старше этого id"
+// @Param limit query int false "Размер страницы"
+// @Success 200 {array} MessageResponse
+// @Failure 404 {string} string "Чат не найден"
+// @Security BearerAuth
+// @Router /chats/{id}/messages [get]
+func (h *ChatHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	beforeID, _ := strconv.ParseInt(r.URL.Query().Get("before_id"), 10, 64)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	msgs, err := h.chat.ListMessages(r.Context(), userID, chatID, beforeID, limit)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, msgs)
+}
+
+// SendMessage godoc
+// @Summary Отправить сообщение
+// @Description Упоминания @username разрешаются в участников чата.
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Param id path int true "ID чата"
+// @Param input body SendMessageRequest true "Текст"
+// @Success 201 {object} MessageResponse
+// @Failure 400 {string} string "Пустое или слишком длинное сообщение"
+// @Failure 404 {string} string "Чат не найден"
+// @Security BearerAuth
+// @Router /chats/{id}/messages [post]
+func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	msg, err := h.chat.SendMessage(r.Context(), userID, chatID, req.Body)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+// EditMessage godoc
+// @Summary Изменить своё сообщение
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Param id path int true "ID сообщения"
+// @Param input body SendMessageRequest true "Новый текст"
+// @Success 200 {object} MessageResponse
+// @Failure 404 {string} string "Сообщение не найдено"
+// @Security BearerAuth
+// @Router /chats/messages/{id} [put]
+func (h *ChatHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	messageID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	msg, err := h.chat.EditMessage(r.Context(), userID, messageID, req.Body)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
+
+// DeleteMessage godoc
+// @Summary Удалить своё сообщение
+// @Tags chat
+// @Param id path int true "ID сообщения"
+// @Success 204
+// @Failure 404 {string} string "Сообщение не найдено"
+// @Security BearerAuth
+// @Router /chats/messages/{id} [delete]
+func (h *ChatHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	messageID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.chat.DeleteMessage(r.Context(), userID, messageID); err != nil {
+		writeChatError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkRead godoc
+// @Summary Отметить чат прочитанным
+// @Description Сдвигает отметку прочтения до message_id включительно. Назад отметка не двигается.
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Param id path int true "ID чата"
+// @Param input body MarkReadRequest true "Последнее прочитанное сообщение"
+// @Success 200 {object} ReadReceipt
+// @Failure 404 {string} string "Чат или сообщение не найдены"
+// @Security BearerAuth
+// @Router /chats/{id}/read [post]
+func (h *ChatHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	receipt, err := h.chat.MarkRead(r.Context(), userID, chatID, req.MessageID)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, receipt)
+}
+
+// Events godoc
+// @Summary Поток событий чатов
+// @Description Server-Sent Events: message.created, message.updated, message.deleted, chat.read. Данные события — JSON Event.
+// @Tags chat
+// @Produce text/event-stream
+// @Success 200 {object} Event
+// @Security BearerAuth
+// @Router /chats/events [get]
+func (h *ChatHandler) Events(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Потоковая передача не поддерживается", http.StatusInternalServerError)
+		return
+	}
+
+	events, cancel, err := h.chat.Subscribe(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(h.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeChatError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrChatNotFound), errors.Is(err, ErrMessageNotFound), errors.Is(err, ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrEmptyMessage), errors.Is(err, ErrMessageTooLong), errors.Is(err, ErrDirectToSelf):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,73 @@
+package chat
+
+import (
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Типы событий в потоке /chats/events
+const (
+	EventMessageCreated = "message.created"
+	EventMessageUpdated = "message.updated"
+	EventMessageDeleted = "message.deleted"
+	EventChatRead       = "chat.read"
+)
+
+type Event struct {
+	Type    string           `json:"type"`
+	ChatID  int64            `json:"chat_id"`
+	Message *MessageResponse `json:"message,omitempty"`
+	Read    *ReadReceipt     `json:"read,omitempty"`
+}
+
+// Hub раздаёт события открытым потокам пользователей в этом процессе.
+// Медленный подписчик не тормозит остальных: если его буфер полон,
+// событие для него теряется, клиент догружает историю запросом.
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[int64]map[chan Event]struct{}
+	buffer int
+}
+
+func NewHub(buffer int) *Hub {
+	return &Hub{subs: make(map[int64]map[chan Event]struct{}), buffer: buffer}
+}
+
+// Subscribe открывает поток событий пользователя; cancel закрывает его
+func (h *Hub) Subscribe(userID int64) (events <-chan Event, cancel func()) {
+	ch := make(chan Event, h.buffer)
+	h.mu.Lock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[chan Event]struct{})
+	}
+	h.subs[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[userID], ch)
+			if len(h.subs[userID]) == 0 {
+				delete(h.subs, userID)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (h *Hub) Publish(userIDs []int64, ev Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, id := range userIDs {
+		for ch := range h.subs[id] {
+			select {
+			case ch <- ev:
+			default:
+				log.Warn().Int64("user_id", id).Str("event", ev.Type).Msg("[chat] Поток не успевает, событие пропущено")
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package chat
+
+import (
+	"regexp"
+	"strings"
+)
+
+// @username: буквы, цифры, "_" и "."; перед @ не должно быть буквы или цифры,
+// чтобы не ловить адреса почты
+var mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@([\p{L}\p{N}_.]+)`)
+
+// ParseMentions возвращает уникальные username из текста в порядке появления
+func ParseMentions(body string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, m := range mentionRe.FindAllStringSubmatch(body, -1) {
+		name := strings.TrimRight(m[1], ".")
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, name)
+	}
+	return out
+}
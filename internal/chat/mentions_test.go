@@ -0,0 +1,33 @@
+package chat
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", "привет всем", nil},
+		{"single", "@ivan зайди", []string{"ivan"}},
+		{"cyrillic", "спасибо, @Мария!", []string{"Мария"}},
+		{"underscore and dot", "@ivan_petrov.2 привет", []string{"ivan_petrov.2"}},
+		{"trailing dot", "написал @ivan.", []string{"ivan"}},
+		{"email is not a mention", "пишите на ivan@example.com", nil},
+		{"double at", "@@ivan", nil},
+		{"duplicates case-insensitive", "@Ivan и снова @ivan", []string{"Ivan"}},
+		{"order kept", "@b, @a и @c", []string{"b", "a", "c"}},
+		{"bare at", "встреча @ 18:00", nil},
+		{"in parentheses", "(@ivan)", []string{"ivan"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMentions(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	GetUserByID(ctx context.Context, userID int64) (*db.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*db.User, error)
+	BuildingAddresses(ctx context.Context, userID int64) ([]string, error)
+
+	UpsertChat(ctx context.Context, key, kind, title string) (*db.Chat, error)
+	GetChat(ctx context.Context, chatID int64) (*db.Chat, error)
+	AddMember(ctx context.Context, chatID, userID int64) error
+	RemoveStaleBuildingMemberships(ctx context.Context, userID int64, keepKeys []string) error
+	RemoveFormerBuildingMembers(ctx context.Context, chatID int64) error
+	GetMember(ctx context.Context, chatID, userID int64) (*db.ChatMember, error)
+	ListUserChats(ctx context.Context, userID int64) ([]db.ListUserChatsRow, error)
+	ListMembers(ctx context.Context, chatID int64) ([]db.ListChatMembersRow, error)
+
+	CreateMessage(ctx context.Context, chatID, senderID int64, body string, mentions []int64) (*db.ChatMessage, error)
+	GetMessage(ctx context.Context, messageID int64) (*db.ChatMessage, error)
+	ListMessages(ctx context.Context, chatID, beforeID int64, limit int) ([]db.ChatMessage, error)
+	UpdateMessage(ctx context.Context, messageID, senderID int64, body string, mentions []int64, now time.Time) (*db.ChatMessage, error)
+	DeleteMessage(ctx context.Context, messageID, senderID int64, now time.Time) (*db.ChatMessage, error)
+	ListMentions(ctx context.Context, messageIDs []int64) ([]db.ListChatMessageMentionsRow, error)
+	MarkRead(ctx context.Context, chatID, userID, messageID int64, now time.Time) (*db.ChatMember, error)
+}
+
+type ChatRepository struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+func NewChatRepository(pool *pgxpool.Pool) *ChatRepository {
+	return &ChatRepository{pool: pool, queries: db.New(pool)}
+}
+
+// nil, nil — пользователя нет
+func (r *ChatRepository) GetUserByID(ctx context.Context, userID int64) (*db.User, error) {
+	u, err := r.queries.GetUserByID(ctx, int32(userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+// nil, nil — пользователя нет
+func (r *ChatRepository) GetUserByUsername(ctx context.Context, username string) (*db.User, error) {
+	u, err := r.queries.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *ChatRepository) BuildingAddresses(ctx context.Context, userID int64) ([]string, error) {
+	return r.queries.GetUserBuildingAddresses(ctx, pgtype.Int4{Int32: int32(userID), Valid: true})
+}
+
+func (r *ChatRepository) UpsertChat(ctx context.Context, key, kind, title string) (*db.Chat, error) {
+	c, err := r.queries.UpsertChat(ctx, db.UpsertChatParams{
+		ChatKey: key,
+		Kind:    kind,
+		Title:   pgtype.Text{String: title, Valid: title != ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// nil, nil — чата нет
+func (r *ChatRepository) GetChat(ctx context.Context, chatID int64) (*db.Chat, error) {
+	c, err := r.queries.GetChat(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *ChatRepository) AddMember(ctx context.Context, chatID, userID int64) error {
+	return r.queries.AddChatMember(ctx, db.AddChatMemberParams{ChatID: chatID, UserID: int32(userID)})
+}
+
+func (r *ChatRepository) RemoveStaleBuildingMemberships(ctx context.Context, userID int64, keepKeys []string) error {
+	if keepKeys == nil {
+		keepKeys = []string{}
+	}
+	return r.queries.RemoveStaleBuildingMemberships(ctx, db.RemoveStaleBuildingMembershipsParams{
+		UserID:   int32(userID),
+		KeepKeys: keepKeys,
+	})
+}
+
+// Для личных чатов ничего не делает
+func (r *ChatRepository) RemoveFormerBuildingMembers(ctx context.Context, chatID int64) error {
+	return r.queries.RemoveFormerBuildingMembers(ctx, chatID)
+}
+
+// nil, nil — пользователь не участник
+func (r *ChatRepository) GetMember(ctx context.Context, chatID, userID int64) (*db.ChatMember, error) {
+	m, err := r.queries.GetChatMember(ctx, db.GetChatMemberParams{ChatID: chatID, UserID: int32(userID)})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *ChatRepository) ListUserChats(ctx context.Context, userID int64) ([]db.ListUserChatsRow, error) {
+	return r.queries.ListUserChats(ctx, int32(userID))
+}
+
+func (r *ChatRepository) ListMembers(ctx context.Context, chatID int64) ([]db.ListChatMembersRow, error) {
+	return r.queries.ListChatMembers(ctx, chatID)
+}
+
+// Сообщение и его упоминания сохраняются в одной транзакции
+func (r *ChatRepository) CreateMessage(ctx context.Context, chatID, senderID int64, body string, mentions []int64) (*db.ChatMessage, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	m, err := q.CreateChatMessage(ctx, db.CreateChatMessageParams{
+		ChatID:   chatID,
+		SenderID: pgtype.Int4{Int32: int32(senderID), Valid: true},
+		Body:     body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := insertMentions(ctx, q, m.ID, mentions); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// nil, nil — сообщения нет
+func (r *ChatRepository) GetMessage(ctx context.Context, messageID int64) (*db.ChatMessage, error) {
+	m, err := r.queries.GetChatMessage(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *ChatRepository) ListMessages(ctx context.Context, chatID, beforeID int64, limit int) ([]db.ChatMessage, error) {
+	return r.queries.ListChatMessages(ctx, db.ListChatMessagesParams{
+		ChatID:   chatID,
+		BeforeID: beforeID,
+		PageSize: int32(limit),
+	})
+}
+
+// Правка текста; упоминания пересчитываются. nil, nil — сообщение не найдено,
+// удалено или принадлежит другому пользователю.
+func (r *ChatRepository) UpdateMessage(ctx context.Context, messageID, senderID int64, body string, mentions []int64, now time.Time) (*db.ChatMessage, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	m, err := q.UpdateChatMessage(ctx, db.UpdateChatMessageParams{
+		ID:       messageID,
+		SenderID: pgtype.Int4{Int32: int32(senderID), Valid: true},
+		Body:     body,
+		EditedAt: pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := q.DeleteChatMessageMentions(ctx, messageID); err != nil {
+		return nil, err
+	}
+	if err := insertMentions(ctx, q, messageID, mentions); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// nil, nil — сообщение не найдено, уже удалено или принадлежит другому пользователю
+func (r *ChatRepository) DeleteMessage(ctx context.Context, messageID, senderID int64, now time.Time) (*db.ChatMessage, error) {
+	m, err := r.queries.DeleteChatMessage(ctx, db.DeleteChatMessageParams{
+		ID:        messageID,
+		SenderID:  pgtype.Int4{Int32: int32(senderID), Valid: true},
+		DeletedAt: pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *ChatRepository) ListMentions(ctx context.Context, messageIDs []int64) ([]db.ListChatMessageMentionsRow, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	return r.queries.ListChatMessageMentions(ctx, messageIDs)
+}
+
+func (r *ChatRepository) MarkRead(ctx context.Context, chatID, userID, messageID int64, now time.Time) (*db.ChatMember, error) {
+	m, err := r.queries.MarkChatRead(ctx, db.MarkChatReadParams{
+		MessageID: messageID,
+		ReadAt:    pgtype.Timestamp{Time: now, Valid: true},
+		ChatID:    chatID,
+		UserID:    int32(userID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func insertMentions(ctx context.Context, q *db.Queries, messageID int64, userIDs []int64) error {
+	for _, id := range userIDs {
+		if err := q.CreateChatMessageMention(ctx, db.CreateChatMessageMentionParams{
+			MessageID: messageID,
+			UserID:    int32(id),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
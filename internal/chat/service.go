@@ -0,0 +1,551 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"domofon/internal/config"
+	"domofon/internal/db"
+	"domofon/internal/push"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	KindBuilding = "building"
+	KindDirect   = "direct"
+
+	// Сколько символов сообщения показывать в push-уведомлении
+	pushPreviewLength = 200
+)
+
+var (
+	ErrChatNotFound    = errors.New("чат не найден")
+	ErrMessageNotFound = errors.New("сообщение не найдено")
+	ErrEmptyMessage    = errors.New("сообщение не может быть пустым")
+	ErrMessageTooLong  = errors.New("сообщение слишком длинное")
+	ErrUserNotFound    = errors.New("пользователь не найден")
+	ErrDirectToSelf    = errors.New("нельзя написать самому себе")
+)
+
+// Push-уведомления участникам, которые не держат открытым поток событий
+type Notifier interface {
+	Notify(ctx context.Context, userID int64, n push.Notification) (int, error)
+}
+
+type ChatService struct {
+	repo     Repository
+	hub      *Hub
+	notifier Notifier
+	cfg      *config.ChatConfig
+}
+
+func NewChatService(repo Repository, hub *Hub, notifier Notifier, cfg *config.ChatConfig) *ChatService {
+	return &ChatService{repo: repo, hub: hub, notifier: notifier, cfg: cfg}
+}
+
+func buildingKey(address string) string { return "building:" + address }
+
+func directKey(a, b int64) string {
+	if a > b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("direct:%d:%d", a, b)
+}
+
+// Чаты пользователя. Перед выдачей состав чатов домов сверяется с его квартирами:
+// новые дома добавляются, из чатов домов, где квартир больше нет, он выходит.
+func (s *ChatService) ListChats(ctx context.Context, userID int64) ([]ChatResponse, error) {
+	if err := s.syncBuildingChats(ctx, userID); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.ListUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ChatResponse, 0, len(rows))
+	for _, row := range rows {
+		c := ChatResponse{
+			ID:                row.ID,
+			Kind:              row.Kind,
+			Title:             row.Title.String,
+			LastMessageID:     row.LastMessageID,
+			LastReadMessageID: row.LastReadMessageID.Int64,
+			UnreadCount:       row.UnreadCount,
+		}
+		if row.LastMessageAt.Valid {
+			t := row.LastMessageAt.Time
+			c.LastMessageAt = &t
+		}
+		if row.Kind == KindDirect {
+			members, err := s.repo.ListMembers(ctx, row.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range members {
+				if int64(m.UserID) != userID {
+					peer := memberResponse(m)
+					c.Peer = &peer
+					c.Title = displayName(m)
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (s *ChatService) syncBuildingChats(ctx context.Context, userID int64) error {
+	addresses, err := s.repo.BuildingAddresses(ctx, userID)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		key := buildingKey(addr)
+		keys = append(keys, key)
+		c, err := s.repo.UpsertChat(ctx, key, KindBuilding, addr)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.AddMember(ctx, c.ID, userID); err != nil {
+			return err
+		}
+	}
+	return s.repo.RemoveStaleBuildingMemberships(ctx, userID, keys)
+}
+
+// Личный чат с пользователем по username; создаётся при первом обращении.
+// Написать можно только соседу — тому, у кого есть квартира в одном из домов
+// пользователя. Для остальных ответ как для несуществующего username, чтобы
+// не раскрывать имена и аватары жильцов чужих домов.
+func (s *ChatService) OpenDirect(ctx context.Context, userID int64, username string) (*ChatResponse, error) {
+	peer, err := s.repo.GetUserByUsername(ctx, strings.TrimPrefix(strings.TrimSpace(username), "@"))
+	if err != nil {
+		return nil, err
+	}
+	if peer == nil {
+		return nil, ErrUserNotFound
+	}
+	peerID := int64(peer.ID)
+	if peerID == userID {
+		return nil, ErrDirectToSelf
+	}
+	neighbours, err := s.shareBuilding(ctx, userID, peerID)
+	if err != nil {
+		return nil, err
+	}
+	if !neighbours {
+		return nil, ErrUserNotFound
+	}
+
+	c, err := s.repo.UpsertChat(ctx, directKey(userID, peerID), KindDirect, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range []int64{userID, peerID} {
+		if err := s.repo.AddMember(ctx, c.ID, id); err != nil {
+			return nil, err
+		}
+	}
+	return &ChatResponse{
+		ID:    c.ID,
+		Kind:  KindDirect,
+		Title: displayName(db.ListChatMembersRow{Username: peer.Username, FirstName: peer.FirstName, LastName: peer.LastName}),
+		Peer: &MemberResponse{
+			UserID:    peerID,
+			Username:  peer.Username,
+			FirstName: peer.FirstName.String,
+			LastName:  peer.LastName.String,
+			AvatarURL: peer.AvatarUrl.String,
+		},
+	}, nil
+}
+
+// Есть ли у двух пользователей квартиры в одном доме (общий чат дома)
+func (s *ChatService) shareBuilding(ctx context.Context, userID, peerID int64) (bool, error) {
+	own, err := s.repo.BuildingAddresses(ctx, userID)
+	if err != nil || len(own) == 0 {
+		return false, err
+	}
+	peer, err := s.repo.BuildingAddresses(ctx, peerID)
+	if err != nil {
+		return false, err
+	}
+	for _, addr := range peer {
+		if slices.Contains(own, addr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Участники с отметками прочтения
+func (s *ChatService) Members(ctx context.Context, userID, chatID int64) ([]MemberResponse, error) {
+	if err := s.requireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.ListMembers(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MemberResponse, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, memberResponse(m))
+	}
+	return out, nil
+}
+
+// История от новых к старым. beforeID = 0 — последние сообщения.
+func (s *ChatService) ListMessages(ctx context.Context, userID, chatID, beforeID int64, limit int) ([]MessageResponse, error) {
+	if err := s.requireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = s.cfg.PageSize
+	}
+	if limit > s.cfg.MaxPageSize {
+		limit = s.cfg.MaxPageSize
+	}
+
+	msgs, err := s.repo.ListMessages(ctx, chatID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.repo.ListMembers(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return s.messageResponses(ctx, msgs, members)
+}
+
+func (s *ChatService) SendMessage(ctx context.Context, userID, chatID int64, body string) (*MessageResponse, error) {
+	body, err := s.validateBody(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+	mentions, err := s.resolveMentions(ctx, chatID, body)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := s.repo.CreateMessage(ctx, chatID, userID, body, mentions)
+	if err != nil {
+		return nil, err
+	}
+	// Отправленное сообщение прочитано самим отправителем
+	if _, err := s.repo.MarkRead(ctx, chatID, userID, msg.ID, time.Now()); err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("[chat] Не удалось отметить прочтение")
+	}
+	resp, err := s.publishMessage(ctx, EventMessageCreated, msg)
+	if err != nil {
+		return nil, err
+	}
+	go s.notifyMembers(context.WithoutCancel(ctx), msg, mentions)
+	return resp, nil
+}
+
+func (s *ChatService) EditMessage(ctx context.Context, userID, messageID int64, body string) (*MessageResponse, error) {
+	body, err := s.validateBody(body)
+	if err != nil {
+		return nil, err
+	}
+	orig, err := s.repo.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if orig == nil {
+		return nil, ErrMessageNotFound
+	}
+	if err := s.requireMember(ctx, orig.ChatID, userID); err != nil {
+		return nil, err
+	}
+	mentions, err := s.resolveMentions(ctx, orig.ChatID, body)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := s.repo.UpdateMessage(ctx, messageID, userID, body, mentions, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, ErrMessageNotFound
+	}
+	return s.publishMessage(ctx, EventMessageUpdated, msg)
+}
+
+// Удаление своего сообщения. В истории остаётся отметка без текста.
+func (s *ChatService) DeleteMessage(ctx context.Context, userID, messageID int64) error {
+	orig, err := s.repo.GetMessage(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if orig == nil {
+		return ErrMessageNotFound
+	}
+	if err := s.requireMember(ctx, orig.ChatID, userID); err != nil {
+		return err
+	}
+	msg, err := s.repo.DeleteMessage(ctx, messageID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		return ErrMessageNotFound
+	}
+	_, err = s.publishMessage(ctx, EventMessageDeleted, msg)
+	return err
+}
+
+// Отметка прочтения до messageID включительно; остальные участники получают chat.read
+func (s *ChatService) MarkRead(ctx context.Context, userID, chatID, messageID int64) (*ReadReceipt, error) {
+	if err := s.requireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+	msg, err := s.repo.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil || msg.ChatID != chatID {
+		return nil, ErrMessageNotFound
+	}
+
+	m, err := s.repo.MarkRead(ctx, chatID, userID, messageID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	receipt := &ReadReceipt{
+		UserID:            userID,
+		LastReadMessageID: m.LastReadMessageID.Int64,
+		ReadAt:            m.LastReadAt.Time,
+	}
+	if ids, err := s.memberIDs(ctx, chatID); err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("[chat] Не удалось разослать отметку прочтения")
+	} else {
+		s.hub.Publish(ids, Event{Type: EventChatRead, ChatID: chatID, Read: receipt})
+	}
+	return receipt, nil
+}
+
+// Поток событий всех чатов пользователя. Перед подпиской состав чатов домов
+// сверяется с его квартирами, как в ListChats.
+func (s *ChatService) Subscribe(ctx context.Context, userID int64) (<-chan Event, func(), error) {
+	if err := s.syncBuildingChats(ctx, userID); err != nil {
+		return nil, nil, err
+	}
+	events, cancel := s.hub.Subscribe(userID)
+	return events, cancel, nil
+}
+
+// Участие в чате дома держится, пока у пользователя есть там квартира: перед
+// проверкой из чата убираются все выехавшие, и события им больше не рассылаются
+func (s *ChatService) requireMember(ctx context.Context, chatID, userID int64) error {
+	if err := s.repo.RemoveFormerBuildingMembers(ctx, chatID); err != nil {
+		return err
+	}
+	m, err := s.repo.GetMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		// Не раскрываем, существует ли чужой чат
+		return ErrChatNotFound
+	}
+	return nil
+}
+
+func (s *ChatService) validateBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", ErrEmptyMessage
+	}
+	if s.cfg.MaxMessageLength > 0 && utf8.RuneCountInString(body) > s.cfg.MaxMessageLength {
+		return "", ErrMessageTooLong
+	}
+	return body, nil
+}
+
+// Упоминания, которые разрешились в участников этого чата; остальные — просто текст.
+// Разбираются только первые CHAT_MAX_MENTIONS имён.
+func (s *ChatService) resolveMentions(ctx context.Context, chatID int64, body string) ([]int64, error) {
+	names := ParseMentions(body)
+	if len(names) > s.cfg.MaxMentions {
+		names = names[:s.cfg.MaxMentions]
+	}
+	var ids []int64
+	for _, name := range names {
+		u, err := s.repo.GetUserByUsername(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			continue
+		}
+		m, err := s.repo.GetMember(ctx, chatID, int64(u.ID))
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			ids = append(ids, int64(u.ID))
+		}
+	}
+	return ids, nil
+}
+
+func (s *ChatService) publishMessage(ctx context.Context, eventType string, msg *db.ChatMessage) (*MessageResponse, error) {
+	members, err := s.repo.ListMembers(ctx, msg.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	resps, err := s.messageResponses(ctx, []db.ChatMessage{*msg}, members)
+	if err != nil {
+		return nil, err
+	}
+	resp := &resps[0]
+
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, int64(m.UserID))
+	}
+	s.hub.Publish(ids, Event{Type: eventType, ChatID: msg.ChatID, Message: resp})
+	return resp, nil
+}
+
+// Уведомляет участников, кроме отправителя. Упомянутые получают уведомление
+// типа chat_mention, остальные — chat_message; каждый тип отключается отдельно.
+func (s *ChatService) notifyMembers(ctx context.Context, msg *db.ChatMessage, mentions []int64) {
+	if s.notifier == nil {
+		return
+	}
+	c, err := s.repo.GetChat(ctx, msg.ChatID)
+	if err != nil || c == nil {
+		return
+	}
+	members, err := s.repo.ListMembers(ctx, msg.ChatID)
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", msg.ChatID).Msg("[chat] Не удалось получить участников для уведомлений")
+		return
+	}
+
+	sender := ""
+	for _, m := range members {
+		if m.UserID == msg.SenderID.Int32 {
+			sender = displayName(m)
+		}
+	}
+	preview := msg.Body
+	if utf8.RuneCountInString(preview) > pushPreviewLength {
+		preview = string([]rune(preview)[:pushPreviewLength]) + "…"
+	}
+	data := map[string]string{
+		"chat_id":    strconv.FormatInt(msg.ChatID, 10),
+		"message_id": strconv.FormatInt(msg.ID, 10),
+	}
+
+	for _, m := range members {
+		if m.UserID == msg.SenderID.Int32 {
+			continue
+		}
+		n := push.Notification{Event: push.EventChatMessage, Title: sender, Body: preview, Data: data}
+		if c.Kind == KindBuilding {
+			n.Title = sender + " · " + c.Title.String
+		}
+		if slices.Contains(mentions, int64(m.UserID)) {
+			n.Event = push.EventChatMention
+		}
+		if _, err := s.notifier.Notify(ctx, int64(m.UserID), n); err != nil {
+			log.Error().Err(err).Int64("chat_id", msg.ChatID).Int32("user_id", m.UserID).Msg("[chat] Не удалось отправить уведомление")
+		}
+	}
+}
+
+func (s *ChatService) memberIDs(ctx context.Context, chatID int64) ([]int64, error) {
+	members, err := s.repo.ListMembers(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, int64(m.UserID))
+	}
+	return ids, nil
+}
+
+func (s *ChatService) messageResponses(ctx context.Context, msgs []db.ChatMessage, members []db.ListChatMembersRow) ([]MessageResponse, error) {
+	ids := make([]int64, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+	mentionRows, err := s.repo.ListMentions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	mentions := make(map[int64][]MentionResponse)
+	for _, row := range mentionRows {
+		mentions[row.MessageID] = append(mentions[row.MessageID], MentionResponse{
+			UserID:   int64(row.UserID),
+			Username: row.Username,
+		})
+	}
+
+	out := make([]MessageResponse, 0, len(msgs))
+	for _, m := range msgs {
+		r := MessageResponse{
+			ID:        m.ID,
+			ChatID:    m.ChatID,
+			SenderID:  int64(m.SenderID.Int32),
+			Body:      m.Body,
+			Mentions:  mentions[m.ID],
+			CreatedAt: m.CreatedAt.Time,
+			Deleted:   m.DeletedAt.Valid,
+		}
+		if r.Mentions == nil {
+			r.Mentions = []MentionResponse{}
+		}
+		if m.EditedAt.Valid {
+			t := m.EditedAt.Time
+			r.EditedAt = &t
+		}
+		for _, mem := range members {
+			if mem.UserID != m.SenderID.Int32 && mem.LastReadMessageID.Int64 >= m.ID {
+				r.ReadCount++
+			}
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func memberResponse(m db.ListChatMembersRow) MemberResponse {
+	r := MemberResponse{
+		UserID:            int64(m.UserID),
+		Username:          m.Username,
+		FirstName:         m.FirstName.String,
+		LastName:          m.LastName.String,
+		AvatarURL:         m.AvatarUrl.String,
+		LastReadMessageID: m.LastReadMessageID.Int64,
+	}
+	if m.LastReadAt.Valid {
+		t := m.LastReadAt.Time
+		r.LastReadAt = &t
+	}
+	return r
+}
+
+func displayName(m db.ListChatMembersRow) string {
+	if name := strings.TrimSpace(m.FirstName.String + " " + m.LastName.String); name != "" {
+		return name
+	}
+	return "@" + m.Username
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки доступа через домофон: гостевые пропуска и т.п.
+type AccessConfig struct {
+	// Длина цифрового гостевого кода
+	GuestCodeLength int
+	// Срок действия пропуска по умолчанию и максимальный
+	GuestDefaultValidity time.Duration
+	GuestMaxValidity     time.Duration
+	// Максимальное число проходов по одному пропуску
+	GuestMaxUses int
+	// Сколько пропусков с истёкшим сроком показывать в списке жителя
+	GuestHistoryWindow time.Duration
+	// Время жизни QR-кода жителя: приложение обновляет код с этим периодом
+	QRTokenTTL time.Duration
+	// Допустимое расхождение часов при проверке QR-кода
+	QRClockSkew time.Duration
+	// Максимум записей в одной выгрузке офлайн-журнала панели
+	OfflineMaxBatch int
+	// Расхождение часов панели, после которого время записей поправляется и помечается
+	OfflineMaxClockSkew time.Duration
+	// Записи старше этого срока принимаются, но помечаются too_old
+	OfflineMaxAge time.Duration
+	// Роли, которые блокируют и перевыпускают ключи любых жителей
+	KeyAdminRoles []string
+}
+
+func LoadAccessConfig() *AccessConfig {
+	cfg := &AccessConfig{
+		GuestCodeLength:      getInt("GUEST_CODE_LENGTH", 6),
+		GuestDefaultValidity: getDuration("GUEST_PASS_DEFAULT_VALIDITY", 24*time.Hour),
+		GuestMaxValidity:     getDuration("GUEST_PASS_MAX_VALIDITY", 7*24*time.Hour),
+		GuestMaxUses:         getInt("GUEST_PASS_MAX_USES", 20),
+		GuestHistoryWindow:   getDuration("GUEST_PASS_HISTORY_WINDOW", 30*24*time.Hour),
+		QRTokenTTL:           getDuration("QR_TOKEN_TTL", 30*time.Second),
+		QRClockSkew:          getDuration("QR_CLOCK_SKEW", 5*time.Second),
+		OfflineMaxBatch:      getInt("ACCESS_OFFLINE_MAX_BATCH", 500),
+		OfflineMaxClockSkew:  getDuration("ACCESS_OFFLINE_MAX_CLOCK_SKEW", 2*time.Minute),
+		OfflineMaxAge:        getDuration("ACCESS_OFFLINE_MAX_AGE", 30*24*time.Hour),
+		KeyAdminRoles:        splitList(getEnv("KEY_ADMIN_ROLES", "admin,manager")),
+	}
+	if cfg.GuestCodeLength < 4 {
+		cfg.GuestCodeLength = 4
+	}
+	if cfg.GuestMaxUses < 1 {
+		cfg.GuestMaxUses = 1
+	}
+	if cfg.QRTokenTTL < 5*time.Second {
+		cfg.QRTokenTTL = 5 * time.Second
+	}
+	if cfg.OfflineMaxBatch < 1 {
+		cfg.OfflineMaxBatch = 1
+	}
+	if cfg.GuestDefaultValidity > cfg.GuestMaxValidity {
+		cfg.GuestDefaultValidity = cfg.GuestMaxValidity
+	}
+
+	log.Info().
+		Int("guest_code_length", cfg.GuestCodeLength).
+		Dur("guest_max_validity", cfg.GuestMaxValidity).
+		Int("guest_max_uses", cfg.GuestMaxUses).
+		Dur("qr_token_ttl", cfg.QRTokenTTL).
+		Int("offline_max_batch", cfg.OfflineMaxBatch).
+		Dur("offline_max_clock_skew", cfg.OfflineMaxClockSkew).
+		Strs("key_admin_roles", cfg.KeyAdminRoles).
+		Msg("[config] Загружены настройки доступа")
+
+	return cfg
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type AnnouncementConfig struct {
+	// Роли, которым можно публиковать и отменять объявления
+	PublisherRoles []string
+	// Каталог для вложений. Не должен совпадать с публичным uploads:
+	// файлы отдаются только адресатам объявления.
+	StorageDir        string
+	MaxAttachmentSize int64
+	// Разрешённые типы вложений; тип определяется по содержимому файла
+	AttachmentTypes []string
+	// Размер страницы списка для управляющей компании по умолчанию и максимальный
+	PageSize    int
+	MaxPageSize int
+	// Как часто проверять запланированные объявления, чтобы разослать push
+	// в момент публикации; 0 — не проверять
+	NotifyInterval time.Duration
+}
+
+func LoadAnnouncementConfig() *AnnouncementConfig {
+	cfg := &AnnouncementConfig{
+		PublisherRoles:    splitList(getEnv("ANNOUNCEMENT_PUBLISHER_ROLES", "admin,manager")),
+		StorageDir:        getEnv("ANNOUNCEMENT_STORAGE_DIR", "storage/announcements"),
+		MaxAttachmentSize: int64(getInt("ANNOUNCEMENT_MAX_ATTACHMENT_SIZE", 10<<20)),
+		AttachmentTypes:   splitList(getEnv("ANNOUNCEMENT_ATTACHMENT_TYPES", "image/jpeg,image/png,image/webp,application/pdf")),
+		PageSize:          getInt("ANNOUNCEMENT_PAGE_SIZE", 50),
+		MaxPageSize:       getInt("ANNOUNCEMENT_MAX_PAGE_SIZE", 200),
+		NotifyInterval:    getDuration("ANNOUNCEMENT_NOTIFY_INTERVAL", time.Minute),
+	}
+	if cfg.PageSize < 1 {
+		cfg.PageSize = 1
+	}
+	if cfg.MaxPageSize < cfg.PageSize {
+		cfg.MaxPageSize = cfg.PageSize
+	}
+
+	log.Info().
+		Strs("publisher_roles", cfg.PublisherRoles).
+		Str("storage_dir", cfg.StorageDir).
+		Int64("max_attachment_size", cfg.MaxAttachmentSize).
+		Strs("attachment_types", cfg.AttachmentTypes).
+		Dur("notify_interval", cfg.NotifyInterval).
+		Msg("[config] Загружены настройки объявлений")
+
+	return cfg
+}
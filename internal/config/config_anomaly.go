@@ -0,0 +1,63 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки фоновой проверки журнала доступа на аномалии
+type AnomalyConfig struct {
+	// Как часто проверять новые записи журнала и сколько записей брать за раз
+	ScanInterval time.Duration
+	BatchSize    int
+	// Дольше этого транзакция, пишущая в журнал, не длится: более свежие записи
+	// проверяются повторно, чтобы не пропустить запись, закоммиченную позже соседних
+	CommitLag time.Duration
+	// Один ключ в разных домах в пределах этого интервала — impossible_travel
+	TravelWindow time.Duration
+	// DenialThreshold отказов за DenialWindow на одном устройстве или по одному ключу — repeated_denials
+	DenialWindow    time.Duration
+	DenialThreshold int
+	// За какой период смотреть обычные часы использования ключа и сколько
+	// проходов нужно, чтобы судить о необычном часе
+	BaselinePeriod  time.Duration
+	BaselineMinUses int
+	// Часовой пояс, в котором считаются часы прохода
+	Timezone string
+	// Роли, которым доступен список тревог
+	AdminRoles []string
+}
+
+func LoadAnomalyConfig() *AnomalyConfig {
+	cfg := &AnomalyConfig{
+		ScanInterval:    getDuration("ANOMALY_SCAN_INTERVAL", time.Minute),
+		BatchSize:       getInt("ANOMALY_BATCH_SIZE", 500),
+		CommitLag:       getDuration("ANOMALY_COMMIT_LAG", 2*time.Minute),
+		TravelWindow:    getDuration("ANOMALY_TRAVEL_WINDOW", 10*time.Minute),
+		DenialWindow:    getDuration("ANOMALY_DENIAL_WINDOW", 5*time.Minute),
+		DenialThreshold: getInt("ANOMALY_DENIAL_THRESHOLD", 5),
+		BaselinePeriod:  getDuration("ANOMALY_BASELINE_PERIOD", 30*24*time.Hour),
+		BaselineMinUses: getInt("ANOMALY_BASELINE_MIN_USES", 20),
+		Timezone:        getEnv("ANOMALY_TIMEZONE", "Europe/Moscow"),
+		AdminRoles:      splitList(getEnv("ANOMALY_ADMIN_ROLES", "admin,manager")),
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 1
+	}
+	if cfg.DenialThreshold < 2 {
+		cfg.DenialThreshold = 2
+	}
+
+	log.Info().
+		Dur("scan_interval", cfg.ScanInterval).
+		Dur("commit_lag", cfg.CommitLag).
+		Dur("travel_window", cfg.TravelWindow).
+		Int("denial_threshold", cfg.DenialThreshold).
+		Dur("denial_window", cfg.DenialWindow).
+		Str("timezone", cfg.Timezone).
+		Strs("admin_roles", cfg.AdminRoles).
+		Msg("[config] Загружены настройки поиска аномалий доступа")
+
+	return cfg
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки защиты входа от перебора паролей
+type LockoutConfig struct {
+	// После скольких неудач подряд включается задержка между попытками
+	BackoffAfter int
+	// Начальная задержка, удваивается с каждой следующей неудачей
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// После скольких неудач по номеру аккаунт блокируется
+	LockAfter    int
+	LockDuration time.Duration
+	// После скольких неудач с одного IP блокируется адрес
+	IPLockAfter int
+	// Неудачи старше окна не учитываются
+	Window time.Duration
+}
+
+type AuthConfig struct {
+	Lockout LockoutConfig
+	// Сколько живёт билет подтверждения номера между шагами сценария
+	VerificationTicketTTL time.Duration
+
+	// Адрес API, от которого строятся ссылки в письмах
+	PublicURL string
+	// Страница фронтенда, куда ведёт ссылка сброса пароля (токен добавляется в ?token=)
+	PasswordResetURL string
+	EmailConfirmTTL  time.Duration
+	PasswordResetTTL time.Duration
+
+	MFA          MFAConfig
+	Password     PasswordPolicyConfig
+	PasswordHash PasswordHashConfig
+}
+
+func LoadAuthConfig() *AuthConfig {
+	cfg := &AuthConfig{
+		Lockout: LockoutConfig{
+			BackoffAfter: getInt("LOGIN_BACKOFF_AFTER", 3),
+			BackoffBase:  getDuration("LOGIN_BACKOFF_BASE", time.Second),
+			BackoffMax:   getDuration("LOGIN_BACKOFF_MAX", 5*time.Minute),
+			LockAfter:    getInt("LOGIN_LOCK_AFTER", 10),
+			LockDuration: getDuration("LOGIN_LOCK_DURATION", 30*time.Minute),
+			IPLockAfter:  getInt("LOGIN_IP_LOCK_AFTER", 50),
+			Window:       getDuration("LOGIN_ATTEMPT_WINDOW", time.Hour),
+		},
+		VerificationTicketTTL: getDuration("VERIFICATION_TICKET_TTL", 15*time.Minute),
+		PublicURL:             strings.TrimRight(getEnv("APP_PUBLIC_URL", "http://localhost:8080"), "/"),
+		EmailConfirmTTL:       getDuration("EMAIL_CONFIRM_TTL", 24*time.Hour),
+		PasswordResetTTL:      getDuration("PASSWORD_RESET_TTL", time.Hour),
+		MFA:                   loadMFAConfig(),
+		Password:              loadPasswordPolicyConfig(),
+		PasswordHash:          loadPasswordHashConfig(),
+	}
+	cfg.PasswordResetURL = getEnv("PASSWORD_RESET_URL", cfg.PublicURL+"/reset-password")
+
+	log.Info().
+		Int("backoff_after", cfg.Lockout.BackoffAfter).
+		Int("lock_after", cfg.Lockout.LockAfter).
+		Dur("lock_duration", cfg.Lockout.LockDuration).
+		Int("ip_lock_after", cfg.Lockout.IPLockAfter).
+		Str("public_url", cfg.PublicURL).
+		Strs("mfa_required_roles", cfg.MFA.RequiredRoles).
+		Msg("[config] Загружены настройки авторизации")
+
+	return cfg
+}
+
+func getInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("[config] Некорректное число, используется значение по умолчанию")
+		return def
+	}
+	return n
+}
+
+func getBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("[config] Некорректное логическое значение, используется значение по умолчанию")
+		return def
+	}
+	return b
+}
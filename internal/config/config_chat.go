@@ -0,0 +1,49 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type ChatConfig struct {
+	// Максимальная длина сообщения в символах
+	MaxMessageLength int
+	// Сколько упоминаний в одном сообщении разбирать, остальные остаются текстом
+	MaxMentions int
+	// Размер страницы истории по умолчанию и максимальный
+	PageSize    int
+	MaxPageSize int
+	// Как часто слать комментарий в поток событий, чтобы прокси не рвали соединение
+	StreamKeepAlive time.Duration
+	// Сколько событий копится для одного потока, пока клиент их не заберёт
+	StreamBuffer int
+}
+
+func LoadChatConfig() *ChatConfig {
+	cfg := &ChatConfig{
+		MaxMessageLength: getInt("CHAT_MAX_MESSAGE_LENGTH", 4000),
+		MaxMentions:      getInt("CHAT_MAX_MENTIONS", 20),
+		PageSize:         getInt("CHAT_PAGE_SIZE", 50),
+		MaxPageSize:      getInt("CHAT_MAX_PAGE_SIZE", 200),
+		StreamKeepAlive:  getDuration("CHAT_STREAM_KEEPALIVE", 25*time.Second),
+		StreamBuffer:     getInt("CHAT_STREAM_BUFFER", 64),
+	}
+	if cfg.MaxMentions < 0 {
+		cfg.MaxMentions = 0
+	}
+	if cfg.PageSize < 1 {
+		cfg.PageSize = 1
+	}
+	if cfg.MaxPageSize < cfg.PageSize {
+		cfg.MaxPageSize = cfg.PageSize
+	}
+
+	log.Info().
+		Int("max_message_length", cfg.MaxMessageLength).
+		Int("max_mentions", cfg.MaxMentions).
+		Int("page_size", cfg.PageSize).
+		Msg("[config] Загружены настройки чата")
+
+	return cfg
+}
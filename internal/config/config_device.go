@@ -0,0 +1,22 @@
+package config
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+type DeviceConfig struct {
+	// Роли, которым можно выпускать ключи устройств
+	AdminRoles []string
+}
+
+func LoadDeviceConfig() *DeviceConfig {
+	cfg := &DeviceConfig{
+		AdminRoles: splitList(getEnv("DEVICE_ADMIN_ROLES", "admin,installer")),
+	}
+
+	log.Info().
+		Strs("admin_roles", cfg.AdminRoles).
+		Msg("[config] Загружены настройки устройств")
+
+	return cfg
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки HTTP-сервера
+type HTTPConfig struct {
+	// Адреса и сети (CIDR) обратных прокси, которым можно верить
+	// в X-Forwarded-For и X-Real-IP. Пусто — IP клиента берётся из соединения.
+	TrustedProxies []string
+}
+
+func LoadHTTPConfig() *HTTPConfig {
+	cfg := &HTTPConfig{
+		TrustedProxies: splitList(getEnv("HTTP_TRUSTED_PROXIES", "")),
+	}
+
+	log.Info().
+		Strs("trusted_proxies", cfg.TrustedProxies).
+		Msg("[config] Загружены настройки HTTP")
+
+	return cfg
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки подписи JWT
+type JWTConfig struct {
+	// Каталог с приватными ключами (<kid>.pem, PKCS#8)
+	KeysDir string
+	// Алгоритм для новых ключей при ротации: RS256 или EdDSA
+	Algorithm string
+	// Как часто выпускать новый ключ подписи (0 — без ротации)
+	RotationInterval time.Duration
+	// Как часто перечитывать каталог ключей, чтобы подхватить ключ,
+	// выпущенный другим экземпляром
+	ReloadInterval time.Duration
+	// Сколько держать старый ключ для проверки после выхода из ротации
+	KeyRetention time.Duration
+
+	Issuer     string
+	Audience   string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func LoadJWTConfig() *JWTConfig {
+	cfg := &JWTConfig{
+		KeysDir:          os.Getenv("JWT_KEYS_DIR"),
+		Algorithm:        getEnv("JWT_SIGNING_ALG", "EdDSA"),
+		RotationInterval: getDuration("JWT_ROTATION_INTERVAL", 0),
+		ReloadInterval:   getDuration("JWT_KEYS_RELOAD_INTERVAL", time.Minute),
+		KeyRetention:     getDuration("JWT_KEY_RETENTION", 7*24*time.Hour),
+		Issuer:           getEnv("JWT_ISSUER", "domofon"),
+		Audience:         getEnv("JWT_AUDIENCE", "domofon"),
+		AccessTTL:        getDuration("JWT_ACCESS_TTL", 15*time.Minute),
+		RefreshTTL:       getDuration("JWT_REFRESH_TTL", 7*24*time.Hour),
+	}
+
+	// Ключ должен жить не меньше самого долгого токена, иначе refresh-токены
+	// станут непроверяемыми раньше срока
+	if cfg.KeyRetention < cfg.RefreshTTL {
+		cfg.KeyRetention = cfg.RefreshTTL
+	}
+
+	log.Info().
+		Str("keys_dir", cfg.KeysDir).
+		Str("alg", cfg.Algorithm).
+		Dur("rotation", cfg.RotationInterval).
+		Dur("reload", cfg.ReloadInterval).
+		Dur("retention", cfg.KeyRetention).
+		Dur("access_ttl", cfg.AccessTTL).
+		Dur("refresh_ttl", cfg.RefreshTTL).
+		Msg("[config] Загружены настройки JWT")
+
+	return cfg
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func getDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("[config] Некорректная длительность, используется значение по умолчанию")
+		return def
+	}
+	return d
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки офлайн-списков ключей для панелей
+type KeyListConfig struct {
+	// Как часто пересобирать списки всех устройств
+	RebuildInterval time.Duration
+	// Сколько последних версий хранить для выдачи разницы
+	RetainVersions int
+	// Через сколько после выхода новой версии устройство без подтверждения считается отставшим
+	StaleAfter time.Duration
+}
+
+func LoadKeyListConfig() *KeyListConfig {
+	cfg := &KeyListConfig{
+		RebuildInterval: getDuration("KEYLIST_REBUILD_INTERVAL", time.Minute),
+		RetainVersions:  getInt("KEYLIST_RETAIN_VERSIONS", 20),
+		StaleAfter:      getDuration("KEYLIST_STALE_AFTER", 15*time.Minute),
+	}
+	if cfg.RetainVersions < 1 {
+		cfg.RetainVersions = 1
+	}
+
+	log.Info().
+		Dur("rebuild_interval", cfg.RebuildInterval).
+		Int("retain_versions", cfg.RetainVersions).
+		Dur("stale_after", cfg.StaleAfter).
+		Msg("[config] Загружены настройки списков ключей")
+
+	return cfg
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки отправки почты
+type MailConfig struct {
+	// smtp или mock
+	Driver string
+	From   string
+
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// starttls (по умолчанию), tls (сразу TLS, обычно порт 465) или none
+	SMTPTLS string
+}
+
+func LoadMailConfig() *MailConfig {
+	cfg := &MailConfig{
+		Driver:       getEnv("MAIL_DRIVER", "mock"),
+		From:         getEnv("MAIL_FROM", "Domofon <no-reply@domofon.local>"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPTLS:      getEnv("SMTP_TLS", "starttls"),
+	}
+
+	log.Info().
+		Str("driver", cfg.Driver).
+		Str("from", cfg.From).
+		Str("smtp_host", cfg.SMTPHost).
+		Str("smtp_port", cfg.SMTPPort).
+		Str("smtp_username", cfg.SMTPUsername).
+		Str("smtp_password", mask(cfg.SMTPPassword)).
+		Str("smtp_tls", cfg.SMTPTLS).
+		Msg("[config] Загружены настройки почты")
+
+	return cfg
+}
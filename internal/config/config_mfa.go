@@ -0,0 +1,51 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки второго фактора (TOTP)
+type MFAConfig struct {
+	// Название сервиса в приложении-аутентификаторе
+	Issuer string
+	// Сколько живёт токен второго шага входа
+	ChallengeTTL time.Duration
+	// Роли, которым второй фактор обязателен
+	RequiredRoles []string
+	// Ключ AES-256 для шифрования секретов TOTP в базе (32 байта, base64).
+	// Без него подключить второй фактор нельзя.
+	SecretKey     []byte
+	RecoveryCodes int
+}
+
+func loadMFAConfig() MFAConfig {
+	cfg := MFAConfig{
+		Issuer:        getEnv("MFA_ISSUER", "Domofon"),
+		ChallengeTTL:  getDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		RequiredRoles: splitList(getEnv("MFA_REQUIRED_ROLES", "admin,installer")),
+		RecoveryCodes: getInt("MFA_RECOVERY_CODES", 10),
+	}
+
+	if v := os.Getenv("MFA_SECRET_KEY"); v != "" {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err == nil && len(key) == 32 {
+			cfg.SecretKey = key
+		} else {
+			log.Error().Msg("[config] MFA_SECRET_KEY должен быть 32 байтами в base64, второй фактор недоступен")
+		}
+	} else {
+		log.Warn().Msg("[config] MFA_SECRET_KEY не задан, второй фактор недоступен")
+	}
+
+	// Без ключа обязательный второй фактор не подключить: такие роли не смогли бы
+	// войти или, хуже, вошли бы без него — сервер не должен стартовать
+	if len(cfg.SecretKey) == 0 && len(cfg.RequiredRoles) > 0 {
+		log.Fatal().Strs("roles", cfg.RequiredRoles).Msg("[config] MFA_REQUIRED_ROLES требует корректного MFA_SECRET_KEY")
+	}
+
+	return cfg
+}
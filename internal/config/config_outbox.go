@@ -0,0 +1,67 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки очереди исходящих сообщений (SMS, email, push)
+type OutboxConfig struct {
+	// Сколько воркеров одновременно разбирают очередь
+	Workers int
+	// Сколько сообщений воркер забирает за один проход
+	BatchSize    int
+	PollInterval time.Duration
+	// Сколько сообщение считается занятым воркером; после — его заберёт другой.
+	// Блокировка ставится на всю пачку, поэтому не меньше (BatchSize+1) * SendTimeout.
+	LockTimeout time.Duration
+	SendTimeout time.Duration
+	// После стольких неудачных попыток сообщение уходит в dead
+	MaxAttempts int
+	// Задержка перед повтором: RetryBase * 2^(попытка-1), не больше RetryMax
+	RetryBase time.Duration
+	RetryMax  time.Duration
+}
+
+func LoadOutboxConfig() *OutboxConfig {
+	cfg := &OutboxConfig{
+		Workers:      getInt("OUTBOX_WORKERS", 4),
+		BatchSize:    getInt("OUTBOX_BATCH_SIZE", 10),
+		PollInterval: getDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		LockTimeout:  getDuration("OUTBOX_LOCK_TIMEOUT", 15*time.Minute),
+		SendTimeout:  getDuration("OUTBOX_SEND_TIMEOUT", time.Minute),
+		MaxAttempts:  getInt("OUTBOX_MAX_ATTEMPTS", 5),
+		RetryBase:    getDuration("OUTBOX_RETRY_BASE", 10*time.Second),
+		RetryMax:     getDuration("OUTBOX_RETRY_MAX", 30*time.Minute),
+	}
+
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 1
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	// Последнее сообщение пачки отправляется не раньше, чем истекут таймауты
+	// всех предыдущих; запас в один SendTimeout — на отметки в базе
+	if minLock := time.Duration(cfg.BatchSize+1) * cfg.SendTimeout; cfg.LockTimeout < minLock {
+		log.Warn().
+			Dur("lock_timeout", cfg.LockTimeout).
+			Dur("min_lock_timeout", minLock).
+			Msg("[config] OUTBOX_LOCK_TIMEOUT меньше времени отправки пачки, увеличен")
+		cfg.LockTimeout = minLock
+	}
+
+	log.Info().
+		Int("workers", cfg.Workers).
+		Int("batch_size", cfg.BatchSize).
+		Int("max_attempts", cfg.MaxAttempts).
+		Dur("lock_timeout", cfg.LockTimeout).
+		Dur("retry_base", cfg.RetryBase).
+		Msg("[config] Загружены настройки очереди сообщений")
+
+	return cfg
+}
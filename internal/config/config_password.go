@@ -0,0 +1,78 @@
+package config
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// Требования к паролю
+type PasswordPolicyConfig struct {
+	MinLength int
+	MaxLength int
+	// Обязательные классы символов: lower, upper, letter, digit, symbol
+	RequiredClasses []string
+	// Запрещать пароль, совпадающий с телефоном или username или содержащий их
+	ForbidPersonal bool
+	// Проверять по встроенному списку утёкших паролей
+	CheckBreached bool
+	// Дополнительный список утёкших паролей, по одному в строке
+	BreachedListFile string
+}
+
+func loadPasswordPolicyConfig() PasswordPolicyConfig {
+	cfg := PasswordPolicyConfig{
+		MinLength:        getInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:        getInt("PASSWORD_MAX_LENGTH", 128),
+		RequiredClasses:  splitList(getEnv("PASSWORD_REQUIRED_CLASSES", "letter,digit")),
+		ForbidPersonal:   getBool("PASSWORD_FORBID_PERSONAL", true),
+		CheckBreached:    getBool("PASSWORD_CHECK_BREACHED", true),
+		BreachedListFile: getEnv("PASSWORD_BREACHED_LIST_FILE", ""),
+	}
+
+	log.Info().
+		Int("min_length", cfg.MinLength).
+		Strs("required_classes", cfg.RequiredClasses).
+		Bool("check_breached", cfg.CheckBreached).
+		Msg("[config] Загружена политика паролей")
+
+	return cfg
+}
+
+// Хеширование паролей. Хеши с другим алгоритмом или параметрами
+// пересчитываются при следующем успешном входе.
+type PasswordHashConfig struct {
+	// argon2id или bcrypt
+	Algorithm         string
+	Argon2Memory      uint32 // КиБ
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+}
+
+func loadPasswordHashConfig() PasswordHashConfig {
+	cfg := PasswordHashConfig{
+		Algorithm:         getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		Argon2Memory:      uint32(getInt("PASSWORD_ARGON2_MEMORY_KB", 64*1024)),
+		Argon2Iterations:  uint32(getInt("PASSWORD_ARGON2_ITERATIONS", 3)),
+		Argon2Parallelism: uint8(getInt("PASSWORD_ARGON2_PARALLELISM", 2)),
+		BcryptCost:        getInt("PASSWORD_BCRYPT_COST", 12),
+	}
+	if cfg.Algorithm != "argon2id" && cfg.Algorithm != "bcrypt" {
+		log.Warn().Str("algorithm", cfg.Algorithm).Msg("[config] Неизвестный PASSWORD_HASH_ALGORITHM, используется argon2id")
+		cfg.Algorithm = "argon2id"
+	}
+	if cfg.Argon2Iterations < 1 {
+		cfg.Argon2Iterations = 1
+	}
+	if cfg.Argon2Parallelism < 1 {
+		cfg.Argon2Parallelism = 1
+	}
+
+	log.Info().
+		Str("algorithm", cfg.Algorithm).
+		Uint32("argon2_memory_kb", cfg.Argon2Memory).
+		Uint32("argon2_iterations", cfg.Argon2Iterations).
+		Int("bcrypt_cost", cfg.BcryptCost).
+		Msg("[config] Загружены настройки хеширования паролей")
+
+	return cfg
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки push-уведомлений
+type PushConfig struct {
+	// Push API Expo. Для локальной заглушки: http://localhost:9092/--/api/v2/push/send
+	ExpoURL string
+	// Токен доступа Expo, если в проекте включена защита push API
+	ExpoAccessToken string
+	// FCM HTTP v1 API и JSON-ключ сервисного аккаунта Firebase.
+	// Без ключа токены FCM не принимаются. Проект берётся из ключа,
+	// если не задан FCMProjectID.
+	FCMURL             string
+	FCMCredentialsFile string
+	FCMProjectID       string
+	Timeout            time.Duration
+
+	// Сколько попыток доставки у одного уведомления
+	MaxAttempts int
+	// Токены, которые приложение не обновляло дольше, считаются устаревшими
+	DeviceTTL time.Duration
+	// Часовой пояс для тихих часов, если пользователь не указал свой
+	DefaultTimezone string
+	// Типы событий, которые доставляются и в тихие часы
+	QuietHoursBypass []string
+}
+
+func LoadPushConfig() *PushConfig {
+	cfg := &PushConfig{
+		ExpoURL:            getEnv("PUSH_EXPO_URL", "https://exp.host/--/api/v2/push/send"),
+		ExpoAccessToken:    os.Getenv("PUSH_EXPO_ACCESS_TOKEN"),
+		FCMURL:             getEnv("PUSH_FCM_URL", "https://fcm.googleapis.com"),
+		FCMCredentialsFile: os.Getenv("PUSH_FCM_CREDENTIALS_FILE"),
+		FCMProjectID:       os.Getenv("PUSH_FCM_PROJECT_ID"),
+		Timeout:            getDuration("PUSH_TIMEOUT", 10*time.Second),
+		MaxAttempts:        getInt("PUSH_MAX_ATTEMPTS", 3),
+		DeviceTTL:          getDuration("PUSH_DEVICE_TTL", 60*24*time.Hour),
+		DefaultTimezone:    getEnv("PUSH_DEFAULT_TIMEZONE", "Europe/Moscow"),
+		QuietHoursBypass:   splitList(getEnv("PUSH_QUIET_HOURS_BYPASS", "security")),
+	}
+	if _, err := time.LoadLocation(cfg.DefaultTimezone); err != nil {
+		log.Warn().Err(err).Str("timezone", cfg.DefaultTimezone).Msg("[config] Неизвестный часовой пояс, используется UTC")
+		cfg.DefaultTimezone = "UTC"
+	}
+
+	log.Info().
+		Str("expo_url", cfg.ExpoURL).
+		Str("fcm_url", cfg.FCMURL).
+		Str("fcm_credentials", cfg.FCMCredentialsFile).
+		Int("max_attempts", cfg.MaxAttempts).
+		Str("default_timezone", cfg.DefaultTimezone).
+		Strs("quiet_hours_bypass", cfg.QuietHoursBypass).
+		Msg("[config] Загружены настройки push-уведомлений")
+
+	return cfg
+}
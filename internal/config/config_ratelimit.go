@@ -0,0 +1,75 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Лимиты одной ручки в формате "N/длительность" (см. ratelimit.ParseLimit).
+// Пустая строка или "off" — без ограничения.
+type RouteRateLimit struct {
+	IP    string
+	Phone string
+}
+
+// Ограничение частоты запросов к открытым ручкам
+type RateLimitConfig struct {
+	Enabled bool
+	// memory — в памяти процесса, postgres — общий для всех экземпляров
+	Store string
+	// Сколько хранить неиспользуемые вёдра в Postgres
+	StaleAfter time.Duration
+	// Ключ — имя ручки в роутере (login, registration_code, ...)
+	Routes map[string]RouteRateLimit
+}
+
+// Значения по умолчанию; переопределяются RATE_LIMIT_<ROUTE>_IP и RATE_LIMIT_<ROUTE>_PHONE
+var defaultRouteRateLimits = map[string]RouteRateLimit{
+	"login":                 {IP: "20/1m", Phone: "10/10m"},
+	"login_sms_code":        {IP: "5/10m", Phone: "3/10m"},
+	"login_sms":             {IP: "20/10m", Phone: "10/10m"},
+	"login_mfa":             {IP: "20/10m"},
+	"mfa_enroll":            {IP: "10/10m"},
+	"mfa_enroll_code":       {IP: "5/10m"},
+	"registration_code":     {IP: "5/10m", Phone: "3/10m"},
+	"verify_phone":          {IP: "20/10m", Phone: "10/10m"},
+	"register":              {IP: "10/10m", Phone: "5/10m"},
+	"forgot_password":       {IP: "5/10m", Phone: "3/10m"},
+	"reset_password":        {IP: "10/10m", Phone: "5/10m"},
+	"unlock_code":           {IP: "5/10m", Phone: "3/10m"},
+	"unlock":                {IP: "20/10m", Phone: "10/10m"},
+	"email_forgot_password": {IP: "5/10m"},
+	// Проверка кодов с панели домофона: защита гостевых кодов от перебора
+	"device_access_check": {IP: "30/1m"},
+}
+
+func LoadRateLimitConfig() *RateLimitConfig {
+	cfg := &RateLimitConfig{
+		Enabled:    getBool("RATE_LIMIT_ENABLED", true),
+		Store:      getEnv("RATE_LIMIT_STORE", "memory"),
+		StaleAfter: getDuration("RATE_LIMIT_STALE_AFTER", 24*time.Hour),
+		Routes:     make(map[string]RouteRateLimit, len(defaultRouteRateLimits)),
+	}
+	if cfg.Store != "memory" && cfg.Store != "postgres" {
+		log.Warn().Str("store", cfg.Store).Msg("[config] Неизвестный RATE_LIMIT_STORE, используется memory")
+		cfg.Store = "memory"
+	}
+
+	for route, def := range defaultRouteRateLimits {
+		prefix := "RATE_LIMIT_" + strings.ToUpper(route)
+		cfg.Routes[route] = RouteRateLimit{
+			IP:    getEnv(prefix+"_IP", def.IP),
+			Phone: getEnv(prefix+"_PHONE", def.Phone),
+		}
+	}
+
+	log.Info().
+		Bool("enabled", cfg.Enabled).
+		Str("store", cfg.Store).
+		Int("routes", len(cfg.Routes)).
+		Msg("[config] Загружены настройки ограничения частоты запросов")
+
+	return cfg
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки расписаний доступа
+type ScheduleConfig struct {
+	// Роли, которым доступны создание расписаний и привязка их к ключам и типам жителей
+	AdminRoles []string
+	// Часовой пояс расписания, если он не указан
+	DefaultTimezone string
+	// Ограничения на размер одного расписания
+	MaxRules    int
+	MaxHolidays int
+}
+
+func LoadScheduleConfig() *ScheduleConfig {
+	cfg := &ScheduleConfig{
+		AdminRoles:      splitList(getEnv("SCHEDULE_ADMIN_ROLES", "admin,manager")),
+		DefaultTimezone: getEnv("SCHEDULE_DEFAULT_TIMEZONE", "Europe/Moscow"),
+		MaxRules:        getInt("SCHEDULE_MAX_RULES", 50),
+		MaxHolidays:     getInt("SCHEDULE_MAX_HOLIDAYS", 366),
+	}
+
+	log.Info().
+		Strs("admin_roles", cfg.AdminRoles).
+		Str("default_timezone", cfg.DefaultTimezone).
+		Msg("[config] Загружены настройки расписаний доступа")
+
+	return cfg
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки отправки SMS
+type SMSConfig struct {
+	// Провайдеры в порядке приоритета: smsru, smsc, mock
+	Providers []string
+	// Попыток на одного провайдера при временных ошибках
+	Retries    int
+	RetryDelay time.Duration
+	Timeout    time.Duration
+	// Имя отправителя (если согласовано с провайдером)
+	From string
+
+	SMSRuAPIKey string
+	SMSRuAPIURL string
+
+	SMSCLogin    string
+	SMSCPassword string
+	SMSCAPIURL   string
+}
+
+func LoadSMSConfig() *SMSConfig {
+	cfg := &SMSConfig{
+		Providers:    splitList(getEnv("SMS_PROVIDERS", "mock")),
+		Retries:      getInt("SMS_RETRIES", 2),
+		RetryDelay:   getDuration("SMS_RETRY_DELAY", 500*time.Millisecond),
+		Timeout:      getDuration("SMS_TIMEOUT", 10*time.Second),
+		From:         os.Getenv("SMS_FROM"),
+		SMSRuAPIKey:  os.Getenv("SMSRU_API_KEY"),
+		SMSRuAPIURL:  getEnv("SMSRU_API_URL", "https://sms.ru/sms/send"),
+		SMSCLogin:    os.Getenv("SMSC_LOGIN"),
+		SMSCPassword: os.Getenv("SMSC_PASSWORD"),
+		SMSCAPIURL:   getEnv("SMSC_API_URL", "https://smsc.ru/sys/send.php"),
+	}
+
+	log.Info().
+		Strs("providers", cfg.Providers).
+		Int("retries", cfg.Retries).
+		Str("smsru_url", cfg.SMSRuAPIURL).
+		Str("smsru_key", mask(cfg.SMSRuAPIKey)).
+		Str("smsc_url", cfg.SMSCAPIURL).
+		Str("smsc_login", cfg.SMSCLogin).
+		Msg("[config] Загружены настройки SMS")
+
+	return cfg
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
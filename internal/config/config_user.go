@@ -0,0 +1,26 @@
+package config
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+type UserConfig struct {
+	// Роли, которым можно заводить пользователей, менять чужие профили и роли
+	AdminRoles []string
+	// Может ли пользователь удалить собственный аккаунт без администратора
+	AllowSelfDelete bool
+}
+
+func LoadUserConfig() *UserConfig {
+	cfg := &UserConfig{
+		AdminRoles:      splitList(getEnv("USER_ADMIN_ROLES", "admin")),
+		AllowSelfDelete: getBool("USER_ALLOW_SELF_DELETE", false),
+	}
+
+	log.Info().
+		Strs("admin_roles", cfg.AdminRoles).
+		Bool("allow_self_delete", cfg.AllowSelfDelete).
+		Msg("[config] Загружены настройки пользователей")
+
+	return cfg
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Настройки SMS-кодов подтверждения
+type VerificationConfig struct {
+	// Количество цифр в коде (4–10)
+	CodeLength int
+	CodeTTL    time.Duration
+	// Минимальный интервал между отправками на один номер
+	ResendInterval time.Duration
+	// После стольких неверных вводов код аннулируется. Счётчик общий для
+	// всех кодов номера за AttemptWindow: повторная отправка его не сбрасывает.
+	MaxAttempts   int
+	AttemptWindow time.Duration
+}
+
+func LoadVerificationConfig() *VerificationConfig {
+	cfg := &VerificationConfig{
+		CodeLength:     getInt("VERIFICATION_CODE_LENGTH", 6),
+		CodeTTL:        getDuration("VERIFICATION_CODE_TTL", 5*time.Minute),
+		ResendInterval: getDuration("VERIFICATION_RESEND_INTERVAL", time.Minute),
+		MaxAttempts:    getInt("VERIFICATION_MAX_ATTEMPTS", 5),
+		AttemptWindow:  getDuration("VERIFICATION_ATTEMPT_WINDOW", time.Hour),
+	}
+
+	// verification_code в БД — VARCHAR(10)
+	if cfg.CodeLength < 4 {
+		cfg.CodeLength = 4
+	}
+	if cfg.CodeLength > 10 {
+		cfg.CodeLength = 10
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+
+	log.Info().
+		Int("code_length", cfg.CodeLength).
+		Dur("code_ttl", cfg.CodeTTL).
+		Int("max_attempts", cfg.MaxAttempts).
+		Dur("attempt_window", cfg.AttemptWindow).
+		Msg("[config] Загружены настройки кодов подтверждения")
+
+	return cfg
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// AdvisoryLock — сессионная advisory-блокировка Postgres с ключом key.
+// Держится на отдельном соединении из пула до вызова unlock.
+type AdvisoryLock struct {
+	pool *pgxpool.Pool
+	key  int64
+}
+
+func NewAdvisoryLock(pool *pgxpool.Pool, key int64) *AdvisoryLock {
+	return &AdvisoryLock{pool: pool, key: key}
+}
+
+// TryLock берёт блокировку, не дожидаясь её. ok=false — её держит другой экземпляр.
+func (l *AdvisoryLock) TryLock(ctx context.Context) (unlock func(), ok bool, err error) {
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	q := New(conn)
+	ok, err = q.TryAdvisoryLock(ctx, l.key)
+	if err != nil || !ok {
+		conn.Release()
+		return nil, false, err
+	}
+	return func() {
+		if err := q.AdvisoryUnlock(context.Background(), l.key); err != nil {
+			// Соединение с чужой блокировкой возвращать в пул нельзя
+			log.Error().Err(err).Int64("key", l.key).Msg("[db] Не удалось снять advisory-блокировку")
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}, true, nil
+}
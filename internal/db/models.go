@@ -16,6 +16,71 @@ type AccessHistory struct {
 	AccessTime  pgtype.Timestamp
 	Result      pgtype.Text
 	Description pgtype.Text
+	CreatedAt   pgtype.Timestamp
+}
+
+type AccessSchedule struct {
+	ID        int32
+	Name      string
+	Timezone  string
+	CreatedBy pgtype.Int4
+	CreatedAt pgtype.Timestamp
+	UpdatedAt pgtype.Timestamp
+}
+
+type AccessScheduleHoliday struct {
+	ScheduleID int32
+	Day        pgtype.Date
+}
+
+type AccessScheduleRule struct {
+	ID          int32
+	ScheduleID  int32
+	Weekdays    int16
+	StartMinute int32
+	EndMinute   int32
+}
+
+type Announcement struct {
+	ID             int64
+	AuthorID       pgtype.Int4
+	Title          string
+	Body           string
+	TargetType     string
+	TargetAddress  pgtype.Text
+	TargetEntrance pgtype.Text
+	PublishAt      pgtype.Timestamp
+	ExpiresAt      pgtype.Timestamp
+	CancelledAt    pgtype.Timestamp
+	CreatedAt      pgtype.Timestamp
+	NotifiedAt     pgtype.Timestamp
+}
+
+type AnnouncementApartment struct {
+	AnnouncementID int64
+	ApartmentID    int32
+}
+
+type AnnouncementAttachment struct {
+	ID             int64
+	AnnouncementID int64
+	FileName       string
+	StoragePath    string
+	ContentType    string
+	SizeBytes      int64
+	CreatedAt      pgtype.Timestamp
+}
+
+type AnnouncementRead struct {
+	AnnouncementID int64
+	UserID         int32
+	ReadAt         pgtype.Timestamp
+}
+
+type AnomalyScanCursor struct {
+	Name          string
+	LastHistoryID int32
+	ScannedAt     pgtype.Timestamp
 }
 
 type Apartment struct {
@@ -25,6 +90,7 @@ type Apartment struct {
 	Description pgtype.Text
 	OwnerID     pgtype.Int4
 	CreatedAt   pgtype.Timestamp
+	Entrance    pgtype.Text
 }
 
 type ApartmentResident struct {
@@ -36,6 +102,37 @@ type ApartmentResident struct {
 	Since        pgtype.Timestamp
 }
 
+type Chat struct {
+	ID        int64
+	ChatKey   string
+	Kind      string
+	Title     pgtype.Text
+	CreatedAt pgtype.Timestamp
+}
+
+type ChatMember struct {
+	ChatID            int64
+	UserID            int32
+	JoinedAt          pgtype.Timestamp
+	LastReadMessageID pgtype.Int8
+	LastReadAt        pgtype.Timestamp
+}
+
+type ChatMessage struct {
+	ID        int64
+	ChatID    int64
+	SenderID  pgtype.Int4
+	Body      string
+	CreatedAt pgtype.Timestamp
+	EditedAt  pgtype.Timestamp
+	DeletedAt pgtype.Timestamp
+}
+
+type ChatMessageMention struct {
+	MessageID int64
+	UserID    int32
+}
+
 type Device struct {
 	ID           int32
 	SerialNumber string
@@ -44,6 +141,22 @@ type Device struct {
 	SipAccountID pgtype.Int4
 	Status       pgtype.Text
 	CreatedAt    pgtype.Timestamp
+	ApiKeyHash   pgtype.Text
+	LastSeenAt   pgtype.Timestamp
+}
+
+type DeviceKeyList struct {
+	DeviceID    int32
+	Version     int64
+	ContentHash string
+	Content     []byte
+	CreatedAt   pgtype.Timestamp
+}
+
+type DeviceKeyListAck struct {
+	DeviceID int32
+	Version  int64
+	AckedAt  pgtype.Timestamp
 }
 
 type DeviceLog struct {
@@ -55,6 +168,14 @@ type DeviceLog struct {
 	Payload  []byte
 }
 
+type EmailVerificationToken struct {
+	TokenHash string
+	UserID    int32
+	Email     string
+	ExpiresAt pgtype.Timestamp
+	CreatedAt pgtype.Timestamp
+}
+
 type Event struct {
 	ID          int32
 	DeviceID    pgtype.Int4
@@ -64,6 +185,19 @@ type Event struct {
 	CreatedAt   pgtype.Timestamp
 }
 
+type GuestPass struct {
+	KeyID       int32
+	ApartmentID int32
+	IssuedBy    pgtype.Int4
+	GuestName   pgtype.Text
+	CodeFormat  string
+	CodeHash    string
+	MaxUses     int32
+	UseCount    int32
+	DeviceID    pgtype.Int4
+	RevokedAt   pgtype.Timestamp
+}
+
 type Key struct {
 	ID          int32
 	KeyCode     string
@@ -74,6 +208,23 @@ type Key struct {
 	ValidFrom   pgtype.Timestamp
 	ValidTo     pgtype.Timestamp
 	Description pgtype.Text
+	ScheduleID  pgtype.Int4
+}
+
+type LoginAttempt struct {
+	AttemptKey   string
+	FailedCount  int32
+	LastFailedAt pgtype.Timestamp
+	LockedUntil  pgtype.Timestamp
+}
+
+type LostKeyReport struct {
+	KeyID            int32
+	ReportedBy       pgtype.Int4
+	ReportedAt       pgtype.Timestamp
+	ReplacementKeyID pgtype.Int4
+	PresentedCount   int32
+	LastPresentedAt  pgtype.Timestamp
 }
 
 type Medium struct {
@@ -84,6 +235,56 @@ type Medium struct {
 	CreatedAt pgtype.Timestamp
 }
 
+type MfaRecoveryCode struct {
+	ID        int32
+	UserID    int32
+	CodeHash  string
+	UsedAt    pgtype.Timestamp
+	CreatedAt pgtype.Timestamp
+}
+
+type NotificationPreference struct {
+	UserID    int32
+	EventType string
+	Enabled   bool
+}
+
+type NotificationSetting struct {
+	UserID          int32
+	QuietHoursStart pgtype.Int4
+	QuietHoursEnd   pgtype.Int4
+	Timezone        string
+	UpdatedAt       pgtype.Timestamp
+}
+
+type OfflineAccessRecord struct {
+	DeviceID         int32
+	RecordID         string
+	AccessHistoryID  pgtype.Int4
+	RecordedAt       pgtype.Timestamp
+	AccessTime       pgtype.Timestamp
+	ClockSkewSeconds int32
+	Anomaly          pgtype.Text
+	ReceivedAt       pgtype.Timestamp
+}
+
+type OutboxMessage struct {
+	ID            int64
+	Channel       string
+	Recipient     string
+	Subject       pgtype.Text
+	Body          string
+	Payload       []byte
+	Status        string
+	Attempts      int32
+	MaxAttempts   int32
+	NextAttemptAt pgtype.Timestamp
+	LockedUntil   pgtype.Timestamp
+	LastError     pgtype.Text
+	CreatedAt     pgtype.Timestamp
+	SentAt        pgtype.Timestamp
+}
+
 type PasswordResetToken struct {
 	ID        int32
 	UserID    pgtype.Int4
@@ -92,11 +293,40 @@ type PasswordResetToken struct {
 	CreatedAt pgtype.Timestamp
 }
 
+type PhoneNormalizationConflict struct {
+	ID         int32
+	UserID     pgtype.Int4
+	Phone      string
+	Normalized pgtype.Text
+	Reason     string
+	CreatedAt  pgtype.Timestamp
+}
+
 type PhoneVerificationToken struct {
 	Phone            string
 	VerificationCode string
 	ExpiresAt        pgtype.Timestamp
 	CreatedAt        pgtype.Timestamp
+	Purpose          string
+	Attempts         int32
+	AttemptsResetAt  pgtype.Timestamp
+}
+
+type PushDevice struct {
+	ID        int64
+	UserID    int32
+	SessionID string
+	Token     string
+	Provider  string
+	Platform  pgtype.Text
+	CreatedAt pgtype.Timestamp
+	UpdatedAt pgtype.Timestamp
+}
+
+type RateLimitBucket struct {
+	BucketKey string
+	Tokens    float64
+	UpdatedAt pgtype.Timestamp
 }
 
 type RefreshToken struct {
@@ -107,6 +337,28 @@ type RefreshToken struct {
 	ExpiresAt pgtype.Timestamp
 }
 
+type ResidentTypeSchedule struct {
+	ResidentType string
+	ScheduleID   int32
+}
+
+type SecurityAlert struct {
+	ID              int32
+	Kind            string
+	Severity        string
+	KeyID           pgtype.Int4
+	DeviceID        pgtype.Int4
+	UserID          pgtype.Int4
+	AccessHistoryID pgtype.Int4
+	DedupKey        string
+	Description     string
+	DetectedAt      pgtype.Timestamp
+	Status          string
+	ReviewedBy      pgtype.Int4
+	ReviewedAt      pgtype.Timestamp
+	ReviewNote      pgtype.Text
+}
+
 type SipAccount struct {
 	ID        int32
 	Username  string
@@ -118,15 +370,34 @@ type SipAccount struct {
 }
 
 type User struct {
-	ID           int32
-	Username     string
-	PasswordHash string
-	Email        string
-	Phone        string
-	Role         pgtype.Text
-	IsActive     pgtype.Bool
+	ID            int32
+	Username      string
+	PasswordHash  string
+	Email         string
+	Phone         string
+	Role          pgtype.Text
+	IsActive      pgtype.Bool
+	CreatedAt     pgtype.Timestamp
+	FirstName     pgtype.Text
+	LastName      pgtype.Text
+	AvatarUrl     pgtype.Text
+	EmailVerified bool
+}
+
+type UserMfa struct {
+	UserID       int32
+	Secret       string
+	Enabled      bool
+	LastUsedStep pgtype.Int8
 	CreatedAt    pgtype.Timestamp
-	FirstName    pgtype.Text
-	LastName     pgtype.Text
-	AvatarUrl    pgtype.Text
+	EnabledAt    pgtype.Timestamp
+}
+
+type VerificationTicket struct {
+	Jti       string
+	Phone     string
+	Purpose   string
+	ExpiresAt pgtype.Timestamp
+	UsedAt    pgtype.Timestamp
+	CreatedAt pgtype.Timestamp
 }
@@ -25,6 +25,20 @@ func (q *Queries) ChangePassword(ctx context.Context, arg ChangePasswordParams)
 	return err
 }
 
+const changePasswordByID = `-- name: ChangePasswordByID :exec
+UPDATE users SET password_hash = $2 WHERE id = $1
+`
+
+type ChangePasswordByIDParams struct {
+	ID           int32
+	PasswordHash string
+}
+
+func (q *Queries) ChangePasswordByID(ctx context.Context, arg ChangePasswordByIDParams) error {
+	_, err := q.db.Exec(ctx, changePasswordByID, arg.ID, arg.PasswordHash)
+	return err
+}
+
 const changePasswordByPhone = `-- name: ChangePasswordByPhone :exec
 UPDATE users
 SET password_hash = $1
@@ -57,6 +71,138 @@ func (q *Queries) ChangeUsername(ctx context.Context, arg ChangeUsernameParams)
 	return err
 }
 
+const claimPhoneVerificationAttempt = `-- name: ClaimPhoneVerificationAttempt :one
+UPDATE phone_verification_tokens
+SET attempts = attempts + 1
+WHERE phone = $1 AND purpose = $2 AND expires_at > NOW() AND attempts < $3
+RETURNING verification_code, attempts
+`
+
+type ClaimPhoneVerificationAttemptParams struct {
+	Phone       string
+	Purpose     string
+	MaxAttempts int32
+}
+
+type ClaimPhoneVerificationAttemptRow struct {
+	VerificationCode string
+	Attempts         int32
+}
+
+// Занимает попытку ввода до сравнения кода; нет строки — кода нет, он истёк или попытки кончились
+func (q *Queries) ClaimPhoneVerificationAttempt(ctx context.Context, arg ClaimPhoneVerificationAttemptParams) (ClaimPhoneVerificationAttemptRow, error) {
+	row := q.db.QueryRow(ctx, claimPhoneVerificationAttempt, arg.Phone, arg.Purpose, arg.MaxAttempts)
+	var i ClaimPhoneVerificationAttemptRow
+	err := row.Scan(&i.VerificationCode, &i.Attempts)
+	return i, err
+}
+
+const confirmUserEmail = `-- name: ConfirmUserEmail :exec
+UPDATE users SET email = $2, email_verified = TRUE WHERE id = $1
+`
+
+type ConfirmUserEmailParams struct {
+	ID    int32
+	Email string
+}
+
+// Адрес из ссылки становится основным и подтверждённым
+func (q *Queries) ConfirmUserEmail(ctx context.Context, arg ConfirmUserEmailParams) error {
+	_, err := q.db.Exec(ctx, confirmUserEmail, arg.ID, arg.Email)
+	return err
+}
+
+const consumePasswordResetToken = `-- name: ConsumePasswordResetToken :execrows
+DELETE FROM password_reset_tokens
+WHERE token = $1 AND user_id = $2 AND expires_at > NOW()
+`
+
+type ConsumePasswordResetTokenParams struct {
+	Token  string
+	UserID pgtype.Int4
+}
+
+// Гасит ссылку сброса; 0 строк — ссылка чужая, просрочена или уже использована
+func (q *Queries) ConsumePasswordResetToken(ctx context.Context, arg ConsumePasswordResetTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, consumePasswordResetToken, arg.Token, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const consumeVerificationTicket = `-- name: ConsumeVerificationTicket :execrows
+UPDATE verification_tickets
+SET used_at = $4
+WHERE jti = $1 AND phone = $2 AND purpose = $3
+  AND used_at IS NULL AND expires_at > $4
+`
+
+type ConsumeVerificationTicketParams struct {
+	Jti     string
+	Phone   string
+	Purpose string
+	Now     pgtype.Timestamp
+}
+
+// Помечает билет использованным; 0 строк — билет чужой, просрочен или уже погашен
+func (q *Queries) ConsumeVerificationTicket(ctx context.Context, arg ConsumeVerificationTicketParams) (int64, error) {
+	result, err := q.db.Exec(ctx, consumeVerificationTicket,
+		arg.Jti,
+		arg.Phone,
+		arg.Purpose,
+		arg.Now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const createEmailVerificationToken = `-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, user_id, email, expires_at)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateEmailVerificationTokenParams struct {
+	TokenHash string
+	UserID    int32
+	Email     string
+	ExpiresAt pgtype.Timestamp
+}
+
+func (q *Queries) CreateEmailVerificationToken(ctx context.Context, arg CreateEmailVerificationTokenParams) error {
+	_, err := q.db.Exec(ctx, createEmailVerificationToken,
+		arg.TokenHash,
+		arg.UserID,
+		arg.Email,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createEvent = `-- name: CreateEvent :exec
+INSERT INTO events (device_id, event_type, user_id, description)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateEventParams struct {
+	DeviceID    pgtype.Int4
+	EventType   string
+	UserID      pgtype.Int4
+	Description pgtype.Text
+}
+
+func (q *Queries) CreateEvent(ctx context.Context, arg CreateEventParams) error {
+	_, err := q.db.Exec(ctx, createEvent,
+		arg.DeviceID,
+		arg.EventType,
+		arg.UserID,
+		arg.Description,
+	)
+	return err
+}
+
 const createPasswordResetToken = `-- name: CreatePasswordResetToken :exec
 INSERT INTO password_reset_tokens (user_id, token, expires_at)
 VALUES ($1, $2, $3)
@@ -76,7 +222,7 @@ func (q *Queries) CreatePasswordResetToken(ctx context.Context, arg CreatePasswo
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (username, password_hash, email, phone, role, first_name, last_name)
 VALUES ($1, $2, $3, $4, $5, $6, $7)
-RETURNING id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url
+RETURNING id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url, email_verified
 `
 
 type CreateUserParams struct {
@@ -112,10 +258,33 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.FirstName,
 		&i.LastName,
 		&i.AvatarUrl,
+		&i.EmailVerified,
 	)
 	return i, err
 }
 
+const createVerificationTicket = `-- name: CreateVerificationTicket :exec
+INSERT INTO verification_tickets (jti, phone, purpose, expires_at)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateVerificationTicketParams struct {
+	Jti       string
+	Phone     string
+	Purpose   string
+	ExpiresAt pgtype.Timestamp
+}
+
+func (q *Queries) CreateVerificationTicket(ctx context.Context, arg CreateVerificationTicketParams) error {
+	_, err := q.db.Exec(ctx, createVerificationTicket,
+		arg.Jti,
+		arg.Phone,
+		arg.Purpose,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
 const deleteAllResetTokensForUser = `-- name: DeleteAllResetTokensForUser :exec
 DELETE FROM password_reset_tokens WHERE user_id = $1
 `
@@ -125,12 +294,26 @@ func (q *Queries) DeleteAllResetTokensForUser(ctx context.Context, userID pgtype
 	return err
 }
 
+const deleteEmailVerificationTokens = `-- name: DeleteEmailVerificationTokens :exec
+DELETE FROM email_verification_tokens WHERE user_id = $1
+`
+
+func (q *Queries) DeleteEmailVerificationTokens(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, deleteEmailVerificationTokens, userID)
+	return err
+}
+
 const deletePhoneVerificationToken = `-- name: DeletePhoneVerificationToken :exec
-DELETE FROM phone_verification_tokens WHERE phone = $1
+DELETE FROM phone_verification_tokens WHERE phone = $1 AND purpose = $2
 `
 
-func (q *Queries) DeletePhoneVerificationToken(ctx context.Context, phone string) error {
-	_, err := q.db.Exec(ctx, deletePhoneVerificationToken, phone)
+type DeletePhoneVerificationTokenParams struct {
+	Phone   string
+	Purpose string
+}
+
+func (q *Queries) DeletePhoneVerificationToken(ctx context.Context, arg DeletePhoneVerificationTokenParams) error {
+	_, err := q.db.Exec(ctx, deletePhoneVerificationToken, arg.Phone, arg.Purpose)
 	return err
 }
 
@@ -153,34 +336,88 @@ func (q *Queries) DeleteUser(ctx context.Context, id int32) error {
 	return err
 }
 
+const deleteUserRefreshTokens = `-- name: DeleteUserRefreshTokens :exec
+DELETE FROM refresh_tokens WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserRefreshTokens(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, deleteUserRefreshTokens, userID)
+	return err
+}
+
+const getEmailVerificationToken = `-- name: GetEmailVerificationToken :one
+SELECT token_hash, user_id, email, expires_at, created_at FROM email_verification_tokens
+WHERE token_hash = $1 AND expires_at > $2
+`
+
+type GetEmailVerificationTokenParams struct {
+	TokenHash string
+	Now       pgtype.Timestamp
+}
+
+func (q *Queries) GetEmailVerificationToken(ctx context.Context, arg GetEmailVerificationTokenParams) (EmailVerificationToken, error) {
+	row := q.db.QueryRow(ctx, getEmailVerificationToken, arg.TokenHash, arg.Now)
+	var i EmailVerificationToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.Email,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLoginAttempt = `-- name: GetLoginAttempt :one
+SELECT attempt_key, failed_count, last_failed_at, locked_until FROM login_attempts WHERE attempt_key = $1
+`
+
+func (q *Queries) GetLoginAttempt(ctx context.Context, attemptKey string) (LoginAttempt, error) {
+	row := q.db.QueryRow(ctx, getLoginAttempt, attemptKey)
+	var i LoginAttempt
+	err := row.Scan(
+		&i.AttemptKey,
+		&i.FailedCount,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
 const getPhoneVerificationToken = `-- name: GetPhoneVerificationToken :one
-SELECT phone, verification_code, expires_at, created_at FROM phone_verification_tokens
-WHERE phone = $1 AND verification_code = $2 AND expires_at > NOW()
+SELECT phone, verification_code, expires_at, created_at, purpose, attempts, attempts_reset_at FROM phone_verification_tokens
+WHERE phone = $1 AND purpose = $2 AND expires_at > NOW()
 `
 
 type GetPhoneVerificationTokenParams struct {
-	Phone            string
-	VerificationCode string
+	Phone   string
+	Purpose string
 }
 
 func (q *Queries) GetPhoneVerificationToken(ctx context.Context, arg GetPhoneVerificationTokenParams) (PhoneVerificationToken, error) {
-	row := q.db.QueryRow(ctx, getPhoneVerificationToken, arg.Phone, arg.VerificationCode)
+	row := q.db.QueryRow(ctx, getPhoneVerificationToken, arg.Phone, arg.Purpose)
 	var i PhoneVerificationToken
 	err := row.Scan(
 		&i.Phone,
 		&i.VerificationCode,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.Purpose,
+		&i.Attempts,
+		&i.AttemptsResetAt,
 	)
 	return i, err
 }
 
 const getPhoneVerificationTokenByPhone = `-- name: GetPhoneVerificationTokenByPhone :one
-SELECT phone, verification_code, expires_at, created_at
+SELECT phone, verification_code, expires_at, created_at, purpose, attempts, attempts_reset_at
 FROM phone_verification_tokens
 WHERE phone = $1
+ORDER BY created_at DESC
+LIMIT 1
 `
 
+// Последний выданный код на номер (для ограничения частоты отправки)
 func (q *Queries) GetPhoneVerificationTokenByPhone(ctx context.Context, phone string) (PhoneVerificationToken, error) {
 	row := q.db.QueryRow(ctx, getPhoneVerificationTokenByPhone, phone)
 	var i PhoneVerificationToken
@@ -189,6 +426,9 @@ func (q *Queries) GetPhoneVerificationTokenByPhone(ctx context.Context, phone st
 		&i.VerificationCode,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.Purpose,
+		&i.Attempts,
+		&i.AttemptsResetAt,
 	)
 	return i, err
 }
@@ -212,6 +452,34 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 	return i, err
 }
 
+const getUserApartmentIDs = `-- name: GetUserApartmentIDs :many
+SELECT DISTINCT a.id
+FROM apartments a
+LEFT JOIN apartment_residents ar ON ar.apartment_id = a.id AND ar.is_active = TRUE
+WHERE a.owner_id = $1 OR ar.user_id = $1
+ORDER BY a.id
+`
+
+func (q *Queries) GetUserApartmentIDs(ctx context.Context, userID pgtype.Int4) ([]int32, error) {
+	rows, err := q.db.Query(ctx, getUserApartmentIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserAvatarURL = `-- name: GetUserAvatarURL :one
 SELECT avatar_url FROM users WHERE id = $1
 `
@@ -224,7 +492,7 @@ func (q *Queries) GetUserAvatarURL(ctx context.Context, id int32) (pgtype.Text,
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url FROM users WHERE email = $1
+SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url, email_verified FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -242,12 +510,13 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.FirstName,
 		&i.LastName,
 		&i.AvatarUrl,
+		&i.EmailVerified,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url FROM users WHERE id = $1
+SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url, email_verified FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id int32) (User, error) {
@@ -265,12 +534,13 @@ func (q *Queries) GetUserByID(ctx context.Context, id int32) (User, error) {
 		&i.FirstName,
 		&i.LastName,
 		&i.AvatarUrl,
+		&i.EmailVerified,
 	)
 	return i, err
 }
 
 const getUserByPhone = `-- name: GetUserByPhone :one
-SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url FROM users WHERE phone = $1
+SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url, email_verified FROM users WHERE phone = $1
 `
 
 func (q *Queries) GetUserByPhone(ctx context.Context, phone string) (User, error) {
@@ -288,12 +558,13 @@ func (q *Queries) GetUserByPhone(ctx context.Context, phone string) (User, error
 		&i.FirstName,
 		&i.LastName,
 		&i.AvatarUrl,
+		&i.EmailVerified,
 	)
 	return i, err
 }
 
 const getUserByResetToken = `-- name: GetUserByResetToken :one
-SELECT u.id, u.username, u.password_hash, u.email, u.phone, u.role, u.is_active, u.created_at, u.first_name, u.last_name, u.avatar_url
+SELECT u.id, u.username, u.password_hash, u.email, u.phone, u.role, u.is_active, u.created_at, u.first_name, u.last_name, u.avatar_url, u.email_verified
 FROM users u
 JOIN password_reset_tokens prt ON prt.user_id = u.id
 WHERE prt.token = $1 AND prt.expires_at > NOW()
@@ -314,12 +585,13 @@ func (q *Queries) GetUserByResetToken(ctx context.Context, token string) (User,
 		&i.FirstName,
 		&i.LastName,
 		&i.AvatarUrl,
+		&i.EmailVerified,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url FROM users WHERE username = $1
+SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url, email_verified FROM users WHERE username = $1
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -337,12 +609,13 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.FirstName,
 		&i.LastName,
 		&i.AvatarUrl,
+		&i.EmailVerified,
 	)
 	return i, err
 }
 
 const getUsers = `-- name: GetUsers :many
-SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url FROM users
+SELECT id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url, email_verified FROM users
 `
 
 // Получить всех пользователей
@@ -367,6 +640,7 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.FirstName,
 			&i.LastName,
 			&i.AvatarUrl,
+			&i.EmailVerified,
 		); err != nil {
 			return nil, err
 		}
@@ -387,6 +661,51 @@ func (q *Queries) InvalidateResetToken(ctx context.Context, token string) error
 	return err
 }
 
+const lockLoginAttempt = `-- name: LockLoginAttempt :exec
+UPDATE login_attempts SET locked_until = $2 WHERE attempt_key = $1
+`
+
+type LockLoginAttemptParams struct {
+	AttemptKey  string
+	LockedUntil pgtype.Timestamp
+}
+
+func (q *Queries) LockLoginAttempt(ctx context.Context, arg LockLoginAttemptParams) error {
+	_, err := q.db.Exec(ctx, lockLoginAttempt, arg.AttemptKey, arg.LockedUntil)
+	return err
+}
+
+const registerLoginFailure = `-- name: RegisterLoginFailure :one
+INSERT INTO login_attempts (attempt_key, failed_count, last_failed_at)
+VALUES ($1, 1, $2)
+ON CONFLICT (attempt_key) DO UPDATE
+SET failed_count = CASE
+        WHEN login_attempts.last_failed_at < $3 THEN 1
+        ELSE login_attempts.failed_count + 1
+    END,
+    last_failed_at = EXCLUDED.last_failed_at
+RETURNING attempt_key, failed_count, last_failed_at, locked_until
+`
+
+type RegisterLoginFailureParams struct {
+	AttemptKey   string
+	LastFailedAt pgtype.Timestamp
+	WindowStart  pgtype.Timestamp
+}
+
+// Счётчик сбрасывается, если прошлая неудача была раньше начала окна
+func (q *Queries) RegisterLoginFailure(ctx context.Context, arg RegisterLoginFailureParams) (LoginAttempt, error) {
+	row := q.db.QueryRow(ctx, registerLoginFailure, arg.AttemptKey, arg.LastFailedAt, arg.WindowStart)
+	var i LoginAttempt
+	err := row.Scan(
+		&i.AttemptKey,
+		&i.FailedCount,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
 const registerUser = `-- name: RegisterUser :exec
 INSERT INTO users(username, password_hash, email, phone, role, is_active, first_name, last_name)
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
@@ -417,6 +736,15 @@ func (q *Queries) RegisterUser(ctx context.Context, arg RegisterUserParams) erro
 	return err
 }
 
+const resetLoginAttempts = `-- name: ResetLoginAttempts :exec
+DELETE FROM login_attempts WHERE attempt_key = $1
+`
+
+func (q *Queries) ResetLoginAttempts(ctx context.Context, attemptKey string) error {
+	_, err := q.db.Exec(ctx, resetLoginAttempts, attemptKey)
+	return err
+}
+
 const saveRefreshToken = `-- name: SaveRefreshToken :exec
 INSERT INTO refresh_tokens (user_id, token, jti, expires_at)
 VALUES ($1, $2, $3, $4)
@@ -441,7 +769,8 @@ func (q *Queries) SaveRefreshToken(ctx context.Context, arg SaveRefreshTokenPara
 
 const updateEmail = `-- name: UpdateEmail :exec
 UPDATE users
-SET email = $2
+SET email = $2,
+    email_verified = email_verified AND email = $2
 WHERE id = $1
 `
 
@@ -458,34 +787,25 @@ func (q *Queries) UpdateEmail(ctx context.Context, arg UpdateEmailParams) error
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET username = $2,
-    password_hash = $3,
-    email = $4,
-    phone = $5,
-    role = $6,
-    first_name = $7,
-    last_name = $8
+    role = $3,
+    first_name = $4,
+    last_name = $5
 WHERE id = $1
-RETURNING id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url
+RETURNING id, username, password_hash, email, phone, role, is_active, created_at, first_name, last_name, avatar_url, email_verified
 `
 
 type UpdateUserParams struct {
-	ID           int32
-	Username     string
-	PasswordHash string
-	Email        string
-	Phone        string
-	Role         pgtype.Text
-	FirstName    pgtype.Text
-	LastName     pgtype.Text
+	ID        int32
+	Username  string
+	Role      pgtype.Text
+	FirstName pgtype.Text
+	LastName  pgtype.Text
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
 	row := q.db.QueryRow(ctx, updateUser,
 		arg.ID,
 		arg.Username,
-		arg.PasswordHash,
-		arg.Email,
-		arg.Phone,
 		arg.Role,
 		arg.FirstName,
 		arg.LastName,
@@ -503,6 +823,7 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.FirstName,
 		&i.LastName,
 		&i.AvatarUrl,
+		&i.EmailVerified,
 	)
 	return i, err
 }
@@ -539,20 +860,54 @@ func (q *Queries) UpdateUserFullName(ctx context.Context, arg UpdateUserFullName
 	return err
 }
 
-const upsertPhoneVerificationToken = `-- name: UpsertPhoneVerificationToken :exec
-INSERT INTO phone_verification_tokens (phone, verification_code, expires_at)
-VALUES ($1, $2, $3)
-ON CONFLICT (phone)
-DO UPDATE SET verification_code = EXCLUDED.verification_code, expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP
+const updateUserPhone = `-- name: UpdateUserPhone :execrows
+UPDATE users SET phone = $2
+WHERE id = $1 AND phone = $3
+`
+
+type UpdateUserPhoneParams struct {
+	ID       int32
+	NewPhone string
+	OldPhone string
+}
+
+// Меняет номер, только если он не изменился с момента проверки
+func (q *Queries) UpdateUserPhone(ctx context.Context, arg UpdateUserPhoneParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUserPhone, arg.ID, arg.NewPhone, arg.OldPhone)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const upsertPhoneVerificationToken = `-- name: UpsertPhoneVerificationToken :one
+INSERT INTO phone_verification_tokens (phone, purpose, verification_code, expires_at, attempts_reset_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (phone, purpose)
+DO UPDATE SET verification_code = EXCLUDED.verification_code, expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP,
+    attempts = CASE WHEN phone_verification_tokens.attempts_reset_at > NOW() THEN phone_verification_tokens.attempts ELSE 0 END,
+    attempts_reset_at = CASE WHEN phone_verification_tokens.attempts_reset_at > NOW() THEN phone_verification_tokens.attempts_reset_at ELSE EXCLUDED.attempts_reset_at END
+RETURNING attempts
 `
 
 type UpsertPhoneVerificationTokenParams struct {
 	Phone            string
+	Purpose          string
 	VerificationCode string
 	ExpiresAt        pgtype.Timestamp
+	AttemptsResetAt  pgtype.Timestamp
 }
 
-func (q *Queries) UpsertPhoneVerificationToken(ctx context.Context, arg UpsertPhoneVerificationTokenParams) error {
-	_, err := q.db.Exec(ctx, upsertPhoneVerificationToken, arg.Phone, arg.VerificationCode, arg.ExpiresAt)
-	return err
+// Новый код не обнуляет счётчик попыток, пока не закончилось окно attempts_reset_at
+func (q *Queries) UpsertPhoneVerificationToken(ctx context.Context, arg UpsertPhoneVerificationTokenParams) (int32, error) {
+	row := q.db.QueryRow(ctx, upsertPhoneVerificationToken,
+		arg.Phone,
+		arg.Purpose,
+		arg.VerificationCode,
+		arg.ExpiresAt,
+		arg.AttemptsResetAt,
+	)
+	var attempts int32
+	err := row.Scan(&attempts)
+	return attempts, err
 }
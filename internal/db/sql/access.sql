@@ -0,0 +1,95 @@
+-- name: CreateKey :one
+INSERT INTO keys (key_code, key_type, owner_id, is_active, valid_from, valid_to, description, schedule_id)
+VALUES ($1, $2, $3, TRUE, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: DeactivateKey :exec
+UPDATE keys SET is_active = FALSE WHERE id = $1;
+
+-- name: CreateGuestPass :one
+INSERT INTO guest_passes (key_id, apartment_id, issued_by, guest_name, code_format, code_hash, max_uses, device_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- Пропуска с этим кодом, которые ещё могут сработать; окончательную проверку делает сервис
+-- name: FindGuestPassesByCode :many
+SELECT gp.key_id, gp.apartment_id, gp.issued_by, gp.guest_name, gp.max_uses, gp.use_count, gp.device_id,
+       k.valid_from, k.valid_to, a.address
+FROM guest_passes gp
+JOIN keys k ON k.id = gp.key_id
+JOIN apartments a ON a.id = gp.apartment_id
+WHERE gp.code_hash = sqlc.arg(code_hash)
+  AND gp.revoked_at IS NULL
+  AND k.is_active = TRUE
+  AND gp.use_count < gp.max_uses
+  AND (k.valid_to IS NULL OR k.valid_to > sqlc.arg(now))
+ORDER BY gp.key_id;
+
+-- Занимает одно использование; нет строки — пропуск исчерпан или отозван
+-- name: UseGuestPass :one
+UPDATE guest_passes SET use_count = use_count + 1
+WHERE key_id = $1 AND use_count < max_uses AND revoked_at IS NULL
+RETURNING use_count, max_uses;
+
+-- name: GetGuestPass :one
+SELECT gp.key_id, gp.apartment_id, gp.issued_by, gp.guest_name, gp.code_format, gp.max_uses, gp.use_count,
+       gp.device_id, gp.revoked_at, k.is_active, k.issued_at, k.valid_from, k.valid_to, k.schedule_id
+FROM guest_passes gp
+JOIN keys k ON k.id = gp.key_id
+WHERE gp.key_id = $1;
+
+-- name: ListGuestPassesByIssuer :many
+SELECT gp.key_id, gp.apartment_id, gp.issued_by, gp.guest_name, gp.code_format, gp.max_uses, gp.use_count,
+       gp.device_id, gp.revoked_at, k.is_active, k.issued_at, k.valid_from, k.valid_to, k.schedule_id
+FROM guest_passes gp
+JOIN keys k ON k.id = gp.key_id
+WHERE gp.issued_by = sqlc.arg(issued_by)
+  AND (k.valid_to IS NULL OR k.valid_to > sqlc.arg(since))
+ORDER BY k.issued_at DESC;
+
+-- name: RevokeGuestPass :execrows
+UPDATE guest_passes SET revoked_at = $2
+WHERE key_id = $1 AND revoked_at IS NULL;
+
+-- name: CreateAccessHistory :one
+INSERT INTO access_history (key_id, device_id, user_id, access_time, result, description)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id;
+
+-- name: ListAccessHistoryByKey :many
+SELECT * FROM access_history
+WHERE key_id = $1
+ORDER BY access_time DESC
+LIMIT $2;
+
+-- name: GetKey :one
+SELECT * FROM keys WHERE id = $1;
+
+-- Действующий ключ пользователя заданного типа (например, qr)
+-- name: GetActiveKeyByOwner :one
+SELECT * FROM keys
+WHERE owner_id = sqlc.arg(owner_id) AND key_type = sqlc.arg(key_type) AND is_active = TRUE
+ORDER BY issued_at DESC
+LIMIT 1;
+
+-- Есть ли у пользователя (владельца или жителя) квартира в доме с этим адресом
+-- name: UserHasApartmentAtAddress :one
+SELECT EXISTS (
+    SELECT 1
+    FROM apartments a
+    LEFT JOIN apartment_residents ar ON ar.apartment_id = a.id AND ar.is_active = TRUE
+    WHERE a.address = sqlc.arg(address)
+      AND (a.owner_id = sqlc.arg(user_id) OR ar.user_id = sqlc.arg(user_id))
+);
+
+-- name: GetApartmentAddress :one
+SELECT address FROM apartments WHERE id = $1;
+
+-- Кому звонит домофон: владелец и активные жители квартиры по номеру в доме
+-- name: ListApartmentCallees :many
+SELECT DISTINCT u.id
+FROM apartments a
+LEFT JOIN apartment_residents ar ON ar.apartment_id = a.id AND ar.is_active = TRUE
+JOIN users u ON u.id = a.owner_id OR u.id = ar.user_id
+WHERE a.address = $1 AND a.number = $2 AND u.is_active = TRUE
+ORDER BY u.id;
@@ -0,0 +1,133 @@
+-- name: CreateAnnouncement :one
+INSERT INTO announcements (author_id, title, body, target_type, target_address, target_entrance, publish_at, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: AddAnnouncementApartment :exec
+INSERT INTO announcement_apartments (announcement_id, apartment_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: ListAnnouncementApartmentIDs :many
+SELECT apartment_id FROM announcement_apartments
+WHERE announcement_id = $1
+ORDER BY apartment_id;
+
+-- name: GetAnnouncement :one
+SELECT * FROM announcements WHERE id = $1;
+
+-- name: CancelAnnouncement :execrows
+UPDATE announcements SET cancelled_at = $2
+WHERE id = $1 AND cancelled_at IS NULL;
+
+-- Все объявления для управляющей компании, с числом прочтений
+-- name: ListAnnouncements :many
+SELECT a.id, a.author_id, a.title, a.body, a.target_type, a.target_address, a.target_entrance,
+       a.publish_at, a.expires_at, a.cancelled_at, a.created_at,
+       (SELECT count(*) FROM announcement_reads r WHERE r.announcement_id = a.id) AS read_count
+FROM announcements a
+ORDER BY a.publish_at DESC
+LIMIT $1 OFFSET $2;
+
+-- Действующие объявления для квартир пользователя: опубликованы, не истекли,
+-- не отменены и адресованы его дому, подъезду или квартире
+-- name: ListActiveAnnouncementsForUser :many
+WITH my_apartments AS (
+    SELECT DISTINCT ap.id, ap.address, ap.entrance
+    FROM apartments ap
+    LEFT JOIN apartment_residents ar ON ar.apartment_id = ap.id AND ar.is_active = TRUE
+    WHERE ap.owner_id = sqlc.arg(user_id) OR ar.user_id = sqlc.arg(user_id)
+)
+SELECT a.id, a.author_id, a.title, a.body, a.target_type, a.target_address, a.target_entrance,
+       a.publish_at, a.expires_at, a.cancelled_at, a.created_at,
+       r.read_at
+FROM announcements a
+LEFT JOIN announcement_reads r ON r.announcement_id = a.id AND r.user_id = sqlc.arg(user_id)
+WHERE a.cancelled_at IS NULL
+  AND a.publish_at <= sqlc.arg(now)
+  AND (a.expires_at IS NULL OR a.expires_at > sqlc.arg(now))
+  AND EXISTS (
+      SELECT 1 FROM my_apartments ma
+      WHERE (a.target_type = 'address' AND ma.address = a.target_address)
+         OR (a.target_type = 'entrance' AND ma.address = a.target_address AND ma.entrance = a.target_entrance)
+         OR (a.target_type = 'apartments' AND EXISTS (
+                SELECT 1 FROM announcement_apartments aa
+                WHERE aa.announcement_id = a.id AND aa.apartment_id = ma.id))
+  )
+ORDER BY a.publish_at DESC;
+
+-- То же условие, что в ListActiveAnnouncementsForUser, для одного объявления
+-- name: IsAnnouncementVisibleToUser :one
+SELECT EXISTS (
+    SELECT 1
+    FROM announcements a
+    JOIN (
+        SELECT DISTINCT ap.id, ap.address, ap.entrance
+        FROM apartments ap
+        LEFT JOIN apartment_residents ar ON ar.apartment_id = ap.id AND ar.is_active = TRUE
+        WHERE ap.owner_id = sqlc.arg(user_id) OR ar.user_id = sqlc.arg(user_id)
+    ) ma ON (a.target_type = 'address' AND ma.address = a.target_address)
+         OR (a.target_type = 'entrance' AND ma.address = a.target_address AND ma.entrance = a.target_entrance)
+         OR (a.target_type = 'apartments' AND EXISTS (
+                SELECT 1 FROM announcement_apartments aa
+                WHERE aa.announcement_id = a.id AND aa.apartment_id = ma.id))
+    WHERE a.id = sqlc.arg(announcement_id)
+      AND a.cancelled_at IS NULL
+      AND a.publish_at <= sqlc.arg(now)
+      AND (a.expires_at IS NULL OR a.expires_at > sqlc.arg(now))
+) AS visible;
+
+-- name: MarkAnnouncementRead :exec
+INSERT INTO announcement_reads (announcement_id, user_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (announcement_id, user_id) DO NOTHING;
+
+-- name: ListAnnouncementReads :many
+SELECT r.user_id, r.read_at, u.username, u.first_name, u.last_name
+FROM announcement_reads r
+JOIN users u ON u.id = r.user_id
+WHERE r.announcement_id = $1
+ORDER BY r.read_at;
+
+-- name: CreateAnnouncementAttachment :one
+INSERT INTO announcement_attachments (announcement_id, file_name, storage_path, content_type, size_bytes)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetAnnouncementAttachment :one
+SELECT * FROM announcement_attachments WHERE id = $1;
+
+-- name: ListAnnouncementAttachments :many
+SELECT * FROM announcement_attachments
+WHERE announcement_id = ANY(sqlc.arg(announcement_ids)::bigint[])
+ORDER BY announcement_id, id;
+
+-- Забирает наступившие объявления, о которых ещё не рассылали push, и сразу
+-- помечает их разосланными. Отменённые и истёкшие тоже помечаются, рассылку
+-- по ним пропускает сервис.
+-- name: ClaimAnnouncementsToNotify :many
+UPDATE announcements SET notified_at = sqlc.arg(now)
+WHERE id IN (
+    SELECT id FROM announcements
+    WHERE notified_at IS NULL AND publish_at <= sqlc.arg(now)
+    ORDER BY publish_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- Адресаты рассылки: владельцы и активные жители квартир, которым адресовано
+-- объявление (то же условие, что в ListActiveAnnouncementsForUser)
+-- name: ListAnnouncementRecipients :many
+SELECT DISTINCT u.id
+FROM announcements a
+JOIN apartments ap
+  ON (a.target_type = 'address' AND ap.address = a.target_address)
+  OR (a.target_type = 'entrance' AND ap.address = a.target_address AND ap.entrance = a.target_entrance)
+  OR (a.target_type = 'apartments' AND EXISTS (
+         SELECT 1 FROM announcement_apartments aa
+         WHERE aa.announcement_id = a.id AND aa.apartment_id = ap.id))
+LEFT JOIN apartment_residents ar ON ar.apartment_id = ap.id AND ar.is_active = TRUE
+JOIN users u ON u.id = ap.owner_id OR u.id = ar.user_id
+WHERE a.id = $1 AND u.is_active = TRUE
+ORDER BY u.id;
@@ -0,0 +1,88 @@
+-- name: EnsureAnomalyScanCursor :exec
+INSERT INTO anomaly_scan_cursors (name, last_history_id)
+VALUES ($1, 0)
+ON CONFLICT (name) DO NOTHING;
+
+-- name: GetAnomalyScanCursorForUpdate :one
+SELECT last_history_id FROM anomaly_scan_cursors
+WHERE name = $1
+FOR UPDATE;
+
+-- name: UpdateAnomalyScanCursor :exec
+UPDATE anomaly_scan_cursors
+SET last_history_id = $2, scanned_at = $3
+WHERE name = $1;
+
+-- Новые записи журнала вместе с тем, что нужно для проверки:
+-- адрес устройства и момент отзыва ключа (заявление о потере или отзыв пропуска)
+-- name: ListAccessHistoryAfter :many
+SELECT ah.id, ah.key_id, ah.device_id, ah.user_id, ah.access_time, ah.result, ah.created_at,
+       a.address, lr.reported_at AS lost_at, gp.revoked_at
+FROM access_history ah
+LEFT JOIN devices d ON d.id = ah.device_id
+LEFT JOIN apartments a ON a.id = d.apartment_id
+LEFT JOIN lost_key_reports lr ON lr.key_id = ah.key_id
+LEFT JOIN guest_passes gp ON gp.key_id = ah.key_id
+WHERE ah.id > $1
+ORDER BY ah.id
+LIMIT $2;
+
+-- Проход по тому же ключу в другом доме в заданном интервале
+-- name: FindKeyUseElsewhere :one
+SELECT ah.id, ah.device_id, ah.access_time
+FROM access_history ah
+JOIN devices d ON d.id = ah.device_id
+JOIN apartments a ON a.id = d.apartment_id
+WHERE ah.key_id = sqlc.arg(key_id)
+  AND ah.id <> sqlc.arg(id)
+  AND ah.result = 'granted'
+  AND a.address <> sqlc.arg(address)
+  AND ah.access_time BETWEEN sqlc.arg(from_time) AND sqlc.arg(to_time)
+ORDER BY ah.access_time DESC
+LIMIT 1;
+
+-- name: CountDeviceDenials :one
+SELECT count(*) FROM access_history
+WHERE device_id = sqlc.arg(device_id)
+  AND result = 'denied'
+  AND access_time BETWEEN sqlc.arg(from_time) AND sqlc.arg(to_time);
+
+-- name: CountKeyDenials :one
+SELECT count(*) FROM access_history
+WHERE key_id = sqlc.arg(key_id)
+  AND result = 'denied'
+  AND access_time BETWEEN sqlc.arg(from_time) AND sqlc.arg(to_time);
+
+-- Время прошлых проходов по ключу — для оценки, в какие часы им обычно пользуются
+-- name: ListKeyGrantedTimes :many
+SELECT access_time FROM access_history
+WHERE key_id = sqlc.arg(key_id)
+  AND id <> sqlc.arg(id)
+  AND result = 'granted'
+  AND access_time >= sqlc.arg(since)
+ORDER BY access_time DESC
+LIMIT sqlc.arg(lim);
+
+-- Нет строки — такая тревога уже есть
+-- name: CreateSecurityAlert :one
+INSERT INTO security_alerts (kind, severity, key_id, device_id, user_id, access_history_id, dedup_key, description, detected_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (dedup_key) DO NOTHING
+RETURNING id;
+
+-- Пустые status и kind — без фильтра
+-- name: ListSecurityAlerts :many
+SELECT * FROM security_alerts
+WHERE (sqlc.arg(status)::text = '' OR status = sqlc.arg(status))
+  AND (sqlc.arg(kind)::text = '' OR kind = sqlc.arg(kind))
+ORDER BY detected_at DESC, id DESC
+LIMIT sqlc.arg(lim);
+
+-- name: GetSecurityAlert :one
+SELECT * FROM security_alerts WHERE id = $1;
+
+-- name: ReviewSecurityAlert :one
+UPDATE security_alerts
+SET status = $2, reviewed_by = $3, reviewed_at = $4, review_note = $5
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,128 @@
+-- Адреса домов, где у пользователя есть квартира (владелец или активный житель)
+-- name: GetUserBuildingAddresses :many
+SELECT DISTINCT a.address
+FROM apartments a
+LEFT JOIN apartment_residents ar ON ar.apartment_id = a.id AND ar.is_active = TRUE
+WHERE a.owner_id = sqlc.arg(user_id) OR ar.user_id = sqlc.arg(user_id)
+ORDER BY a.address;
+
+-- name: UpsertChat :one
+INSERT INTO chats (chat_key, kind, title)
+VALUES ($1, $2, $3)
+ON CONFLICT (chat_key) DO UPDATE SET title = COALESCE(chats.title, EXCLUDED.title)
+RETURNING *;
+
+-- name: GetChat :one
+SELECT * FROM chats WHERE id = $1;
+
+-- name: AddChatMember :exec
+INSERT INTO chat_members (chat_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT (chat_id, user_id) DO NOTHING;
+
+-- Убирает из чата дома всех, у кого в этом доме больше нет квартиры
+-- name: RemoveFormerBuildingMembers :exec
+DELETE FROM chat_members cm
+USING chats c
+WHERE cm.chat_id = c.id
+  AND c.id = $1
+  AND c.kind = 'building'
+  AND NOT EXISTS (
+      SELECT 1
+      FROM apartments a
+      LEFT JOIN apartment_residents ar ON ar.apartment_id = a.id AND ar.is_active = TRUE
+      WHERE 'building:' || a.address = c.chat_key
+        AND (a.owner_id = cm.user_id OR ar.user_id = cm.user_id)
+  );
+
+-- Убирает пользователя из чатов домов, где у него больше нет квартиры
+-- name: RemoveStaleBuildingMemberships :exec
+DELETE FROM chat_members cm
+USING chats c
+WHERE cm.chat_id = c.id
+  AND c.kind = 'building'
+  AND cm.user_id = sqlc.arg(user_id)
+  AND NOT (c.chat_key = ANY(sqlc.arg(keep_keys)::text[]));
+
+-- name: GetChatMember :one
+SELECT * FROM chat_members WHERE chat_id = $1 AND user_id = $2;
+
+-- name: ListUserChats :many
+SELECT c.id, c.chat_key, c.kind, c.title, c.created_at,
+       cm.last_read_message_id,
+       COALESCE(last.id, 0)::bigint AS last_message_id,
+       last.created_at AS last_message_at,
+       (SELECT count(*) FROM chat_messages m
+        WHERE m.chat_id = c.id
+          AND m.id > COALESCE(cm.last_read_message_id, 0)
+          AND m.deleted_at IS NULL
+          AND m.sender_id IS DISTINCT FROM cm.user_id) AS unread_count
+FROM chat_members cm
+JOIN chats c ON c.id = cm.chat_id
+LEFT JOIN LATERAL (
+    SELECT id, created_at FROM chat_messages
+    WHERE chat_id = c.id
+    ORDER BY id DESC
+    LIMIT 1
+) last ON TRUE
+WHERE cm.user_id = $1
+ORDER BY last.id DESC NULLS LAST, c.id DESC;
+
+-- name: ListChatMembers :many
+SELECT cm.chat_id, cm.user_id, cm.last_read_message_id, cm.last_read_at,
+       u.username, u.first_name, u.last_name, u.avatar_url
+FROM chat_members cm
+JOIN users u ON u.id = cm.user_id
+WHERE cm.chat_id = $1
+ORDER BY u.username;
+
+-- name: CreateChatMessage :one
+INSERT INTO chat_messages (chat_id, sender_id, body)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetChatMessage :one
+SELECT * FROM chat_messages WHERE id = $1;
+
+-- Страница истории от новых к старым; before_id = 0 — с самого нового
+-- name: ListChatMessages :many
+SELECT * FROM chat_messages
+WHERE chat_id = sqlc.arg(chat_id)
+  AND (sqlc.arg(before_id)::bigint = 0 OR id < sqlc.arg(before_id)::bigint)
+ORDER BY id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: UpdateChatMessage :one
+UPDATE chat_messages
+SET body = $3, edited_at = $4
+WHERE id = $1 AND sender_id = $2 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeleteChatMessage :one
+UPDATE chat_messages
+SET body = '', deleted_at = $3
+WHERE id = $1 AND sender_id = $2 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: CreateChatMessageMention :exec
+INSERT INTO chat_message_mentions (message_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChatMessageMentions :exec
+DELETE FROM chat_message_mentions WHERE message_id = $1;
+
+-- name: ListChatMessageMentions :many
+SELECT mm.message_id, mm.user_id, u.username
+FROM chat_message_mentions mm
+JOIN users u ON u.id = mm.user_id
+WHERE mm.message_id = ANY(sqlc.arg(message_ids)::bigint[])
+ORDER BY mm.message_id, u.username;
+
+-- Отметка прочтения только сдвигается вперёд
+-- name: MarkChatRead :one
+UPDATE chat_members
+SET last_read_message_id = GREATEST(COALESCE(last_read_message_id, 0), sqlc.arg(message_id)::bigint),
+    last_read_at = sqlc.arg(read_at)
+WHERE chat_id = sqlc.arg(chat_id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
@@ -0,0 +1,18 @@
+-- name: GetDevice :one
+SELECT * FROM devices WHERE id = $1;
+
+-- name: GetDeviceByAPIKeyHash :one
+SELECT * FROM devices WHERE api_key_hash = $1;
+
+-- name: SetDeviceAPIKeyHash :execrows
+UPDATE devices SET api_key_hash = $2 WHERE id = $1;
+
+-- name: TouchDevice :exec
+UPDATE devices SET last_seen_at = $2 WHERE id = $1;
+
+-- Адрес дома, к которому относится устройство (через его квартиру)
+-- name: GetDeviceAddress :one
+SELECT a.address
+FROM devices d
+JOIN apartments a ON a.id = d.apartment_id
+WHERE d.id = $1;
@@ -0,0 +1,99 @@
+-- name: ListKeyListDeviceIDs :many
+SELECT id FROM devices
+WHERE status = 'active' AND api_key_hash IS NOT NULL
+ORDER BY id;
+
+-- Хеш ключа устройства: из него панель и сервер получают ключ HMAC гостевых кодов
+-- name: GetDeviceKeyHash :one
+SELECT api_key_hash FROM devices WHERE id = $1;
+
+-- name: GetLatestDeviceKeyList :one
+SELECT * FROM device_key_lists
+WHERE device_id = $1
+ORDER BY version DESC
+LIMIT 1;
+
+-- name: GetDeviceKeyList :one
+SELECT * FROM device_key_lists
+WHERE device_id = $1 AND version = $2;
+
+-- name: CreateDeviceKeyList :execrows
+INSERT INTO device_key_lists (device_id, version, content_hash, content, created_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (device_id, version) DO NOTHING;
+
+-- name: DeleteDeviceKeyListsBefore :exec
+DELETE FROM device_key_lists
+WHERE device_id = $1 AND version < $2;
+
+-- name: UpsertDeviceKeyListAck :exec
+INSERT INTO device_key_list_acks (device_id, version, acked_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (device_id) DO UPDATE
+SET version = EXCLUDED.version, acked_at = EXCLUDED.acked_at;
+
+-- name: ListDeviceKeyListStatus :many
+SELECT d.id, d.serial_number, d.status, d.last_seen_at,
+       l.version AS latest_version, l.created_at AS latest_created_at,
+       ak.version AS acked_version, ak.acked_at
+FROM devices d
+LEFT JOIN LATERAL (
+    SELECT version, created_at FROM device_key_lists
+    WHERE device_id = d.id
+    ORDER BY version DESC
+    LIMIT 1
+) l ON TRUE
+LEFT JOIN device_key_list_acks ak ON ak.device_id = d.id
+ORDER BY d.id;
+
+-- Ключи жителей дома (владельцев и активных жильцов квартир по адресу)
+-- name: ListAddressResidentKeys :many
+SELECT k.id, k.key_code, k.key_type, k.owner_id, k.valid_from, k.valid_to, k.schedule_id,
+       array_agg(DISTINCT m.apartment_id ORDER BY m.apartment_id)::int[] AS apartment_ids
+FROM keys k
+JOIN (
+    SELECT a.id AS apartment_id, a.owner_id AS user_id
+    FROM apartments a
+    WHERE a.address = sqlc.arg(address) AND a.owner_id IS NOT NULL
+    UNION
+    SELECT ar.apartment_id, ar.user_id
+    FROM apartment_residents ar
+    JOIN apartments a ON a.id = ar.apartment_id
+    WHERE a.address = sqlc.arg(address) AND ar.is_active = TRUE
+) m ON m.user_id = k.owner_id
+WHERE k.is_active = TRUE
+  AND k.key_type <> 'guest'
+  AND (k.valid_to IS NULL OR k.valid_to > sqlc.arg(now))
+GROUP BY k.id
+ORDER BY k.id;
+
+-- Гостевые пропуска, которые могут сработать на устройстве
+-- name: ListDeviceGuestPasses :many
+SELECT gp.key_id, gp.apartment_id, gp.code_hash, gp.max_uses, gp.use_count,
+       k.valid_from, k.valid_to, k.schedule_id, gp.issued_by
+FROM guest_passes gp
+JOIN keys k ON k.id = gp.key_id
+JOIN apartments a ON a.id = gp.apartment_id
+WHERE gp.revoked_at IS NULL
+  AND k.is_active = TRUE
+  AND gp.use_count < gp.max_uses
+  AND (k.valid_to IS NULL OR k.valid_to > sqlc.arg(now))
+  AND a.address = sqlc.arg(address)
+  AND (gp.device_id IS NULL OR gp.device_id = sqlc.arg(device_id))
+ORDER BY gp.key_id;
+
+-- Устройства в домах, где у пользователя есть квартира (как владельца или жильца)
+-- name: ListUserKeyListDeviceIDs :many
+SELECT DISTINCT d.id
+FROM devices d
+JOIN apartments da ON da.id = d.apartment_id
+WHERE d.status = 'active' AND d.api_key_hash IS NOT NULL
+  AND da.address IN (
+    SELECT a.address FROM apartments a WHERE a.owner_id = $1
+    UNION
+    SELECT a.address
+    FROM apartment_residents ar
+    JOIN apartments a ON a.id = ar.apartment_id
+    WHERE ar.user_id = $1 AND ar.is_active = TRUE
+  )
+ORDER BY d.id;
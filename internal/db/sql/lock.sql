@@ -0,0 +1,6 @@
+-- Сессионная advisory-блокировка: задачу выполняет один экземпляр API
+-- name: TryAdvisoryLock :one
+SELECT pg_try_advisory_lock(sqlc.arg(lock_key)::bigint);
+
+-- name: AdvisoryUnlock :exec
+SELECT pg_advisory_unlock(sqlc.arg(lock_key)::bigint);
@@ -0,0 +1,33 @@
+-- Нет вставленной строки — о потере уже сообщали
+-- name: CreateLostKeyReport :execrows
+INSERT INTO lost_key_reports (key_id, reported_by, reported_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (key_id) DO NOTHING;
+
+-- name: GetLostKeyReport :one
+SELECT * FROM lost_key_reports WHERE key_id = $1;
+
+-- name: FindLostKeyByCode :one
+SELECT r.key_id, k.owner_id, r.reported_at
+FROM lost_key_reports r
+JOIN keys k ON k.id = r.key_id
+WHERE k.key_code = $1;
+
+-- name: MarkLostKeyPresented :exec
+UPDATE lost_key_reports
+SET presented_count = presented_count + 1, last_presented_at = $2
+WHERE key_id = $1;
+
+-- name: SetLostKeyReplacement :execrows
+UPDATE lost_key_reports SET replacement_key_id = $2
+WHERE key_id = $1 AND replacement_key_id IS NULL;
+
+-- Ключи пользователя, кроме гостевых пропусков и QR-кодов
+-- name: ListOwnerKeys :many
+SELECT k.id, k.key_code, k.key_type, k.is_active, k.issued_at, k.valid_from, k.valid_to, k.description,
+       k.schedule_id, r.reported_at AS lost_at, r.replacement_key_id
+FROM keys k
+LEFT JOIN lost_key_reports r ON r.key_id = k.id
+WHERE k.owner_id = $1
+  AND (k.key_type IS NULL OR k.key_type NOT IN ('guest', 'qr'))
+ORDER BY k.issued_at DESC, k.id DESC;
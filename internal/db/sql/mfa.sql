@@ -0,0 +1,41 @@
+-- Начало подключения: новый секрет, второй фактор ещё выключен
+-- name: UpsertUserMFA :exec
+INSERT INTO user_mfa (user_id, secret, enabled, created_at)
+VALUES ($1, $2, FALSE, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    enabled = FALSE,
+    last_used_step = NULL,
+    created_at = EXCLUDED.created_at,
+    enabled_at = NULL;
+
+-- name: GetUserMFA :one
+SELECT * FROM user_mfa WHERE user_id = $1;
+
+-- name: EnableUserMFA :exec
+UPDATE user_mfa
+SET enabled = TRUE, enabled_at = $2, last_used_step = $3
+WHERE user_id = $1;
+
+-- Принимает шаг TOTP, только если он новее последнего использованного
+-- name: UseMFAStep :execrows
+UPDATE user_mfa
+SET last_used_step = sqlc.arg(step)
+WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < sqlc.arg(step));
+
+-- name: DeleteUserMFA :exec
+DELETE FROM user_mfa WHERE user_id = $1;
+
+-- name: CreateMFARecoveryCode :exec
+INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2);
+
+-- name: DeleteMFARecoveryCodes :exec
+DELETE FROM mfa_recovery_codes WHERE user_id = $1;
+
+-- name: UseMFARecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = $3
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountMFARecoveryCodes :one
+SELECT count(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL;
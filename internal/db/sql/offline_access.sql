@@ -0,0 +1,14 @@
+-- Нет вставленной строки — запись уже выгружалась
+-- name: CreateOfflineAccessRecord :execrows
+INSERT INTO offline_access_records (device_id, record_id, recorded_at, access_time, clock_skew_seconds, anomaly, received_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (device_id, record_id) DO NOTHING;
+
+-- name: SetOfflineAccessRecordHistory :exec
+UPDATE offline_access_records SET access_history_id = $3
+WHERE device_id = $1 AND record_id = $2;
+
+-- Проход по гостевому пропуску, разрешённый панелью офлайн
+-- name: RecordOfflineGuestPassUse :exec
+UPDATE guest_passes SET use_count = LEAST(use_count + 1, max_uses)
+WHERE key_id = $1;
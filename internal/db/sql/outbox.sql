@@ -0,0 +1,35 @@
+-- name: EnqueueOutboxMessage :one
+INSERT INTO outbox_messages (channel, recipient, subject, body, payload, max_attempts)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id;
+
+-- Забирает пачку готовых к отправке сообщений и зависшие после падения воркера
+-- name: ClaimOutboxMessages :many
+UPDATE outbox_messages
+SET status = 'processing', locked_until = sqlc.arg(locked_until)
+WHERE id IN (
+    SELECT id FROM outbox_messages
+    WHERE (status = 'pending' AND next_attempt_at <= sqlc.arg(now))
+       OR (status = 'processing' AND locked_until < sqlc.arg(now))
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- Отметки ставит только воркер, который держит сообщение: если блокировка истекла
+-- и сообщение забрал другой, locked_until уже другой и строка не меняется.
+-- Текст отправленного сообщения (коды, ссылки) больше не нужен и стирается.
+-- name: MarkOutboxSent :execrows
+UPDATE outbox_messages
+SET status = 'sent', attempts = attempts + 1, sent_at = $2, locked_until = NULL, last_error = NULL,
+    body = '', payload = NULL
+WHERE id = $1 AND locked_until = sqlc.arg(claimed_until);
+
+-- name: MarkOutboxFailed :execrows
+UPDATE outbox_messages
+SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_error = $4, locked_until = NULL
+WHERE id = $1 AND locked_until = sqlc.arg(claimed_until);
+
+-- name: GetOutboxMessage :one
+SELECT * FROM outbox_messages WHERE id = $1;
@@ -0,0 +1,52 @@
+-- Токен переехал в другую сессию или к другому пользователю — старая запись удаляется
+-- name: DeleteOtherPushDevicesWithToken :exec
+DELETE FROM push_devices
+WHERE token = sqlc.arg(token)
+  AND (user_id <> sqlc.arg(user_id) OR session_id <> sqlc.arg(session_id));
+
+-- name: UpsertPushDevice :one
+INSERT INTO push_devices (user_id, session_id, token, provider, platform, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id, session_id) DO UPDATE
+SET token = EXCLUDED.token,
+    provider = EXCLUDED.provider,
+    platform = EXCLUDED.platform,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: DeletePushDeviceBySession :execrows
+DELETE FROM push_devices WHERE user_id = $1 AND session_id = $2;
+
+-- name: DeletePushDeviceByToken :exec
+DELETE FROM push_devices WHERE token = $1;
+
+-- Все сессии пользователя отозваны — его устройства больше не получают уведомления
+-- name: DeleteUserPushDevices :exec
+DELETE FROM push_devices WHERE user_id = $1;
+
+-- Устройства, обновлявшие токен не раньше active_after
+-- name: ListUserPushDevices :many
+SELECT * FROM push_devices
+WHERE user_id = sqlc.arg(user_id) AND updated_at >= sqlc.arg(active_after)
+ORDER BY id;
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences WHERE user_id = $1 ORDER BY event_type;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, event_type, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, event_type) DO UPDATE SET enabled = EXCLUDED.enabled;
+
+-- name: GetNotificationSettings :one
+SELECT * FROM notification_settings WHERE user_id = $1;
+
+-- name: UpsertNotificationSettings :one
+INSERT INTO notification_settings (user_id, quiet_hours_start, quiet_hours_end, timezone, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE
+SET quiet_hours_start = EXCLUDED.quiet_hours_start,
+    quiet_hours_end = EXCLUDED.quiet_hours_end,
+    timezone = EXCLUDED.timezone,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
@@ -10,12 +10,9 @@ RETURNING *;
 -- name: UpdateUser :one
 UPDATE users
 SET username = $2,
-    password_hash = $3,
-    email = $4,
-    phone = $5,
-    role = $6,
-    first_name = $7,
-    last_name = $8
+    role = $3,
+    first_name = $4,
+    last_name = $5
 WHERE id = $1
 RETURNING *;
 
@@ -42,6 +39,11 @@ FROM users u
 JOIN password_reset_tokens prt ON prt.user_id = u.id
 WHERE prt.token = $1 AND prt.expires_at > NOW();
 
+-- Гасит ссылку сброса; 0 строк — ссылка чужая, просрочена или уже использована
+-- name: ConsumePasswordResetToken :execrows
+DELETE FROM password_reset_tokens
+WHERE token = sqlc.arg(token) AND user_id = sqlc.arg(user_id) AND expires_at > NOW();
+
 -- name: InvalidateResetToken :exec
 DELETE FROM password_reset_tokens WHERE token = $1;
 
@@ -56,26 +58,40 @@ UPDATE users
 SET password_hash = $1
 WHERE phone = $2;
 
--- name: UpsertPhoneVerificationToken :exec
-INSERT INTO phone_verification_tokens (phone, verification_code, expires_at)
-VALUES ($1, $2, $3)
-ON CONFLICT (phone)
-DO UPDATE SET verification_code = EXCLUDED.verification_code, expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP;
+-- Новый код не обнуляет счётчик попыток, пока не закончилось окно attempts_reset_at
+-- name: UpsertPhoneVerificationToken :one
+INSERT INTO phone_verification_tokens (phone, purpose, verification_code, expires_at, attempts_reset_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (phone, purpose)
+DO UPDATE SET verification_code = EXCLUDED.verification_code, expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP,
+    attempts = CASE WHEN phone_verification_tokens.attempts_reset_at > NOW() THEN phone_verification_tokens.attempts ELSE 0 END,
+    attempts_reset_at = CASE WHEN phone_verification_tokens.attempts_reset_at > NOW() THEN phone_verification_tokens.attempts_reset_at ELSE EXCLUDED.attempts_reset_at END
+RETURNING attempts;
 
 -- name: GetPhoneVerificationToken :one
 SELECT * FROM phone_verification_tokens
-WHERE phone = $1 AND verification_code = $2 AND expires_at > NOW();
+WHERE phone = $1 AND purpose = $2 AND expires_at > NOW();
+
+-- Занимает попытку ввода до сравнения кода; нет строки — кода нет, он истёк или попытки кончились
+-- name: ClaimPhoneVerificationAttempt :one
+UPDATE phone_verification_tokens
+SET attempts = attempts + 1
+WHERE phone = $1 AND purpose = $2 AND expires_at > NOW() AND attempts < sqlc.arg(max_attempts)
+RETURNING verification_code, attempts;
 
 -- name: DeletePhoneVerificationToken :exec
-DELETE FROM phone_verification_tokens WHERE phone = $1;
+DELETE FROM phone_verification_tokens WHERE phone = $1 AND purpose = $2;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE email = $1;
 
+-- Последний выданный код на номер (для ограничения частоты отправки)
 -- name: GetPhoneVerificationTokenByPhone :one
-SELECT phone, verification_code, expires_at, created_at
+SELECT phone, verification_code, expires_at, created_at, purpose, attempts, attempts_reset_at
 FROM phone_verification_tokens
-WHERE phone = $1;
+WHERE phone = $1
+ORDER BY created_at DESC
+LIMIT 1;
 
 -- name: SaveRefreshToken :exec
 INSERT INTO refresh_tokens (user_id, token, jti, expires_at)
@@ -112,5 +128,75 @@ WHERE id = $1;
 
 -- name: UpdateEmail :exec
 UPDATE users
-SET email = $2
+SET email = $2,
+    email_verified = email_verified AND email = $2
 WHERE id = $1;
+
+-- name: GetUserApartmentIDs :many
+SELECT DISTINCT a.id
+FROM apartments a
+LEFT JOIN apartment_residents ar ON ar.apartment_id = a.id AND ar.is_active = TRUE
+WHERE a.owner_id = sqlc.arg(user_id) OR ar.user_id = sqlc.arg(user_id)
+ORDER BY a.id;
+
+-- name: CreateEvent :exec
+INSERT INTO events (device_id, event_type, user_id, description)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts WHERE attempt_key = $1;
+
+-- Счётчик сбрасывается, если прошлая неудача была раньше начала окна
+-- name: RegisterLoginFailure :one
+INSERT INTO login_attempts (attempt_key, failed_count, last_failed_at)
+VALUES ($1, 1, $2)
+ON CONFLICT (attempt_key) DO UPDATE
+SET failed_count = CASE
+        WHEN login_attempts.last_failed_at < sqlc.arg(window_start) THEN 1
+        ELSE login_attempts.failed_count + 1
+    END,
+    last_failed_at = EXCLUDED.last_failed_at
+RETURNING *;
+
+-- name: LockLoginAttempt :exec
+UPDATE login_attempts SET locked_until = $2 WHERE attempt_key = $1;
+
+-- name: ResetLoginAttempts :exec
+DELETE FROM login_attempts WHERE attempt_key = $1;
+
+-- name: CreateVerificationTicket :exec
+INSERT INTO verification_tickets (jti, phone, purpose, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- Помечает билет использованным; 0 строк — билет чужой, просрочен или уже погашен
+-- name: ConsumeVerificationTicket :execrows
+UPDATE verification_tickets
+SET used_at = sqlc.arg(now)
+WHERE jti = $1 AND phone = $2 AND purpose = $3
+  AND used_at IS NULL AND expires_at > sqlc.arg(now);
+
+-- name: DeleteUserRefreshTokens :exec
+DELETE FROM refresh_tokens WHERE user_id = $1;
+
+-- name: ChangePasswordByID :exec
+UPDATE users SET password_hash = $2 WHERE id = $1;
+
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, user_id, email, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetEmailVerificationToken :one
+SELECT * FROM email_verification_tokens
+WHERE token_hash = $1 AND expires_at > sqlc.arg(now);
+
+-- name: DeleteEmailVerificationTokens :exec
+DELETE FROM email_verification_tokens WHERE user_id = $1;
+
+-- Адрес из ссылки становится основным и подтверждённым
+-- name: ConfirmUserEmail :exec
+UPDATE users SET email = $2, email_verified = TRUE WHERE id = $1;
+
+-- Меняет номер, только если он не изменился с момента проверки
+-- name: UpdateUserPhone :execrows
+UPDATE users SET phone = sqlc.arg(new_phone)
+WHERE id = $1 AND phone = sqlc.arg(old_phone);
@@ -0,0 +1,19 @@
+-- name: EnsureRateLimitBucket :exec
+INSERT INTO rate_limit_buckets (bucket_key, tokens, updated_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (bucket_key) DO NOTHING;
+
+-- name: GetRateLimitBucketForUpdate :one
+SELECT * FROM rate_limit_buckets
+WHERE bucket_key = $1
+FOR UPDATE;
+
+-- name: UpdateRateLimitBucket :exec
+UPDATE rate_limit_buckets
+SET tokens = $2, updated_at = $3
+WHERE bucket_key = $1;
+
+-- Вёдра, которые давно не трогали, уже полные — хранить их незачем
+-- name: DeleteStaleRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets
+WHERE updated_at < $1;
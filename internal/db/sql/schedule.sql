@@ -0,0 +1,69 @@
+-- name: CreateAccessSchedule :one
+INSERT INTO access_schedules (name, timezone, created_by)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: UpdateAccessSchedule :execrows
+UPDATE access_schedules
+SET name = $2, timezone = $3, updated_at = $4
+WHERE id = $1;
+
+-- name: DeleteAccessSchedule :execrows
+DELETE FROM access_schedules WHERE id = $1;
+
+-- name: GetAccessSchedule :one
+SELECT * FROM access_schedules WHERE id = $1;
+
+-- name: ListAccessSchedules :many
+SELECT * FROM access_schedules ORDER BY name, id;
+
+-- name: CreateAccessScheduleRule :exec
+INSERT INTO access_schedule_rules (schedule_id, weekdays, start_minute, end_minute)
+VALUES ($1, $2, $3, $4);
+
+-- name: DeleteAccessScheduleRules :exec
+DELETE FROM access_schedule_rules WHERE schedule_id = $1;
+
+-- name: ListAccessScheduleRules :many
+SELECT * FROM access_schedule_rules
+WHERE schedule_id = ANY(sqlc.arg(schedule_ids)::int[])
+ORDER BY schedule_id, id;
+
+-- name: AddAccessScheduleHoliday :exec
+INSERT INTO access_schedule_holidays (schedule_id, day)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteAccessScheduleHolidays :exec
+DELETE FROM access_schedule_holidays WHERE schedule_id = $1;
+
+-- name: ListAccessScheduleHolidays :many
+SELECT * FROM access_schedule_holidays
+WHERE schedule_id = ANY(sqlc.arg(schedule_ids)::int[])
+ORDER BY schedule_id, day;
+
+-- name: SetKeySchedule :execrows
+UPDATE keys SET schedule_id = $2 WHERE id = $1;
+
+-- name: SetResidentTypeSchedule :exec
+INSERT INTO resident_type_schedules (resident_type, schedule_id)
+VALUES ($1, $2)
+ON CONFLICT (resident_type) DO UPDATE SET schedule_id = EXCLUDED.schedule_id;
+
+-- name: DeleteResidentTypeSchedule :execrows
+DELETE FROM resident_type_schedules WHERE resident_type = $1;
+
+-- name: ListResidentTypeSchedules :many
+SELECT * FROM resident_type_schedules ORDER BY resident_type;
+
+-- Расписания, которые действуют на пользователя по типу жителя.
+-- NULL — у пользователя есть квартира без ограничений (владелец или тип без расписания).
+-- name: ListUserResidentSchedules :many
+SELECT rts.schedule_id
+FROM apartment_residents ar
+LEFT JOIN resident_type_schedules rts ON rts.resident_type = ar.resident_type
+WHERE ar.user_id = sqlc.arg(user_id) AND ar.is_active = TRUE
+UNION ALL
+SELECT NULL::int
+FROM apartments a
+WHERE a.owner_id = sqlc.arg(user_id);
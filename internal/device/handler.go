@@ -0,0 +1,72 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"domofon/internal/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+type DeviceHandler struct {
+	devices *DeviceService
+}
+
+func NewDeviceHandler(s *DeviceService) *DeviceHandler {
+	return &DeviceHandler{devices: s}
+}
+
+type IssueKeyResponse struct {
+	DeviceID int64 `json:"device_id"`
+	// Передаётся устройством в заголовке Authorization: Device <key>. Показывается один раз.
+	Key string `json:"key"`
+}
+
+// IssueKey godoc
+// @Summary Выпустить ключ устройства
+// @Description Для ролей из DEVICE_ADMIN_ROLES. Прежний ключ устройства перестаёт действовать.
+// @Tags devices
+// @Produce json
+// @Param id path int true "ID устройства"
+// @Success 200 {object} IssueKeyResponse
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Устройство не найдено"
+// @Security BearerAuth
+// @Router /devices/{id}/api-key [post]
+func (h *DeviceHandler) IssueKey(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	deviceID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || deviceID <= 0 {
+		http.Error(w, "Некорректный id", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.devices.IssueKey(r.Context(), userID, claims.Role, deviceID)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, ErrDeviceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(IssueKeyResponse{DeviceID: deviceID, Key: key})
+}
@@ -0,0 +1,67 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Repository interface {
+	GetDevice(ctx context.Context, id int64) (*db.Device, error)
+	GetDeviceByKeyHash(ctx context.Context, hash string) (*db.Device, error)
+	SetKeyHash(ctx context.Context, id int64, hash string) (bool, error)
+	Touch(ctx context.Context, id int64, now time.Time) error
+}
+
+type DeviceRepository struct {
+	queries *db.Queries
+}
+
+func NewDeviceRepository(queries *db.Queries) *DeviceRepository {
+	return &DeviceRepository{queries: queries}
+}
+
+// nil, nil — устройства нет
+func (r *DeviceRepository) GetDevice(ctx context.Context, id int64) (*db.Device, error) {
+	d, err := r.queries.GetDevice(ctx, int32(id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// nil, nil — ключ не выпускался
+func (r *DeviceRepository) GetDeviceByKeyHash(ctx context.Context, hash string) (*db.Device, error) {
+	d, err := r.queries.GetDeviceByAPIKeyHash(ctx, pgtype.Text{String: hash, Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// false — устройства нет
+func (r *DeviceRepository) SetKeyHash(ctx context.Context, id int64, hash string) (bool, error) {
+	n, err := r.queries.SetDeviceAPIKeyHash(ctx, db.SetDeviceAPIKeyHashParams{
+		ID:         int32(id),
+		ApiKeyHash: pgtype.Text{String: hash, Valid: true},
+	})
+	return n > 0, err
+}
+
+func (r *DeviceRepository) Touch(ctx context.Context, id int64, now time.Time) error {
+	return r.queries.TouchDevice(ctx, db.TouchDeviceParams{
+		ID:         int32(id),
+		LastSeenAt: pgtype.Timestamp{Time: now, Valid: true},
+	})
+}
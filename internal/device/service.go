@@ -0,0 +1,82 @@
+package device
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+
+	"domofon/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Устройство считается активным, пока статус не сменили вручную
+const StatusActive = "active"
+
+var (
+	ErrForbidden      = errors.New("недостаточно прав")
+	ErrDeviceNotFound = errors.New("устройство не найдено")
+	ErrInvalidKey     = errors.New("неверный ключ устройства")
+	ErrDeviceInactive = errors.New("устройство отключено")
+)
+
+type DeviceService struct {
+	repo Repository
+	cfg  *config.DeviceConfig
+}
+
+func NewDeviceService(repo Repository, cfg *config.DeviceConfig) *DeviceService {
+	return &DeviceService{repo: repo, cfg: cfg}
+}
+
+// Выпускает новый ключ устройства; прежний перестаёт действовать.
+// Ключ показывается один раз, в базе хранится только его хеш.
+func (s *DeviceService) IssueKey(ctx context.Context, userID int64, role string, deviceID int64) (string, error) {
+	if !slices.Contains(s.cfg.AdminRoles, role) {
+		return "", ErrForbidden
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(b)
+	ok, err := s.repo.SetKeyHash(ctx, deviceID, hashKey(key))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrDeviceNotFound
+	}
+	log.Info().Int64("device_id", deviceID).Int64("user_id", userID).Msg("[device] Выпущен новый ключ устройства")
+	return key, nil
+}
+
+// Реализует middleware.DeviceAuthenticator
+func (s *DeviceService) AuthenticateDevice(ctx context.Context, key string) (int64, error) {
+	if key == "" {
+		return 0, ErrInvalidKey
+	}
+	d, err := s.repo.GetDeviceByKeyHash(ctx, hashKey(key))
+	if err != nil {
+		return 0, err
+	}
+	if d == nil {
+		return 0, ErrInvalidKey
+	}
+	if d.Status.Valid && d.Status.String != StatusActive {
+		return 0, ErrDeviceInactive
+	}
+	if err := s.repo.Touch(ctx, int64(d.ID), time.Now()); err != nil {
+		log.Warn().Err(err).Int32("device_id", d.ID).Msg("[device] Не удалось обновить время последнего обращения")
+	}
+	return int64(d.ID), nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
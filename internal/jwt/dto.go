@@ -1,15 +1,68 @@
 package jwt
 
-import "github.com/golang-jwt/jwt/v4"
+import (
+	"strconv"
 
-// Claims для access-токена
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Данные пользователя, которые попадают в access-токен
+type Identity struct {
+	UserID       int64
+	Role         string
+	SessionID    string
+	ApartmentIDs []int64
+}
+
+// Claims для access-токена. ID пользователя — в стандартном sub (строкой).
 type AccessClaims struct {
-	UserID int64 `json:"sub"`
+	Type         string  `json:"typ"`
+	Role         string  `json:"role,omitempty"`
+	SessionID    string  `json:"sid"`
+	ApartmentIDs []int64 `json:"apartments"`
 	jwt.RegisteredClaims
 }
 
 // Claims для refresh-токена
 type RefreshClaims struct {
-	UserID int64 `json:"sub"`
+	Type      string `json:"typ"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
+
+func (c *AccessClaims) UserID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+func (c *RefreshClaims) UserID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+// Есть ли у пользователя доступ к квартире
+func (c *AccessClaims) HasApartment(apartmentID int64) bool {
+	for _, id := range c.ApartmentIDs {
+		if id == apartmentID {
+			return true
+		}
+	}
+	return false
+}
+
+// JWK — публичный ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 (OKP)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSResponse — ответ /.well-known/jwks.json
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
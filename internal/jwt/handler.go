@@ -0,0 +1,50 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+)
+
+// Публичные ключи в формате JWK. Неподдерживаемые ключи пропускаются.
+func (ks *KeySet) JWKS() JWKSResponse {
+	resp := JWKSResponse{Keys: []JWK{}}
+	for _, k := range ks.All() {
+		jwk := JWK{Kid: k.ID, Alg: k.Method.Alg(), Use: "sig"}
+		switch pub := k.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		resp.Keys = append(resp.Keys, jwk)
+	}
+	return resp
+}
+
+// JWKS godoc
+// @Summary Публичные ключи для проверки JWT
+// @Description Набор JWK (RFC 7517) для проверки подписи токенов устройствами и другими сервисами. Ключи ротируются, выбирайте ключ по kid из заголовка токена.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} JWKSResponse "Набор ключей"
+// @Failure 503 {string} string "Ключи не загружены"
+// @Router /.well-known/jwks.json [get]
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if keys == nil {
+		http.Error(w, "Ключи не загружены", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(keys.JWKS())
+}
@@ -1,77 +1,155 @@
 package jwt
 
 import (
-	"os"
+	"errors"
+	"strconv"
 	"time"
+
+	"domofon/internal/config"
+
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
-	"errors"
+)
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
 )
 
 var (
-  jwtToken = []byte(os.Getenv("JWT_TOKEN"))
-	refreshToken = []byte(os.Getenv("REFRESH_JWT_TOKEN"))
-	issuer        = "domofon"
+	keys       *KeySet
+	issuer     = "domofon"
+	audience   = "domofon"
+	accessTTL  = 15 * time.Minute
+	refreshTTL = 7 * 24 * time.Hour
+
+	ErrNotInitialized = errors.New("jwt: ключи подписи не загружены")
+	ErrWrongTokenType = errors.New("wrong token type")
+	ErrInvalidClaims  = errors.New("invalid issuer or audience")
 )
 
-func GenerateAccessToken(userID int64) (string, string, error) {
+// Загружает ключи подписи и настройки токенов. Вызывается один раз при старте
+// сервера; ошибка означает, что сервер запускать нельзя.
+func Init(cfg *config.JWTConfig) (*KeySet, error) {
+	ks, err := LoadKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	keys = ks
+	issuer = cfg.Issuer
+	audience = cfg.Audience
+	accessTTL = cfg.AccessTTL
+	refreshTTL = cfg.RefreshTTL
+	return ks, nil
+}
+
+// Время жизни refresh-токена (для записи в refresh_tokens)
+func RefreshTTL() time.Duration {
+	return refreshTTL
+}
+
+func sign(claims jwt.Claims) (string, error) {
+	if keys == nil {
+		return "", ErrNotInitialized
+	}
+	key := keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Находит публичный ключ по kid из заголовка токена
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	if keys == nil {
+		return nil, ErrNotInitialized
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keys.Lookup(kid)
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Public(), nil
+}
+
+func GenerateAccessToken(id Identity) (string, string, error) {
 	jti := uuid.NewString()
+	now := time.Now()
 	claims := AccessClaims{
-		UserID: userID,
+		Type:         tokenTypeAccess,
+		Role:         id.Role,
+		SessionID:    id.SessionID,
+		ApartmentIDs: id.ApartmentIDs,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    issuer,
-			Subject:   string(rune(userID)),
-			Audience:  []string{"domofon"},
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
+			Subject:   strconv.FormatInt(id.UserID, 10),
+			Audience:  []string{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTTL)),
 			ID:        jti,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(jwtToken)
+	signed, err := sign(claims)
 	return signed, jti, err
 }
 
-func GenerateRefreshToken(userID int64, jti string) (string, error) {
+func GenerateRefreshToken(userID int64, sessionID, jti string) (string, error) {
+	now := time.Now()
 	claims := RefreshClaims{
-		UserID: userID,
+		Type:      tokenTypeRefresh,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    issuer,
-			Subject:   string(rune(userID)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			Subject:   strconv.FormatInt(userID, 10),
+			Audience:  []string{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(refreshTTL)),
 			ID:        jti,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(refreshToken)
+	return sign(claims)
+}
+
+// Проверка iss/aud, общая для обоих типов токенов
+func verifyRegistered(c *jwt.RegisteredClaims) error {
+	if !c.VerifyIssuer(issuer, true) || !c.VerifyAudience(audience, true) {
+		return ErrInvalidClaims
+	}
+	if _, err := strconv.ParseInt(c.Subject, 10, 64); err != nil {
+		return ErrInvalidClaims
+	}
+	return nil
 }
 
 func ParseAccessToken(tokenStr string) (*AccessClaims, error) {
 	claims := &AccessClaims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtToken, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenStr, claims, keyFunc)
 	if err != nil || !token.Valid {
 		return nil, err
 	}
+	// Refresh-токен подписан тем же ключом — не даём использовать его как access
+	if claims.Type != tokenTypeAccess {
+		return nil, ErrWrongTokenType
+	}
+	if err := verifyRegistered(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
 	return claims, nil
 }
 
 func ParseRefreshToken(tokenStr string) (*RefreshClaims, error) {
 	claims := &RefreshClaims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return refreshToken, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenStr, claims, keyFunc)
 	if err != nil || !token.Valid {
 		return nil, err
 	}
+	if claims.Type != tokenTypeRefresh {
+		return nil, ErrWrongTokenType
+	}
+	if err := verifyRegistered(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
 	return claims, nil
 }
-
@@ -0,0 +1,43 @@
+package jwt
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const tokenTypeKeyList = "key_list"
+
+// Claims подписанного списка ключей панели. Срока действия нет: панель
+// пользуется последним полученным списком, пока нет сети.
+type KeyListClaims struct {
+	Type    string `json:"typ"`
+	Version int64  `json:"ver"`
+	// Версия, относительно которой посчитана разница; 0 — полный снимок
+	BaseVersion int64           `json:"base,omitempty"`
+	Full        bool            `json:"full"`
+	Data        json.RawMessage `json:"data"`
+	jwt.RegisteredClaims
+}
+
+// Подписывает список ключей устройства; sub — id устройства
+func SignKeyList(deviceID, version, baseVersion int64, full bool, data json.RawMessage) (string, error) {
+	claims := &KeyListClaims{
+		Type:        tokenTypeKeyList,
+		Version:     version,
+		BaseVersion: baseVersion,
+		Full:        full,
+		Data:        data,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  strconv.FormatInt(deviceID, 10),
+			Issuer:   issuer,
+			Audience: []string{audience},
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			ID:       uuid.NewString(),
+		},
+	}
+	return sign(claims)
+}
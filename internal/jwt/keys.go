@@ -0,0 +1,385 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"domofon/internal/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// kid ключей, выпущенных ротацией, — время создания в UTC. По нему, а не по
+// mtime файла, определяется возраст ключа: mtime меняют копирование и бэкапы.
+const kidLayout = "20060102T150405Z"
+
+// Ключ advisory-блокировки Postgres, под которой выполняется ротация
+const RotationLockKey int64 = 0x6a77742d726f74 // "jwt-rot"
+
+// Блокировка на время ротации: новый ключ выпускает только один экземпляр API,
+// остальные подхватывают его из общего каталога при перечитывании
+type RotationLock interface {
+	TryLock(ctx context.Context) (unlock func(), ok bool, err error)
+}
+
+var (
+	ErrNoSigningKeys  = errors.New("не настроено ни одного ключа подписи JWT")
+	ErrUnknownKeyID   = errors.New("unknown key id")
+	ErrUnsupportedKey = errors.New("unsupported key type")
+	ErrUnsupportedAlg = errors.New("unsupported signing algorithm")
+)
+
+// Ключ подписи, идентифицируется по kid
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// Набор ключей: последний по времени создания — активный (им подписываем),
+// остальные используются только для проверки уже выданных токенов.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys map[string]*SigningKey
+	// kid активного ключа
+	active string
+
+	dir       string
+	alg       string
+	retention time.Duration
+}
+
+// Загружает все ключи из каталога. Без единого ключа сервер стартовать не должен.
+func LoadKeySet(cfg *config.JWTConfig) (*KeySet, error) {
+	if cfg.KeysDir == "" {
+		return nil, fmt.Errorf("%w: переменная JWT_KEYS_DIR не задана", ErrNoSigningKeys)
+	}
+	if _, err := methodForAlg(cfg.Algorithm); err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{
+		keys:      make(map[string]*SigningKey),
+		dir:       cfg.KeysDir,
+		alg:       cfg.Algorithm,
+		retention: cfg.KeyRetention,
+	}
+
+	files, err := filepath.Glob(filepath.Join(cfg.KeysDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		key, err := loadKeyFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %s: %w", f, err)
+		}
+		ks.keys[key.ID] = key
+	}
+	if len(ks.keys) == 0 {
+		return nil, fmt.Errorf("%w: в каталоге %s нет *.pem", ErrNoSigningKeys, cfg.KeysDir)
+	}
+	ks.pickActive()
+	return ks, nil
+}
+
+func loadKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("не удалось разобрать PEM")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kid := kidFromPath(path)
+	createdAt, err := time.Parse(kidLayout, kid)
+	if err != nil {
+		// Ключ положен вручную под другим именем: возраст неизвестен, считаем его
+		// самым старым — при включённой ротации он будет заменён первым же выпуском
+		log.Warn().Str("kid", kid).Msg("[jwt] Имя ключа не в формате " + kidLayout + ", время создания неизвестно")
+		createdAt = time.Time{}
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, Private: k, CreatedAt: createdAt}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: k, CreatedAt: createdAt}, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+func kidFromPath(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+func methodForAlg(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case "RS256":
+		return jwt.SigningMethodRS256, nil
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+	}
+}
+
+// Выбирает самый свежий ключ активным; при равном времени — больший kid,
+// чтобы все экземпляры выбрали один и тот же. Вызывается под mu.Lock.
+func (ks *KeySet) pickActive() {
+	var newest *SigningKey
+	for _, k := range ks.keys {
+		if newest == nil || k.CreatedAt.After(newest.CreatedAt) ||
+			(k.CreatedAt.Equal(newest.CreatedAt) && k.ID > newest.ID) {
+			newest = k
+		}
+	}
+	if newest != nil {
+		ks.active = newest.ID
+	}
+}
+
+// Активный ключ для подписи
+func (ks *KeySet) Active() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[ks.active]
+}
+
+// Ключ по kid для проверки подписи
+func (ks *KeySet) Lookup(kid string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// Все ключи, отсортированные от нового к старому (для JWKS)
+func (ks *KeySet) All() []*SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	out := make([]*SigningKey, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// Выпускает новый ключ, сохраняет его в каталог и делает активным.
+// Ключи, вышедшие из ротации раньше чем retention назад, удаляются.
+func (ks *KeySet) Rotate() (*SigningKey, error) {
+	method, err := methodForAlg(ks.alg)
+	if err != nil {
+		return nil, err
+	}
+
+	var priv crypto.Signer
+	switch method {
+	case jwt.SigningMethodRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	// Время создания с точностью kid — так его увидят и другие экземпляры
+	createdAt := now.UTC().Truncate(time.Second)
+	key := &SigningKey{
+		ID:        createdAt.Format(kidLayout),
+		Method:    method,
+		Private:   priv,
+		CreatedAt: createdAt,
+	}
+	// Через временный файл: другие экземпляры не должны прочитать ключ наполовину
+	path := filepath.Join(ks.dir, key.ID+".pem")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	// Предыдущие ключи остаются в наборе для проверки токенов, выданных до ротации
+	ks.keys[key.ID] = key
+	ks.active = key.ID
+	ks.pruneLocked(now)
+	return key, nil
+}
+
+// Удаляет неактивные ключи старше retention. Вызывается под mu.Lock.
+func (ks *KeySet) pruneLocked(now time.Time) {
+	if ks.retention <= 0 {
+		return
+	}
+	// Ключ перестаёт подписывать в момент создания следующего за ним,
+	// поэтому его возраст считаем от создания более нового ключа.
+	all := make([]*SigningKey, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		all = append(all, k)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	for i := 1; i < len(all); i++ {
+		retiredAt := all[i-1].CreatedAt
+		if now.Sub(retiredAt) > ks.retention {
+			delete(ks.keys, all[i].ID)
+			_ = os.Remove(filepath.Join(ks.dir, all[i].ID+".pem"))
+			log.Info().Str("kid", all[i].ID).Msg("[jwt] Старый ключ подписи удалён")
+		}
+	}
+}
+
+// Перечитывает каталог: подхватывает ключи, выпущенные другими экземплярами,
+// и забывает удалённые. Файлы, которые не удалось прочитать, пропускаются.
+func (ks *KeySet) Reload() error {
+	files, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	ks.mu.RLock()
+	known := make(map[string]*SigningKey, len(ks.keys))
+	for id, k := range ks.keys {
+		known[id] = k
+	}
+	ks.mu.RUnlock()
+
+	found := make(map[string]*SigningKey, len(files))
+	for _, f := range files {
+		kid := kidFromPath(f)
+		if k, ok := known[kid]; ok {
+			found[kid] = k
+			continue
+		}
+		key, err := loadKeyFile(f)
+		if err != nil {
+			log.Warn().Err(err).Str("file", f).Msg("[jwt] Не удалось прочитать ключ подписи")
+			continue
+		}
+		found[kid] = key
+		log.Info().Str("kid", kid).Msg("[jwt] Подхвачен новый ключ подписи")
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("%w: в каталоге %s нет *.pem", ErrNoSigningKeys, ks.dir)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = found
+	ks.pickActive()
+	return nil
+}
+
+// Плановая ротация ключей. Блокирует до отмены ctx, запускать в горутине.
+// Каждые reload каталог перечитывается; ключ выпускает только экземпляр,
+// взявший lock, и только если активный ключ старше interval. Каталог
+// JWT_KEYS_DIR должен быть общим для всех экземпляров.
+func (ks *KeySet) RunRotation(ctx context.Context, interval, reload time.Duration, lock RotationLock) {
+	if interval <= 0 {
+		return
+	}
+	if reload <= 0 || reload > interval {
+		reload = interval
+	}
+	ks.rotateIfDue(ctx, interval, lock)
+
+	ticker := time.NewTicker(reload)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ks.rotateIfDue(ctx, interval, lock)
+		}
+	}
+}
+
+func (ks *KeySet) rotateIfDue(ctx context.Context, interval time.Duration, lock RotationLock) {
+	if err := ks.Reload(); err != nil {
+		log.Error().Err(err).Msg("[jwt] Не удалось перечитать ключи подписи")
+	}
+	if !ks.due(interval) {
+		return
+	}
+
+	unlock, ok, err := lock.TryLock(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("[jwt] Не удалось взять блокировку ротации")
+		return
+	}
+	if !ok {
+		// Ротирует другой экземпляр, ключ подхватим при следующем перечитывании
+		return
+	}
+	defer unlock()
+
+	// Пока брали блокировку, ключ мог выпустить другой экземпляр
+	if err := ks.Reload(); err != nil {
+		log.Error().Err(err).Msg("[jwt] Не удалось перечитать ключи подписи")
+		return
+	}
+	if ks.due(interval) {
+		ks.rotateAndLog()
+	}
+}
+
+// Пора ли выпускать новый ключ: активный старше interval
+func (ks *KeySet) due(interval time.Duration) bool {
+	active := ks.Active()
+	return active == nil || time.Since(active.CreatedAt) >= interval
+}
+
+func (ks *KeySet) rotateAndLog() {
+	key, err := ks.Rotate()
+	if err != nil {
+		log.Error().Err(err).Msg("[jwt] Не удалось выполнить ротацию ключа подписи")
+		return
+	}
+	log.Info().Str("kid", key.ID).Str("alg", key.Method.Alg()).Msg("[jwt] Выпущен новый ключ подписи")
+}
@@ -0,0 +1,66 @@
+package jwt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"domofon/internal/config"
+)
+
+func TestKeyAgeFromKid(t *testing.T) {
+	dir := t.TempDir()
+	ks := &KeySet{keys: map[string]*SigningKey{}, dir: dir, alg: "EdDSA"}
+	key, err := ks.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mtime не должен влиять на возраст ключа
+	path := filepath.Join(dir, key.ID+".pem")
+	old := time.Now().Add(-365 * 24 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.CreatedAt.Equal(key.CreatedAt) {
+		t.Errorf("CreatedAt = %s, want %s from kid", loaded.CreatedAt, key.CreatedAt)
+	}
+}
+
+func TestReloadPicksUpNewKeys(t *testing.T) {
+	dir := t.TempDir()
+	leader := &KeySet{keys: map[string]*SigningKey{}, dir: dir, alg: "EdDSA"}
+	if _, err := leader.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	follower, err := LoadKeySet(&config.JWTConfig{KeysDir: dir, Algorithm: "EdDSA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ключ другого экземпляра, выпущенный позже
+	if err := os.Rename(filepath.Join(dir, leader.Active().ID+".pem"), filepath.Join(dir, "20200101T000000Z.pem")); err != nil {
+		t.Fatal(err)
+	}
+	newer, err := leader.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := follower.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := follower.Active().ID; got != newer.ID {
+		t.Errorf("active kid = %s, want %s", got, newer.ID)
+	}
+	if _, ok := follower.Lookup("20200101T000000Z"); !ok {
+		t.Error("older key not loaded")
+	}
+	if len(follower.All()) != 2 {
+		t.Errorf("keys = %d, want 2: removed file must be forgotten", len(follower.All()))
+	}
+}
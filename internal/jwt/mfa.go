@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const tokenTypeMFA = "mfa"
+
+// Этапы входа со вторым фактором
+const (
+	MFAStageVerify = "verify" // нужно ввести код TOTP или код восстановления
+	MFAStageEnroll = "enroll" // роль требует второй фактор, а он ещё не подключён
+)
+
+// Claims токена второго шага входа: выдаётся после верного пароля
+// вместо access/refresh, если нужен второй фактор
+type MFAClaims struct {
+	Type  string `json:"typ"`
+	Stage string `json:"stage"`
+	jwt.RegisteredClaims
+}
+
+func (c *MFAClaims) UserID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+func GenerateMFAToken(userID int64, stage string, ttl time.Duration) (string, *MFAClaims, error) {
+	now := time.Now()
+	claims := &MFAClaims{
+		Type:  tokenTypeMFA,
+		Stage: stage,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(userID, 10),
+			Issuer:    issuer,
+			Audience:  []string{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			ID:        uuid.NewString(),
+		},
+	}
+	signed, err := sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+func ParseMFAToken(tokenStr string) (*MFAClaims, error) {
+	claims := &MFAClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, ErrInvalidClaims
+	}
+	if claims.Type != tokenTypeMFA {
+		return nil, ErrWrongTokenType
+	}
+	if !claims.VerifyIssuer(issuer, true) || !claims.VerifyAudience(audience, true) {
+		return nil, ErrInvalidClaims
+	}
+	if _, err := claims.UserID(); err != nil {
+		return nil, ErrInvalidClaims
+	}
+	return claims, nil
+}
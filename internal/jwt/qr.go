@@ -0,0 +1,74 @@
+package jwt
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const tokenTypeDoorQR = "door_qr"
+
+var ErrTokenExpired = errors.New("token expired")
+
+// Claims QR-кода для входа в подъезд. Подписаны тем же ключом, что и access-токены,
+// поэтому панель может проверить код без сети по /.well-known/jwks.json.
+type DoorQRClaims struct {
+	Type string `json:"typ"`
+	// ID ключа в keys (key_type = qr); по нему панель проверяет список отозванных
+	KeyID int64 `json:"key"`
+	// Квартиры жителя на момент выпуска
+	ApartmentIDs []int64 `json:"apartments"`
+	jwt.RegisteredClaims
+}
+
+func (c *DoorQRClaims) UserID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+func GenerateDoorQRToken(userID, keyID int64, apartmentIDs []int64, ttl time.Duration) (string, *DoorQRClaims, error) {
+	now := time.Now()
+	claims := &DoorQRClaims{
+		Type:         tokenTypeDoorQR,
+		KeyID:        keyID,
+		ApartmentIDs: apartmentIDs,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(userID, 10),
+			Issuer:    issuer,
+			Audience:  []string{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			ID:        uuid.NewString(),
+		},
+	}
+	signed, err := sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// Проверяет подпись и срок QR-кода. leeway — допустимое расхождение часов панели и сервера.
+func ParseDoorQRToken(tokenStr string, leeway time.Duration) (*DoorQRClaims, error) {
+	claims := &DoorQRClaims{}
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(tokenStr, claims, keyFunc)
+	if err != nil || !token.Valid {
+		return nil, err
+	}
+	if claims.Type != tokenTypeDoorQR {
+		return nil, ErrWrongTokenType
+	}
+	if err := verifyRegistered(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt == nil || time.Now().After(claims.ExpiresAt.Add(leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if claims.KeyID <= 0 {
+		return nil, ErrInvalidClaims
+	}
+	return claims, nil
+}
@@ -0,0 +1,55 @@
+package jwt
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const tokenTypeTicket = "phone_ticket"
+
+// Claims билета подтверждения номера: выдаётся после ввода верного SMS-кода
+// и предъявляется в следующем шаге сценария (регистрация, сброс пароля).
+type TicketClaims struct {
+	Type    string `json:"typ"`
+	Phone   string `json:"phone"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+func GenerateVerificationTicket(phone, purpose string, ttl time.Duration) (string, *TicketClaims, error) {
+	now := time.Now()
+	claims := &TicketClaims{
+		Type:    tokenTypeTicket,
+		Phone:   phone,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Audience:  []string{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			ID:        uuid.NewString(),
+		},
+	}
+	signed, err := sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+func ParseVerificationTicket(tokenStr string) (*TicketClaims, error) {
+	claims := &TicketClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, keyFunc)
+	if err != nil || !token.Valid {
+		return nil, err
+	}
+	if claims.Type != tokenTypeTicket {
+		return nil, ErrWrongTokenType
+	}
+	if !claims.VerifyIssuer(issuer, true) || !claims.VerifyAudience(audience, true) || claims.ID == "" {
+		return nil, ErrInvalidClaims
+	}
+	return claims, nil
+}
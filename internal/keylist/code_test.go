@@ -0,0 +1,44 @@
+package keylist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// Панель получает ключ сама: sha256 от своего ключа устройства
+func panelCodeKey(t *testing.T, deviceKey string) []byte {
+	t.Helper()
+	key, err := hex.DecodeString(sha256Hex(deviceKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestGuestCodeMAC(t *testing.T) {
+	key := panelCodeKey(t, "device-a")
+	code := sha256Hex("4821")
+
+	got := guestCodeMAC(key, 7, code)
+	if got == code {
+		t.Fatal("code hash must not be shipped as plain sha256")
+	}
+	if again := guestCodeMAC(key, 7, code); again != got {
+		t.Errorf("not deterministic: %s != %s", again, got)
+	}
+	if other := guestCodeMAC(panelCodeKey(t, "device-b"), 7, code); other == got {
+		t.Error("different devices must get different hashes for the same pass")
+	}
+	if other := guestCodeMAC(key, 8, code); other == got {
+		t.Error("same code on different passes must not match")
+	}
+	if other := guestCodeMAC(key, 7, sha256Hex("4822")); other == got {
+		t.Error("different codes must not match")
+	}
+}
@@ -0,0 +1,102 @@
+package keylist
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"domofon/internal/schedule"
+)
+
+func snapshotJSON(t *testing.T, entries []Entry, schedules []schedule.ScheduleResponse) []byte {
+	t.Helper()
+	if schedules == nil {
+		schedules = []schedule.ScheduleResponse{}
+	}
+	b, err := json.Marshal(Snapshot{Entries: entries, Schedules: schedules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func upsertIDs(d *Delta) []int64 {
+	ids := make([]int64, 0, len(d.Upserts))
+	for _, e := range d.Upserts {
+		ids = append(ids, e.KeyID)
+	}
+	return ids
+}
+
+func TestDiff(t *testing.T) {
+	validTo := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	later := validTo.Add(24 * time.Hour)
+	card := Entry{KeyID: 1, Type: "rfid", Code: "A1", UserID: 10, ApartmentIDs: []int64{5}}
+	qr := Entry{KeyID: 2, Type: "qr", UserID: 11, ApartmentIDs: []int64{6}}
+	guest := Entry{KeyID: 3, Type: "guest", CodeHash: "h", ApartmentIDs: []int64{5}, ValidTo: &validTo, RemainingUses: 2}
+
+	changed := func(e Entry, f func(*Entry)) Entry {
+		e.ApartmentIDs = slices.Clone(e.ApartmentIDs)
+		f(&e)
+		return e
+	}
+
+	tests := []struct {
+		name        string
+		old, cur    []Entry
+		wantUpserts []int64
+		wantRemoved []int64
+	}{
+		{"no changes", []Entry{card, qr, guest}, []Entry{card, qr, guest}, []int64{}, []int64{}},
+		{"from empty", nil, []Entry{card, qr}, []int64{1, 2}, []int64{}},
+		{"to empty", []Entry{card, qr, guest}, nil, []int64{}, []int64{1, 2, 3}},
+		{"added", []Entry{card}, []Entry{card, guest}, []int64{3}, []int64{}},
+		{"removed are sorted", []Entry{guest, qr, card}, []Entry{qr}, []int64{}, []int64{1, 3}},
+		{"code changed", []Entry{card}, []Entry{changed(card, func(e *Entry) { e.Code = "B2" })}, []int64{1}, []int64{}},
+		{"apartment added", []Entry{card}, []Entry{changed(card, func(e *Entry) { e.ApartmentIDs = append(e.ApartmentIDs, 7) })}, []int64{1}, []int64{}},
+		{"guest use counted", []Entry{guest}, []Entry{changed(guest, func(e *Entry) { e.RemainingUses = 1 })}, []int64{3}, []int64{}},
+		{"validity extended", []Entry{guest}, []Entry{changed(guest, func(e *Entry) { e.ValidTo = &later })}, []int64{3}, []int64{}},
+		{"schedule attached", []Entry{card}, []Entry{changed(card, func(e *Entry) { e.ScheduleIDs = []int64{4} })}, []int64{1}, []int64{}},
+		{"replaced and changed", []Entry{card, qr}, []Entry{changed(qr, func(e *Entry) { e.UserID = 12 }), guest}, []int64{2, 3}, []int64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := diff(snapshotJSON(t, tt.old, nil), snapshotJSON(t, tt.cur, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := upsertIDs(d); !slices.Equal(got, tt.wantUpserts) {
+				t.Errorf("upserts = %v, want %v", got, tt.wantUpserts)
+			}
+			if !slices.Equal(d.Removed, tt.wantRemoved) {
+				t.Errorf("removed = %v, want %v", d.Removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+// Расписания в дельте передаются целиком из новой версии
+func TestDiffSchedules(t *testing.T) {
+	cur := []schedule.ScheduleResponse{{ID: 4, Name: "Дневной"}}
+	d, err := diff(snapshotJSON(t, nil, []schedule.ScheduleResponse{{ID: 3, Name: "Старый"}}), snapshotJSON(t, nil, cur))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Schedules) != 1 || d.Schedules[0].ID != 4 {
+		t.Errorf("schedules = %+v, want %+v", d.Schedules, cur)
+	}
+	if d.Upserts == nil || d.Removed == nil {
+		t.Error("empty upserts and removed must encode as [] for panels")
+	}
+}
+
+func TestDiffInvalidJSON(t *testing.T) {
+	valid := snapshotJSON(t, nil, nil)
+	if _, err := diff([]byte("{"), valid); err == nil {
+		t.Error("diff with broken old version: want error")
+	}
+	if _, err := diff(valid, []byte("{")); err == nil {
+		t.Error("diff with broken new version: want error")
+	}
+}
@@ -0,0 +1,66 @@
+package keylist
+
+import (
+	"time"
+
+	"domofon/internal/schedule"
+)
+
+// Ключ в списке панели
+type Entry struct {
+	KeyID int64 `json:"key_id"`
+	// rfid, qr, guest и т.п.
+	Type string `json:"type"`
+	// Код карты или брелока. Для qr пусто: QR-код проверяется по подписи и key_id.
+	Code string `json:"code,omitempty"`
+	// Гостевой код: HMAC-SHA256 (hex) с ключом sha256(ключ устройства) от
+	// "<key_id>:<sha256 кода в hex>"
+	CodeHash      string     `json:"code_hash,omitempty"`
+	UserID        int64      `json:"user_id,omitempty"`
+	ApartmentIDs  []int64    `json:"apartment_ids"`
+	ValidFrom     *time.Time `json:"valid_from,omitempty"`
+	ValidTo       *time.Time `json:"valid_to,omitempty"`
+	RemainingUses int        `json:"remaining_uses,omitempty"`
+	// Пускать, если разрешает хотя бы одно из расписаний; пусто — без ограничений
+	ScheduleIDs []int64 `json:"schedule_ids,omitempty"`
+}
+
+// Полный список ключей устройства (поле data подписанного списка при full = true)
+type Snapshot struct {
+	Entries   []Entry                     `json:"entries"`
+	Schedules []schedule.ScheduleResponse `json:"schedules"`
+}
+
+// Разница со старой версией (data при full = false). Расписания передаются целиком.
+type Delta struct {
+	Upserts   []Entry                     `json:"upserts"`
+	Removed   []int64                     `json:"removed"`
+	Schedules []schedule.ScheduleResponse `json:"schedules"`
+}
+
+type SyncResponse struct {
+	Version     int64 `json:"version"`
+	BaseVersion int64 `json:"base_version,omitempty"`
+	Full        bool  `json:"full"`
+	// Панель уже на последней версии, payload не передаётся
+	UpToDate bool `json:"up_to_date"`
+	// JWT (key_list), подписанный ключом из /.well-known/jwks.json; в data — Snapshot или Delta
+	Payload string `json:"payload,omitempty"`
+}
+
+type AckRequest struct {
+	Version int64 `json:"version"`
+}
+
+type DeviceStatusResponse struct {
+	DeviceID        int64      `json:"device_id"`
+	SerialNumber    string     `json:"serial_number"`
+	Status          string     `json:"status,omitempty"`
+	LastSeenAt      *time.Time `json:"last_seen_at,omitempty"`
+	LatestVersion   int64      `json:"latest_version"`
+	LatestCreatedAt *time.Time `json:"latest_created_at,omitempty"`
+	AckedVersion    int64      `json:"acked_version"`
+	AckedAt         *time.Time `json:"acked_at,omitempty"`
+	// Новая версия вышла больше KEYLIST_STALE_AFTER назад, а панель её не подтвердила
+	Stale bool `json:"stale"`
+}
@@ -0,0 +1,121 @@
+package keylist
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"domofon/internal/middleware"
+)
+
+type KeyListHandler struct {
+	keyLists *KeyListService
+}
+
+func NewKeyListHandler(s *KeyListService) *KeyListHandler {
+	return &KeyListHandler{keyLists: s}
+}
+
+// Sync godoc
+// @Summary Список ключей для офлайн-работы панели
+// @Description Для устройств (Authorization: Device <key>). payload — JWT, подписанный ключом сервера (проверяется по /.well-known/jwks.json). Если версия since ещё хранится, в data приходит разница (upserts/removed), иначе полный список. code_hash гостевых пропусков — HMAC с ключом sha256(ключ устройства), см. Entry.
+// @Tags devices
+// @Produce json
+// @Param since query int false "Последняя применённая версия"
+// @Success 200 {object} SyncResponse
+// @Failure 400 {string} string "Некорректный since"
+// @Failure 401 {string} string "Неверный ключ устройства"
+// @Router /device/key-list [get]
+func (h *KeyListHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	deviceID, ok := middleware.DeviceIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		since, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || since < 0 {
+			http.Error(w, "Некорректный since", http.StatusBadRequest)
+			return
+		}
+	}
+	resp, err := h.keyLists.Sync(r.Context(), deviceID, since)
+	if err != nil {
+		writeKeyListError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Ack godoc
+// @Summary Подтвердить применение списка ключей
+// @Description Для устройств (Authorization: Device <key>).
+// @Tags devices
+// @Accept json
+// @Param input body AckRequest true "Применённая версия"
+// @Success 204
+// @Failure 400 {string} string "Неизвестная версия"
+// @Failure 401 {string} string "Неверный ключ устройства"
+// @Router /device/key-list/ack [post]
+func (h *KeyListHandler) Ack(w http.ResponseWriter, r *http.Request) {
+	deviceID, ok := middleware.DeviceIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req AckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.keyLists.Ack(r.Context(), deviceID, req.Version); err != nil {
+		writeKeyListError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Status godoc
+// @Summary Состояние списков ключей на устройствах
+// @Description Для ролей из DEVICE_ADMIN_ROLES. stale — устройство не подтвердило последнюю версию дольше KEYLIST_STALE_AFTER.
+// @Tags devices
+// @Produce json
+// @Param stale query bool false "Только отставшие устройства"
+// @Success 200 {array} DeviceStatusResponse
+// @Failure 403 {string} string "Недостаточно прав"
+// @Security BearerAuth
+// @Router /devices/key-lists [get]
+func (h *KeyListHandler) Status(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	staleOnly, _ := strconv.ParseBool(r.URL.Query().Get("stale"))
+	result, err := h.keyLists.Status(r.Context(), claims.Role, staleOnly)
+	if err != nil {
+		writeKeyListError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeKeyListError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrUnknownVersion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,141 @@
+package keylist
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Repository interface {
+	DeviceIDs(ctx context.Context) ([]int64, error)
+	UserDeviceIDs(ctx context.Context, userID int64) ([]int64, error)
+	DeviceAddress(ctx context.Context, deviceID int64) (string, error)
+	DeviceKeyHash(ctx context.Context, deviceID int64) (string, error)
+	ResidentKeys(ctx context.Context, address string, now time.Time) ([]db.ListAddressResidentKeysRow, error)
+	GuestPasses(ctx context.Context, deviceID int64, address string, now time.Time) ([]db.ListDeviceGuestPassesRow, error)
+
+	Latest(ctx context.Context, deviceID int64) (*db.DeviceKeyList, error)
+	Get(ctx context.Context, deviceID, version int64) (*db.DeviceKeyList, error)
+	Create(ctx context.Context, params db.CreateDeviceKeyListParams) (bool, error)
+	DeleteBefore(ctx context.Context, deviceID, version int64) error
+	Ack(ctx context.Context, deviceID, version int64, now time.Time) error
+	Status(ctx context.Context) ([]db.ListDeviceKeyListStatusRow, error)
+}
+
+type KeyListRepository struct {
+	queries *db.Queries
+}
+
+func NewKeyListRepository(queries *db.Queries) *KeyListRepository {
+	return &KeyListRepository{queries: queries}
+}
+
+// Устройства, которым нужен список: активные и с выпущенным ключом API
+func (r *KeyListRepository) DeviceIDs(ctx context.Context) ([]int64, error) {
+	ids, err := r.queries.ListKeyListDeviceIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int64, len(ids))
+	for i, id := range ids {
+		result[i] = int64(id)
+	}
+	return result, nil
+}
+
+// Устройства в домах, где у пользователя есть квартира
+func (r *KeyListRepository) UserDeviceIDs(ctx context.Context, userID int64) ([]int64, error) {
+	ids, err := r.queries.ListUserKeyListDeviceIDs(ctx, pgtype.Int4{Int32: int32(userID), Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int64, len(ids))
+	for i, id := range ids {
+		result[i] = int64(id)
+	}
+	return result, nil
+}
+
+// Пустая строка — устройство не привязано к дому
+func (r *KeyListRepository) DeviceAddress(ctx context.Context, deviceID int64) (string, error) {
+	addr, err := r.queries.GetDeviceAddress(ctx, int32(deviceID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return addr, err
+}
+
+// Пустая строка — устройству не выпущен ключ
+func (r *KeyListRepository) DeviceKeyHash(ctx context.Context, deviceID int64) (string, error) {
+	hash, err := r.queries.GetDeviceKeyHash(ctx, int32(deviceID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return hash.String, err
+}
+
+func (r *KeyListRepository) ResidentKeys(ctx context.Context, address string, now time.Time) ([]db.ListAddressResidentKeysRow, error) {
+	return r.queries.ListAddressResidentKeys(ctx, db.ListAddressResidentKeysParams{
+		Address: address,
+		Now:     pgtype.Timestamp{Time: now, Valid: true},
+	})
+}
+
+func (r *KeyListRepository) GuestPasses(ctx context.Context, deviceID int64, address string, now time.Time) ([]db.ListDeviceGuestPassesRow, error) {
+	return r.queries.ListDeviceGuestPasses(ctx, db.ListDeviceGuestPassesParams{
+		Now:      pgtype.Timestamp{Time: now, Valid: true},
+		DeviceID: pgtype.Int4{Int32: int32(deviceID), Valid: true},
+		Address:  address,
+	})
+}
+
+// nil, nil — списков для устройства ещё не было
+func (r *KeyListRepository) Latest(ctx context.Context, deviceID int64) (*db.DeviceKeyList, error) {
+	l, err := r.queries.GetLatestDeviceKeyList(ctx, int32(deviceID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// nil, nil — такой версии нет (или она уже удалена)
+func (r *KeyListRepository) Get(ctx context.Context, deviceID, version int64) (*db.DeviceKeyList, error) {
+	l, err := r.queries.GetDeviceKeyList(ctx, db.GetDeviceKeyListParams{DeviceID: int32(deviceID), Version: version})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// false — эту версию уже записал параллельный запрос
+func (r *KeyListRepository) Create(ctx context.Context, params db.CreateDeviceKeyListParams) (bool, error) {
+	n, err := r.queries.CreateDeviceKeyList(ctx, params)
+	return n > 0, err
+}
+
+func (r *KeyListRepository) DeleteBefore(ctx context.Context, deviceID, version int64) error {
+	return r.queries.DeleteDeviceKeyListsBefore(ctx, db.DeleteDeviceKeyListsBeforeParams{DeviceID: int32(deviceID), Version: version})
+}
+
+func (r *KeyListRepository) Ack(ctx context.Context, deviceID, version int64, now time.Time) error {
+	return r.queries.UpsertDeviceKeyListAck(ctx, db.UpsertDeviceKeyListAckParams{
+		DeviceID: int32(deviceID),
+		Version:  version,
+		AckedAt:  pgtype.Timestamp{Time: now, Valid: true},
+	})
+}
+
+func (r *KeyListRepository) Status(ctx context.Context) ([]db.ListDeviceKeyListStatusRow, error) {
+	return r.queries.ListDeviceKeyListStatus(ctx)
+}
@@ -0,0 +1,400 @@
+package keylist
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"domofon/internal/config"
+	"domofon/internal/db"
+	"domofon/internal/jwt"
+	"domofon/internal/schedule"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const keyTypeGuest = "guest"
+
+var (
+	ErrForbidden      = errors.New("недостаточно прав")
+	ErrUnknownVersion = errors.New("неизвестная версия списка ключей")
+	ErrNoDeviceKey    = errors.New("устройству не выпущен ключ")
+)
+
+// Источник расписаний (реализует schedule.ScheduleService)
+type ScheduleSource interface {
+	ResidentScheduleIDs(ctx context.Context, userID int64) ([]int64, error)
+	ByIDs(ctx context.Context, ids []int64) ([]schedule.ScheduleResponse, error)
+}
+
+// Версионированные списки ключей для офлайн-работы панелей. Новая версия
+// появляется, только когда меняется содержимое списка.
+type KeyListService struct {
+	repo       Repository
+	schedules  ScheduleSource
+	cfg        *config.KeyListConfig
+	adminRoles []string
+}
+
+func NewKeyListService(repo Repository, schedules ScheduleSource, cfg *config.KeyListConfig, deviceCfg *config.DeviceConfig) *KeyListService {
+	return &KeyListService{repo: repo, schedules: schedules, cfg: cfg, adminRoles: deviceCfg.AdminRoles}
+}
+
+// Sync отдаёт панели подписанный список: разницу с версией since, если она
+// ещё хранится, иначе полный снимок.
+func (s *KeyListService) Sync(ctx context.Context, deviceID, since int64) (*SyncResponse, error) {
+	cur, err := s.Current(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &SyncResponse{Version: cur.Version, Full: true}
+	if since == cur.Version {
+		resp.Full = false
+		resp.UpToDate = true
+		return resp, nil
+	}
+
+	data := json.RawMessage(cur.Content)
+	if since > 0 && since < cur.Version {
+		old, err := s.repo.Get(ctx, deviceID, since)
+		if err != nil {
+			return nil, err
+		}
+		if old != nil {
+			delta, err := diff(old.Content, cur.Content)
+			if err != nil {
+				return nil, err
+			}
+			if data, err = json.Marshal(delta); err != nil {
+				return nil, err
+			}
+			resp.Full = false
+			resp.BaseVersion = since
+		}
+	}
+
+	resp.Payload, err = jwt.SignKeyList(deviceID, resp.Version, resp.BaseVersion, resp.Full, data)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Панель сообщает, какую версию применила
+func (s *KeyListService) Ack(ctx context.Context, deviceID, version int64) error {
+	latest, err := s.repo.Latest(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if latest == nil || version < 1 || version > latest.Version {
+		return ErrUnknownVersion
+	}
+	return s.repo.Ack(ctx, deviceID, version, time.Now())
+}
+
+// Состояние списков по устройствам; staleOnly — только отставшие
+func (s *KeyListService) Status(ctx context.Context, role string, staleOnly bool) ([]DeviceStatusResponse, error) {
+	if !slices.Contains(s.adminRoles, role) {
+		return nil, ErrForbidden
+	}
+	rows, err := s.repo.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make([]DeviceStatusResponse, 0, len(rows))
+	for _, row := range rows {
+		st := DeviceStatusResponse{
+			DeviceID:        int64(row.ID),
+			SerialNumber:    row.SerialNumber,
+			Status:          row.Status.String,
+			LastSeenAt:      db.TimePtr(row.LastSeenAt),
+			LatestVersion:   row.LatestVersion.Int64,
+			LatestCreatedAt: db.TimePtr(row.LatestCreatedAt),
+			AckedVersion:    row.AckedVersion.Int64,
+			AckedAt:         db.TimePtr(row.AckedAt),
+		}
+		st.Stale = row.LatestVersion.Valid && st.AckedVersion < st.LatestVersion &&
+			now.Sub(row.LatestCreatedAt.Time) > s.cfg.StaleAfter
+		if staleOnly && !st.Stale {
+			continue
+		}
+		result = append(result, st)
+	}
+	return result, nil
+}
+
+// Current собирает список устройства и, если он изменился, записывает новую версию
+func (s *KeyListService) Current(ctx context.Context, deviceID int64) (*db.DeviceKeyList, error) {
+	now := time.Now()
+	snap, err := s.build(ctx, deviceID, now)
+	if err != nil {
+		return nil, err
+	}
+	content, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	latest, err := s.repo.Latest(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.ContentHash == hash {
+		return latest, nil
+	}
+
+	list := db.CreateDeviceKeyListParams{
+		DeviceID:    int32(deviceID),
+		Version:     1,
+		ContentHash: hash,
+		Content:     content,
+		CreatedAt:   pgtype.Timestamp{Time: now, Valid: true},
+	}
+	if latest != nil {
+		list.Version = latest.Version + 1
+	}
+	created, err := s.repo.Create(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// Версию только что записал параллельный запрос
+		return s.repo.Latest(ctx, deviceID)
+	}
+	if keep := list.Version - int64(s.cfg.RetainVersions) + 1; keep > 1 {
+		if err := s.repo.DeleteBefore(ctx, deviceID, keep); err != nil {
+			log.Error().Err(err).Int64("device_id", deviceID).Msg("[keylist] Не удалось удалить старые версии")
+		}
+	}
+	log.Info().Int64("device_id", deviceID).Int64("version", list.Version).Int("entries", len(snap.Entries)).
+		Msg("[keylist] Новая версия списка ключей")
+	return &db.DeviceKeyList{
+		DeviceID:    list.DeviceID,
+		Version:     list.Version,
+		ContentHash: list.ContentHash,
+		Content:     list.Content,
+		CreatedAt:   list.CreatedAt,
+	}, nil
+}
+
+// Плановая пересборка списков всех устройств, чтобы версии появлялись,
+// даже пока панель не на связи. Блокирует до отмены ctx, запускать в горутине.
+func (s *KeyListService) RunRebuild(ctx context.Context) {
+	if s.cfg.RebuildInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.RebuildInterval)
+	defer ticker.Stop()
+	for {
+		s.rebuildAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Немедленная пересборка списков на домофонах домов пользователя, например
+// после блокировки его ключа: панель получит изменения при следующей синхронизации,
+// не дожидаясь плановой пересборки.
+func (s *KeyListService) RebuildForUser(ctx context.Context, userID int64) error {
+	ids, err := s.repo.UserDeviceIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := s.Current(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *KeyListService) rebuildAll(ctx context.Context) {
+	ids, err := s.repo.DeviceIDs(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("[keylist] Не удалось получить список устройств")
+		return
+	}
+	for _, id := range ids {
+		if _, err := s.Current(ctx, id); err != nil {
+			log.Error().Err(err).Int64("device_id", id).Msg("[keylist] Не удалось пересобрать список ключей")
+		}
+	}
+}
+
+func (s *KeyListService) build(ctx context.Context, deviceID int64, now time.Time) (*Snapshot, error) {
+	address, err := s.repo.DeviceAddress(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{Entries: []Entry{}, Schedules: []schedule.ScheduleResponse{}}
+	// Расписания типа жителя по пользователю: и для его ключей, и для выданных им пропусков
+	residentSchedules := make(map[int64][]int64)
+
+	if address != "" {
+		keys, err := s.repo.ResidentKeys(ctx, address, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			e := Entry{
+				KeyID:        int64(k.ID),
+				Type:         k.KeyType.String,
+				UserID:       int64(k.OwnerID.Int32),
+				ApartmentIDs: toInt64(k.ApartmentIds),
+				ValidFrom:    db.TimePtr(k.ValidFrom),
+				ValidTo:      db.TimePtr(k.ValidTo),
+			}
+			if e.Type != "qr" {
+				e.Code = k.KeyCode
+			}
+			if k.ScheduleID.Valid {
+				e.ScheduleIDs = []int64{int64(k.ScheduleID.Int32)}
+			} else if k.OwnerID.Valid {
+				ids, ok := residentSchedules[e.UserID]
+				if !ok {
+					if ids, err = s.schedules.ResidentScheduleIDs(ctx, e.UserID); err != nil {
+						return nil, err
+					}
+					residentSchedules[e.UserID] = ids
+				}
+				e.ScheduleIDs = ids
+			}
+			snap.Entries = append(snap.Entries, e)
+		}
+	}
+
+	passes, err := s.repo.GuestPasses(ctx, deviceID, address, now)
+	if err != nil {
+		return nil, err
+	}
+	var codeKey []byte
+	if len(passes) > 0 {
+		if codeKey, err = s.guestCodeKey(ctx, deviceID); err != nil {
+			return nil, err
+		}
+	}
+	for _, gp := range passes {
+		e := Entry{
+			KeyID:         int64(gp.KeyID),
+			Type:          keyTypeGuest,
+			CodeHash:      guestCodeMAC(codeKey, int64(gp.KeyID), gp.CodeHash),
+			ApartmentIDs:  []int64{int64(gp.ApartmentID)},
+			ValidFrom:     db.TimePtr(gp.ValidFrom),
+			ValidTo:       db.TimePtr(gp.ValidTo),
+			RemainingUses: int(gp.MaxUses - gp.UseCount),
+		}
+		if gp.ScheduleID.Valid {
+			e.ScheduleIDs = []int64{int64(gp.ScheduleID.Int32)}
+		} else if gp.IssuedBy.Valid {
+			// Гость ограничен расписанием того, кто выдал пропуск
+			issuer := int64(gp.IssuedBy.Int32)
+			ids, ok := residentSchedules[issuer]
+			if !ok {
+				if ids, err = s.schedules.ResidentScheduleIDs(ctx, issuer); err != nil {
+					return nil, err
+				}
+				residentSchedules[issuer] = ids
+			}
+			e.ScheduleIDs = ids
+		}
+		snap.Entries = append(snap.Entries, e)
+	}
+	slices.SortFunc(snap.Entries, func(a, b Entry) int { return int(a.KeyID - b.KeyID) })
+
+	var scheduleIDs []int64
+	for _, e := range snap.Entries {
+		for _, id := range e.ScheduleIDs {
+			if !slices.Contains(scheduleIDs, id) {
+				scheduleIDs = append(scheduleIDs, id)
+			}
+		}
+	}
+	if len(scheduleIDs) > 0 {
+		slices.Sort(scheduleIDs)
+		if snap.Schedules, err = s.schedules.ByIDs(ctx, scheduleIDs); err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
+
+// Ключ HMAC гостевых кодов — sha256 ключа устройства. Панель вычисляет его
+// сама, сервер берёт хеш из базы; в список ключей он не попадает, и при
+// перевыпуске ключа устройства меняются все коды в списке.
+func (s *KeyListService) guestCodeKey(ctx context.Context, deviceID int64) ([]byte, error) {
+	hash, err := s.repo.DeviceKeyHash(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		return nil, ErrNoDeviceKey
+	}
+	return hex.DecodeString(hash)
+}
+
+// HMAC-SHA256 (hex) от "<key_id>:<sha256 гостевого кода в hex>". Короткие коды
+// по такому списку не перебрать без ключа устройства, а привязка к key_id не
+// даёт сопоставить одинаковые коды разных пропусков.
+func guestCodeMAC(key []byte, keyID int64, codeHash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strconv.FormatInt(keyID, 10) + ":" + codeHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Разница между двумя снимками: новые и изменённые ключи, удалённые id
+func diff(oldContent, newContent []byte) (*Delta, error) {
+	var prev, cur Snapshot
+	if err := json.Unmarshal(oldContent, &prev); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(newContent, &cur); err != nil {
+		return nil, err
+	}
+
+	old := make(map[int64][]byte, len(prev.Entries))
+	for _, e := range prev.Entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		old[e.KeyID] = b
+	}
+	d := &Delta{Upserts: []Entry{}, Removed: []int64{}, Schedules: cur.Schedules}
+	for _, e := range cur.Entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		if prevEntry, ok := old[e.KeyID]; !ok || !bytes.Equal(prevEntry, b) {
+			d.Upserts = append(d.Upserts, e)
+		}
+		delete(old, e.KeyID)
+	}
+	for id := range old {
+		d.Removed = append(d.Removed, id)
+	}
+	slices.Sort(d.Removed)
+	return d, nil
+}
+
+func toInt64(ids []int32) []int64 {
+	result := make([]int64, len(ids))
+	for i, id := range ids {
+		result[i] = int64(id)
+	}
+	return result
+}
@@ -0,0 +1,31 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"domofon/internal/config"
+)
+
+// Письмо (только текст)
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Отправка почты: SMTP в бою, MockMailer в разработке
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+func NewMailer(cfg *config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg)
+	case "mock":
+		return &MockMailer{}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный MAIL_DRIVER: %s", cfg.Driver)
+	}
+}
@@ -0,0 +1,15 @@
+package mail
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Моковая версия — пишет письмо в лог
+type MockMailer struct{}
+
+func (m *MockMailer) Send(ctx context.Context, msg Message) error {
+	log.Info().Str("to", msg.To).Str("subject", msg.Subject).Str("body", msg.Body).Msg("[MOCK MAIL]")
+	return nil
+}
@@ -0,0 +1,162 @@
+package mail
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Локальная заглушка SMTP-сервера для разработки и ручного тестирования.
+// Принимает любые письма без TLS и проверки логина, полученные письма
+// доступны на GET /messages.
+type StubServer struct {
+	mu       sync.Mutex
+	messages []StubMessage
+}
+
+type StubMessage struct {
+	From    string    `json:"from"`
+	To      []string  `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+func (s *StubServer) Messages() []StubMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StubMessage(nil), s.messages...)
+}
+
+func (s *StubServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/messages", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Messages())
+	})
+	return mux
+}
+
+// Принимает SMTP-соединения, пока listener не закрыт
+func (s *StubServer) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.session(conn)
+	}
+}
+
+func (s *StubServer) session(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var from string
+	var to []string
+	reply("220 domofon-smtp-stub ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-domofon-smtp-stub")
+			reply("250-AUTH PLAIN LOGIN")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "HELO"):
+			reply("250 domofon-smtp-stub")
+		case strings.HasPrefix(cmd, "AUTH"):
+			reply("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			from = strings.Trim(line[len("MAIL FROM:"):], " <>")
+			to = nil
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			to = append(to, strings.Trim(line[len("RCPT TO:"):], " <>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			if from == "" || len(to) == 0 {
+				reply("503 need MAIL and RCPT first")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			s.record(from, to, data)
+			from, to = "", nil
+			reply("250 OK")
+		case cmd == "RSET":
+			from, to = "", nil
+			reply("250 OK")
+		case cmd == "NOOP":
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// Читает тело письма до строки "." и снимает dot-stuffing
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func (s *StubServer) record(from string, to []string, data string) {
+	msg := StubMessage{From: from, To: to, Body: data, SentAt: time.Now()}
+	if parsed, err := mail.ReadMessage(strings.NewReader(data)); err == nil {
+		dec := new(mime.WordDecoder)
+		if subj, err := dec.DecodeHeader(parsed.Header.Get("Subject")); err == nil {
+			msg.Subject = subj
+		}
+		var body io.Reader = parsed.Body
+		if strings.EqualFold(parsed.Header.Get("Content-Transfer-Encoding"), "base64") {
+			body = base64.NewDecoder(base64.StdEncoding, body)
+		}
+		if b, err := io.ReadAll(body); err == nil {
+			msg.Body = string(b)
+		}
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+	log.Info().Str("to", strings.Join(to, ",")).Str("subject", msg.Subject).Msg("[smtp-stub] Письмо получено")
+}
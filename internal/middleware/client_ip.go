@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+type clientIPKey struct{}
+
+// RealIP определяет IP клиента для лимитов и блокировок. X-Forwarded-For и
+// X-Real-IP учитываются, только если запрос пришёл с адреса из trustedProxies
+// (IP или CIDR): иначе заголовок может подделать кто угодно. В X-Forwarded-For
+// клиентом считается самый правый адрес, который не принадлежит доверенным
+// прокси, — левее него значения дописал сам клиент.
+func RealIP(trustedProxies []string) func(http.Handler) http.Handler {
+	trusted := parsePrefixes(trustedProxies)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// IP клиента, определённый RealIP; без RealIP — адрес из RemoteAddr
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	return remoteHost(r)
+}
+
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) string {
+	remote := remoteHost(r)
+	if !isTrusted(remote, trusted) {
+		return remote
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		for _, part := range strings.Split(h, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				hops = append(hops, part)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// Мусор в цепочке: дальше влево верить нельзя
+			break
+		}
+		if !isTrusted(addr.String(), trusted) {
+			return addr.Unmap().String()
+		}
+		if i == 0 {
+			// Вся цепочка из доверенных прокси — клиент первый в ней
+			return addr.Unmap().String()
+		}
+	}
+	if len(hops) == 0 {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return addr.Unmap().String()
+		}
+	}
+	return remote
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isTrusted(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePrefixes(list []string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				log.Error().Err(err).Str("proxy", s).Msg("[middleware] Некорректный адрес доверенного прокси, пропущен")
+				continue
+			}
+			addr = addr.Unmap()
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			log.Error().Err(err).Str("proxy", s).Msg("[middleware] Некорректная сеть доверенных прокси, пропущена")
+			continue
+		}
+		out = append(out, p.Masked())
+	}
+	return out
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const deviceIDKey contextKey = "deviceID"
+
+// Проверка ключа устройства; возвращает id устройства
+type DeviceAuthenticator interface {
+	AuthenticateDevice(ctx context.Context, key string) (int64, error)
+}
+
+// DeviceAuth пропускает запросы домофонов с заголовком Authorization: Device <key>
+func DeviceAuth(auth DeviceAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Device ")
+			if !ok || key == "" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			deviceID, err := auth.AuthenticateDevice(r.Context(), key)
+			if err != nil {
+				log.Warn().Err(err).Str("ip", ClientIP(r)).Msg("[device] Запрос устройства отклонён")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), deviceIDKey, deviceID)))
+		})
+	}
+}
+
+func DeviceIDFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(deviceIDKey).(int64)
+	return id, ok
+}
@@ -2,14 +2,20 @@ package middleware
 
 import (
     "net/http"
+    "strconv"
     "strings"
     "context"
     "domofon/internal/jwt"
+
+    "github.com/gorilla/mux"
 )
 
 type contextKey string
 
-const userIDKey contextKey = "userID"
+const (
+    userIDKey contextKey = "userID"
+    claimsKey contextKey = "claims"
+)
 
 func JWTAuth(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -25,9 +31,15 @@ func JWTAuth(next http.Handler) http.Handler {
             http.Error(w, "Unauthorized", http.StatusUnauthorized)
             return
         }
+        userID, err := claims.UserID()
+        if err != nil {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
 
-        // Кладём userID в context для handler-ов
-        ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
+        // Кладём userID и claims в context для handler-ов
+        ctx := context.WithValue(r.Context(), userIDKey, userID)
+        ctx = context.WithValue(ctx, claimsKey, claims)
         next.ServeHTTP(w, r.WithContext(ctx))
     })
 }
@@ -37,3 +49,32 @@ func UserIDFromContext(ctx context.Context) (int64, bool) {
     uid, ok := ctx.Value(userIDKey).(int64)
     return uid, ok
 }
+
+// Полные claims access-токена: роль, сессия, доступные квартиры
+func ClaimsFromContext(ctx context.Context) (*jwt.AccessClaims, bool) {
+    claims, ok := ctx.Value(claimsKey).(*jwt.AccessClaims)
+    return claims, ok
+}
+
+// userID и роль из access-токена, ok=false если запрос не прошёл JWTAuth
+func UserAndRole(r *http.Request) (int64, string, bool) {
+    claims, ok := ClaimsFromContext(r.Context())
+    if !ok {
+        return 0, "", false
+    }
+    userID, err := claims.UserID()
+    if err != nil {
+        return 0, "", false
+    }
+    return userID, claims.Role, true
+}
+
+// Числовой id из пути ({id}, {attachment_id} и т.п.), при ошибке сам отвечает 400
+func PathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+    id, err := strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+    if err != nil || id <= 0 {
+        http.Error(w, "Некорректный id", http.StatusBadRequest)
+        return 0, false
+    }
+    return id, true
+}
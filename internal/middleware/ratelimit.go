@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"domofon/internal/phone"
+	"domofon/internal/ratelimit"
+)
+
+// Тело больше этого не разбирается в поисках номера
+const maxRateLimitBody = 64 << 10
+
+// RateLimit ограничивает частоту запросов к ручке route по IP клиента и,
+// если для ручки задан лимит по номеру, по полю phone из JSON-тела.
+// При превышении — 429 с Retry-After.
+func RateLimit(l *ratelimit.Limiter, route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var phoneKey string
+			if l.LimitsPhone(route) {
+				phoneKey = phoneFromBody(r)
+			}
+
+			allowed, retryAfter := l.Allow(r.Context(), route, ClientIP(r), phoneKey)
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				http.Error(w, "Слишком много запросов, попробуйте позже", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Достаёт phone из JSON-тела и возвращает тело на место для handler-а.
+// Номер приводится к E.164, чтобы разные записи одного номера делили ведро.
+func phoneFromBody(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRateLimitBody))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	var req struct {
+		Phone string `json:"phone"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.Phone == "" {
+		return ""
+	}
+	if n, err := phone.Normalize(req.Phone); err == nil {
+		return n
+	}
+	return req.Phone
+}
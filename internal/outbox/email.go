@@ -0,0 +1,25 @@
+package outbox
+
+import (
+	"context"
+
+	"domofon/internal/db"
+	"domofon/internal/mail"
+)
+
+// Канал email поверх SMTP (или мока)
+type EmailSender struct {
+	mailer mail.Mailer
+}
+
+func NewEmailSender(mailer mail.Mailer) *EmailSender {
+	return &EmailSender{mailer: mailer}
+}
+
+func (s *EmailSender) Send(ctx context.Context, msg *db.OutboxMessage) error {
+	return s.mailer.Send(ctx, mail.Message{
+		To:      msg.Recipient,
+		Subject: msg.Subject.String,
+		Body:    msg.Body,
+	})
+}
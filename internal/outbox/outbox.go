@@ -0,0 +1,82 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+
+	"domofon/internal/db"
+)
+
+// Канал доставки
+type Channel string
+
+const (
+	ChannelSMS   Channel = "sms"
+	ChannelEmail Channel = "email"
+	ChannelPush  Channel = "push"
+)
+
+// Статусы сообщения в outbox_messages
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusSent       = "sent"
+	StatusDead       = "dead"
+)
+
+var ErrEmptyRecipient = errors.New("не указан получатель")
+
+// Исходящее сообщение. Subject нужен только для email, Payload — произвольные
+// данные для канала (например, data для push).
+type Message struct {
+	Channel   Channel
+	Recipient string
+	Subject   string
+	Body      string
+	Payload   []byte
+	// Сколько раз пытаться отправить; 0 — значение из конфига
+	MaxAttempts int
+}
+
+// Отправитель для конкретного канала. Воркер вызывает его для каждого сообщения
+// этого канала; ошибка означает, что попытку нужно повторить позже.
+type Sender interface {
+	Send(ctx context.Context, msg *db.OutboxMessage) error
+}
+
+// Постановка сообщений в очередь. Сама отправка — в Worker.
+type Queue struct {
+	repo        Repository
+	maxAttempts int
+}
+
+func NewQueue(repo Repository, maxAttempts int) *Queue {
+	return &Queue{repo: repo, maxAttempts: maxAttempts}
+}
+
+func (q *Queue) Enqueue(ctx context.Context, msg Message) (int64, error) {
+	if msg.Recipient == "" {
+		return 0, ErrEmptyRecipient
+	}
+	maxAttempts := msg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = q.maxAttempts
+	}
+	return q.repo.Enqueue(ctx, msg, maxAttempts)
+}
+
+// Статус доставки сообщения
+func (q *Queue) Status(ctx context.Context, id int64) (*db.OutboxMessage, error) {
+	return q.repo.Get(ctx, id)
+}
+
+// Реализует verification.SMSSender: SMS не отправляется в запросе,
+// а ставится в очередь
+func (q *Queue) SendSMS(toPhone, message string) error {
+	_, err := q.Enqueue(context.Background(), Message{
+		Channel:   ChannelSMS,
+		Recipient: toPhone,
+		Body:      message,
+	})
+	return err
+}
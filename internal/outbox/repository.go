@@ -0,0 +1,78 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Repository interface {
+	// Ставит сообщение в очередь, возвращает его id
+	Enqueue(ctx context.Context, msg Message, maxAttempts int) (int64, error)
+	// Забирает до limit сообщений, готовых к отправке, и помечает их занятыми до lockedUntil
+	Claim(ctx context.Context, now, lockedUntil time.Time, limit int) ([]db.OutboxMessage, error)
+	// Отметки применяются, только пока сообщение занято этим воркером (locked_until
+	// не изменился с Claim); false — блокировка истекла и сообщение забрал другой
+	MarkSent(ctx context.Context, msg *db.OutboxMessage, sentAt time.Time) (bool, error)
+	// Учитывает неудачную попытку: status — pending (повтор в nextAttempt) или dead
+	MarkFailed(ctx context.Context, msg *db.OutboxMessage, status string, nextAttempt time.Time, lastErr string) (bool, error)
+	Get(ctx context.Context, id int64) (*db.OutboxMessage, error)
+}
+
+type OutboxRepository struct {
+	queries *db.Queries
+}
+
+func NewRepository(queries *db.Queries) *OutboxRepository {
+	return &OutboxRepository{queries: queries}
+}
+
+func (r *OutboxRepository) Enqueue(ctx context.Context, msg Message, maxAttempts int) (int64, error) {
+	return r.queries.EnqueueOutboxMessage(ctx, db.EnqueueOutboxMessageParams{
+		Channel:     string(msg.Channel),
+		Recipient:   msg.Recipient,
+		Subject:     pgtype.Text{String: msg.Subject, Valid: msg.Subject != ""},
+		Body:        msg.Body,
+		Payload:     msg.Payload,
+		MaxAttempts: int32(maxAttempts),
+	})
+}
+
+func (r *OutboxRepository) Claim(ctx context.Context, now, lockedUntil time.Time, limit int) ([]db.OutboxMessage, error) {
+	return r.queries.ClaimOutboxMessages(ctx, db.ClaimOutboxMessagesParams{
+		LockedUntil: pgtype.Timestamp{Time: lockedUntil, Valid: true},
+		Now:         pgtype.Timestamp{Time: now, Valid: true},
+		BatchSize:   int32(limit),
+	})
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, msg *db.OutboxMessage, sentAt time.Time) (bool, error) {
+	n, err := r.queries.MarkOutboxSent(ctx, db.MarkOutboxSentParams{
+		ID:           msg.ID,
+		SentAt:       pgtype.Timestamp{Time: sentAt, Valid: true},
+		ClaimedUntil: msg.LockedUntil,
+	})
+	return n == 1, err
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, msg *db.OutboxMessage, status string, nextAttempt time.Time, lastErr string) (bool, error) {
+	n, err := r.queries.MarkOutboxFailed(ctx, db.MarkOutboxFailedParams{
+		ID:            msg.ID,
+		Status:        status,
+		NextAttemptAt: pgtype.Timestamp{Time: nextAttempt, Valid: true},
+		LastError:     pgtype.Text{String: lastErr, Valid: lastErr != ""},
+		ClaimedUntil:  msg.LockedUntil,
+	})
+	return n == 1, err
+}
+
+func (r *OutboxRepository) Get(ctx context.Context, id int64) (*db.OutboxMessage, error) {
+	msg, err := r.queries.GetOutboxMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
@@ -0,0 +1,21 @@
+package outbox
+
+import (
+	"context"
+
+	"domofon/internal/db"
+	"domofon/internal/sms"
+)
+
+// Канал SMS поверх шлюза с резервными провайдерами
+type SMSSender struct {
+	gateway *sms.Gateway
+}
+
+func NewSMSSender(gateway *sms.Gateway) *SMSSender {
+	return &SMSSender{gateway: gateway}
+}
+
+func (s *SMSSender) Send(ctx context.Context, msg *db.OutboxMessage) error {
+	return s.gateway.Send(ctx, msg.Recipient, msg.Body)
+}
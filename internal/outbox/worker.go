@@ -0,0 +1,149 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"domofon/internal/config"
+	"domofon/internal/db"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Пул воркеров, разбирающих outbox_messages. Воркеры забирают сообщения через
+// FOR UPDATE SKIP LOCKED, поэтому несколько экземпляров сервиса могут работать
+// с одной очередью. Доставка «хотя бы один раз»: если процесс упал после отправки,
+// но до отметки sent, сообщение уйдёт повторно после LockTimeout.
+type Worker struct {
+	repo    Repository
+	cfg     config.OutboxConfig
+	mu      sync.RWMutex
+	senders map[Channel]Sender
+}
+
+func NewWorker(repo Repository, cfg *config.OutboxConfig) *Worker {
+	return &Worker{repo: repo, cfg: *cfg, senders: make(map[Channel]Sender)}
+}
+
+// Регистрирует отправителя для канала. Сообщения каналов без отправителя уходят в dead.
+func (w *Worker) Register(channel Channel, sender Sender) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.senders[channel] = sender
+}
+
+func (w *Worker) sender(channel Channel) Sender {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.senders[channel]
+}
+
+// Запускает воркеров и ждёт их завершения после отмены ctx
+func (w *Worker) Run(ctx context.Context) {
+	log.Info().Int("workers", w.cfg.Workers).Msg("[outbox] Воркеры очереди запущены")
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		n, err := w.processBatch(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("[outbox] Не удалось получить сообщения из очереди")
+		}
+		// Пачка была полной — сразу берём следующую
+		if err == nil && n == w.cfg.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.cfg.PollInterval):
+		}
+	}
+}
+
+func (w *Worker) processBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	msgs, err := w.repo.Claim(ctx, now, now.Add(w.cfg.LockTimeout), w.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i := range msgs {
+		w.process(ctx, &msgs[i])
+	}
+	return len(msgs), nil
+}
+
+func (w *Worker) process(ctx context.Context, msg *db.OutboxMessage) {
+	logger := log.With().
+		Int64("id", msg.ID).
+		Str("channel", msg.Channel).
+		Int32("attempt", msg.Attempts+1).
+		Logger()
+
+	sender := w.sender(Channel(msg.Channel))
+	if sender == nil {
+		logger.Error().Msg("[outbox] Нет отправителя для канала, сообщение отброшено")
+		w.markFailed(ctx, msg, StatusDead, time.Now(), "нет отправителя для канала "+msg.Channel)
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, w.cfg.SendTimeout)
+	err := sender.Send(sendCtx, msg)
+	cancel()
+
+	// Отметки ставим и после остановки сервиса, иначе сообщение зависнет до LockTimeout
+	markCtx := context.WithoutCancel(ctx)
+	if err == nil {
+		ok, err := w.repo.MarkSent(markCtx, msg, time.Now())
+		if err != nil {
+			logger.Error().Err(err).Msg("[outbox] Не удалось отметить сообщение отправленным")
+		} else if !ok {
+			logger.Warn().Msg("[outbox] Блокировка истекла до отметки, сообщение уже у другого воркера")
+		}
+		return
+	}
+
+	if msg.Attempts+1 >= msg.MaxAttempts {
+		logger.Error().Err(err).Msg("[outbox] Попытки исчерпаны, сообщение перенесено в dead")
+		w.markFailed(markCtx, msg, StatusDead, time.Now(), err.Error())
+		return
+	}
+	next := time.Now().Add(w.retryDelay(int(msg.Attempts) + 1))
+	logger.Warn().Err(err).Time("next_attempt", next).Msg("[outbox] Не удалось отправить сообщение")
+	w.markFailed(markCtx, msg, StatusPending, next, err.Error())
+}
+
+func (w *Worker) markFailed(ctx context.Context, msg *db.OutboxMessage, status string, next time.Time, lastErr string) {
+	ok, err := w.repo.MarkFailed(ctx, msg, status, next, lastErr)
+	if err != nil {
+		log.Error().Err(err).Int64("id", msg.ID).Msg("[outbox] Не удалось сохранить результат отправки")
+	} else if !ok {
+		log.Warn().Int64("id", msg.ID).Msg("[outbox] Блокировка истекла до отметки, сообщение уже у другого воркера")
+	}
+}
+
+// RetryBase * 2^(attempt-1), не больше RetryMax
+func (w *Worker) retryDelay(attempt int) time.Duration {
+	delay := w.cfg.RetryBase
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= w.cfg.RetryMax {
+			return w.cfg.RetryMax
+		}
+	}
+	return delay
+}
@@ -0,0 +1,212 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"domofon/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// Пределы параметров чужих argon2id-хешей: хеш из БД не должен заставить
+// сервер считать минуты или выделять гигабайты на одну проверку
+const (
+	argon2MaxMemory     = 1 << 20 // КиБ, 1 ГиБ
+	argon2MaxIterations = 64
+	argon2MinSaltLen    = 8
+	argon2MaxSaltLen    = 64
+	argon2MinKeyLen     = 16
+	argon2MaxKeyLen     = 64
+)
+
+var ErrUnknownHash = errors.New("неизвестный формат хеша пароля")
+
+// Hasher хеширует пароли и проверяет их. Хеш хранится в формате PHC
+// ($argon2id$v=19$m=...,t=...,p=...$salt$hash) или в родном формате bcrypt ($2a$...).
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// Хеш сделан другим алгоритмом или с устаревшими параметрами
+	NeedsRehash(encoded string) bool
+}
+
+type Argon2idHasher struct {
+	Memory      uint32 // КиБ
+	Iterations  uint32
+	Parallelism uint8
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	p, err := parseArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), p.salt, p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	p, err := parseArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return p.version != argon2.Version || p.memory != h.Memory ||
+		p.iterations != h.Iterations || p.parallelism != h.Parallelism ||
+		len(p.key) != argon2KeyLen
+}
+
+type argon2Params struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt, key   []byte
+}
+
+func parseArgon2id(encoded string) (*argon2Params, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return nil, ErrUnknownHash
+	}
+	var p argon2Params
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &p.version); err != nil {
+		return nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return nil, ErrUnknownHash
+	}
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnknownHash
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, ErrUnknownHash
+	}
+	if p.iterations < 1 || p.iterations > argon2MaxIterations ||
+		p.parallelism < 1 || p.memory < 8*uint32(p.parallelism) || p.memory > argon2MaxMemory ||
+		len(p.salt) < argon2MinSaltLen || len(p.salt) > argon2MaxSaltLen ||
+		len(p.key) < argon2MinKeyLen || len(p.key) > argon2MaxKeyLen {
+		return nil, ErrUnknownHash
+	}
+	return &p, nil
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(b), err
+}
+
+func (h *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	// Хеш со стоимостью ниже минимальной библиотеки не принимаем как пароль
+	if cost, err := bcrypt.Cost([]byte(encoded)); err != nil || cost < bcrypt.MinCost {
+		return false, ErrUnknownHash
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// Хеширует основным алгоритмом, а проверяет любым известным: старые
+// bcrypt-хеши продолжают работать и перехешируются при входе
+type MultiHasher struct {
+	primary  string
+	argon2id *Argon2idHasher
+	bcrypt   *BcryptHasher
+}
+
+func NewHasher(cfg config.PasswordHashConfig) *MultiHasher {
+	return &MultiHasher{
+		primary: cfg.Algorithm,
+		argon2id: &Argon2idHasher{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+		},
+		bcrypt: &BcryptHasher{Cost: cfg.BcryptCost},
+	}
+}
+
+func (h *MultiHasher) Hash(password string) (string, error) {
+	return h.forAlgorithm(h.primary).Hash(password)
+}
+
+func (h *MultiHasher) Verify(password, encoded string) (bool, error) {
+	hasher := h.forAlgorithm(algorithmOf(encoded))
+	if hasher == nil {
+		return false, ErrUnknownHash
+	}
+	return hasher.Verify(password, encoded)
+}
+
+func (h *MultiHasher) NeedsRehash(encoded string) bool {
+	alg := algorithmOf(encoded)
+	if alg != h.primary {
+		return true
+	}
+	return h.forAlgorithm(alg).NeedsRehash(encoded)
+}
+
+func (h *MultiHasher) forAlgorithm(alg string) Hasher {
+	switch alg {
+	case AlgorithmArgon2id:
+		return h.argon2id
+	case AlgorithmBcrypt:
+		return h.bcrypt
+	}
+	return nil
+}
+
+func algorithmOf(encoded string) string {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return AlgorithmArgon2id
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return AlgorithmBcrypt
+	}
+	return ""
+}
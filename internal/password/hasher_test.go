@@ -0,0 +1,120 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"domofon/internal/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Минимальные параметры, чтобы тесты не считали настоящий argon2id
+func testHashConfig(alg string) config.PasswordHashConfig {
+	return config.PasswordHashConfig{
+		Algorithm:         alg,
+		Argon2Memory:      64,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+		BcryptCost:        bcrypt.MinCost,
+	}
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgorithmArgon2id, AlgorithmBcrypt} {
+		t.Run(alg, func(t *testing.T) {
+			h := NewHasher(testHashConfig(alg))
+			encoded, err := h.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if got := algorithmOf(encoded); got != alg {
+				t.Fatalf("algorithmOf(%q) = %q, want %q", encoded, got, alg)
+			}
+			if ok, err := h.Verify("correct horse", encoded); err != nil || !ok {
+				t.Errorf("Verify(right) = %v, %v; want true, nil", ok, err)
+			}
+			if ok, err := h.Verify("wrong horse", encoded); err != nil || ok {
+				t.Errorf("Verify(wrong) = %v, %v; want false, nil", ok, err)
+			}
+			if h.NeedsRehash(encoded) {
+				t.Errorf("NeedsRehash of a fresh hash = true")
+			}
+		})
+	}
+}
+
+func TestHasherNeedsRehash(t *testing.T) {
+	bcryptHash, err := NewHasher(testHashConfig(AlgorithmBcrypt)).Hash("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := NewHasher(testHashConfig(AlgorithmArgon2id)).Hash("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := testHashConfig(AlgorithmArgon2id)
+	stronger.Argon2Iterations = 2
+	costlier := testHashConfig(AlgorithmBcrypt)
+	costlier.BcryptCost = bcrypt.MinCost + 1
+
+	tests := []struct {
+		name    string
+		cfg     config.PasswordHashConfig
+		encoded string
+		want    bool
+	}{
+		{"same argon2id params", testHashConfig(AlgorithmArgon2id), argonHash, false},
+		{"argon2id iterations raised", stronger, argonHash, true},
+		{"bcrypt under argon2id primary", testHashConfig(AlgorithmArgon2id), bcryptHash, true},
+		{"same bcrypt cost", testHashConfig(AlgorithmBcrypt), bcryptHash, false},
+		{"bcrypt cost raised", costlier, bcryptHash, true},
+		{"argon2id under bcrypt primary", testHashConfig(AlgorithmBcrypt), argonHash, true},
+		{"unknown format", testHashConfig(AlgorithmArgon2id), "plain", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewHasher(tt.cfg).NeedsRehash(tt.encoded); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasherRejectsUnsafeParams(t *testing.T) {
+	h := NewHasher(testHashConfig(AlgorithmArgon2id))
+	// 16 байт соли и 32 байта ключа в base64 без паддинга
+	salt := "c29tZXNhbHRzb21lc2FsdA"
+	key := "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+	lowCost, err := NewHasher(testHashConfig(AlgorithmBcrypt)).Hash("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowCost = strings.Replace(lowCost, "$04$", "$03$", 1)
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"zero iterations", "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key},
+		{"too many iterations", "$argon2id$v=19$m=64,t=1000000,p=1$" + salt + "$" + key},
+		{"zero parallelism", "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key},
+		{"memory over 1 GiB", "$argon2id$v=19$m=4194304,t=1,p=1$" + salt + "$" + key},
+		{"short salt", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$" + key},
+		{"short key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$a2V5"},
+		{"bad version", "$argon2id$v=x$m=64,t=1,p=1$" + salt + "$" + key},
+		{"bcrypt below MinCost", lowCost},
+		{"unknown prefix", "$md5$abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := h.Verify("pw", tt.encoded)
+			if ok || !errors.Is(err, ErrUnknownHash) {
+				t.Errorf("Verify = %v, %v; want false, ErrUnknownHash", ok, err)
+			}
+		})
+	}
+}
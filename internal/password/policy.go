@@ -0,0 +1,172 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"domofon/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed breached.txt
+var bundledBreached string
+
+// Коды нарушенных правил — стабильные, на них опирается клиент
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleLower     = "lower"
+	RuleUpper     = "upper"
+	RuleLetter    = "letter"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RulePersonal  = "personal"
+	RuleBreached  = "breached"
+)
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Пароль не прошёл проверку; Violations — все нарушенные правила
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Message
+	}
+	return "пароль не соответствует требованиям: " + strings.Join(msgs, "; ")
+}
+
+type Policy struct {
+	cfg      config.PasswordPolicyConfig
+	breached map[string]struct{}
+}
+
+func NewPolicy(cfg config.PasswordPolicyConfig) *Policy {
+	p := &Policy{cfg: cfg, breached: make(map[string]struct{})}
+	if !cfg.CheckBreached {
+		return p
+	}
+	p.loadList(strings.NewReader(bundledBreached))
+	if cfg.BreachedListFile != "" {
+		f, err := os.Open(cfg.BreachedListFile)
+		if err != nil {
+			log.Error().Err(err).Str("file", cfg.BreachedListFile).Msg("[password] Не удалось открыть список утёкших паролей")
+		} else {
+			p.loadList(f)
+			f.Close()
+		}
+	}
+	log.Info().Int("count", len(p.breached)).Msg("[password] Загружен список утёкших паролей")
+	return p
+}
+
+func (p *Policy) loadList(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := sc.Err(); err != nil {
+		log.Error().Err(err).Msg("[password] Ошибка чтения списка утёкших паролей")
+	}
+}
+
+// Проверка пароля. personal — телефон, username и т.п.: пароль не должен
+// совпадать с ними или содержать их. nil — пароль подходит, иначе *PolicyError.
+func (p *Policy) Check(password string, personal ...string) error {
+	var vs []Violation
+	add := func(rule, msg string) { vs = append(vs, Violation{Rule: rule, Message: msg}) }
+
+	n := utf8.RuneCountInString(password)
+	if n < p.cfg.MinLength {
+		add(RuleMinLength, "не короче "+strconv.Itoa(p.cfg.MinLength)+" символов")
+	}
+	if p.cfg.MaxLength > 0 && n > p.cfg.MaxLength {
+		add(RuleMaxLength, "не длиннее "+strconv.Itoa(p.cfg.MaxLength)+" символов")
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	for _, class := range p.cfg.RequiredClasses {
+		switch class {
+		case RuleLower:
+			if !lower {
+				add(RuleLower, "хотя бы одна строчная буква")
+			}
+		case RuleUpper:
+			if !upper {
+				add(RuleUpper, "хотя бы одна заглавная буква")
+			}
+		case RuleLetter:
+			if !lower && !upper {
+				add(RuleLetter, "хотя бы одна буква")
+			}
+		case RuleDigit:
+			if !digit {
+				add(RuleDigit, "хотя бы одна цифра")
+			}
+		case RuleSymbol:
+			if !symbol {
+				add(RuleSymbol, "хотя бы один спецсимвол")
+			}
+		}
+	}
+
+	lowered := strings.ToLower(password)
+	if p.cfg.ForbidPersonal && containsPersonal(lowered, personal) {
+		add(RulePersonal, "не должен совпадать с номером телефона или именем пользователя")
+	}
+	if _, ok := p.breached[lowered]; ok {
+		add(RuleBreached, "пароль встречается в утечках, выберите другой")
+	}
+
+	if len(vs) > 0 {
+		return &PolicyError{Violations: vs}
+	}
+	return nil
+}
+
+func containsPersonal(password string, personal []string) bool {
+	for _, v := range personal {
+		v = strings.ToLower(strings.TrimSpace(v))
+		// Слишком короткие значения запретили бы почти любой пароль
+		if utf8.RuneCountInString(v) < 3 {
+			continue
+		}
+		if strings.Contains(password, v) {
+			return true
+		}
+		// Номер в пароле часто пишут без "+" и кода страны
+		if digits := strings.TrimPrefix(v, "+"); len(digits) > 10 && strings.Contains(password, digits[len(digits)-10:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+// Пакет phone приводит номера телефонов к E.164 (+79001234567).
+// Номера без кода страны считаются российскими: 8 900 123-45-67 и
+// 900 123-45-67 дают +79001234567.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// Код страны для номеров, введённых без него
+const DefaultCountryCode = "7"
+
+var ErrInvalid = errors.New("некорректный номер телефона")
+
+// Normalize разбирает номер в произвольной записи (пробелы, скобки, дефисы,
+// префиксы 8 и 00) и возвращает его в формате E.164.
+func Normalize(raw string) (string, error) {
+	s := strings.TrimSpace(raw)
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		international = true
+		s = s[1:]
+	case strings.HasPrefix(s, "00"):
+		international = true
+		s = s[2:]
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.' || r == '\u00a0':
+			// разделители
+		default:
+			return "", ErrInvalid
+		}
+	}
+	digits := b.String()
+
+	if !international {
+		switch {
+		case len(digits) == 11 && (digits[0] == '8' || digits[0] == '7'):
+			digits = DefaultCountryCode + digits[1:]
+		case len(digits) == 10:
+			digits = DefaultCountryCode + digits
+		default:
+			return "", ErrInvalid
+		}
+	}
+
+	// E.164: до 15 цифр, код страны не начинается с 0
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", ErrInvalid
+	}
+	// Российские и казахстанские номера: код 7 и ровно 10 цифр после него
+	if digits[0] == '7' && len(digits) != 11 {
+		return "", ErrInvalid
+	}
+	return "+" + digits, nil
+}
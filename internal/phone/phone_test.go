@@ -0,0 +1,70 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"e164", "+79001234567", "+79001234567"},
+		{"leading 8", "89001234567", "+79001234567"},
+		{"leading 7 without plus", "79001234567", "+79001234567"},
+		{"ten digits", "9001234567", "+79001234567"},
+		{"formatted with 8", "8 (900) 123-45-67", "+79001234567"},
+		{"formatted with plus", "+7 900 123-45-67", "+79001234567"},
+		{"dots", "8.900.123.45.67", "+79001234567"},
+		{"non-breaking spaces", "+7\u00a0900\u00a0123\u00a045\u00a067", "+79001234567"},
+		{"surrounding spaces", "  89001234567\t", "+79001234567"},
+		{"00 prefix", "0079001234567", "+79001234567"},
+		{"foreign e164", "+375291234567", "+375291234567"},
+		{"foreign 00 prefix", "00 44 20 7946 0958", "+442079460958"},
+		{"shortest foreign", "+12345678", "+12345678"},
+		{"longest foreign", "+123456789012345", "+123456789012345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.raw)
+			if err != nil {
+				t.Fatalf("Normalize(%q) error: %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"spaces only", "   "},
+		{"plus only", "+"},
+		{"letters", "8900ABC4567"},
+		{"extension", "+79001234567 ext 1"},
+		{"plus in the middle", "8+9001234567"},
+		{"nine digits", "900123456"},
+		{"eleven digits without 7 or 8", "99001234567"},
+		{"twelve local digits", "890012345678"},
+		{"russian number too short", "+7900123456"},
+		{"russian number too long", "+790012345678"},
+		{"country code 0", "+0123456789"},
+		{"too short", "+1234567"},
+		{"too long", "+1234567890123456"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.raw)
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("Normalize(%q) = %q, %v, want ErrInvalid", tt.raw, got, err)
+			}
+		})
+	}
+}
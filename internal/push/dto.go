@@ -0,0 +1,45 @@
+package push
+
+type RegisterDeviceRequest struct {
+	// ExponentPushToken[...] или токен FCM
+	Token string `json:"token"`
+	// ios, android или web — для статистики
+	Platform string `json:"platform,omitempty"`
+	// expo или fcm; не задан — определяется по виду токена
+	Provider string `json:"provider,omitempty"`
+}
+
+type DeviceResponse struct {
+	ID       int64  `json:"id"`
+	Provider string `json:"provider"`
+	Platform string `json:"platform,omitempty"`
+}
+
+type UpdatePreferencesRequest struct {
+	// Тип события → включено ли уведомление. Не упомянутые типы не меняются.
+	Events map[string]bool `json:"events"`
+}
+
+type QuietHoursRequest struct {
+	// Время начала и конца в формате HH:MM; конец может быть на следующий день
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Часовой пояс IANA, например Europe/Moscow; не задан — часовой пояс по умолчанию
+	Timezone string `json:"timezone,omitempty"`
+}
+
+type QuietHoursResponse struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+}
+
+type PreferencesResponse struct {
+	Events     map[string]bool     `json:"events"`
+	QuietHours *QuietHoursResponse `json:"quiet_hours,omitempty"`
+}
+
+type TestResponse struct {
+	// На сколько устройств поставлено уведомление
+	Devices int `json:"devices"`
+}
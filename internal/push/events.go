@@ -0,0 +1,67 @@
+package push
+
+import (
+	"fmt"
+	"time"
+)
+
+// Типы событий, о которых приходят уведомления
+const (
+	EventCall         = "call"
+	EventChatMessage  = "chat_message"
+	EventChatMention  = "chat_mention"
+	EventAnnouncement = "announcement"
+	EventSecurity     = "security"
+	// Проверочное уведомление; не отключается и приходит в тихие часы
+	EventTest = "test"
+)
+
+// События, которые пользователь может включать и выключать
+var Events = []string{EventCall, EventChatMessage, EventChatMention, EventAnnouncement, EventSecurity}
+
+type eventOptions struct {
+	priority string
+	// Через сколько уведомление теряет смысл: звонок в домофон через минуту уже не нужен
+	ttl time.Duration
+}
+
+var eventDefaults = map[string]eventOptions{
+	EventCall:         {priority: "high", ttl: time.Minute},
+	EventChatMessage:  {priority: "normal", ttl: 24 * time.Hour},
+	EventChatMention:  {priority: "high", ttl: 24 * time.Hour},
+	EventAnnouncement: {priority: "normal", ttl: 72 * time.Hour},
+	EventSecurity:     {priority: "high", ttl: 24 * time.Hour},
+	EventTest:         {priority: "high", ttl: time.Hour},
+}
+
+// Уведомление пользователю; уходит на все его устройства
+type Notification struct {
+	Event string
+	Title string
+	Body  string
+	// Данные для приложения: куда перейти по нажатию и т.п.
+	Data map[string]string
+}
+
+// Попадает ли момент в тихие часы [start, end). Границы — минуты от полуночи;
+// start > end — интервал через полночь (например 22:00–07:00).
+func inQuietHours(start, end int, t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+func formatMinutes(m int) string {
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
+
+// "HH:MM" в минуты от полуночи
+func parseMinutes(s string) (int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
@@ -0,0 +1,127 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Expo Push API. Expo сам доставляет уведомление через FCM или APNs,
+// поэтому приложению в front/ достаточно передать свой ExponentPushToken.
+type Expo struct {
+	client      *http.Client
+	apiURL      string
+	accessToken string
+}
+
+func NewExpo(client *http.Client, apiURL, accessToken string) *Expo {
+	return &Expo{client: client, apiURL: apiURL, accessToken: accessToken}
+}
+
+func (p *Expo) Name() string { return ProviderExpo }
+
+type expoMessage struct {
+	To        string            `json:"to"`
+	Title     string            `json:"title,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+	Priority  string            `json:"priority,omitempty"`
+	Sound     string            `json:"sound,omitempty"`
+	ChannelID string            `json:"channelId,omitempty"`
+	TTL       int               `json:"ttl,omitempty"`
+}
+
+type expoTicket struct {
+	Status  string `json:"status"`
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message,omitempty"`
+	Details struct {
+		Error string `json:"error,omitempty"`
+	} `json:"details,omitempty"`
+}
+
+type expoError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type expoResponse struct {
+	Data   []expoTicket `json:"data"`
+	Errors []expoError  `json:"errors,omitempty"`
+}
+
+// Ошибки Expo, при которых есть смысл повторить
+var expoTemporary = map[string]bool{
+	"MessageRateExceeded":   true,
+	"TOO_MANY_REQUESTS":     true,
+	"INTERNAL_SERVER_ERROR": true,
+}
+
+func (p *Expo) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal([]expoMessage{{
+		To:        msg.Token,
+		Title:     msg.Title,
+		Body:      msg.Body,
+		Data:      msg.Data,
+		Priority:  msg.Priority,
+		Sound:     "default",
+		ChannelID: msg.ChannelID,
+		TTL:       int(msg.TTL.Seconds()),
+	}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if p.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.accessToken)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var out expoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return statusError(p.Name(), resp)
+		}
+		return &ProviderError{Provider: p.Name(), Code: "bad_response", Message: err.Error(), Temporary: true}
+	}
+
+	// Ошибка всего запроса (неверный токен доступа, лимиты и т.п.)
+	if len(out.Errors) > 0 {
+		e := out.Errors[0]
+		return &ProviderError{
+			Provider:  p.Name(),
+			Code:      e.Code,
+			Message:   e.Message,
+			Temporary: expoTemporary[e.Code] || resp.StatusCode >= 500,
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return statusError(p.Name(), resp)
+	}
+	if len(out.Data) == 0 {
+		return &ProviderError{Provider: p.Name(), Code: "bad_response", Message: "пустой ответ", Temporary: true}
+	}
+	// Ошибка по конкретному токену
+	if t := out.Data[0]; t.Status != "ok" {
+		return &ProviderError{
+			Provider:     p.Name(),
+			Code:         t.Details.Error,
+			Message:      t.Message,
+			Temporary:    expoTemporary[t.Details.Error],
+			Unregistered: t.Details.Error == "DeviceNotRegistered",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	fcmScope           = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmDefaultTokenURI = "https://oauth2.googleapis.com/token"
+)
+
+// Firebase Cloud Messaging, HTTP v1 API (/v1/projects/{id}/messages:send).
+// Доступ — по ключу сервисного аккаунта: подписанный им JWT обменивается
+// на OAuth-токен, токен кешируется до истечения.
+type FCM struct {
+	client    *http.Client
+	apiURL    string
+	projectID string
+	creds     fcmCredentials
+	key       *rsa.PrivateKey
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// Поля JSON-ключа сервисного аккаунта из консоли Firebase
+type fcmCredentials struct {
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// projectID берётся из ключа, если не задан явно
+func NewFCM(client *http.Client, apiURL, credentialsFile, projectID string) (*FCM, error) {
+	raw, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var creds fcmCredentials
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("ключ сервисного аккаунта: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(creds.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("ключ сервисного аккаунта: %w", err)
+	}
+	if creds.ClientEmail == "" {
+		return nil, fmt.Errorf("ключ сервисного аккаунта: нет client_email")
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = fcmDefaultTokenURI
+	}
+	if projectID == "" {
+		projectID = creds.ProjectID
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("не задан project_id")
+	}
+	return &FCM{
+		client:    client,
+		apiURL:    strings.TrimRight(apiURL, "/"),
+		projectID: projectID,
+		creds:     creds,
+		key:       key,
+	}, nil
+}
+
+func (p *FCM) Name() string { return ProviderFCM }
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmAndroidNotification struct {
+	ChannelID string `json:"channel_id,omitempty"`
+	Sound     string `json:"sound,omitempty"`
+}
+
+type fcmAndroid struct {
+	Priority     string                 `json:"priority,omitempty"`
+	TTL          string                 `json:"ttl,omitempty"`
+	Notification fcmAndroidNotification `json:"notification"`
+}
+
+type fcmAPNS struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload map[string]any    `json:"payload,omitempty"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      *fcmAndroid       `json:"android,omitempty"`
+	APNS         *fcmAPNS          `json:"apns,omitempty"`
+}
+
+type fcmRequest struct {
+	Message fcmMessage `json:"message"`
+}
+
+type fcmErrorDetail struct {
+	Type      string `json:"@type"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+type fcmError struct {
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Status  string           `json:"status"`
+	Details []fcmErrorDetail `json:"details,omitempty"`
+}
+
+type fcmResponse struct {
+	Name  string    `json:"name,omitempty"`
+	Error *fcmError `json:"error,omitempty"`
+}
+
+type fcmTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// Ошибки FCM, при которых есть смысл повторить
+var fcmTemporary = map[string]bool{
+	"UNAVAILABLE":    true,
+	"INTERNAL":       true,
+	"QUOTA_EXCEEDED": true,
+}
+
+// Токен больше не действует: приложение удалено или токен выдан другому проекту
+var fcmUnregistered = map[string]bool{
+	"UNREGISTERED":       true,
+	"SENDER_ID_MISMATCH": true,
+}
+
+func (p *FCM) Send(ctx context.Context, msg Message) error {
+	m := fcmMessage{
+		Token:        msg.Token,
+		Notification: fcmNotification{Title: msg.Title, Body: msg.Body},
+		Data:         msg.Data,
+		Android: &fcmAndroid{
+			Priority:     "NORMAL",
+			Notification: fcmAndroidNotification{ChannelID: msg.ChannelID, Sound: "default"},
+		},
+		APNS: &fcmAPNS{
+			Headers: map[string]string{"apns-priority": "5"},
+			Payload: map[string]any{"aps": map[string]any{"sound": "default"}},
+		},
+	}
+	if msg.Priority == "high" {
+		m.Android.Priority = "HIGH"
+		m.APNS.Headers["apns-priority"] = "10"
+	}
+	if msg.TTL > 0 {
+		m.Android.TTL = fmt.Sprintf("%ds", int(msg.TTL.Seconds()))
+		m.APNS.Headers["apns-expiration"] = fmt.Sprint(time.Now().Add(msg.TTL).Unix())
+	}
+	body, err := json.Marshal(fcmRequest{Message: m})
+	if err != nil {
+		return err
+	}
+
+	token, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", p.apiURL, url.PathEscape(p.projectID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Токен отозван раньше срока — получим новый при повторе
+		p.resetToken()
+	}
+	var out fcmResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return statusError(p.Name(), resp)
+		}
+		return &ProviderError{Provider: p.Name(), Code: "bad_response", Message: err.Error(), Temporary: true}
+	}
+	if out.Error == nil {
+		if resp.StatusCode != http.StatusOK {
+			return statusError(p.Name(), resp)
+		}
+		return nil
+	}
+
+	// Точная причина — в details (FcmError), общий статус — запасной вариант
+	code := out.Error.Status
+	for _, d := range out.Error.Details {
+		if d.ErrorCode != "" {
+			code = d.ErrorCode
+			break
+		}
+	}
+	return &ProviderError{
+		Provider:     p.Name(),
+		Code:         code,
+		Message:      out.Error.Message,
+		Temporary:    fcmTemporary[code] || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode >= 500,
+		Unregistered: fcmUnregistered[code],
+	}
+}
+
+// OAuth-токен сервисного аккаунта; обновляется за минуту до истечения
+func (p *FCM) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.accessToken != "" && time.Now().Before(p.expiresAt.Add(-time.Minute)) {
+		return p.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.creds.ClientEmail,
+		"scope": fcmScope,
+		"aud":   p.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if p.creds.PrivateKeyID != "" {
+		assertion.Header["kid"] = p.creds.PrivateKeyID
+	}
+	signed, err := assertion.SignedString(p.key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {signed},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		pe := statusError(p.Name(), resp)
+		pe.Code = "oauth_" + pe.Code
+		return "", pe
+	}
+	var out fcmTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.AccessToken == "" {
+		return "", &ProviderError{Provider: p.Name(), Code: "oauth_bad_response", Message: "не удалось получить токен доступа", Temporary: true}
+	}
+	p.accessToken = out.AccessToken
+	p.expiresAt = now.Add(time.Duration(out.ExpiresIn) * time.Second)
+	return p.accessToken, nil
+}
+
+func (p *FCM) resetToken() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.accessToken = ""
+}
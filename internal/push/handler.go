@@ -0,0 +1,219 @@
+package push
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"domofon/internal/middleware"
+)
+
+type PushHandler struct {
+	push *PushService
+}
+
+func NewPushHandler(s *PushService) *PushHandler {
+	return &PushHandler{push: s}
+}
+
+// RegisterDevice godoc
+// @Summary Зарегистрировать push-токен
+// @Description Токен привязывается к текущей сессии входа; повторный вызов заменяет его. Поддерживаются токены Expo (ExponentPushToken[...]) и FCM.
+// @Tags push
+// @Accept json
+// @Produce json
+// @Param input body RegisterDeviceRequest true "Токен устройства"
+// @Success 200 {object} DeviceResponse
+// @Failure 400 {string} string "Некорректный токен"
+// @Security BearerAuth
+// @Router /push/devices [post]
+func (h *PushHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := claims.UserID()
+	if err != nil || claims.SessionID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req RegisterDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	d, err := h.push.RegisterDevice(r.Context(), userID, claims.SessionID, req)
+	if err != nil {
+		writePushError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// UnregisterDevice godoc
+// @Summary Удалить push-токен текущей сессии
+// @Description Приложение вызывает перед выходом, чтобы уведомления не приходили на устройство.
+// @Tags push
+// @Success 204 {string} string "Удалён"
+// @Failure 404 {string} string "Устройство не зарегистрировано"
+// @Security BearerAuth
+// @Router /push/devices/current [delete]
+func (h *PushHandler) UnregisterDevice(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.push.UnregisterDevice(r.Context(), userID, claims.SessionID); err != nil {
+		writePushError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Preferences godoc
+// @Summary Настройки уведомлений
+// @Description Включённость уведомлений по типам событий (call, chat_message, chat_mention, announcement, security) и тихие часы.
+// @Tags push
+// @Produce json
+// @Success 200 {object} PreferencesResponse
+// @Security BearerAuth
+// @Router /push/preferences [get]
+func (h *PushHandler) Preferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	prefs, err := h.push.Preferences(r.Context(), userID)
+	if err != nil {
+		writePushError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// UpdatePreferences godoc
+// @Summary Включить или выключить типы уведомлений
+// @Description Не упомянутые в запросе типы событий не меняются.
+// @Tags push
+// @Accept json
+// @Produce json
+// @Param input body UpdatePreferencesRequest true "Типы событий"
+// @Success 200 {object} PreferencesResponse
+// @Failure 400 {string} string "Неизвестный тип уведомлений"
+// @Security BearerAuth
+// @Router /push/preferences [put]
+func (h *PushHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req UpdatePreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	prefs, err := h.push.UpdatePreferences(r.Context(), userID, req)
+	if err != nil {
+		writePushError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// SetQuietHours godoc
+// @Summary Задать тихие часы
+// @Description В тихие часы уведомления не отправляются, кроме типов из PUSH_QUIET_HOURS_BYPASS. Интервал может переходить через полночь (22:00–07:00).
+// @Tags push
+// @Accept json
+// @Produce json
+// @Param input body QuietHoursRequest true "Тихие часы"
+// @Success 200 {object} PreferencesResponse
+// @Failure 400 {string} string "Некорректные тихие часы"
+// @Security BearerAuth
+// @Router /push/quiet-hours [put]
+func (h *PushHandler) SetQuietHours(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req QuietHoursRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	prefs, err := h.push.SetQuietHours(r.Context(), userID, req)
+	if err != nil {
+		writePushError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// DisableQuietHours godoc
+// @Summary Отключить тихие часы
+// @Tags push
+// @Success 204 {string} string "Отключены"
+// @Security BearerAuth
+// @Router /push/quiet-hours [delete]
+func (h *PushHandler) DisableQuietHours(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.push.DisableQuietHours(r.Context(), userID); err != nil {
+		writePushError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SendTest godoc
+// @Summary Проверочное уведомление
+// @Description Ставит уведомление в очередь на все устройства пользователя без учёта настроек и тихих часов.
+// @Tags push
+// @Produce json
+// @Success 202 {object} TestResponse
+// @Security BearerAuth
+// @Router /push/test [post]
+func (h *PushHandler) SendTest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	n, err := h.push.SendTest(r.Context(), userID)
+	if err != nil {
+		writePushError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, TestResponse{Devices: n})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writePushError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrDeviceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrUnknownProvider), errors.Is(err, ErrProviderUnavailable),
+		errors.Is(err, ErrUnknownEvent), errors.Is(err, ErrInvalidQuietHours), errors.Is(err, ErrInvalidTimezone):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,94 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"domofon/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ProviderExpo = "expo"
+	ProviderFCM  = "fcm"
+)
+
+// Уведомление для одного устройства
+type Message struct {
+	Token    string
+	Title    string
+	Body     string
+	Data     map[string]string
+	Priority string
+	// Android-канал уведомлений; у нас совпадает с типом события
+	ChannelID string
+	// Сколько провайдер хранит уведомление, если устройство недоступно; 0 — по умолчанию
+	TTL time.Duration
+}
+
+// HTTP-провайдер push-уведомлений
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Ошибка, которую вернул провайдер. Temporary — имеет смысл повторить позже.
+// Unregistered — токен больше не действует, устройство нужно забыть.
+type ProviderError struct {
+	Provider     string
+	Code         string
+	Message      string
+	Temporary    bool
+	Unregistered bool
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Provider, e.Code, e.Message)
+}
+
+func isUnregistered(err error) bool {
+	var pe *ProviderError
+	return errors.As(err, &pe) && pe.Unregistered
+}
+
+// Ошибка по HTTP-статусу, если тело ответа разобрать не удалось
+func statusError(provider string, resp *http.Response) *ProviderError {
+	return &ProviderError{
+		Provider:  provider,
+		Code:      fmt.Sprintf("http_%d", resp.StatusCode),
+		Message:   resp.Status,
+		Temporary: resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+	}
+}
+
+// Токены Expo выглядят как ExponentPushToken[...] (или ExpoPushToken[...]),
+// всё остальное считаем токенами FCM
+func detectProvider(token string) string {
+	if (strings.HasPrefix(token, "ExponentPushToken[") || strings.HasPrefix(token, "ExpoPushToken[")) &&
+		strings.HasSuffix(token, "]") {
+		return ProviderExpo
+	}
+	return ProviderFCM
+}
+
+// Провайдеры по имени. FCM подключается, только если задан ключ сервисного аккаунта.
+func NewProviders(cfg *config.PushConfig) map[string]Provider {
+	client := &http.Client{Timeout: cfg.Timeout}
+	providers := map[string]Provider{
+		ProviderExpo: NewExpo(client, cfg.ExpoURL, cfg.ExpoAccessToken),
+	}
+	if cfg.FCMCredentialsFile != "" {
+		fcm, err := NewFCM(client, cfg.FCMURL, cfg.FCMCredentialsFile, cfg.FCMProjectID)
+		if err != nil {
+			log.Error().Err(err).Str("file", cfg.FCMCredentialsFile).Msg("[push] Не удалось загрузить ключ FCM, токены FCM не обслуживаются")
+		} else {
+			providers[ProviderFCM] = fcm
+		}
+	}
+	return providers
+}
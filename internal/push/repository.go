@@ -0,0 +1,133 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	RegisterDevice(ctx context.Context, userID int64, sessionID, token, provider, platform string, now time.Time) (*db.PushDevice, error)
+	DeleteSessionDevice(ctx context.Context, userID int64, sessionID string) (bool, error)
+	DeleteDeviceByToken(ctx context.Context, token string) error
+	ListDevices(ctx context.Context, userID int64, activeAfter time.Time) ([]db.PushDevice, error)
+
+	ListPreferences(ctx context.Context, userID int64) ([]db.NotificationPreference, error)
+	SetPreferences(ctx context.Context, userID int64, events map[string]bool) error
+	GetSettings(ctx context.Context, userID int64) (*db.NotificationSetting, error)
+	SaveSettings(ctx context.Context, params db.UpsertNotificationSettingsParams) (*db.NotificationSetting, error)
+}
+
+type PushRepository struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+func NewPushRepository(pool *pgxpool.Pool) *PushRepository {
+	return &PushRepository{pool: pool, queries: db.New(pool)}
+}
+
+// Токен сессии заменяется новым. Если тот же токен был у другой сессии
+// (повторный вход на том же телефоне), старая запись удаляется.
+func (r *PushRepository) RegisterDevice(ctx context.Context, userID int64, sessionID, token, provider, platform string, now time.Time) (*db.PushDevice, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	err = q.DeleteOtherPushDevicesWithToken(ctx, db.DeleteOtherPushDevicesWithTokenParams{
+		Token:     token,
+		UserID:    int32(userID),
+		SessionID: sessionID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	d, err := q.UpsertPushDevice(ctx, db.UpsertPushDeviceParams{
+		UserID:    int32(userID),
+		SessionID: sessionID,
+		Token:     token,
+		Provider:  provider,
+		Platform:  pgtype.Text{String: platform, Valid: platform != ""},
+		UpdatedAt: pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *PushRepository) DeleteSessionDevice(ctx context.Context, userID int64, sessionID string) (bool, error) {
+	n, err := r.queries.DeletePushDeviceBySession(ctx, db.DeletePushDeviceBySessionParams{
+		UserID:    int32(userID),
+		SessionID: sessionID,
+	})
+	return n > 0, err
+}
+
+func (r *PushRepository) DeleteDeviceByToken(ctx context.Context, token string) error {
+	return r.queries.DeletePushDeviceByToken(ctx, token)
+}
+
+func (r *PushRepository) ListDevices(ctx context.Context, userID int64, activeAfter time.Time) ([]db.PushDevice, error) {
+	return r.queries.ListUserPushDevices(ctx, db.ListUserPushDevicesParams{
+		UserID:      int32(userID),
+		ActiveAfter: pgtype.Timestamp{Time: activeAfter, Valid: true},
+	})
+}
+
+func (r *PushRepository) ListPreferences(ctx context.Context, userID int64) ([]db.NotificationPreference, error) {
+	return r.queries.ListNotificationPreferences(ctx, int32(userID))
+}
+
+func (r *PushRepository) SetPreferences(ctx context.Context, userID int64, events map[string]bool) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	for event, enabled := range events {
+		err := q.UpsertNotificationPreference(ctx, db.UpsertNotificationPreferenceParams{
+			UserID:    int32(userID),
+			EventType: event,
+			Enabled:   enabled,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// nil, nil — пользователь ничего не настраивал
+func (r *PushRepository) GetSettings(ctx context.Context, userID int64) (*db.NotificationSetting, error) {
+	s, err := r.queries.GetNotificationSettings(ctx, int32(userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *PushRepository) SaveSettings(ctx context.Context, params db.UpsertNotificationSettingsParams) (*db.NotificationSetting, error) {
+	s, err := r.queries.UpsertNotificationSettings(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,57 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Канал push для outbox.Worker. Токены, которые провайдер считает удалёнными,
+// забываются, повторять такую отправку бессмысленно.
+type Sender struct {
+	repo      Repository
+	providers map[string]Provider
+}
+
+func NewSender(repo Repository, providers map[string]Provider) *Sender {
+	return &Sender{repo: repo, providers: providers}
+}
+
+func (s *Sender) Send(ctx context.Context, msg *db.OutboxMessage) error {
+	var p payload
+	if err := json.Unmarshal(msg.Payload, &p); err != nil {
+		return fmt.Errorf("некорректные данные уведомления: %w", err)
+	}
+	ttl := time.Until(p.ExpiresAt)
+	if !p.ExpiresAt.IsZero() && ttl <= 0 {
+		log.Warn().Int64("id", msg.ID).Str("event", p.Event).Msg("[push] Уведомление устарело, не отправлено")
+		return nil
+	}
+	provider := s.providers[p.Provider]
+	if provider == nil {
+		return fmt.Errorf("%w: %s", ErrProviderUnavailable, p.Provider)
+	}
+
+	err := provider.Send(ctx, Message{
+		Token:     msg.Recipient,
+		Title:     msg.Subject.String,
+		Body:      msg.Body,
+		Data:      p.Data,
+		Priority:  p.Priority,
+		ChannelID: p.Event,
+		TTL:       ttl,
+	})
+	if isUnregistered(err) {
+		log.Info().Err(err).Int64("id", msg.ID).Msg("[push] Токен больше не действует, устройство удалено")
+		if err := s.repo.DeleteDeviceByToken(ctx, msg.Recipient); err != nil {
+			return err
+		}
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,285 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"domofon/internal/config"
+	"domofon/internal/db"
+	"domofon/internal/outbox"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const maxTokenLength = 512
+
+var (
+	ErrInvalidToken        = errors.New("некорректный токен устройства")
+	ErrUnknownProvider     = errors.New("неизвестный push-провайдер")
+	ErrProviderUnavailable = errors.New("push-провайдер не настроен")
+	ErrDeviceNotFound      = errors.New("устройство не зарегистрировано")
+	ErrUnknownEvent        = errors.New("неизвестный тип уведомлений")
+	ErrInvalidQuietHours   = errors.New("некорректные тихие часы")
+	ErrInvalidTimezone     = errors.New("неизвестный часовой пояс")
+)
+
+// Данные уведомления в outbox_messages.payload. Получатель (токен), заголовок
+// и текст лежат в полях самого сообщения.
+type payload struct {
+	Provider  string            `json:"provider"`
+	Event     string            `json:"event"`
+	Priority  string            `json:"priority"`
+	Data      map[string]string `json:"data,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type PushService struct {
+	repo  Repository
+	queue *outbox.Queue
+	cfg   *config.PushConfig
+}
+
+func NewPushService(repo Repository, queue *outbox.Queue, cfg *config.PushConfig) *PushService {
+	return &PushService{repo: repo, queue: queue, cfg: cfg}
+}
+
+// Регистрирует токен для текущей сессии. Повторная регистрация заменяет токен.
+func (s *PushService) RegisterDevice(ctx context.Context, userID int64, sessionID string, req RegisterDeviceRequest) (*DeviceResponse, error) {
+	token := strings.TrimSpace(req.Token)
+	if token == "" || len(token) > maxTokenLength {
+		return nil, ErrInvalidToken
+	}
+	provider := req.Provider
+	if provider == "" {
+		provider = detectProvider(token)
+	}
+	switch provider {
+	case ProviderExpo:
+		if detectProvider(token) != ProviderExpo {
+			return nil, ErrInvalidToken
+		}
+	case ProviderFCM:
+		if s.cfg.FCMCredentialsFile == "" {
+			return nil, ErrProviderUnavailable
+		}
+	default:
+		return nil, ErrUnknownProvider
+	}
+	platform := strings.ToLower(strings.TrimSpace(req.Platform))
+	if len(platform) > 16 {
+		platform = platform[:16]
+	}
+
+	d, err := s.repo.RegisterDevice(ctx, userID, sessionID, token, provider, platform, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	log.Info().
+		Int64("user_id", userID).
+		Str("provider", provider).
+		Str("platform", platform).
+		Msg("[push] Устройство зарегистрировано")
+	return &DeviceResponse{ID: d.ID, Provider: d.Provider, Platform: d.Platform.String}, nil
+}
+
+// Забывает токен текущей сессии — приложение вызывает перед выходом
+func (s *PushService) UnregisterDevice(ctx context.Context, userID int64, sessionID string) error {
+	ok, err := s.repo.DeleteSessionDevice(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (s *PushService) Preferences(ctx context.Context, userID int64) (*PreferencesResponse, error) {
+	enabled, err := s.enabledEvents(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.repo.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &PreferencesResponse{Events: enabled}
+	if settings != nil && settings.QuietHoursStart.Valid && settings.QuietHoursEnd.Valid {
+		resp.QuietHours = &QuietHoursResponse{
+			Start:    formatMinutes(int(settings.QuietHoursStart.Int32)),
+			End:      formatMinutes(int(settings.QuietHoursEnd.Int32)),
+			Timezone: settings.Timezone,
+		}
+	}
+	return resp, nil
+}
+
+func (s *PushService) UpdatePreferences(ctx context.Context, userID int64, req UpdatePreferencesRequest) (*PreferencesResponse, error) {
+	for event := range req.Events {
+		if !slices.Contains(Events, event) {
+			return nil, ErrUnknownEvent
+		}
+	}
+	if err := s.repo.SetPreferences(ctx, userID, req.Events); err != nil {
+		return nil, err
+	}
+	return s.Preferences(ctx, userID)
+}
+
+func (s *PushService) SetQuietHours(ctx context.Context, userID int64, req QuietHoursRequest) (*PreferencesResponse, error) {
+	start, ok1 := parseMinutes(req.Start)
+	end, ok2 := parseMinutes(req.End)
+	if !ok1 || !ok2 || start == end {
+		return nil, ErrInvalidQuietHours
+	}
+	tz := strings.TrimSpace(req.Timezone)
+	if tz == "" {
+		tz = s.cfg.DefaultTimezone
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return nil, ErrInvalidTimezone
+	}
+
+	_, err := s.repo.SaveSettings(ctx, db.UpsertNotificationSettingsParams{
+		UserID:          int32(userID),
+		QuietHoursStart: pgtype.Int4{Int32: int32(start), Valid: true},
+		QuietHoursEnd:   pgtype.Int4{Int32: int32(end), Valid: true},
+		Timezone:        tz,
+		UpdatedAt:       pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Preferences(ctx, userID)
+}
+
+func (s *PushService) DisableQuietHours(ctx context.Context, userID int64) error {
+	tz := s.cfg.DefaultTimezone
+	settings, err := s.repo.GetSettings(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if settings != nil {
+		tz = settings.Timezone
+	}
+	_, err = s.repo.SaveSettings(ctx, db.UpsertNotificationSettingsParams{
+		UserID:    int32(userID),
+		Timezone:  tz,
+		UpdatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	return err
+}
+
+// Ставит уведомление в очередь на все устройства пользователя с учётом его
+// настроек: отключённые типы событий не отправляются, в тихие часы уходят
+// только события из PUSH_QUIET_HOURS_BYPASS. Возвращает число устройств.
+func (s *PushService) Notify(ctx context.Context, userID int64, n Notification) (int, error) {
+	opts, ok := eventDefaults[n.Event]
+	if !ok {
+		return 0, ErrUnknownEvent
+	}
+	now := time.Now()
+
+	if n.Event != EventTest {
+		enabled, err := s.enabledEvents(ctx, userID)
+		if err != nil {
+			return 0, err
+		}
+		if !enabled[n.Event] {
+			return 0, nil
+		}
+		if !slices.Contains(s.cfg.QuietHoursBypass, n.Event) {
+			quiet, err := s.inQuietHours(ctx, userID, now)
+			if err != nil {
+				return 0, err
+			}
+			if quiet {
+				log.Debug().Int64("user_id", userID).Str("event", n.Event).Msg("[push] Тихие часы, уведомление не отправлено")
+				return 0, nil
+			}
+		}
+	}
+
+	devices, err := s.repo.ListDevices(ctx, userID, now.Add(-s.cfg.DeviceTTL))
+	if err != nil {
+		return 0, err
+	}
+	// Тип события нужен приложению, чтобы открыть нужный экран
+	msgData := map[string]string{"event": n.Event}
+	for k, v := range n.Data {
+		msgData[k] = v
+	}
+	sent := 0
+	for _, d := range devices {
+		data, err := json.Marshal(payload{
+			Provider:  d.Provider,
+			Event:     n.Event,
+			Priority:  opts.priority,
+			Data:      msgData,
+			ExpiresAt: now.Add(opts.ttl),
+		})
+		if err != nil {
+			return sent, err
+		}
+		_, err = s.queue.Enqueue(ctx, outbox.Message{
+			Channel:     outbox.ChannelPush,
+			Recipient:   d.Token,
+			Subject:     n.Title,
+			Body:        n.Body,
+			Payload:     data,
+			MaxAttempts: s.cfg.MaxAttempts,
+		})
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Проверочное уведомление на все устройства пользователя
+func (s *PushService) SendTest(ctx context.Context, userID int64) (int, error) {
+	return s.Notify(ctx, userID, Notification{
+		Event: EventTest,
+		Title: "Домофон",
+		Body:  "Уведомления работают",
+	})
+}
+
+// Включённость каждого типа событий; без явной настройки тип включён
+func (s *PushService) enabledEvents(ctx context.Context, userID int64) (map[string]bool, error) {
+	prefs, err := s.repo.ListPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(Events))
+	for _, e := range Events {
+		out[e] = true
+	}
+	for _, p := range prefs {
+		if _, ok := out[p.EventType]; ok {
+			out[p.EventType] = p.Enabled
+		}
+	}
+	return out, nil
+}
+
+func (s *PushService) inQuietHours(ctx context.Context, userID int64, now time.Time) (bool, error) {
+	settings, err := s.repo.GetSettings(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if settings == nil || !settings.QuietHoursStart.Valid || !settings.QuietHoursEnd.Valid {
+		return false, nil
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return inQuietHours(int(settings.QuietHoursStart.Int32), int(settings.QuietHoursEnd.Int32), now.In(loc)), nil
+}
@@ -0,0 +1,216 @@
+package push
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Локальная заглушка push-провайдеров для разработки и ручного тестирования.
+// Отвечает в форматах Expo (/--/api/v2/push/send) и FCM HTTP v1
+// (/v1/projects/{id}/messages:send, токен — на /token, ключ сервисного
+// аккаунта для него — на GET /credentials), отправленные уведомления
+// доступны на GET /messages.
+// Токен, переданный в POST /unregister?token=..., дальше отклоняется
+// как удалённый с устройства.
+type StubServer struct {
+	// Если true — провайдеры отвечают временной ошибкой
+	Fail bool
+
+	mu           sync.Mutex
+	messages     []StubMessage
+	unregistered map[string]bool
+	keyPEM       string
+}
+
+const stubAccessToken = "stub-access-token"
+
+type StubMessage struct {
+	Provider string            `json:"provider"`
+	To       string            `json:"to"`
+	Title    string            `json:"title"`
+	Body     string            `json:"body"`
+	Data     map[string]string `json:"data,omitempty"`
+	Priority string            `json:"priority,omitempty"`
+	SentAt   time.Time         `json:"sent_at"`
+}
+
+func (s *StubServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/--/api/v2/push/send", s.handleExpo)
+	mux.HandleFunc("/v1/projects/", s.handleFCM)
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/credentials", s.handleCredentials)
+	mux.HandleFunc("/messages", s.handleMessages)
+	mux.HandleFunc("/unregister", s.handleUnregister)
+	return mux
+}
+
+func (s *StubServer) Messages() []StubMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StubMessage(nil), s.messages...)
+}
+
+// Помечает токен удалённым: следующие уведомления на него провайдер отклонит
+func (s *StubServer) Unregister(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unregistered == nil {
+		s.unregistered = make(map[string]bool)
+	}
+	s.unregistered[token] = true
+}
+
+// false — токен удалён, уведомление не записано
+func (s *StubServer) record(m StubMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unregistered[m.To] {
+		return false
+	}
+	m.SentAt = time.Now()
+	s.messages = append(s.messages, m)
+	return true
+}
+
+func (s *StubServer) handleExpo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var msgs []expoMessage
+	if err := json.NewDecoder(r.Body).Decode(&msgs); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(expoResponse{Errors: []expoError{{Code: "VALIDATION_ERROR", Message: err.Error()}}})
+		return
+	}
+	if s.Fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(expoResponse{Errors: []expoError{{Code: "INTERNAL_SERVER_ERROR", Message: "stub failure"}}})
+		return
+	}
+
+	out := expoResponse{Data: make([]expoTicket, 0, len(msgs))}
+	for _, m := range msgs {
+		t := expoTicket{Status: "ok", ID: time.Now().Format("20060102150405.000000000")}
+		if detectProvider(m.To) != ProviderExpo || !s.record(StubMessage{
+			Provider: ProviderExpo,
+			To:       m.To,
+			Title:    m.Title,
+			Body:     m.Body,
+			Data:     m.Data,
+			Priority: m.Priority,
+		}) {
+			t = expoTicket{Status: "error", Message: m.To + " is not a registered push notification recipient"}
+			t.Details.Error = "DeviceNotRegistered"
+		}
+		out.Data = append(out.Data, t)
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func (s *StubServer) handleFCM(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/v1/projects/") || !strings.HasSuffix(r.URL.Path, "/messages:send") {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") != "Bearer "+stubAccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(fcmResponse{Error: &fcmError{Code: 401, Status: "UNAUTHENTICATED", Message: "Request had invalid authentication credentials"}})
+		return
+	}
+	var req fcmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(fcmResponse{Error: &fcmError{Code: 400, Status: "INVALID_ARGUMENT", Message: err.Error()}})
+		return
+	}
+	if s.Fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(fcmResponse{Error: &fcmError{Code: 503, Status: "UNAVAILABLE", Message: "stub failure"}})
+		return
+	}
+	m := req.Message
+	priority := ""
+	if m.Android != nil {
+		priority = strings.ToLower(m.Android.Priority)
+	}
+	if !s.record(StubMessage{
+		Provider: ProviderFCM,
+		To:       m.Token,
+		Title:    m.Notification.Title,
+		Body:     m.Notification.Body,
+		Data:     m.Data,
+		Priority: priority,
+	}) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(fcmResponse{Error: &fcmError{
+			Code:    404,
+			Status:  "NOT_FOUND",
+			Message: "Requested entity was not found.",
+			Details: []fcmErrorDetail{{Type: "type.googleapis.com/google.firebase.fcm.v1.FcmError", ErrorCode: "UNREGISTERED"}},
+		}})
+		return
+	}
+	project := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/projects/"), "/messages:send")
+	json.NewEncoder(w).Encode(fcmResponse{Name: "projects/" + project + "/messages/" + time.Now().Format("20060102150405.000000000")})
+}
+
+// OAuth-обмен для FCM: подпись assertion не проверяется, выдаётся постоянный токен
+func (s *StubServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.PostFormValue("assertion") == "" {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fcmTokenResponse{AccessToken: stubAccessToken, ExpiresIn: 3600, TokenType: "Bearer"})
+}
+
+// Ключ сервисного аккаунта, у которого token_uri указывает на эту заглушку.
+// Ключ RSA создаётся при первом запросе и живёт до перезапуска.
+func (s *StubServer) handleCredentials(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if s.keyPEM == "" {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			s.mu.Unlock()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	}
+	keyPEM := s.keyPEM
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fcmCredentials{
+		ProjectID:   "stub",
+		ClientEmail: "stub@stub.iam.gserviceaccount.com",
+		PrivateKey:  keyPEM,
+		TokenURI:    "http://" + r.Host + "/token",
+	})
+}
+
+func (s *StubServer) handleMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Messages())
+}
+
+func (s *StubServer) handleUnregister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	s.Unregister(token)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"domofon/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+type routeLimits struct {
+	ip    Limit
+	phone Limit
+}
+
+// Limiter проверяет запрос по вёдрам ручки: отдельно по IP и по номеру телефона
+type Limiter struct {
+	store   Store
+	enabled bool
+	routes  map[string]routeLimits
+}
+
+func NewLimiter(store Store, cfg *config.RateLimitConfig) *Limiter {
+	l := &Limiter{store: store, enabled: cfg.Enabled, routes: make(map[string]routeLimits)}
+	for route, rc := range cfg.Routes {
+		var rl routeLimits
+		var err error
+		if rl.ip, err = ParseLimit(rc.IP); err != nil {
+			log.Error().Err(err).Str("route", route).Msg("[ratelimit] Лимит по IP не задан")
+		}
+		if rl.phone, err = ParseLimit(rc.Phone); err != nil {
+			log.Error().Err(err).Str("route", route).Msg("[ratelimit] Лимит по номеру не задан")
+		}
+		l.routes[route] = rl
+	}
+	return l
+}
+
+// Нужен ли ручке номер телефона из тела запроса
+func (l *Limiter) LimitsPhone(route string) bool {
+	return l.enabled && l.routes[route].phone.Enabled()
+}
+
+// Allow списывает по токену из вёдер ручки — из всех сразу или ни из одного. false — лимит исчерпан,
+// retryAfter — когда можно повторить. Если хранилище недоступно,
+// запрос пропускается: лимиты не должны ронять вход.
+func (l *Limiter) Allow(ctx context.Context, route, ip, phone string) (allowed bool, retryAfter time.Duration) {
+	if !l.enabled {
+		return true, 0
+	}
+	rl, ok := l.routes[route]
+	if !ok {
+		return true, 0
+	}
+	var buckets []Bucket
+	if rl.ip.Enabled() && ip != "" {
+		buckets = append(buckets, Bucket{Key: "rl:" + route + ":ip:" + ip, Limit: rl.ip})
+	}
+	if rl.phone.Enabled() && phone != "" {
+		buckets = append(buckets, Bucket{Key: "rl:" + route + ":phone:" + phone, Limit: rl.phone})
+	}
+	if len(buckets) == 0 {
+		return true, 0
+	}
+
+	// Все вёдра проверяются разом: отказ по номеру не съедает токен IP и наоборот
+	allowed, wait, err := l.store.Take(ctx, buckets, time.Now())
+	if err != nil {
+		log.Error().Err(err).Str("route", route).Msg("[ratelimit] Хранилище недоступно, запрос пропущен")
+		return true, 0
+	}
+	if !allowed {
+		log.Warn().Str("route", route).Str("ip", ip).Dur("retry_after", wait).Msg("[ratelimit] Лимит исчерпан")
+	}
+	return allowed, wait
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Вёдра в памяти процесса. Подходит для одного экземпляра API;
+// при нескольких экземплярах лимит фактически умножается на их число.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// Когда ведро снова станет полным и его можно забыть
+	fullAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *MemoryStore) Take(_ context.Context, buckets []Bucket, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	left := make([]float64, len(buckets))
+	allowed, retryAfter := true, time.Duration(0)
+	for i, bk := range buckets {
+		tokens, updatedAt := float64(bk.Limit.Burst), now
+		if b, ok := s.buckets[bk.Key]; ok {
+			tokens, updatedAt = b.tokens, b.updatedAt
+		}
+		var ok bool
+		var wait time.Duration
+		left[i], ok, wait = take(tokens, updatedAt, now, bk.Limit)
+		if !ok {
+			allowed = false
+			retryAfter = max(retryAfter, wait)
+		}
+	}
+	if !allowed {
+		return false, retryAfter, nil
+	}
+
+	for i, bk := range buckets {
+		s.buckets[bk.Key] = &memoryBucket{
+			tokens:    left[i],
+			updatedAt: now,
+			fullAt:    now.Add(time.Duration((float64(bk.Limit.Burst) - left[i]) / bk.Limit.rate() * float64(time.Second))),
+		}
+	}
+	return true, 0, nil
+}
+
+// Раз в минуту выбрасывает полные вёдра, чтобы карта не росла бесконечно
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !b.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
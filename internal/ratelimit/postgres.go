@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Вёдра в таблице rate_limit_buckets: лимит общий для всех экземпляров API.
+// Ведро блокируется на время списания (SELECT ... FOR UPDATE).
+type PostgresStore struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+	// Строки старше staleAfter удаляются; должно быть не меньше самого длинного периода
+	staleAfter time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(pool *pgxpool.Pool, staleAfter time.Duration) *PostgresStore {
+	return &PostgresStore{pool: pool, queries: db.New(pool), staleAfter: staleAfter}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, buckets []Bucket, now time.Time) (bool, time.Duration, error) {
+	s.sweep(ctx, now)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback(ctx)
+	q := s.queries.WithTx(tx)
+
+	// Вёдра блокируются в порядке ключей, чтобы параллельные запросы не взаимоблокировались
+	buckets = slices.Clone(buckets)
+	slices.SortFunc(buckets, func(a, b Bucket) int { return strings.Compare(a.Key, b.Key) })
+
+	ts := pgtype.Timestamp{Time: now, Valid: true}
+	left := make([]float64, len(buckets))
+	allowed, retryAfter := true, time.Duration(0)
+	for i, bk := range buckets {
+		if err := q.EnsureRateLimitBucket(ctx, db.EnsureRateLimitBucketParams{
+			BucketKey: bk.Key,
+			Tokens:    float64(bk.Limit.Burst),
+			UpdatedAt: ts,
+		}); err != nil {
+			return false, 0, err
+		}
+		b, err := q.GetRateLimitBucketForUpdate(ctx, bk.Key)
+		if err != nil {
+			return false, 0, err
+		}
+		var ok bool
+		var wait time.Duration
+		left[i], ok, wait = take(b.Tokens, b.UpdatedAt.Time, now, bk.Limit)
+		if !ok {
+			allowed = false
+			retryAfter = max(retryAfter, wait)
+		}
+	}
+	if !allowed {
+		// Ничего не списываем: отказ по одному ведру не должен тратить другие
+		return false, retryAfter, nil
+	}
+
+	for i, bk := range buckets {
+		if err := q.UpdateRateLimitBucket(ctx, db.UpdateRateLimitBucketParams{
+			BucketKey: bk.Key,
+			Tokens:    left[i],
+			UpdatedAt: ts,
+		}); err != nil {
+			return false, 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, 0, err
+	}
+	return true, 0, nil
+}
+
+// Не чаще раза в несколько минут удаляет давно не использованные вёдра
+func (s *PostgresStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < 5*time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	n, err := s.queries.DeleteStaleRateLimitBuckets(ctx, pgtype.Timestamp{Time: now.Add(-s.staleAfter), Valid: true})
+	if err != nil {
+		log.Error().Err(err).Msg("[ratelimit] Не удалось удалить старые вёдра")
+		return
+	}
+	if n > 0 {
+		log.Debug().Int64("deleted", n).Msg("[ratelimit] Удалены старые вёдра")
+	}
+}
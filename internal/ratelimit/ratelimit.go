@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit — ведро на Burst запросов, которое полностью наполняется за Period.
+// "5/10m" — не больше 5 запросов подряд, затем один запрос раз в 2 минуты.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+func (l Limit) Enabled() bool { return l.Burst > 0 && l.Period > 0 }
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return strconv.Itoa(l.Burst) + "/" + l.Period.String()
+}
+
+// Пополнение в токенах за секунду
+func (l Limit) rate() float64 { return float64(l.Burst) / l.Period.Seconds() }
+
+// ParseLimit разбирает "N/длительность"; "off", "0" и пустая строка — без ограничения
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" || s == "0" {
+		return Limit{}, nil
+	}
+	n, d, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("лимит %q: ожидается формат N/длительность", s)
+	}
+	burst, err := strconv.Atoi(strings.TrimSpace(n))
+	if err != nil || burst < 0 {
+		return Limit{}, fmt.Errorf("лимит %q: некорректное число запросов", s)
+	}
+	period, err := time.ParseDuration(strings.TrimSpace(d))
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("лимит %q: некорректный период", s)
+	}
+	return Limit{Burst: burst, Period: period}, nil
+}
+
+// Ведро запроса: ключ (ручка + IP или номер) и его лимит
+type Bucket struct {
+	Key   string
+	Limit Limit
+}
+
+// Store хранит вёдра. Take атомарно списывает по токену из каждого ведра,
+// только если токен есть во всех; иначе ничего не списывает и возвращает,
+// через сколько появятся токены во всех вёдрах.
+type Store interface {
+	Take(ctx context.Context, buckets []Bucket, now time.Time) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// Состояние ведра после пополнения и попытки взять токен
+func take(tokens float64, updatedAt, now time.Time, limit Limit) (left float64, allowed bool, retryAfter time.Duration) {
+	if elapsed := now.Sub(updatedAt); elapsed > 0 {
+		tokens += elapsed.Seconds() * limit.rate()
+	}
+	tokens = math.Min(tokens, float64(limit.Burst))
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+	wait := time.Duration((1 - tokens) / limit.rate() * float64(time.Second))
+	return tokens, false, wait
+}
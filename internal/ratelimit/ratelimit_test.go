@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{"", Limit{}, false},
+		{"off", Limit{}, false},
+		{"0", Limit{}, false},
+		{"5/10m", Limit{Burst: 5, Period: 10 * time.Minute}, false},
+		{" 20 / 1m ", Limit{Burst: 20, Period: time.Minute}, false},
+		{"5", Limit{}, true},
+		{"x/1m", Limit{}, true},
+		{"-1/1m", Limit{}, true},
+		{"5/soon", Limit{}, true},
+		{"5/0s", Limit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	limit := Limit{Burst: 5, Period: 10 * time.Minute} // токен раз в 2 минуты
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		tokens      float64
+		updatedAt   time.Time
+		wantLeft    float64
+		wantAllowed bool
+		wantWait    time.Duration
+	}{
+		{"full bucket", 5, now, 4, true, 0},
+		{"last token", 1, now, 0, true, 0},
+		{"empty bucket", 0, now, 0, false, 2 * time.Minute},
+		{"half token", 0.5, now, 0.5, false, time.Minute},
+		{"refilled after period", 0, now.Add(-2 * time.Minute), 0, true, 0},
+		{"refill capped at burst", 0, now.Add(-time.Hour), 4, true, 0},
+		{"clock went back", 0, now.Add(time.Minute), 0, false, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, allowed, wait := take(tt.tokens, tt.updatedAt, now, limit)
+			if allowed != tt.wantAllowed || wait != tt.wantWait || !approx(left, tt.wantLeft) {
+				t.Errorf("take() = (%v, %v, %v), want (%v, %v, %v)", left, allowed, wait, tt.wantLeft, tt.wantAllowed, tt.wantWait)
+			}
+		})
+	}
+}
+
+// Отказ по одному ведру не должен списывать токены из остальных
+func TestMemoryStoreTakeAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ip := Bucket{Key: "ip", Limit: Limit{Burst: 3, Period: time.Hour}}
+	phone := Bucket{Key: "phone", Limit: Limit{Burst: 1, Period: time.Hour}}
+
+	if ok, _, _ := s.Take(ctx, []Bucket{ip, phone}, now); !ok {
+		t.Fatal("first request denied")
+	}
+	for i := 0; i < 3; i++ {
+		if ok, wait, _ := s.Take(ctx, []Bucket{ip, phone}, now); ok || wait <= 0 {
+			t.Fatalf("request over phone limit = (%v, %v), want denied with wait", ok, wait)
+		}
+	}
+	// В ведре IP остались оба токена, которые не были потрачены на отказы
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := s.Take(ctx, []Bucket{ip}, now); !ok {
+			t.Fatalf("ip request %d denied: tokens were spent on denied requests", i+1)
+		}
+	}
+	if ok, _, _ := s.Take(ctx, []Bucket{ip}, now); ok {
+		t.Fatal("ip bucket not exhausted")
+	}
+}
+
+func approx(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
@@ -0,0 +1,51 @@
+package schedule
+
+import "time"
+
+type RuleDTO struct {
+	// Дни недели: 1 — понедельник, ..., 7 — воскресенье
+	Weekdays []int `json:"weekdays"`
+	// Местное время HH:MM. end <= start — интервал через полночь; "24:00" — до конца суток
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type ScheduleRequest struct {
+	Name string `json:"name"`
+	// IANA, например Europe/Moscow. По умолчанию — SCHEDULE_DEFAULT_TIMEZONE
+	Timezone string    `json:"timezone"`
+	Rules    []RuleDTO `json:"rules"`
+	// Дни без доступа, YYYY-MM-DD
+	Holidays []string `json:"holidays"`
+}
+
+type ScheduleResponse struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Timezone  string    `json:"timezone"`
+	Rules     []RuleDTO `json:"rules"`
+	Holidays  []string  `json:"holidays"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SetScheduleRequest struct {
+	// null — снять расписание
+	ScheduleID *int64 `json:"schedule_id"`
+}
+
+type ResidentTypeScheduleResponse struct {
+	ResidentType string `json:"resident_type"`
+	ScheduleID   int64  `json:"schedule_id"`
+}
+
+// Ответ на вопрос, пускать ли ключ в заданный момент
+type Decision struct {
+	KeyID   int64     `json:"key_id"`
+	At      time.Time `json:"at"`
+	Allowed bool      `json:"allowed"`
+	// key_not_found, key_inactive, not_yet_valid, expired, holiday, outside_schedule
+	Reason string `json:"reason,omitempty"`
+	// Расписание, по которому принято решение
+	ScheduleID *int64 `json:"schedule_id,omitempty"`
+}
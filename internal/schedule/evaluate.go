@@ -0,0 +1,102 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ReasonHoliday         = "holiday"
+	ReasonOutsideSchedule = "outside_schedule"
+
+	minutesPerDay = 24 * 60
+	dateLayout    = "2006-01-02"
+)
+
+// Интервал времени по дням недели. Weekdays — битовая маска: бит 0 — понедельник,
+// бит 6 — воскресенье. End <= Start — интервал через полночь, день недели — день начала.
+type Rule struct {
+	Weekdays uint8
+	Start    int
+	End      int
+}
+
+// Расписание, готовое к проверке
+type Schedule struct {
+	ID       int64
+	Name     string
+	Location *time.Location
+	Rules    []Rule
+	// Дни (YYYY-MM-DD по местному времени), когда доступа нет
+	Holidays map[string]struct{}
+}
+
+// Allows отвечает, разрешён ли доступ в момент at. Второе значение — причина отказа.
+func (s *Schedule) Allows(at time.Time) (bool, string) {
+	local := at.In(s.Location)
+	if _, ok := s.Holidays[local.Format(dateLayout)]; ok {
+		return false, ReasonHoliday
+	}
+	minute := local.Hour()*60 + local.Minute()
+	day := weekdayBit(local.Weekday())
+	prevDay := weekdayBit((local.Weekday() + 6) % 7)
+	for _, r := range s.Rules {
+		if r.Start < r.End {
+			if r.Weekdays&day != 0 && minute >= r.Start && minute < r.End {
+				return true, ""
+			}
+			continue
+		}
+		// Через полночь: хвост вчерашнего интервала или начало сегодняшнего
+		if r.Weekdays&day != 0 && minute >= r.Start {
+			return true, ""
+		}
+		if r.Weekdays&prevDay != 0 && minute < r.End {
+			return true, ""
+		}
+	}
+	return false, ReasonOutsideSchedule
+}
+
+// Бит дня недели в маске: понедельник — 0, воскресенье — 6
+func weekdayBit(d time.Weekday) uint8 {
+	return 1 << ((int(d) + 6) % 7)
+}
+
+// Дни недели ISO (1 — понедельник, 7 — воскресенье) в битовую маску
+func weekdaysMask(days []int) (uint8, error) {
+	var mask uint8
+	for _, d := range days {
+		if d < 1 || d > 7 {
+			return 0, fmt.Errorf("день недели %d вне диапазона 1–7", d)
+		}
+		mask |= 1 << (d - 1)
+	}
+	return mask, nil
+}
+
+func maskWeekdays(mask uint8) []int {
+	days := make([]int, 0, 7)
+	for d := 1; d <= 7; d++ {
+		if mask&(1<<(d-1)) != 0 {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// "HH:MM" в минуты от начала суток; "24:00" — конец суток
+func parseMinutes(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("время %q не в формате HH:MM", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h*60+m > minutesPerDay {
+		return 0, fmt.Errorf("время %q вне суток", s)
+	}
+	return h*60 + m, nil
+}
+
+func formatMinutes(m int) string {
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
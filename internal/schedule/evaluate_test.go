@@ -0,0 +1,62 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleAllows(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	weekdays, _ := weekdaysMask([]int{1, 2, 3, 4, 5})
+	friday, _ := weekdaysMask([]int{5})
+	sch := &Schedule{
+		Location: msk,
+		Rules: []Rule{
+			{Weekdays: weekdays, Start: 8 * 60, End: 20 * 60},
+			// Ночная смена с пятницы на субботу
+			{Weekdays: friday, Start: 22 * 60, End: 6 * 60},
+		},
+		Holidays: map[string]struct{}{"2026-01-07": {}, "2026-01-10": {}},
+	}
+
+	// 2026-01-05 — понедельник, 2026-01-09 — пятница
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 1, day, hour, minute, 0, 0, msk)
+	}
+	tests := []struct {
+		name       string
+		at         time.Time
+		wantOK     bool
+		wantReason string
+	}{
+		{"weekday inside", at(5, 9, 0), true, ""},
+		{"interval start is inclusive", at(5, 8, 0), true, ""},
+		{"interval end is exclusive", at(5, 20, 0), false, ReasonOutsideSchedule},
+		{"weekday before start", at(5, 7, 59), false, ReasonOutsideSchedule},
+		{"weekend", at(11, 12, 0), false, ReasonOutsideSchedule},
+		{"holiday on weekday", at(7, 12, 0), false, ReasonHoliday},
+		{"overnight start on friday", at(9, 23, 0), true, ""},
+		{"overnight tail on saturday", at(10, 5, 59), false, ReasonHoliday},
+		{"overnight before start", at(9, 21, 0), false, ReasonOutsideSchedule},
+		{"overnight start only on rule day", at(8, 23, 0), false, ReasonOutsideSchedule},
+		{"overnight tail only after rule day", at(6, 5, 0), false, ReasonOutsideSchedule},
+		{"local time is used", time.Date(2026, 1, 5, 6, 0, 0, 0, time.UTC), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := sch.Allows(tt.at)
+			if ok != tt.wantOK || reason != tt.wantReason {
+				t.Errorf("Allows(%s) = (%v, %q), want (%v, %q)", tt.at, ok, reason, tt.wantOK, tt.wantReason)
+			}
+		})
+	}
+
+	// Хвост ночного интервала в обычную субботу
+	sch.Holidays = nil
+	if ok, _ := sch.Allows(at(10, 5, 59)); !ok {
+		t.Error("overnight tail on saturday denied")
+	}
+	if ok, _ := sch.Allows(at(10, 6, 0)); ok {
+		t.Error("overnight interval end is not exclusive")
+	}
+}
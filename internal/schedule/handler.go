@@ -0,0 +1,315 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"domofon/internal/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+type ScheduleHandler struct {
+	schedules *ScheduleService
+}
+
+func NewScheduleHandler(s *ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{schedules: s}
+}
+
+// List godoc
+// @Summary Расписания доступа
+// @Tags schedules
+// @Produce json
+// @Success 200 {array} ScheduleResponse
+// @Security BearerAuth
+// @Router /access-schedules [get]
+func (h *ScheduleHandler) List(w http.ResponseWriter, r *http.Request) {
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	list, err := h.schedules.List(r.Context())
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Get godoc
+// @Summary Расписание доступа
+// @Tags schedules
+// @Produce json
+// @Param id path int true "ID расписания"
+// @Success 200 {object} ScheduleResponse
+// @Failure 404 {string} string "Расписание не найдено"
+// @Security BearerAuth
+// @Router /access-schedules/{id} [get]
+func (h *ScheduleHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	sch, err := h.schedules.Get(r.Context(), id)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sch)
+}
+
+// Create godoc
+// @Summary Создать расписание доступа
+// @Description Для ролей из SCHEDULE_ADMIN_ROLES. Правила — дни недели и интервалы местного времени, праздники — дни без доступа.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param input body ScheduleRequest true "Расписание"
+// @Success 201 {object} ScheduleResponse
+// @Failure 400 {string} string "Некорректное расписание"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Security BearerAuth
+// @Router /access-schedules [post]
+func (h *ScheduleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	sch, err := h.schedules.Create(r.Context(), userID, role, req)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sch)
+}
+
+// Update godoc
+// @Summary Изменить расписание доступа
+// @Description Правила и праздники заменяются целиком. Изменение сразу действует на все привязанные ключи.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "ID расписания"
+// @Param input body ScheduleRequest true "Расписание"
+// @Success 200 {object} ScheduleResponse
+// @Failure 400 {string} string "Некорректное расписание"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Расписание не найдено"
+// @Security BearerAuth
+// @Router /access-schedules/{id} [put]
+func (h *ScheduleHandler) Update(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	sch, err := h.schedules.Update(r.Context(), role, id, req)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sch)
+}
+
+// Delete godoc
+// @Summary Удалить расписание доступа
+// @Tags schedules
+// @Param id path int true "ID расписания"
+// @Success 204
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Расписание не найдено"
+// @Failure 409 {string} string "Расписание привязано к ключам или типам жителей"
+// @Security BearerAuth
+// @Router /access-schedules/{id} [delete]
+func (h *ScheduleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.schedules.Delete(r.Context(), role, id); err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetKeySchedule godoc
+// @Summary Привязать расписание к ключу
+// @Description Ключом может быть и гостевой пропуск. schedule_id = null снимает расписание.
+// @Tags schedules
+// @Accept json
+// @Param id path int true "ID ключа"
+// @Param input body SetScheduleRequest true "Расписание"
+// @Success 204
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Ключ или расписание не найдены"
+// @Security BearerAuth
+// @Router /keys/{id}/schedule [put]
+func (h *ScheduleHandler) SetKeySchedule(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	keyID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req SetScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.schedules.SetKeySchedule(r.Context(), role, keyID, req.ScheduleID); err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// KeyAccess godoc
+// @Summary Пускать ли ключ в заданный момент
+// @Description Учитывает активность ключа, valid_from/valid_to, расписание ключа и расписание типа жителя-владельца.
+// @Tags schedules
+// @Produce json
+// @Param id path int true "ID ключа"
+// @Param at query string false "Момент проверки, RFC 3339; по умолчанию — сейчас"
+// @Success 200 {object} Decision
+// @Failure 403 {string} string "Недостаточно прав"
+// @Security BearerAuth
+// @Router /keys/{id}/access [get]
+func (h *ScheduleHandler) KeyAccess(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.schedules.isAdmin(role) {
+		writeScheduleError(w, ErrForbidden)
+		return
+	}
+	keyID, ok := middleware.PathID(w, r, "id")
+	if !ok {
+		return
+	}
+	at := time.Now()
+	if v := r.URL.Query().Get("at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Параметр at должен быть в формате RFC 3339", http.StatusBadRequest)
+			return
+		}
+		at = t
+	}
+	d, err := h.schedules.KeyAllowed(r.Context(), keyID, at)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// ListResidentTypeSchedules godoc
+// @Summary Расписания типов жителей
+// @Tags schedules
+// @Produce json
+// @Success 200 {array} ResidentTypeScheduleResponse
+// @Failure 403 {string} string "Недостаточно прав"
+// @Security BearerAuth
+// @Router /resident-type-schedules [get]
+func (h *ScheduleHandler) ListResidentTypeSchedules(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	list, err := h.schedules.ListResidentTypeSchedules(r.Context(), role)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// SetResidentTypeSchedule godoc
+// @Summary Расписание для типа жителя
+// @Description Например, cleaner: ключи таких жителей работают только по расписанию. schedule_id = null снимает ограничение.
+// @Tags schedules
+// @Accept json
+// @Param type path string true "Тип жителя (resident_type)"
+// @Param input body SetScheduleRequest true "Расписание"
+// @Success 204
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Расписание не найдено"
+// @Security BearerAuth
+// @Router /resident-type-schedules/{type} [put]
+func (h *ScheduleHandler) SetResidentTypeSchedule(w http.ResponseWriter, r *http.Request) {
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req SetScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.schedules.SetResidentTypeSchedule(r.Context(), role, mux.Vars(r)["type"], req.ScheduleID); err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeScheduleError(w http.ResponseWriter, err error) {
+	var vErr *ValidationError
+	switch {
+	case errors.As(err, &vErr):
+		http.Error(w, vErr.Message, http.StatusBadRequest)
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrKeyNotFound), errors.Is(err, ErrScheduleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrScheduleInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrEmptyName), errors.Is(err, ErrNameTooLong), errors.Is(err, ErrInvalidTimezone),
+		errors.Is(err, ErrNoRules), errors.Is(err, ErrTooManyRules), errors.Is(err, ErrTooManyHolidays),
+		errors.Is(err, ErrInvalidResidentType):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,200 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	Create(ctx context.Context, params db.CreateAccessScheduleParams, rules []Rule, holidays []pgtype.Date) (*db.AccessSchedule, error)
+	Update(ctx context.Context, params db.UpdateAccessScheduleParams, rules []Rule, holidays []pgtype.Date) (bool, error)
+	Delete(ctx context.Context, id int64) (bool, error)
+	Get(ctx context.Context, id int64) (*db.AccessSchedule, error)
+	List(ctx context.Context) ([]db.AccessSchedule, error)
+	Rules(ctx context.Context, ids []int64) ([]db.AccessScheduleRule, error)
+	Holidays(ctx context.Context, ids []int64) ([]db.AccessScheduleHoliday, error)
+
+	GetKey(ctx context.Context, keyID int64) (*db.Key, error)
+	SetKeySchedule(ctx context.Context, keyID int64, scheduleID *int64) (bool, error)
+	UserResidentSchedules(ctx context.Context, userID int64) ([]pgtype.Int4, error)
+	ListResidentTypeSchedules(ctx context.Context) ([]db.ResidentTypeSchedule, error)
+	SetResidentTypeSchedule(ctx context.Context, residentType string, scheduleID int64) error
+	DeleteResidentTypeSchedule(ctx context.Context, residentType string) (bool, error)
+}
+
+type ScheduleRepository struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+func NewScheduleRepository(pool *pgxpool.Pool) *ScheduleRepository {
+	return &ScheduleRepository{pool: pool, queries: db.New(pool)}
+}
+
+func (r *ScheduleRepository) Create(ctx context.Context, params db.CreateAccessScheduleParams, rules []Rule, holidays []pgtype.Date) (*db.AccessSchedule, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	sch, err := q.CreateAccessSchedule(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertContents(ctx, q, sch.ID, rules, holidays); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &sch, nil
+}
+
+// Правила и праздники заменяются целиком. false — расписания нет.
+func (r *ScheduleRepository) Update(ctx context.Context, params db.UpdateAccessScheduleParams, rules []Rule, holidays []pgtype.Date) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	n, err := q.UpdateAccessSchedule(ctx, params)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	if err := q.DeleteAccessScheduleRules(ctx, params.ID); err != nil {
+		return false, err
+	}
+	if err := q.DeleteAccessScheduleHolidays(ctx, params.ID); err != nil {
+		return false, err
+	}
+	if err := insertContents(ctx, q, params.ID, rules, holidays); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func insertContents(ctx context.Context, q *db.Queries, scheduleID int32, rules []Rule, holidays []pgtype.Date) error {
+	for _, rule := range rules {
+		err := q.CreateAccessScheduleRule(ctx, db.CreateAccessScheduleRuleParams{
+			ScheduleID:  scheduleID,
+			Weekdays:    int16(rule.Weekdays),
+			StartMinute: int32(rule.Start),
+			EndMinute:   int32(rule.End),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, day := range holidays {
+		if err := q.AddAccessScheduleHoliday(ctx, db.AddAccessScheduleHolidayParams{ScheduleID: scheduleID, Day: day}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Расписание, привязанное к ключу или типу жителя, удалить нельзя — ErrScheduleInUse
+func (r *ScheduleRepository) Delete(ctx context.Context, id int64) (bool, error) {
+	n, err := r.queries.DeleteAccessSchedule(ctx, int32(id))
+	if isForeignKeyViolation(err) {
+		return false, ErrScheduleInUse
+	}
+	return n > 0, err
+}
+
+// nil, nil — расписания нет
+func (r *ScheduleRepository) Get(ctx context.Context, id int64) (*db.AccessSchedule, error) {
+	sch, err := r.queries.GetAccessSchedule(ctx, int32(id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sch, nil
+}
+
+func (r *ScheduleRepository) List(ctx context.Context) ([]db.AccessSchedule, error) {
+	return r.queries.ListAccessSchedules(ctx)
+}
+
+func (r *ScheduleRepository) Rules(ctx context.Context, ids []int64) ([]db.AccessScheduleRule, error) {
+	return r.queries.ListAccessScheduleRules(ctx, toInt32(ids))
+}
+
+func (r *ScheduleRepository) Holidays(ctx context.Context, ids []int64) ([]db.AccessScheduleHoliday, error) {
+	return r.queries.ListAccessScheduleHolidays(ctx, toInt32(ids))
+}
+
+// nil, nil — ключа нет
+func (r *ScheduleRepository) GetKey(ctx context.Context, keyID int64) (*db.Key, error) {
+	k, err := r.queries.GetKey(ctx, int32(keyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// scheduleID nil — снять расписание. false — ключа нет.
+func (r *ScheduleRepository) SetKeySchedule(ctx context.Context, keyID int64, scheduleID *int64) (bool, error) {
+	var sid pgtype.Int4
+	if scheduleID != nil {
+		sid = pgtype.Int4{Int32: int32(*scheduleID), Valid: true}
+	}
+	n, err := r.queries.SetKeySchedule(ctx, db.SetKeyScheduleParams{ID: int32(keyID), ScheduleID: sid})
+	if isForeignKeyViolation(err) {
+		return false, ErrScheduleNotFound
+	}
+	return n > 0, err
+}
+
+func (r *ScheduleRepository) UserResidentSchedules(ctx context.Context, userID int64) ([]pgtype.Int4, error) {
+	return r.queries.ListUserResidentSchedules(ctx, pgtype.Int4{Int32: int32(userID), Valid: true})
+}
+
+func (r *ScheduleRepository) ListResidentTypeSchedules(ctx context.Context) ([]db.ResidentTypeSchedule, error) {
+	return r.queries.ListResidentTypeSchedules(ctx)
+}
+
+func (r *ScheduleRepository) SetResidentTypeSchedule(ctx context.Context, residentType string, scheduleID int64) error {
+	err := r.queries.SetResidentTypeSchedule(ctx, db.SetResidentTypeScheduleParams{
+		ResidentType: residentType,
+		ScheduleID:   int32(scheduleID),
+	})
+	if isForeignKeyViolation(err) {
+		return ErrScheduleNotFound
+	}
+	return err
+}
+
+func (r *ScheduleRepository) DeleteResidentTypeSchedule(ctx context.Context, residentType string) (bool, error) {
+	n, err := r.queries.DeleteResidentTypeSchedule(ctx, residentType)
+	return n > 0, err
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+func toInt32(ids []int64) []int32 {
+	result := make([]int32, len(ids))
+	for i, id := range ids {
+		result[i] = int32(id)
+	}
+	return result
+}
@@ -0,0 +1,428 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"domofon/internal/config"
+	"domofon/internal/db"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ReasonKeyNotFound = "key_not_found"
+	ReasonKeyInactive = "key_inactive"
+	ReasonNotYetValid = "not_yet_valid"
+	ReasonExpired     = "expired"
+
+	maxNameLength         = 128
+	maxResidentTypeLength = 32
+)
+
+var (
+	ErrForbidden           = errors.New("недостаточно прав")
+	ErrScheduleNotFound    = errors.New("расписание не найдено")
+	ErrKeyNotFound         = errors.New("ключ не найден")
+	ErrScheduleInUse       = errors.New("расписание привязано к ключам или типам жителей")
+	ErrEmptyName           = errors.New("название не может быть пустым")
+	ErrNameTooLong         = errors.New("название слишком длинное")
+	ErrInvalidTimezone     = errors.New("неизвестный часовой пояс")
+	ErrNoRules             = errors.New("в расписании должно быть хотя бы одно правило")
+	ErrTooManyRules        = errors.New("слишком много правил")
+	ErrTooManyHolidays     = errors.New("слишком много праздничных дней")
+	ErrInvalidResidentType = errors.New("некорректный тип жителя")
+)
+
+type ScheduleService struct {
+	repo Repository
+	cfg  *config.ScheduleConfig
+}
+
+func NewScheduleService(repo Repository, cfg *config.ScheduleConfig) *ScheduleService {
+	return &ScheduleService{repo: repo, cfg: cfg}
+}
+
+func (s *ScheduleService) isAdmin(role string) bool {
+	return slices.Contains(s.cfg.AdminRoles, role)
+}
+
+func (s *ScheduleService) Create(ctx context.Context, userID int64, role string, req ScheduleRequest) (*ScheduleResponse, error) {
+	if !s.isAdmin(role) {
+		return nil, ErrForbidden
+	}
+	v, err := s.validate(req)
+	if err != nil {
+		return nil, err
+	}
+	sch, err := s.repo.Create(ctx, db.CreateAccessScheduleParams{
+		Name:      v.name,
+		Timezone:  v.timezone,
+		CreatedBy: pgtype.Int4{Int32: int32(userID), Valid: true},
+	}, v.rules, v.holidays)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Int64("user_id", userID).Int32("schedule_id", sch.ID).Msg("[schedule] Создано расписание доступа")
+	return s.Get(ctx, int64(sch.ID))
+}
+
+func (s *ScheduleService) Update(ctx context.Context, role string, id int64, req ScheduleRequest) (*ScheduleResponse, error) {
+	if !s.isAdmin(role) {
+		return nil, ErrForbidden
+	}
+	v, err := s.validate(req)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.Update(ctx, db.UpdateAccessScheduleParams{
+		ID:        int32(id),
+		Name:      v.name,
+		Timezone:  v.timezone,
+		UpdatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+	}, v.rules, v.holidays)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *ScheduleService) Delete(ctx context.Context, role string, id int64) error {
+	if !s.isAdmin(role) {
+		return ErrForbidden
+	}
+	ok, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	log.Info().Int64("schedule_id", id).Msg("[schedule] Расписание доступа удалено")
+	return nil
+}
+
+// Расписания видны всем: жители выбирают их для гостевых пропусков
+func (s *ScheduleService) Get(ctx context.Context, id int64) (*ScheduleResponse, error) {
+	sch, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sch == nil {
+		return nil, ErrScheduleNotFound
+	}
+	list, err := s.responses(ctx, []db.AccessSchedule{*sch})
+	if err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// Расписания по списку id; несуществующие пропускаются
+func (s *ScheduleService) ByIDs(ctx context.Context, ids []int64) ([]ScheduleResponse, error) {
+	rows := make([]db.AccessSchedule, 0, len(ids))
+	for _, id := range ids {
+		sch, err := s.repo.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if sch != nil {
+			rows = append(rows, *sch)
+		}
+	}
+	return s.responses(ctx, rows)
+}
+
+func (s *ScheduleService) List(ctx context.Context) ([]ScheduleResponse, error) {
+	rows, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.responses(ctx, rows)
+}
+
+// scheduleID nil — снять расписание с ключа
+func (s *ScheduleService) SetKeySchedule(ctx context.Context, role string, keyID int64, scheduleID *int64) error {
+	if !s.isAdmin(role) {
+		return ErrForbidden
+	}
+	ok, err := s.repo.SetKeySchedule(ctx, keyID, scheduleID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func (s *ScheduleService) ListResidentTypeSchedules(ctx context.Context, role string) ([]ResidentTypeScheduleResponse, error) {
+	if !s.isAdmin(role) {
+		return nil, ErrForbidden
+	}
+	rows, err := s.repo.ListResidentTypeSchedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ResidentTypeScheduleResponse, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, ResidentTypeScheduleResponse{ResidentType: row.ResidentType, ScheduleID: int64(row.ScheduleID)})
+	}
+	return result, nil
+}
+
+// Расписание для типа жителя (resident_type в apartment_residents). scheduleID nil — снять.
+func (s *ScheduleService) SetResidentTypeSchedule(ctx context.Context, role, residentType string, scheduleID *int64) error {
+	if !s.isAdmin(role) {
+		return ErrForbidden
+	}
+	residentType = strings.TrimSpace(residentType)
+	if residentType == "" || len(residentType) > maxResidentTypeLength {
+		return ErrInvalidResidentType
+	}
+	if scheduleID == nil {
+		_, err := s.repo.DeleteResidentTypeSchedule(ctx, residentType)
+		return err
+	}
+	return s.repo.SetResidentTypeSchedule(ctx, residentType, *scheduleID)
+}
+
+// KeyAllowed отвечает, пускать ли ключ в момент at: ключ включён, at попадает в
+// valid_from/valid_to и в расписание. Расписание ключа важнее расписания типа жителя;
+// тип жителя ограничивает, только если у владельца нет квартиры без ограничений.
+// Владелец гостевого пропуска — выдавший его житель: без своего расписания пропуск
+// действует по расписаниям его типа жителя.
+func (s *ScheduleService) KeyAllowed(ctx context.Context, keyID int64, at time.Time) (*Decision, error) {
+	d := &Decision{KeyID: keyID, At: at}
+	key, err := s.repo.GetKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case key == nil:
+		d.Reason = ReasonKeyNotFound
+		return d, nil
+	case !key.IsActive.Bool:
+		d.Reason = ReasonKeyInactive
+		return d, nil
+	case key.ValidFrom.Valid && key.ValidFrom.Time.After(at):
+		d.Reason = ReasonNotYetValid
+		return d, nil
+	case key.ValidTo.Valid && !key.ValidTo.Time.After(at):
+		d.Reason = ReasonExpired
+		return d, nil
+	}
+
+	var ids []int64
+	if key.ScheduleID.Valid {
+		ids = []int64{int64(key.ScheduleID.Int32)}
+	} else if key.OwnerID.Valid {
+		if ids, err = s.ResidentScheduleIDs(ctx, int64(key.OwnerID.Int32)); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		d.Allowed = true
+		return d, nil
+	}
+
+	schedules, err := s.load(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		sch, ok := schedules[id]
+		if !ok {
+			continue
+		}
+		allowed, reason := sch.Allows(at)
+		if d.ScheduleID == nil || allowed {
+			d.ScheduleID = &sch.ID
+			d.Reason = reason
+		}
+		if allowed {
+			d.Allowed = true
+			return d, nil
+		}
+	}
+	return d, nil
+}
+
+// Расписания, действующие на пользователя по типу жителя; пусто — ограничений нет
+func (s *ScheduleService) ResidentScheduleIDs(ctx context.Context, userID int64) ([]int64, error) {
+	rows, err := s.repo.UserResidentSchedules(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(rows))
+	for _, id := range rows {
+		if !id.Valid {
+			return nil, nil
+		}
+		if !slices.Contains(ids, int64(id.Int32)) {
+			ids = append(ids, int64(id.Int32))
+		}
+	}
+	return ids, nil
+}
+
+func (s *ScheduleService) load(ctx context.Context, ids []int64) (map[int64]*Schedule, error) {
+	result := make(map[int64]*Schedule, len(ids))
+	for _, id := range ids {
+		sch, err := s.repo.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if sch == nil {
+			continue
+		}
+		loc, err := time.LoadLocation(sch.Timezone)
+		if err != nil {
+			log.Error().Err(err).Int32("schedule_id", sch.ID).Msg("[schedule] Неизвестный часовой пояс, используется UTC")
+			loc = time.UTC
+		}
+		result[id] = &Schedule{ID: id, Name: sch.Name, Location: loc, Holidays: map[string]struct{}{}}
+	}
+	rules, err := s.repo.Rules(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if sch, ok := result[int64(r.ScheduleID)]; ok {
+			sch.Rules = append(sch.Rules, Rule{Weekdays: uint8(r.Weekdays), Start: int(r.StartMinute), End: int(r.EndMinute)})
+		}
+	}
+	holidays, err := s.repo.Holidays(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range holidays {
+		if sch, ok := result[int64(h.ScheduleID)]; ok {
+			sch.Holidays[h.Day.Time.Format(dateLayout)] = struct{}{}
+		}
+	}
+	return result, nil
+}
+
+func (s *ScheduleService) responses(ctx context.Context, rows []db.AccessSchedule) ([]ScheduleResponse, error) {
+	ids := make([]int64, len(rows))
+	byID := make(map[int64]*ScheduleResponse, len(rows))
+	result := make([]ScheduleResponse, len(rows))
+	for i, row := range rows {
+		ids[i] = int64(row.ID)
+		result[i] = ScheduleResponse{
+			ID:        int64(row.ID),
+			Name:      row.Name,
+			Timezone:  row.Timezone,
+			Rules:     []RuleDTO{},
+			Holidays:  []string{},
+			CreatedAt: row.CreatedAt.Time,
+			UpdatedAt: row.UpdatedAt.Time,
+		}
+		byID[ids[i]] = &result[i]
+	}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	rules, err := s.repo.Rules(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if resp, ok := byID[int64(r.ScheduleID)]; ok {
+			resp.Rules = append(resp.Rules, RuleDTO{
+				Weekdays: maskWeekdays(uint8(r.Weekdays)),
+				Start:    formatMinutes(int(r.StartMinute)),
+				End:      formatMinutes(int(r.EndMinute)),
+			})
+		}
+	}
+	holidays, err := s.repo.Holidays(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range holidays {
+		if resp, ok := byID[int64(h.ScheduleID)]; ok {
+			resp.Holidays = append(resp.Holidays, h.Day.Time.Format(dateLayout))
+		}
+	}
+	return result, nil
+}
+
+type validSchedule struct {
+	name     string
+	timezone string
+	rules    []Rule
+	holidays []pgtype.Date
+}
+
+func (s *ScheduleService) validate(req ScheduleRequest) (*validSchedule, error) {
+	v := &validSchedule{name: strings.TrimSpace(req.Name), timezone: strings.TrimSpace(req.Timezone)}
+	switch {
+	case v.name == "":
+		return nil, ErrEmptyName
+	case utf8.RuneCountInString(v.name) > maxNameLength:
+		return nil, ErrNameTooLong
+	case len(req.Rules) == 0:
+		return nil, ErrNoRules
+	case len(req.Rules) > s.cfg.MaxRules:
+		return nil, ErrTooManyRules
+	case len(req.Holidays) > s.cfg.MaxHolidays:
+		return nil, ErrTooManyHolidays
+	}
+	if v.timezone == "" {
+		v.timezone = s.cfg.DefaultTimezone
+	}
+	if _, err := time.LoadLocation(v.timezone); err != nil {
+		return nil, ErrInvalidTimezone
+	}
+
+	for i, r := range req.Rules {
+		mask, err := weekdaysMask(r.Weekdays)
+		if err == nil && mask == 0 {
+			err = errors.New("не указаны дни недели")
+		}
+		if err != nil {
+			return nil, &ValidationError{fmt.Sprintf("правило %d: %v", i+1, err)}
+		}
+		start, err := parseMinutes(r.Start)
+		if err != nil {
+			return nil, &ValidationError{fmt.Sprintf("правило %d: %v", i+1, err)}
+		}
+		end, err := parseMinutes(r.End)
+		if err != nil {
+			return nil, &ValidationError{fmt.Sprintf("правило %d: %v", i+1, err)}
+		}
+		if start == minutesPerDay || start == end {
+			return nil, &ValidationError{fmt.Sprintf("правило %d: пустой интервал %s–%s", i+1, r.Start, r.End)}
+		}
+		v.rules = append(v.rules, Rule{Weekdays: mask, Start: start, End: end})
+	}
+	for _, h := range req.Holidays {
+		day, err := time.Parse(dateLayout, h)
+		if err != nil {
+			return nil, &ValidationError{fmt.Sprintf("дата %q не в формате YYYY-MM-DD", h)}
+		}
+		v.holidays = append(v.holidays, pgtype.Date{Time: day, Valid: true})
+	}
+	return v, nil
+}
+
+// Ошибка в правилах или праздниках расписания; текст — для пользователя
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,75 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"domofon/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Шлюз с повторами и переключением на резервного провайдера.
+// Реализует verification.SMSSender.
+type Gateway struct {
+	providers  []Provider
+	retries    int
+	retryDelay time.Duration
+	timeout    time.Duration
+}
+
+func NewGateway(providers []Provider, retries int, retryDelay, timeout time.Duration) *Gateway {
+	if retries < 1 {
+		retries = 1
+	}
+	return &Gateway{providers: providers, retries: retries, retryDelay: retryDelay, timeout: timeout}
+}
+
+func NewGatewayFromConfig(cfg *config.SMSConfig) (*Gateway, error) {
+	providers, err := NewProviders(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewGateway(providers, cfg.Retries, cfg.RetryDelay, cfg.Timeout), nil
+}
+
+func (g *Gateway) SendSMS(toPhone, message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout*time.Duration(g.retries*len(g.providers)))
+	defer cancel()
+	return g.Send(ctx, toPhone, message)
+}
+
+// Пробует провайдеров по очереди. Временные ошибки повторяются у того же
+// провайдера до retries раз, постоянные — сразу переключают на следующего.
+func (g *Gateway) Send(ctx context.Context, toPhone, message string) error {
+	if len(g.providers) == 0 {
+		return ErrNoProviders
+	}
+
+	var errs []error
+	for _, p := range g.providers {
+		for attempt := 1; attempt <= g.retries; attempt++ {
+			err := p.Send(ctx, toPhone, message)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+			log.Warn().
+				Err(err).
+				Str("provider", p.Name()).
+				Int("attempt", attempt).
+				Msg("[sms] Не удалось отправить SMS")
+
+			if !isTemporary(err) || attempt == g.retries {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return errors.Join(append(errs, ctx.Err())...)
+			case <-time.After(g.retryDelay * time.Duration(attempt)):
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,17 @@
+package sms
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Провайдер для разработки: ничего не отправляет, пишет сообщение в лог
+type MockProvider struct{}
+
+func (p *MockProvider) Name() string { return "mock" }
+
+func (p *MockProvider) Send(ctx context.Context, toPhone, message string) error {
+	log.Info().Str("to", toPhone).Str("msg", message).Msg("[MOCK SMS]")
+	return nil
+}
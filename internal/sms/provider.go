@@ -0,0 +1,79 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"domofon/internal/config"
+)
+
+// Провайдер SMS: конкретный шлюз (sms.ru, smsc.ru, ...)
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, toPhone, message string) error
+}
+
+// Ошибка, которую вернул шлюз. Temporary — имеет смысл повторить позже
+// (лимиты, перегрузка, сетевые сбои); иначе повтор у того же провайдера бесполезен.
+type ProviderError struct {
+	Provider  string
+	Code      int
+	Message   string
+	Temporary bool
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: код %d: %s", e.Provider, e.Code, e.Message)
+}
+
+var ErrNoProviders = errors.New("не настроено ни одного SMS-провайдера")
+
+func isTemporary(err error) bool {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe.Temporary
+	}
+	// Сетевые ошибки и таймауты считаем временными
+	return true
+}
+
+// Ошибка по HTTP-статусу, если тело ответа разобрать не удалось
+func statusError(provider string, resp *http.Response) *ProviderError {
+	return &ProviderError{
+		Provider:  provider,
+		Code:      resp.StatusCode,
+		Message:   resp.Status,
+		Temporary: resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+	}
+}
+
+// Собирает провайдеров в порядке из SMS_PROVIDERS
+func NewProviders(cfg *config.SMSConfig) ([]Provider, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	var providers []Provider
+	for _, name := range cfg.Providers {
+		switch name {
+		case "smsru":
+			if cfg.SMSRuAPIKey == "" {
+				return nil, fmt.Errorf("smsru: не задан SMSRU_API_KEY")
+			}
+			providers = append(providers, NewSMSRu(client, cfg.SMSRuAPIURL, cfg.SMSRuAPIKey, cfg.From))
+		case "smsc":
+			if cfg.SMSCLogin == "" || cfg.SMSCPassword == "" {
+				return nil, fmt.Errorf("smsc: не заданы SMSC_LOGIN/SMSC_PASSWORD")
+			}
+			providers = append(providers, NewSMSC(client, cfg.SMSCAPIURL, cfg.SMSCLogin, cfg.SMSCPassword, cfg.From))
+		case "mock":
+			providers = append(providers, &MockProvider{})
+		default:
+			return nil, fmt.Errorf("неизвестный SMS-провайдер: %s", name)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, ErrNoProviders
+	}
+	return providers, nil
+}
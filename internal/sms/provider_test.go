@@ -0,0 +1,121 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Провайдер против заглушки, отвечающей status и body
+func sendTo(t *testing.T, status int, body string, newProvider func(client *http.Client, url string) Provider) error {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	return newProvider(srv.Client(), srv.URL).Send(context.Background(), "+79001234567", "Код подтверждения: 123456")
+}
+
+func TestSMSRuResponses(t *testing.T) {
+	smsru := func(client *http.Client, url string) Provider { return NewSMSRu(client, url, "key", "") }
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		wantErr       bool
+		wantCode      int
+		wantTemporary bool
+	}{
+		{"sent", 200, `{"status":"OK","status_code":100,"sms":{"79001234567":{"status":"OK","status_code":100}}}`, false, 0, false},
+		{"bad api key", 200, `{"status":"ERROR","status_code":200,"status_text":"Неправильный api_id"}`, true, 200, false},
+		{"no money", 200, `{"status":"ERROR","status_code":201,"status_text":"Не хватает средств"}`, true, 201, false},
+		{"service unavailable", 200, `{"status":"ERROR","status_code":220,"status_text":"Сервис временно недоступен"}`, true, 220, true},
+		{"per-number error", 200, `{"status":"OK","status_code":100,"sms":{"79001234567":{"status":"ERROR","status_code":207,"status_text":"На этот номер нельзя отправлять"}}}`, true, 207, false},
+		{"daily number limit fails over", 200, `{"status":"OK","status_code":100,"sms":{"79001234567":{"status":"ERROR","status_code":230}}}`, true, 230, false},
+		{"minute number limit", 200, `{"status":"OK","status_code":100,"sms":{"79001234567":{"status":"ERROR","status_code":233}}}`, true, 233, true},
+		{"daily same message limit fails over", 200, `{"status":"ERROR","status_code":232}`, true, 232, false},
+		{"server error without body", 502, `Bad Gateway`, true, 502, true},
+		{"garbage with 200", 200, `<html>`, true, 200, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkProviderError(t, sendTo(t, tt.status, tt.body, smsru), tt.wantErr, tt.wantCode, tt.wantTemporary)
+		})
+	}
+}
+
+func TestSMSCResponses(t *testing.T) {
+	smsc := func(client *http.Client, url string) Provider { return NewSMSC(client, url, "login", "psw", "") }
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		wantErr       bool
+		wantCode      int
+		wantTemporary bool
+	}{
+		{"sent", 200, `{"id":42,"cnt":1}`, false, 0, false},
+		{"bad credentials", 200, `{"error":"authorise error","error_code":2}`, true, 2, false},
+		{"no money", 200, `{"error":"no money","error_code":3}`, true, 3, false},
+		{"ip blocked", 200, `{"error":"ip blocked","error_code":4}`, true, 4, true},
+		{"too many requests", 200, `{"error":"too many concurrent requests","error_code":9}`, true, 9, true},
+		{"error text only", 200, `{"error":"something"}`, true, 0, false},
+		{"server error without body", 503, ``, true, 503, true},
+		{"rate limited without body", 429, ``, true, 429, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkProviderError(t, sendTo(t, tt.status, tt.body, smsc), tt.wantErr, tt.wantCode, tt.wantTemporary)
+		})
+	}
+}
+
+func checkProviderError(t *testing.T, err error, wantErr bool, wantCode int, wantTemporary bool) {
+	t.Helper()
+	if !wantErr {
+		if err != nil {
+			t.Fatalf("Send() = %v, want nil", err)
+		}
+		return
+	}
+	var pe *ProviderError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Send() = %v, want *ProviderError", err)
+	}
+	if pe.Code != wantCode || pe.Temporary != wantTemporary {
+		t.Errorf("ProviderError{Code: %d, Temporary: %v}, want {Code: %d, Temporary: %v}", pe.Code, pe.Temporary, wantCode, wantTemporary)
+	}
+}
+
+type fakeProvider struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Send(context.Context, string, string) error {
+	p.calls++
+	return p.err
+}
+
+// Дневной лимит не повторяется у того же провайдера, а сразу уходит резервному
+func TestGatewayFailsOverOnPermanentError(t *testing.T) {
+	primary := &fakeProvider{name: "smsru", err: &ProviderError{Provider: "smsru", Code: 230, Temporary: smsRuTemporary[230]}}
+	backup := &fakeProvider{name: "smsc"}
+	g := NewGateway([]Provider{primary, backup}, 3, 0, 0)
+
+	if err := g.Send(context.Background(), "+79001234567", "код"); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+	if primary.calls != 1 || backup.calls != 1 {
+		t.Errorf("calls = %d/%d, want 1/1", primary.calls, backup.calls)
+	}
+}
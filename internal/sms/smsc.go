@@ -0,0 +1,79 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// smsc.ru, ответ в JSON (fmt=3)
+type SMSC struct {
+	client   *http.Client
+	apiURL   string
+	login    string
+	password string
+	from     string
+}
+
+func NewSMSC(client *http.Client, apiURL, login, password, from string) *SMSC {
+	return &SMSC{client: client, apiURL: apiURL, login: login, password: password, from: from}
+}
+
+func (p *SMSC) Name() string { return "smsc" }
+
+type smscResponse struct {
+	ID        int64  `json:"id"`
+	Count     int    `json:"cnt"`
+	Error     string `json:"error"`
+	ErrorCode int    `json:"error_code"`
+}
+
+// Коды smsc.ru, при которых есть смысл повторить
+var smscTemporary = map[int]bool{
+	4: true, // IP временно заблокирован
+	9: true, // слишком много одновременных запросов
+}
+
+func (p *SMSC) Send(ctx context.Context, toPhone, message string) error {
+	form := url.Values{}
+	form.Set("login", p.login)
+	form.Set("psw", p.password)
+	form.Set("phones", strings.TrimPrefix(toPhone, "+"))
+	form.Set("mes", message)
+	form.Set("charset", "utf-8")
+	form.Set("fmt", "3")
+	if p.from != "" {
+		form.Set("sender", p.from)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body smscResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return statusError(p.Name(), resp)
+		}
+		return &ProviderError{Provider: p.Name(), Code: resp.StatusCode, Message: "некорректный ответ: " + err.Error(), Temporary: true}
+	}
+	if body.ErrorCode != 0 || body.Error != "" {
+		return &ProviderError{
+			Provider:  p.Name(),
+			Code:      body.ErrorCode,
+			Message:   body.Error,
+			Temporary: smscTemporary[body.ErrorCode],
+		}
+	}
+	return nil
+}
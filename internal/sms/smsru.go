@@ -0,0 +1,97 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// sms.ru, JSON API. Ключ передаётся в теле POST, а не в строке запроса,
+// чтобы не попадать в логи прокси.
+type SMSRu struct {
+	client *http.Client
+	apiURL string
+	apiKey string
+	from   string
+}
+
+func NewSMSRu(client *http.Client, apiURL, apiKey, from string) *SMSRu {
+	return &SMSRu{client: client, apiURL: apiURL, apiKey: apiKey, from: from}
+}
+
+func (p *SMSRu) Name() string { return "smsru" }
+
+type smsRuStatus struct {
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code"`
+	StatusText string `json:"status_text"`
+}
+
+type smsRuResponse struct {
+	smsRuStatus
+	SMS map[string]smsRuStatus `json:"sms"`
+}
+
+// Коды sms.ru, при которых есть смысл повторить: перегрузка, минутные лимиты,
+// внутренние ошибки. Дневные лимиты (230 — сообщений на номер, 232 — одинаковых
+// сообщений) до конца суток не пройдут: шлюз сразу переключится на резервного провайдера.
+var smsRuTemporary = map[int]bool{
+	220: true, // сервис временно недоступен
+	231: true, // превышен лимит одинаковых сообщений в минуту
+	233: true, // превышен лимит сообщений на номер в минуту
+	500: true, // ошибка на сервере
+}
+
+func (p *SMSRu) Send(ctx context.Context, toPhone, message string) error {
+	form := url.Values{}
+	form.Set("api_id", p.apiKey)
+	form.Set("to", strings.TrimPrefix(toPhone, "+"))
+	form.Set("msg", message)
+	form.Set("json", "1")
+	if p.from != "" {
+		form.Set("from", p.from)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body smsRuResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return statusError(p.Name(), resp)
+		}
+		return &ProviderError{Provider: p.Name(), Code: resp.StatusCode, Message: "некорректный ответ: " + err.Error(), Temporary: true}
+	}
+
+	// Ошибка всего запроса (неверный ключ, нет денег и т.п.)
+	if body.Status != "OK" {
+		return p.providerError(body.smsRuStatus)
+	}
+	// Ошибка по конкретному номеру
+	for _, st := range body.SMS {
+		if st.Status != "OK" {
+			return p.providerError(st)
+		}
+	}
+	return nil
+}
+
+func (p *SMSRu) providerError(st smsRuStatus) *ProviderError {
+	return &ProviderError{
+		Provider:  p.Name(),
+		Code:      st.StatusCode,
+		Message:   st.StatusText,
+		Temporary: smsRuTemporary[st.StatusCode],
+	}
+}
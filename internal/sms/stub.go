@@ -0,0 +1,84 @@
+package sms
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Локальная заглушка SMS-шлюзов для разработки и ручного тестирования.
+// Отвечает в форматах sms.ru (/sms/send) и smsc.ru (/sys/send.php),
+// отправленные сообщения доступны на GET /messages.
+type StubServer struct {
+	// Если не 0 — шлюз отвечает этим кодом ошибки (например 220 для sms.ru, 9 для smsc)
+	FailSMSRu int
+	FailSMSC  int
+
+	mu       sync.Mutex
+	messages []StubMessage
+}
+
+type StubMessage struct {
+	Provider string    `json:"provider"`
+	To       string    `json:"to"`
+	Text     string    `json:"text"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+func (s *StubServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sms/send", s.handleSMSRu)
+	mux.HandleFunc("/sys/send.php", s.handleSMSC)
+	mux.HandleFunc("/messages", s.handleMessages)
+	return mux
+}
+
+func (s *StubServer) Messages() []StubMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StubMessage(nil), s.messages...)
+}
+
+func (s *StubServer) record(provider, to, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, StubMessage{Provider: provider, To: to, Text: text, SentAt: time.Now()})
+}
+
+func (s *StubServer) handleSMSRu(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.FormValue("api_id") == "" {
+		json.NewEncoder(w).Encode(smsRuStatus{Status: "ERROR", StatusCode: 200, StatusText: "Неправильный api_id"})
+		return
+	}
+	if s.FailSMSRu != 0 {
+		json.NewEncoder(w).Encode(smsRuStatus{Status: "ERROR", StatusCode: s.FailSMSRu, StatusText: "stub failure"})
+		return
+	}
+	to := r.FormValue("to")
+	s.record("smsru", to, r.FormValue("msg"))
+	json.NewEncoder(w).Encode(smsRuResponse{
+		smsRuStatus: smsRuStatus{Status: "OK", StatusCode: 100},
+		SMS:         map[string]smsRuStatus{to: {Status: "OK", StatusCode: 100}},
+	})
+}
+
+func (s *StubServer) handleSMSC(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.FormValue("login") == "" || r.FormValue("psw") == "" {
+		json.NewEncoder(w).Encode(smscResponse{Error: "authorise error", ErrorCode: 2})
+		return
+	}
+	if s.FailSMSC != 0 {
+		json.NewEncoder(w).Encode(smscResponse{Error: "stub failure", ErrorCode: s.FailSMSC})
+		return
+	}
+	s.record("smsc", r.FormValue("phones"), r.FormValue("mes"))
+	json.NewEncoder(w).Encode(smscResponse{ID: time.Now().UnixNano(), Count: 1})
+}
+
+func (s *StubServer) handleMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Messages())
+}
@@ -2,10 +2,13 @@ package user
 
 import (
     "encoding/json"
+    "errors"
     "net/http"
     "github.com/gorilla/mux"
     "domofon/internal/db"
 		"domofon/internal/middleware"
+		"domofon/internal/password"
+		"domofon/internal/phone"
 		"github.com/jackc/pgx/v5/pgtype"
     "strconv"
 		"strings"
@@ -27,13 +30,13 @@ func toPgText(s string) pgtype.Text {
 }
 
 type CreateUserRequest struct {
-	Username     string `json:"username"`
-	PasswordHash string `json:"password_hash"`
-	Email        string `json:"email"`
-	Phone        string `json:"phone"`
-	Role         string `json:"role"`
-	FirstName    string `json:"first_name"`
-	LastName     string `json:"last_name"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	Role      string `json:"role"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
 }
 
 type UserHandler struct {
@@ -68,32 +71,54 @@ func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 // @Tags         users
 // @Accept       json
 // @Produce      json
+// @Description  Только для администраторов (USER_ADMIN_ROLES). Без role создаётся resident. Жители регистрируются через /auth/register.
 // @Param        user  body      CreateUserRequest  true  "Новый пользователь"
 // @Success      201   {object}  db.User
 // @Failure      400   {string}  string "Bad request"
+// @Failure      401   {string}  string "Неавторизован"
+// @Failure      403   {string}  string "Недостаточно прав"
 // @Failure      500   {string}  string "Internal error"
+// @Security     BearerAuth
 // @Router       /users [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	_, role, ok := middleware.UserAndRole(r)
+	if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+	}
 
 	var req CreateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 	}
+	normPhone, err := phone.Normalize(req.Phone)
+	if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+	}
 
 	params := db.CreateUserParams{
     Username:     req.Username,
-    PasswordHash: req.PasswordHash,
     Email:        req.Email,
-    Phone:        req.Phone,
+    Phone:        normPhone,
     Role:         toPgText(req.Role),
     FirstName:    toPgText(req.FirstName),
     LastName:     toPgText(req.LastName),
 	}
 
 
-	user, err := h.service.CreateUser(ctx, params)
+	user, err := h.service.CreateUser(ctx, role, params, req.Password)
+	if errors.Is(err, ErrForbidden) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+	}
+	var policyErr *password.PolicyError
+	if errors.As(err, &policyErr) {
+			http.Error(w, policyErr.Error(), http.StatusBadRequest)
+			return
+	}
 	if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -106,6 +131,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 // UpdateUser godoc
 // @Summary      Обновить пользователя
+// @Description  Свой профиль может менять каждый, чужой и роль — только администратор (USER_ADMIN_ROLES). Номер меняется только через /auth/change-phone, email — через POST /users/me/email с подтверждением по ссылке.
 // @Tags         users
 // @Accept       json
 // @Produce      json
@@ -113,11 +139,17 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 // @Param        user  body      db.UpdateUserParams  true  "Данные пользователя"
 // @Success      200   {object}  db.User
 // @Failure      400   {string}  string "Bad request"
+// @Failure      403   {string}  string "Недостаточно прав"
 // @Failure      500   {string}  string "Internal error"
 // @Security     BearerAuth
 // @Router       /users/{id} [put]
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
     ctx := r.Context()
+    callerID, callerRole, ok := middleware.UserAndRole(r)
+    if !ok {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return
+    }
     vars := mux.Vars(r)
     idStr := vars["id"]
     id, err := strconv.Atoi(idStr)
@@ -131,7 +163,11 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
         return
     }
     params.ID = int32(id) // обязательно подставить id!
-    user, err := h.service.UpdateUser(ctx, params)
+    user, err := h.service.UpdateUser(ctx, callerID, callerRole, params)
+    if errors.Is(err, ErrForbidden) {
+        http.Error(w, err.Error(), http.StatusForbidden)
+        return
+    }
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
@@ -143,15 +179,22 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 // DeleteUser godoc
 // @Summary      Удалить пользователя
+// @Description  Доступно администратору (USER_ADMIN_ROLES). Свой аккаунт можно удалить, только если включён USER_ALLOW_SELF_DELETE.
 // @Tags         users
 // @Param        id    path      int  true  "ID пользователя"
 // @Success      204   {string}  string "No Content"
 // @Failure      400   {string}  string "Bad request"
+// @Failure      403   {string}  string "Недостаточно прав"
 // @Failure      500   {string}  string "Internal error"
 // @Security     BearerAuth
 // @Router       /users/{id} [delete]
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
     ctx := r.Context()
+    callerID, callerRole, ok := middleware.UserAndRole(r)
+    if !ok {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return
+    }
     vars := mux.Vars(r)
     idStr := vars["id"]
     id, err := strconv.Atoi(idStr)
@@ -159,7 +202,12 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "invalid id", http.StatusBadRequest)
         return
     }
-    if err := h.service.DeleteUser(ctx, int32(id)); err != nil {
+    err = h.service.DeleteUser(ctx, callerID, callerRole, int32(id))
+    if errors.Is(err, ErrForbidden) {
+        http.Error(w, err.Error(), http.StatusForbidden)
+        return
+    }
+    if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
@@ -366,12 +414,12 @@ func (h *UserHandler) UpdateFullName(w http.ResponseWriter, r *http.Request) {
 // UpdateEmail godoc
 //
 // @Summary      Смена email текущего пользователя
-// @Description  Обновляет email авторизованного пользователя
+// @Description  Отправляет ссылку подтверждения на новый адрес. Email меняется после перехода по ссылке.
 // @Tags         user
 // @Accept       json
 // @Produce      json
 // @Param        request body struct{Email string `json:"email"`} true "Новый email"
-// @Success      200 "Email успешно обновлён"
+// @Success      202 "Письмо с подтверждением отправлено"
 // @Failure      400 {string} string "Некорректный email"
 // @Failure      401 {string} string "Пользователь не авторизован"
 // @Failure      409 {string} string "Email уже занят"
@@ -408,5 +456,5 @@ if !ok {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusAccepted)
 }
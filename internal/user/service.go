@@ -2,16 +2,44 @@ package user
 
 import (
     "context"
+    "domofon/internal/config"
     "domofon/internal/db"
+		"errors"
 		"fmt"
+		"slices"
+
+		"github.com/jackc/pgx/v5/pgtype"
 )
 
+var ErrForbidden = errors.New("недостаточно прав")
+
+// Роль, с которой создаются пользователи, если её не назначил администратор
+const defaultRole = "resident"
+
+// Подтверждение нового email ссылкой из письма (auth.AuthService)
+type EmailConfirmer interface {
+    RequestEmailConfirmation(ctx context.Context, userID int64, email string) error
+}
+
+// Политика и хеширование паролей (auth.AuthService)
+type PasswordHasher interface {
+    ValidatePassword(newPassword, phone, username string) error
+    HashPassword(password string) (string, error)
+}
+
 type UserService struct {
-    repo UserRepository
+    repo      UserRepository
+    emails    EmailConfirmer
+    passwords PasswordHasher
+    cfg       *config.UserConfig
+}
+
+func NewUserService(repo UserRepository, emails EmailConfirmer, passwords PasswordHasher, cfg *config.UserConfig) *UserService {
+    return &UserService{repo: repo, emails: emails, passwords: passwords, cfg: cfg}
 }
 
-func NewUserService(repo UserRepository) *UserService {
-    return &UserService{repo: repo}
+func (s *UserService) isAdmin(role string) bool {
+    return slices.Contains(s.cfg.AdminRoles, role)
 }
 
 // Получить всех пользователей
@@ -19,18 +47,50 @@ func (s *UserService) GetUsers(ctx context.Context) ([]db.User, error) {
     return s.repo.GetUsers(ctx)
 }
 
-// Создать пользователя
-func (s *UserService) CreateUser(ctx context.Context, params db.CreateUserParams) (db.User, error) {
+// Создать пользователя. Доступно только администратору: жители регистрируются
+// сами через /auth/register с подтверждением номера. Без роли создаётся resident.
+// Пароль проверяется по политике (ошибка — *password.PolicyError) и сохраняется только в виде хеша.
+func (s *UserService) CreateUser(ctx context.Context, callerRole string, params db.CreateUserParams, password string) (db.User, error) {
+    if !s.isAdmin(callerRole) {
+        return db.User{}, ErrForbidden
+    }
+    if !params.Role.Valid {
+        params.Role = pgtype.Text{String: defaultRole, Valid: true}
+    }
+    if err := s.passwords.ValidatePassword(password, params.Phone, params.Username); err != nil {
+        return db.User{}, err
+    }
+    hash, err := s.passwords.HashPassword(password)
+    if err != nil {
+        return db.User{}, err
+    }
+    params.PasswordHash = hash
     return s.repo.CreateUser(ctx, params)
 }
 
-// Обновить пользователя
-func (s *UserService) UpdateUser(ctx context.Context, params db.UpdateUserParams) (db.User, error) {
+// Обновить пользователя. Свой профиль может менять каждый, чужой — только администратор;
+// роль меняет только администратор, для остальных она остаётся прежней.
+func (s *UserService) UpdateUser(ctx context.Context, callerID int64, callerRole string, params db.UpdateUserParams) (db.User, error) {
+    admin := s.isAdmin(callerRole)
+    if !admin && int64(params.ID) != callerID {
+        return db.User{}, ErrForbidden
+    }
+    if !admin || !params.Role.Valid {
+        current, err := s.repo.GetUserByID(ctx, params.ID)
+        if err != nil {
+            return db.User{}, err
+        }
+        params.Role = current.Role
+    }
     return s.repo.UpdateUser(ctx, params)
 }
 
-// Удалить пользователя
-func (s *UserService) DeleteUser(ctx context.Context, userID int32) error {
+// Удалить пользователя. Доступно администратору; свой аккаунт — только если
+// это разрешено настройкой USER_ALLOW_SELF_DELETE.
+func (s *UserService) DeleteUser(ctx context.Context, callerID int64, callerRole string, userID int32) error {
+    if !s.isAdmin(callerRole) && !(s.cfg.AllowSelfDelete && int64(userID) == callerID) {
+        return ErrForbidden
+    }
     return s.repo.DeleteUser(ctx, userID)
 }
 
@@ -58,6 +118,7 @@ func (s *UserService) UpdateFullName(ctx context.Context, userID int32, firstNam
 	return s.repo.UpdateFullName(ctx, userID, firstName, lastName)
 }
 
+// Смена email: адрес меняется только после перехода по ссылке из письма
 func (s *UserService) UpdateEmail(ctx context.Context, userID int, email string) error {
 	isTaken, err := s.repo.IsEmailTaken(ctx, email)
 	if err != nil {
@@ -66,5 +127,5 @@ func (s *UserService) UpdateEmail(ctx context.Context, userID int, email string)
 	if isTaken {
 		return fmt.Errorf("email is already taken")
 	}
-	return s.repo.UpdateEmail(userID, email)
+	return s.emails.RequestEmailConfirmation(ctx, int64(userID), email)
 }
@@ -4,21 +4,25 @@ import (
 	"context"
 	"domofon/internal/db"
 	"time"
-_"errors"
+"errors"
 
-  _ "github.com/jackc/pgx/v5"
+  "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	_"github.com/jackc/pgx/v5/pgxpool"
 )
 type Repository interface {
-	// Сохраняет или обновляет код подтверждения для телефона
-	UpsertToken(ctx context.Context, phone, code string, expiresAt time.Time) error
-	// Получает запись по телефону и коду
-	GetToken(ctx context.Context, phone, code string) (*db.PhoneVerificationToken, error)
+	// Сохраняет или обновляет код подтверждения для телефона и сценария. Счётчик попыток
+	// сбрасывается, только если прошло окно attemptsResetAt; возвращает текущее число попыток.
+	UpsertToken(ctx context.Context, phone string, purpose Purpose, code string, expiresAt, attemptsResetAt time.Time) (int32, error)
+	// Получает действующую запись по телефону и сценарию
+	GetToken(ctx context.Context, phone string, purpose Purpose) (*db.PhoneVerificationToken, error)
 	// Получает последнюю запись по телефону (например, для ограничения частоты отправки)
 	GetTokenByPhone(ctx context.Context, phone string) (*db.PhoneVerificationToken, error)
+	// Занимает попытку ввода до сравнения кода и возвращает код и число попыток.
+	// nil — кода нет, он истёк или попытки кончились.
+	ClaimAttempt(ctx context.Context, phone string, purpose Purpose, maxAttempts int) (*db.ClaimPhoneVerificationAttemptRow, error)
 	// Удаляет запись после успешной верификации
-	DeleteToken(ctx context.Context, phone string) error
+	DeleteToken(ctx context.Context, phone string, purpose Purpose) error
 }
 
 
@@ -30,18 +34,20 @@ func NewRepository(queries *db.Queries) *VerificationRepository {
 	return &VerificationRepository{queries: queries}
 }
 
-func (r *VerificationRepository) UpsertToken(ctx context.Context, phone, code string, expiresAt time.Time) error {
+func (r *VerificationRepository) UpsertToken(ctx context.Context, phone string, purpose Purpose, code string, expiresAt, attemptsResetAt time.Time) (int32, error) {
 	return r.queries.UpsertPhoneVerificationToken(ctx, db.UpsertPhoneVerificationTokenParams{
 		Phone:            phone,
+		Purpose:          string(purpose),
 		VerificationCode: code,
-		ExpiresAt:         pgtype.Timestamp{Time: expiresAt, Valid: true},
+		ExpiresAt:        pgtype.Timestamp{Time: expiresAt, Valid: true},
+		AttemptsResetAt:  pgtype.Timestamp{Time: attemptsResetAt, Valid: true},
 	})
 }
 
-func (r *VerificationRepository) GetToken(ctx context.Context, phone, code string) (*db.PhoneVerificationToken, error) {
+func (r *VerificationRepository) GetToken(ctx context.Context, phone string, purpose Purpose) (*db.PhoneVerificationToken, error) {
 	token, err := r.queries.GetPhoneVerificationToken(ctx, db.GetPhoneVerificationTokenParams{
-		Phone:            phone,
-		VerificationCode: code,
+		Phone:   phone,
+		Purpose: string(purpose),
 	})
 	if err != nil {
 		return nil, err
@@ -57,29 +63,24 @@ func (r *VerificationRepository) GetTokenByPhone(ctx context.Context, phone stri
 	return &token, nil
 }
 
-func (r *VerificationRepository) DeleteToken(ctx context.Context, phone string) error {
-	return r.queries.DeletePhoneVerificationToken(ctx, phone)
-}
-
-func (r *VerificationRepository) UpsertPhoneVerificationToken(ctx context.Context, phone, code string, expiresAt time.Time) error {
-	return r.queries.UpsertPhoneVerificationToken(ctx, db.UpsertPhoneVerificationTokenParams{
-		Phone:            phone,
-		VerificationCode: code,
-		ExpiresAt:        pgtype.Timestamp{Time: expiresAt, Valid: true},
+func (r *VerificationRepository) ClaimAttempt(ctx context.Context, phone string, purpose Purpose, maxAttempts int) (*db.ClaimPhoneVerificationAttemptRow, error) {
+	row, err := r.queries.ClaimPhoneVerificationAttempt(ctx, db.ClaimPhoneVerificationAttemptParams{
+		Phone:       phone,
+		Purpose:     string(purpose),
+		MaxAttempts: int32(maxAttempts),
 	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
 }
 
-func (r *VerificationRepository) GetPhoneVerificationToken(ctx context.Context, phone, code string) (*db.PhoneVerificationToken, error) {
-    token, err := r.queries.GetPhoneVerificationToken(ctx, db.GetPhoneVerificationTokenParams{
-        Phone:            phone,
-        VerificationCode: code,
-    })
-    if err != nil {
-        return nil, err
-    }
-    return &token, nil
-}
-
-func (r *VerificationRepository) DeletePhoneVerificationToken(ctx context.Context, phone string) error {
-	return r.queries.DeletePhoneVerificationToken(ctx, phone)
+func (r *VerificationRepository) DeleteToken(ctx context.Context, phone string, purpose Purpose) error {
+	return r.queries.DeletePhoneVerificationToken(ctx, db.DeletePhoneVerificationTokenParams{
+		Phone:   phone,
+		Purpose: string(purpose),
+	})
 }
@@ -3,89 +3,154 @@ package verification
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"errors"
-	"fmt"
+	"math/big"
 	"time"
+	"domofon/internal/config"
 	"domofon/internal/db"
 )
 
+// Сценарий, для которого выдан код. Код одного сценария не подходит для другого.
+type Purpose string
+
+const (
+	PurposeRegister    Purpose = "register"
+	PurposeReset       Purpose = "reset"
+	PurposeChangePhone Purpose = "change_phone"
+	PurposeLogin       Purpose = "login"
+	PurposeUnlock      Purpose = "unlock"
+	PurposeMFAEnroll   Purpose = "mfa_enroll"
+)
+
+var (
+	ErrInvalidCode     = errors.New("неверный или просроченный код")
+	ErrTooManyAttempts = errors.New("превышено число попыток, запросите новый код")
+	ErrResendTooSoon   = errors.New("код уже отправлен, попробуйте позже")
+	ErrInvalidPurpose  = errors.New("неизвестное назначение кода")
+)
+
+func ParsePurpose(s string) (Purpose, error) {
+	switch p := Purpose(s); p {
+	case PurposeRegister, PurposeReset, PurposeChangePhone, PurposeLogin, PurposeUnlock, PurposeMFAEnroll:
+		return p, nil
+	default:
+		return "", ErrInvalidPurpose
+	}
+}
+
 type Service interface {
-	UpsertToken(ctx context.Context, phone, code string, expiresAt time.Time) error
-	GetToken(ctx context.Context, phone, code string) (*db.PhoneVerificationToken, error)
+	UpsertToken(ctx context.Context, phone string, purpose Purpose, code string, expiresAt time.Time) error
+	GetToken(ctx context.Context, phone string, purpose Purpose) (*db.PhoneVerificationToken, error)
 	GetTokenByPhone(ctx context.Context, phone string) (*db.PhoneVerificationToken, error)
-	DeleteToken(ctx context.Context, phone string) error
-	SendVerificationCode(ctx context.Context, phone string) error
-	ResendVerificationCode(ctx context.Context, phone string) error
-	VerifyCode(ctx context.Context, phone, code string) error
+	DeleteToken(ctx context.Context, phone string, purpose Purpose) error
+	SendVerificationCode(ctx context.Context, phone string, purpose Purpose) error
+	ResendVerificationCode(ctx context.Context, phone string, purpose Purpose) error
+	VerifyCode(ctx context.Context, phone string, purpose Purpose, code string) error
 }
 
 type serviceImpl struct {
 	repo           Repository
 	sms            SMSSender
+	codeLength     int
 	codeTTL        time.Duration
 	resendInterval time.Duration
+	maxAttempts    int
+	attemptWindow  time.Duration
 }
 
-func NewService(repo Repository, sms SMSSender, codeTTL, resendInterval time.Duration) *serviceImpl {
+func NewService(repo Repository, sms SMSSender, cfg *config.VerificationConfig) *serviceImpl {
 	return &serviceImpl{
 		repo:           repo,
 		sms:            sms,
-		codeTTL:        codeTTL,
-		resendInterval: resendInterval,
+		codeLength:     cfg.CodeLength,
+		codeTTL:        cfg.CodeTTL,
+		resendInterval: cfg.ResendInterval,
+		maxAttempts:    cfg.MaxAttempts,
+		attemptWindow:  cfg.AttemptWindow,
 	}
 }
 
-// 4-значный код
-func generate4DigitCode() string {
-	b := make([]byte, 2)
-	_, _ = rand.Read(b)
-	n := (uint(b[0])<<8 | uint(b[1])) % 10000
-	return fmt.Sprintf("%04d", n)
+// Случайный цифровой код заданной длины
+func generateCode(length int) (string, error) {
+	digits := make([]byte, length)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return string(digits), nil
 }
 
-func (s *serviceImpl) SendVerificationCode(ctx context.Context, phone string) error {
+func (s *serviceImpl) SendVerificationCode(ctx context.Context, phone string, purpose Purpose) error {
 	token, err := s.repo.GetTokenByPhone(ctx, phone)
 	if err == nil && token != nil && time.Since(token.CreatedAt.Time) < s.resendInterval {
-		return errors.New("код уже отправлен, попробуйте позже")
+		return ErrResendTooSoon
 	}
 
-	code := generate4DigitCode()
-	expiresAt := time.Now().Add(s.codeTTL)
-	if err := s.repo.UpsertToken(ctx, phone, code, expiresAt); err != nil {
+	code, err := generateCode(s.codeLength)
+	if err != nil {
 		return err
 	}
+	now := time.Now()
+	attempts, err := s.repo.UpsertToken(ctx, phone, purpose, code, now.Add(s.codeTTL), now.Add(s.attemptWindow))
+	if err != nil {
+		return err
+	}
+	// Попытки на этот номер кончились: новый код всё равно не примут, SMS не шлём
+	if int(attempts) >= s.maxAttempts {
+		return ErrTooManyAttempts
+	}
 	return s.sms.SendSMS(phone, "Код подтверждения: "+code)
 }
 
-func (s *serviceImpl) ResendVerificationCode(ctx context.Context, phone string) error {
-	return s.SendVerificationCode(ctx, phone)
+func (s *serviceImpl) ResendVerificationCode(ctx context.Context, phone string, purpose Purpose) error {
+	return s.SendVerificationCode(ctx, phone, purpose)
 }
 
-func (s *serviceImpl) VerifyCode(ctx context.Context, phone, code string) error {
-	token, err := s.repo.GetToken(ctx, phone, code)
+// Проверка кода. Попытка занимается атомарно до сравнения, поэтому параллельные
+// запросы не дают больше maxAttempts проверок. Счётчик живёт до конца окна
+// attemptWindow и не сбрасывается повторной отправкой кода.
+func (s *serviceImpl) VerifyCode(ctx context.Context, phone string, purpose Purpose, code string) error {
+	claim, err := s.repo.ClaimAttempt(ctx, phone, purpose, s.maxAttempts)
 	if err != nil {
-		return errors.New("неверный или просроченный код")
+		return err
+	}
+	if claim == nil {
+		token, err := s.repo.GetToken(ctx, phone, purpose)
+		if err == nil && int(token.Attempts) >= s.maxAttempts {
+			return ErrTooManyAttempts
+		}
+		return ErrInvalidCode
 	}
-	if token.ExpiresAt.Time.Before(time.Now()) {
-		return errors.New("срок действия кода истёк")
+
+	if subtle.ConstantTimeCompare([]byte(claim.VerificationCode), []byte(code)) != 1 {
+		if int(claim.Attempts) >= s.maxAttempts {
+			return ErrTooManyAttempts
+		}
+		return ErrInvalidCode
 	}
-	_ = s.repo.DeleteToken(ctx, phone)
+
+	_ = s.repo.DeleteToken(ctx, phone, purpose)
 	return nil
 }
 
-func (s *serviceImpl) UpsertToken(ctx context.Context, phone, code string, expiresAt time.Time) error {
-	return s.repo.UpsertToken(ctx, phone, code, expiresAt)
+func (s *serviceImpl) UpsertToken(ctx context.Context, phone string, purpose Purpose, code string, expiresAt time.Time) error {
+	_, err := s.repo.UpsertToken(ctx, phone, purpose, code, expiresAt, time.Now().Add(s.attemptWindow))
+	return err
 }
 
-func (s *serviceImpl) GetToken(ctx context.Context, phone, code string) (*db.PhoneVerificationToken, error) {
-	return s.repo.GetToken(ctx, phone, code)
+func (s *serviceImpl) GetToken(ctx context.Context, phone string, purpose Purpose) (*db.PhoneVerificationToken, error) {
+	return s.repo.GetToken(ctx, phone, purpose)
 }
 
 func (s *serviceImpl) GetTokenByPhone(ctx context.Context, phone string) (*db.PhoneVerificationToken, error) {
 	return s.repo.GetTokenByPhone(ctx, phone)
 }
 
-func (s *serviceImpl) DeleteToken(ctx context.Context, phone string) error {
-	return s.repo.DeleteToken(ctx, phone)
+func (s *serviceImpl) DeleteToken(ctx context.Context, phone string, purpose Purpose) error {
+	return s.repo.DeleteToken(ctx, phone, purpose)
 }
 
@@ -0,0 +1,9 @@
+-- LOGIN_ATTEMPTS (Счётчики неудачных входов по телефону и IP)
+CREATE TABLE login_attempts (
+    attempt_key     VARCHAR(128) PRIMARY KEY, -- 'phone:<номер>' или 'ip:<адрес>'
+    failed_count    INTEGER NOT NULL DEFAULT 0,
+    last_failed_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until    TIMESTAMP
+);
+
+CREATE INDEX idx_login_attempts_locked_until ON login_attempts (locked_until);
@@ -0,0 +1,8 @@
+DELETE FROM phone_verification_tokens WHERE purpose <> 'register';
+
+ALTER TABLE phone_verification_tokens DROP CONSTRAINT phone_verification_tokens_pkey;
+ALTER TABLE phone_verification_tokens ADD PRIMARY KEY (phone);
+
+ALTER TABLE phone_verification_tokens
+    DROP COLUMN attempts,
+    DROP COLUMN purpose;
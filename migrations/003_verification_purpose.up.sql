@@ -0,0 +1,7 @@
+-- PHONE_VERIFICATION_TOKENS: код привязан к сценарию и считает неудачные попытки
+ALTER TABLE phone_verification_tokens
+    ADD COLUMN purpose  VARCHAR(32) NOT NULL DEFAULT 'register',
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE phone_verification_tokens DROP CONSTRAINT phone_verification_tokens_pkey;
+ALTER TABLE phone_verification_tokens ADD PRIMARY KEY (phone, purpose);
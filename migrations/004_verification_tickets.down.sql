@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_verification_tickets_expires_at;
+DROP TABLE IF EXISTS verification_tickets;
@@ -0,0 +1,11 @@
+-- VERIFICATION_TICKETS (Одноразовые подтверждения номера после ввода SMS-кода)
+CREATE TABLE verification_tickets (
+    jti             VARCHAR(64) PRIMARY KEY,
+    phone           VARCHAR(32) NOT NULL,
+    purpose         VARCHAR(32) NOT NULL,
+    expires_at      TIMESTAMP NOT NULL,
+    used_at         TIMESTAMP,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_verification_tickets_expires_at ON verification_tickets (expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_outbox_messages_created_at;
+DROP INDEX IF EXISTS idx_outbox_messages_status_next;
+DROP TABLE IF EXISTS outbox_messages;
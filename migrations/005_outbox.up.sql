@@ -0,0 +1,20 @@
+-- OUTBOX_MESSAGES (Очередь исходящих SMS/email/push)
+CREATE TABLE outbox_messages (
+    id              BIGSERIAL PRIMARY KEY,
+    channel         VARCHAR(16) NOT NULL,               -- sms, email, push
+    recipient       VARCHAR(255) NOT NULL,
+    subject         VARCHAR(255),
+    body            TEXT NOT NULL,
+    payload         JSONB,
+    status          VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending, processing, sent, dead
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    max_attempts    INTEGER NOT NULL DEFAULT 5,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until    TIMESTAMP,
+    last_error      TEXT,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at         TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_messages_status_next ON outbox_messages (status, next_attempt_at);
+CREATE INDEX idx_outbox_messages_created_at ON outbox_messages (created_at DESC);
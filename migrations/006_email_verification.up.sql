@@ -0,0 +1,15 @@
+-- Подтверждение email: флаг у пользователя и одноразовые ссылки подтверждения
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- EMAIL_VERIFICATION_TOKENS (Ссылки подтверждения нового/изменённого email)
+-- Храним SHA-256 токена, сам токен есть только в письме.
+-- email — адрес, который станет основным после подтверждения.
+CREATE TABLE email_verification_tokens (
+    token_hash  VARCHAR(64) PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email       VARCHAR(128) NOT NULL,
+    expires_at  TIMESTAMP NOT NULL,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens (user_id);
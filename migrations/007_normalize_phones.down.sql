@@ -0,0 +1,2 @@
+-- Исходную запись номеров восстановить нельзя: номера остаются в E.164
+DROP TABLE IF EXISTS phone_normalization_conflicts;
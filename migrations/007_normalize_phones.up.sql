@@ -0,0 +1,93 @@
+-- Приведение номеров телефонов к E.164 (+79001234567).
+-- Правила совпадают с internal/phone.Normalize: номера без кода страны считаются российскими.
+-- Номера, которые не удалось разобрать или которые после нормализации совпали
+-- с номером другого пользователя, не меняются и попадают в phone_normalization_conflicts.
+
+CREATE FUNCTION pg_temp.normalize_phone(raw TEXT) RETURNS TEXT AS $$
+DECLARE
+    s    TEXT := btrim(raw);
+    intl BOOLEAN := FALSE;
+    d    TEXT;
+BEGIN
+    IF s LIKE '+%' THEN
+        intl := TRUE;
+        s := substr(s, 2);
+    ELSIF s LIKE '00%' THEN
+        intl := TRUE;
+        s := substr(s, 3);
+    END IF;
+    IF s ~ '[^0-9 ().\-\u00a0]' THEN
+        RETURN NULL;
+    END IF;
+    d := regexp_replace(s, '[^0-9]', '', 'g');
+
+    IF NOT intl THEN
+        IF length(d) = 11 AND left(d, 1) IN ('7', '8') THEN
+            d := '7' || substr(d, 2);
+        ELSIF length(d) = 10 THEN
+            d := '7' || d;
+        ELSE
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    IF length(d) NOT BETWEEN 8 AND 15 OR left(d, 1) = '0' THEN
+        RETURN NULL;
+    END IF;
+    IF left(d, 1) = '7' AND length(d) <> 11 THEN
+        RETURN NULL;
+    END IF;
+    RETURN '+' || d;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- PHONE_NORMALIZATION_CONFLICTS (Номера, требующие ручного разбора)
+CREATE TABLE phone_normalization_conflicts (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    phone       VARCHAR(32) NOT NULL,           -- номер как есть
+    normalized  VARCHAR(16),                    -- NULL, если номер не разобран
+    reason      VARCHAR(16) NOT NULL,           -- invalid, collision
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO phone_normalization_conflicts (user_id, phone, normalized, reason)
+SELECT id, phone, NULL, 'invalid'
+FROM users
+WHERE pg_temp.normalize_phone(phone) IS NULL;
+
+WITH n AS (
+    SELECT id, phone, pg_temp.normalize_phone(phone) AS normalized FROM users
+)
+INSERT INTO phone_normalization_conflicts (user_id, phone, normalized, reason)
+SELECT n.id, n.phone, n.normalized, 'collision'
+FROM n
+WHERE n.normalized IS NOT NULL
+  AND EXISTS (SELECT 1 FROM n o WHERE o.normalized = n.normalized AND o.id <> n.id);
+
+UPDATE users
+SET phone = pg_temp.normalize_phone(phone)
+WHERE pg_temp.normalize_phone(phone) IS NOT NULL
+  AND pg_temp.normalize_phone(phone) <> phone
+  AND NOT EXISTS (SELECT 1 FROM phone_normalization_conflicts c WHERE c.user_id = users.id);
+
+-- E.164 с плюсом — до 16 символов
+ALTER TABLE phone_verification_tokens ALTER COLUMN phone TYPE VARCHAR(16);
+
+-- Коды и счётчики неудачных входов живут недолго, проще начать заново, чем переносить
+DELETE FROM phone_verification_tokens;
+DELETE FROM login_attempts WHERE attempt_key LIKE 'phone:%';
+
+UPDATE verification_tickets
+SET phone = pg_temp.normalize_phone(phone)
+WHERE pg_temp.normalize_phone(phone) IS NOT NULL;
+
+DO $$
+DECLARE
+    n INTEGER;
+BEGIN
+    SELECT count(*) INTO n FROM phone_normalization_conflicts;
+    IF n > 0 THEN
+        RAISE WARNING 'Не удалось нормализовать номера у % пользователей, см. phone_normalization_conflicts', n;
+    END IF;
+END $$;
@@ -0,0 +1,22 @@
+-- USER_MFA (TOTP второй фактор)
+-- secret зашифрован ключом MFA_SECRET_KEY (AES-GCM).
+-- last_used_step — последний принятый шаг TOTP, повторно код не принимается.
+CREATE TABLE user_mfa (
+    user_id         INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret          TEXT NOT NULL,
+    enabled         BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_step  BIGINT,
+    created_at      TIMESTAMP NOT NULL,
+    enabled_at      TIMESTAMP
+);
+
+-- MFA_RECOVERY_CODES (Одноразовые коды восстановления, храним SHA-256)
+CREATE TABLE mfa_recovery_codes (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash   VARCHAR(64) NOT NULL,
+    used_at     TIMESTAMP,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
@@ -0,0 +1,8 @@
+-- RATE_LIMIT_BUCKETS (Token bucket для ограничения частоты запросов к открытым ручкам)
+CREATE TABLE rate_limit_buckets (
+    bucket_key  VARCHAR(255) PRIMARY KEY,           -- rl:<route>:ip:<addr>, rl:<route>:phone:<номер>
+    tokens      DOUBLE PRECISION NOT NULL,
+    updated_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets (updated_at);
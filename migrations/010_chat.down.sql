@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS chat_message_mentions;
+DROP TABLE IF EXISTS chat_messages;
+DROP TABLE IF EXISTS chat_members;
+DROP TABLE IF EXISTS chats;
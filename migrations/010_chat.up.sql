@@ -0,0 +1,43 @@
+-- CHATS (Групповые чаты домов и личные переписки)
+-- chat_key: building:<адрес дома> или direct:<меньший id>:<больший id>
+CREATE TABLE chats (
+    id          BIGSERIAL PRIMARY KEY,
+    chat_key    VARCHAR(300) UNIQUE NOT NULL,
+    kind        VARCHAR(16) NOT NULL,                   -- building, direct
+    title       VARCHAR(255),
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- CHAT_MEMBERS (Участники; last_read_message_id — отметка прочтения)
+CREATE TABLE chat_members (
+    chat_id               BIGINT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id               INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at             TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_read_message_id  BIGINT,
+    last_read_at          TIMESTAMP,
+    PRIMARY KEY (chat_id, user_id)
+);
+
+CREATE INDEX idx_chat_members_user ON chat_members (user_id);
+
+-- CHAT_MESSAGES (Сообщения; удалённые остаются с пустым текстом и deleted_at)
+CREATE TABLE chat_messages (
+    id          BIGSERIAL PRIMARY KEY,
+    chat_id     BIGINT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    sender_id   INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    body        TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    edited_at   TIMESTAMP,
+    deleted_at  TIMESTAMP
+);
+
+CREATE INDEX idx_chat_messages_chat_id ON chat_messages (chat_id, id DESC);
+
+-- CHAT_MESSAGE_MENTIONS (Упоминания @username в сообщениях)
+CREATE TABLE chat_message_mentions (
+    message_id  BIGINT NOT NULL REFERENCES chat_messages(id) ON DELETE CASCADE,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX idx_chat_message_mentions_user ON chat_message_mentions (user_id);
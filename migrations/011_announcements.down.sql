@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS announcement_reads;
+DROP TABLE IF EXISTS announcement_attachments;
+DROP TABLE IF EXISTS announcement_apartments;
+DROP TABLE IF EXISTS announcements;
+ALTER TABLE apartments DROP COLUMN IF EXISTS entrance;
@@ -0,0 +1,50 @@
+-- Подъезд квартиры — для объявлений на подъезд
+ALTER TABLE apartments ADD COLUMN entrance VARCHAR(16);
+
+-- ANNOUNCEMENTS (Объявления управляющей компании)
+-- target_type: address — весь дом, entrance — подъезд дома, apartments — список квартир
+CREATE TABLE announcements (
+    id               BIGSERIAL PRIMARY KEY,
+    author_id        INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    title            VARCHAR(255) NOT NULL,
+    body             TEXT NOT NULL,
+    target_type      VARCHAR(16) NOT NULL,
+    target_address   VARCHAR(255),
+    target_entrance  VARCHAR(16),
+    publish_at       TIMESTAMP NOT NULL,
+    expires_at       TIMESTAMP,
+    cancelled_at     TIMESTAMP,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_announcements_publish_at ON announcements (publish_at DESC);
+CREATE INDEX idx_announcements_target_address ON announcements (target_address);
+
+CREATE TABLE announcement_apartments (
+    announcement_id  BIGINT NOT NULL REFERENCES announcements(id) ON DELETE CASCADE,
+    apartment_id     INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+    PRIMARY KEY (announcement_id, apartment_id)
+);
+
+CREATE INDEX idx_announcement_apartments_apartment ON announcement_apartments (apartment_id);
+
+-- ANNOUNCEMENT_ATTACHMENTS (Файлы; лежат в ANNOUNCEMENT_STORAGE_DIR, отдаются только адресатам)
+CREATE TABLE announcement_attachments (
+    id               BIGSERIAL PRIMARY KEY,
+    announcement_id  BIGINT NOT NULL REFERENCES announcements(id) ON DELETE CASCADE,
+    file_name        VARCHAR(255) NOT NULL,
+    storage_path     VARCHAR(255) NOT NULL,
+    content_type     VARCHAR(128) NOT NULL,
+    size_bytes       BIGINT NOT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_announcement_attachments_announcement ON announcement_attachments (announcement_id);
+
+-- ANNOUNCEMENT_READS (Кто из жителей прочитал объявление)
+CREATE TABLE announcement_reads (
+    announcement_id  BIGINT NOT NULL REFERENCES announcements(id) ON DELETE CASCADE,
+    user_id          INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    read_at          TIMESTAMP NOT NULL,
+    PRIMARY KEY (announcement_id, user_id)
+);
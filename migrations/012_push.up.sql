@@ -0,0 +1,30 @@
+-- PUSH_DEVICES (Токены push-уведомлений; один токен на сессию входа)
+-- provider: expo — токен Expo (ExponentPushToken[...]), fcm — токен Firebase Cloud Messaging
+CREATE TABLE push_devices (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id  VARCHAR(64) NOT NULL,
+    token       VARCHAR(512) NOT NULL UNIQUE,
+    provider    VARCHAR(16) NOT NULL,
+    platform    VARCHAR(16),
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, session_id)
+);
+
+-- NOTIFICATION_PREFERENCES (Отключённые и включённые типы событий; нет строки — значение по умолчанию)
+CREATE TABLE notification_preferences (
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_type  VARCHAR(32) NOT NULL,
+    enabled     BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, event_type)
+);
+
+-- NOTIFICATION_SETTINGS (Тихие часы: минуты от полуночи в часовом поясе пользователя)
+CREATE TABLE notification_settings (
+    user_id            INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    quiet_hours_start  INTEGER,
+    quiet_hours_end    INTEGER,
+    timezone           VARCHAR(64) NOT NULL,
+    updated_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
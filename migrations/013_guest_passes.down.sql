@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS guest_passes;
+ALTER TABLE devices DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE devices DROP COLUMN IF EXISTS api_key_hash;
@@ -0,0 +1,22 @@
+-- Ключ устройства для запросов домофона к API (хранится sha256)
+ALTER TABLE devices ADD COLUMN api_key_hash VARCHAR(64) UNIQUE;
+ALTER TABLE devices ADD COLUMN last_seen_at TIMESTAMP;
+
+-- GUEST_PASSES (Гостевые пропуска; сам пропуск — строка keys с key_type = 'guest')
+-- code_format: numeric — цифровой код для набора на панели, qr — строка для QR-кода
+CREATE TABLE guest_passes (
+    key_id        INTEGER PRIMARY KEY REFERENCES keys(id) ON DELETE CASCADE,
+    apartment_id  INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+    issued_by     INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    guest_name    VARCHAR(128),
+    code_format   VARCHAR(8) NOT NULL,
+    code_hash     VARCHAR(64) NOT NULL,
+    max_uses      INTEGER NOT NULL,
+    use_count     INTEGER NOT NULL DEFAULT 0,
+    -- Если задано — пропуск действует только на этом устройстве
+    device_id     INTEGER REFERENCES devices(id) ON DELETE CASCADE,
+    revoked_at    TIMESTAMP
+);
+
+CREATE INDEX idx_guest_passes_code_hash ON guest_passes (code_hash);
+CREATE INDEX idx_guest_passes_issued_by ON guest_passes (issued_by);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS resident_type_schedules;
+DROP INDEX IF EXISTS idx_keys_schedule;
+ALTER TABLE keys DROP COLUMN IF EXISTS schedule_id;
+DROP TABLE IF EXISTS access_schedule_holidays;
+DROP TABLE IF EXISTS access_schedule_rules;
+DROP TABLE IF EXISTS access_schedules;
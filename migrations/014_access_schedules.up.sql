@@ -0,0 +1,40 @@
+-- ACCESS_SCHEDULES (Повторяющиеся расписания доступа: дни недели, интервалы времени, праздники)
+-- Время в правилах — местное для timezone расписания
+CREATE TABLE access_schedules (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(128) NOT NULL,
+    timezone    VARCHAR(64) NOT NULL,
+    created_by  INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- weekdays — битовая маска дней: бит 0 — понедельник, ..., бит 6 — воскресенье.
+-- Минуты от начала суток; end_minute <= start_minute — интервал через полночь
+-- (день недели — день начала интервала).
+CREATE TABLE access_schedule_rules (
+    id            SERIAL PRIMARY KEY,
+    schedule_id   INTEGER NOT NULL REFERENCES access_schedules(id) ON DELETE CASCADE,
+    weekdays      SMALLINT NOT NULL,
+    start_minute  INTEGER NOT NULL,
+    end_minute    INTEGER NOT NULL
+);
+
+CREATE INDEX idx_access_schedule_rules_schedule ON access_schedule_rules (schedule_id);
+
+-- Дни, когда доступа по расписанию нет
+CREATE TABLE access_schedule_holidays (
+    schedule_id  INTEGER NOT NULL REFERENCES access_schedules(id) ON DELETE CASCADE,
+    day          DATE NOT NULL,
+    PRIMARY KEY (schedule_id, day)
+);
+
+-- Расписание ключа (в том числе гостевого пропуска). Удалить расписание, пока оно привязано, нельзя.
+ALTER TABLE keys ADD COLUMN schedule_id INTEGER REFERENCES access_schedules(id);
+CREATE INDEX idx_keys_schedule ON keys (schedule_id);
+
+-- Расписание для типа жителя (например, cleaner): действует на ключи таких жителей
+CREATE TABLE resident_type_schedules (
+    resident_type  VARCHAR(32) PRIMARY KEY,
+    schedule_id    INTEGER NOT NULL REFERENCES access_schedules(id)
+);
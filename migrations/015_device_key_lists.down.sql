@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS device_key_list_acks;
+DROP TABLE IF EXISTS device_key_lists;
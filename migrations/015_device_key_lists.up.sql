@@ -0,0 +1,17 @@
+-- DEVICE_KEY_LISTS (Версии списка ключей для офлайн-работы панели)
+-- content — снимок списка; старые версии хранятся, чтобы отдавать разницу
+CREATE TABLE device_key_lists (
+    device_id     INTEGER NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    version       BIGINT NOT NULL,
+    content_hash  VARCHAR(64) NOT NULL,
+    content       JSONB NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (device_id, version)
+);
+
+-- Какую версию списка панель применила последней
+CREATE TABLE device_key_list_acks (
+    device_id  INTEGER PRIMARY KEY REFERENCES devices(id) ON DELETE CASCADE,
+    version    BIGINT NOT NULL,
+    acked_at   TIMESTAMP NOT NULL
+);
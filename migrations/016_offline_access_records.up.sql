@@ -0,0 +1,18 @@
+-- OFFLINE_ACCESS_RECORDS (Записи журнала, принятые панелью без связи с сервером)
+-- (device_id, record_id) — идентификатор записи на панели, по нему повторная выгрузка не дублирует журнал.
+-- recorded_at — время по часам панели, access_time — после поправки на расхождение часов.
+-- anomaly: clock_skew — часы панели расходились с сервером, future_time — время записи в будущем,
+-- too_old — запись старше ACCESS_OFFLINE_MAX_AGE
+CREATE TABLE offline_access_records (
+    device_id           INTEGER NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    record_id           VARCHAR(64) NOT NULL,
+    access_history_id   INTEGER REFERENCES access_history(id) ON DELETE SET NULL,
+    recorded_at         TIMESTAMP NOT NULL,
+    access_time         TIMESTAMP NOT NULL,
+    clock_skew_seconds  INTEGER NOT NULL DEFAULT 0,
+    anomaly             VARCHAR(16),
+    received_at         TIMESTAMP NOT NULL,
+    PRIMARY KEY (device_id, record_id)
+);
+
+CREATE INDEX idx_offline_access_records_anomaly ON offline_access_records (received_at DESC) WHERE anomaly IS NOT NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS lost_key_reports;
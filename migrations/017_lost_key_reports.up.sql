@@ -0,0 +1,10 @@
+-- LOST_KEY_REPORTS (Заявления о потере ключа; сам ключ при этом отключается)
+-- presented_count / last_presented_at — сколько раз и когда потерянный ключ прикладывали к домофону
+CREATE TABLE lost_key_reports (
+    key_id              INTEGER PRIMARY KEY REFERENCES keys(id) ON DELETE CASCADE,
+    reported_by         INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reported_at         TIMESTAMP NOT NULL,
+    replacement_key_id  INTEGER REFERENCES keys(id) ON DELETE SET NULL,
+    presented_count     INTEGER NOT NULL DEFAULT 0,
+    last_presented_at   TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS anomaly_scan_cursors;
+DROP TABLE IF EXISTS security_alerts;
@@ -0,0 +1,32 @@
+-- SECURITY_ALERTS (Аномалии в журнале доступа, найденные фоновой проверкой)
+-- kind: impossible_travel — один ключ в разных домах за несколько минут,
+--       repeated_denials — серия отказов на устройстве или по ключу,
+--       unusual_hour — проход в час, когда ключом обычно не пользуются,
+--       revoked_key — предъявлен отозванный или потерянный ключ
+-- dedup_key не даёт поднять одну и ту же тревогу дважды
+-- status: open, acknowledged, dismissed
+CREATE TABLE security_alerts (
+    id                 SERIAL PRIMARY KEY,
+    kind               VARCHAR(32) NOT NULL,
+    severity           VARCHAR(16) NOT NULL,
+    key_id             INTEGER REFERENCES keys(id) ON DELETE SET NULL,
+    device_id          INTEGER REFERENCES devices(id) ON DELETE SET NULL,
+    user_id            INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    access_history_id  INTEGER REFERENCES access_history(id) ON DELETE SET NULL,
+    dedup_key          VARCHAR(128) NOT NULL UNIQUE,
+    description        VARCHAR(255) NOT NULL,
+    detected_at        TIMESTAMP NOT NULL,
+    status             VARCHAR(16) NOT NULL DEFAULT 'open',
+    reviewed_by        INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at        TIMESTAMP,
+    review_note        VARCHAR(255)
+);
+
+CREATE INDEX idx_security_alerts_status ON security_alerts (status, detected_at DESC);
+
+-- До какой записи access_history журнал уже проверен
+CREATE TABLE anomaly_scan_cursors (
+    name             VARCHAR(32) PRIMARY KEY,
+    last_history_id  INTEGER NOT NULL DEFAULT 0,
+    scanned_at       TIMESTAMP
+);
@@ -0,0 +1 @@
+ALTER TABLE phone_verification_tokens DROP COLUMN IF EXISTS attempts_reset_at;
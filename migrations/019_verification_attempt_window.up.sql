@@ -0,0 +1,4 @@
+-- PHONE_VERIFICATION_TOKENS: счётчик неудачных попыток переживает повторную отправку кода
+-- и обнуляется только после окна VERIFICATION_ATTEMPT_WINDOW
+ALTER TABLE phone_verification_tokens
+    ADD COLUMN attempts_reset_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
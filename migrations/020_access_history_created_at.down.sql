@@ -0,0 +1 @@
+ALTER TABLE access_history DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,6 @@
+-- ACCESS_HISTORY: момент вставки записи. access_time у офлайн-записей — время панели,
+-- а проверке на аномалии нужно знать, когда запись появилась в базе.
+-- Значение по умолчанию задаётся отдельно, чтобы не переписывать таблицу;
+-- у старых записей created_at остаётся пустым.
+ALTER TABLE access_history ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE access_history ALTER COLUMN created_at SET DEFAULT clock_timestamp();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_announcements_pending_notify;
+ALTER TABLE announcements DROP COLUMN IF EXISTS notified_at;
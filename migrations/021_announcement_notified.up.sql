@@ -0,0 +1,6 @@
+-- ANNOUNCEMENTS: когда жителям разослан push о публикации.
+-- Уже опубликованные объявления считаются разосланными, запланированные
+-- разошлются, когда наступит publish_at.
+ALTER TABLE announcements ADD COLUMN notified_at TIMESTAMP;
+UPDATE announcements SET notified_at = publish_at WHERE publish_at <= NOW();
+CREATE INDEX idx_announcements_pending_notify ON announcements (publish_at) WHERE notified_at IS NULL;
@@ -1,81 +1,234 @@
-package http
-
-import (
-	"domofon/internal/auth"
-	"domofon/internal/user"
-	"domofon/internal/verification"
-	"domofon/internal/db"
-	"domofon/internal/middleware"
-	"net/http"
-	"time"
-	"github.com/gorilla/mux"
-	"github.com/jackc/pgx/v5/pgxpool"
-	httpSwagger "github.com/swaggo/http-swagger"
-)
-
-func NewRouter(pool *pgxpool.Pool) *mux.Router {
-	// --- User ---
-	userRepo := user.NewUserRepository(pool)
-	userService := user.NewUserService(userRepo)
-	userHandler := user.NewUserHandler(userService)
-
-	queries := db.New(pool)
-
-	// Verification
-	verifRepo := verification.NewRepository(queries)
-	smsSender := &verification.MockSMSSender{}
-	verifService := verification.NewService(
-		verifRepo,
-		smsSender,
-		5*time.Minute,
-		1*time.Minute,
-	)
-
-	// --- Auth ---
-	authRepo := auth.NewAuthRepository(pool)
-	authService := auth.NewAuthService(authRepo, verifService)
-	authHandler := auth.NewAuthHandler(authService)
-
-	r := mux.NewRouter()
-	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
-
-	// --- Открытые ручки ---
-	// Регистрация (трёхшагово):
-	r.HandleFunc("/auth/request-registration-code", authHandler.RequestRegistrationCode).Methods("POST")
-	r.HandleFunc("/auth/verify-phone",              authHandler.VerifyPhone).Methods("POST")
-	r.HandleFunc("/auth/register",                  authHandler.Register).Methods("POST")
-	r.HandleFunc("/auth/login",                     authHandler.Login).Methods("POST")
-	r.HandleFunc("/auth/forgot-password",           authHandler.ForgotPassword).Methods("POST")
-	r.HandleFunc("/auth/reset-password",            authHandler.ResetPassword).Methods("POST")
-	r.HandleFunc("/users",                          userHandler.CreateUser).Methods("POST")
-	r.HandleFunc("/auth/refresh", authHandler.Refresh).Methods("POST")
-	r.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST")
-
-	// --- Защищённые ручки (JWT Auth) ---
-	protected := r.PathPrefix("").Subrouter()
-	protected.Use(middleware.JWTAuth)
-
-	// User endpoints
-	protected.HandleFunc("/users/me", userHandler.GetCurrentUser).Methods("GET")
-	protected.HandleFunc("/users",      userHandler.GetUsers).Methods("GET")
-	protected.HandleFunc("/users/{id}", userHandler.UpdateUser).Methods("PUT")
-	protected.HandleFunc("/users/{id}", userHandler.DeleteUser).Methods("DELETE")
-	protected.HandleFunc("/users/me/avatar", userHandler.UploadAvatar).Methods("POST")
-	protected.HandleFunc("/users/me/avatar", userHandler.DeleteAvatar).Methods("DELETE")
-	protected.HandleFunc("/users/me/username", userHandler.ChangeUsername).Methods("POST")
-	protected.HandleFunc("/users/me/fullname", userHandler.UpdateFullName).Methods("POST")
-	protected.HandleFunc("/users/me/email", userHandler.UpdateEmail).Methods("POST")
-
-avatarHandler := http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads")))
-r.PathPrefix("/uploads/").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-    w.Header().Set("Cache-Control", "public, max-age=604800")
-    avatarHandler.ServeHTTP(w, r)
-}))
-
-
-
-	protected.HandleFunc("/auth/change-password", authHandler.ChangePassword).Methods("POST")
-
-	return r
-}
+package http
+
+import (
+	"domofon/internal/access"
+	"domofon/internal/anomaly"
+	"domofon/internal/announcement"
+	"domofon/internal/auth"
+	"domofon/internal/chat"
+	"domofon/internal/config"
+	"domofon/internal/user"
+	"domofon/internal/verification"
+	"domofon/internal/db"
+	"domofon/internal/device"
+	"domofon/internal/keylist"
+	"domofon/internal/jwt"
+	"domofon/internal/middleware"
+	"domofon/internal/outbox"
+	"domofon/internal/push"
+	"domofon/internal/ratelimit"
+	"domofon/internal/schedule"
+	"net/http"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+	httpSwagger "github.com/swaggo/http-swagger"
+)
+
+func NewRouter(pool *pgxpool.Pool, queue *outbox.Queue) *mux.Router {
+	queries := db.New(pool)
+
+	// Verification
+	// SMS с кодами уходят через очередь, отправляет их outbox.Worker
+	verifRepo := verification.NewRepository(queries)
+	verifService := verification.NewService(
+		verifRepo,
+		queue,
+		config.LoadVerificationConfig(),
+	)
+
+	// --- Auth ---
+	authRepo := auth.NewAuthRepository(pool)
+	authService := auth.NewAuthService(authRepo, verifService, queue, config.LoadAuthConfig())
+	authHandler := auth.NewAuthHandler(authService)
+
+	// --- User ---
+	userRepo := user.NewUserRepository(pool)
+	userService := user.NewUserService(userRepo, authService, authService, config.LoadUserConfig())
+	userHandler := user.NewUserHandler(userService)
+
+	// --- Push ---
+	// Уведомления уходят через очередь, отправляет их outbox.Worker (канал push)
+	pushService := push.NewPushService(push.NewPushRepository(pool), queue, config.LoadPushConfig())
+	pushHandler := push.NewPushHandler(pushService)
+
+	// --- Chat ---
+	chatConfig := config.LoadChatConfig()
+	chatService := chat.NewChatService(chat.NewChatRepository(pool), chat.NewHub(chatConfig.StreamBuffer), pushService, chatConfig)
+	chatHandler := chat.NewChatHandler(chatService)
+
+	// --- Announcements ---
+	announcementService := announcement.NewAnnouncementService(announcement.NewAnnouncementRepository(pool), pushService, config.LoadAnnouncementConfig())
+	announcementHandler := announcement.NewAnnouncementHandler(announcementService)
+
+	// --- Devices ---
+	deviceConfig := config.LoadDeviceConfig()
+	deviceService := device.NewDeviceService(device.NewDeviceRepository(queries), deviceConfig)
+	deviceHandler := device.NewDeviceHandler(deviceService)
+
+	// --- Schedules (расписания доступа) ---
+	scheduleService := schedule.NewScheduleService(schedule.NewScheduleRepository(pool), config.LoadScheduleConfig())
+	scheduleHandler := schedule.NewScheduleHandler(scheduleService)
+
+	// --- Key lists (офлайн-списки ключей для панелей) ---
+	keyListService := keylist.NewKeyListService(keylist.NewKeyListRepository(queries), scheduleService, config.LoadKeyListConfig(), deviceConfig)
+	keyListHandler := keylist.NewKeyListHandler(keyListService)
+
+	// --- Access (гостевые пропуска, QR-коды жителей, потерянные ключи) ---
+	accessService := access.NewAccessService(access.NewAccessRepository(pool), scheduleService, pushService, keyListService, config.LoadAccessConfig())
+	accessHandler := access.NewAccessHandler(accessService)
+
+	// --- Anomalies (тревоги по журналу доступа; проверку запускает main) ---
+	anomalyService := anomaly.NewAnomalyService(anomaly.NewAnomalyRepository(pool), config.LoadAnomalyConfig())
+	anomalyHandler := anomaly.NewAnomalyHandler(anomalyService)
+
+	// --- Ограничение частоты запросов к открытым ручкам ---
+	rlConfig := config.LoadRateLimitConfig()
+	var rlStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if rlConfig.Store == "postgres" {
+		rlStore = ratelimit.NewPostgresStore(pool, rlConfig.StaleAfter)
+	}
+	limiter := ratelimit.NewLimiter(rlStore, rlConfig)
+	limit := func(route string, h http.HandlerFunc) http.Handler {
+		return middleware.RateLimit(limiter, route)(h)
+	}
+
+	r := mux.NewRouter()
+	// IP клиента за доверенными прокси — для лимитов и блокировок
+	r.Use(middleware.RealIP(config.LoadHTTPConfig().TrustedProxies))
+	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	r.HandleFunc("/.well-known/jwks.json", jwt.JWKSHandler).Methods("GET")
+
+	// --- Открытые ручки ---
+	// Регистрация (трёхшагово):
+	r.Handle("/auth/request-registration-code",     limit("registration_code", authHandler.RequestRegistrationCode)).Methods("POST")
+	r.Handle("/auth/verify-phone",                  limit("verify_phone", authHandler.VerifyPhone)).Methods("POST")
+	r.Handle("/auth/register",                      limit("register", authHandler.Register)).Methods("POST")
+	r.Handle("/auth/login",                         limit("login", authHandler.Login)).Methods("POST")
+	r.Handle("/auth/login/sms/request-code",        limit("login_sms_code", authHandler.RequestLoginCode)).Methods("POST")
+	r.Handle("/auth/login/sms",                     limit("login_sms", authHandler.LoginBySMS)).Methods("POST")
+	r.Handle("/auth/login/mfa",                     limit("login_mfa", authHandler.LoginMFA)).Methods("POST")
+	r.Handle("/auth/login/mfa/enroll/request-code", limit("mfa_enroll_code", authHandler.LoginMFAEnrollRequestCode)).Methods("POST")
+	r.Handle("/auth/login/mfa/enroll",              limit("mfa_enroll", authHandler.LoginMFAEnroll)).Methods("POST")
+	r.Handle("/auth/login/mfa/enroll/confirm",      limit("login_mfa", authHandler.LoginMFAEnrollConfirm)).Methods("POST")
+	r.Handle("/auth/forgot-password",               limit("forgot_password", authHandler.ForgotPassword)).Methods("POST")
+	r.Handle("/auth/reset-password",                limit("reset_password", authHandler.ResetPassword)).Methods("POST")
+	r.Handle("/auth/unlock/request-code",           limit("unlock_code", authHandler.RequestUnlockCode)).Methods("POST")
+	r.Handle("/auth/unlock",                        limit("unlock", authHandler.Unlock)).Methods("POST")
+	r.HandleFunc("/auth/email/confirm",             authHandler.ConfirmEmailPage).Methods("GET")
+	r.HandleFunc("/auth/email/confirm",             authHandler.ConfirmEmail).Methods("POST")
+	r.Handle("/auth/email/forgot-password",         limit("email_forgot_password", authHandler.ForgotPasswordEmail)).Methods("POST")
+	r.HandleFunc("/auth/email/reset-password",      authHandler.ResetPasswordEmail).Methods("POST")
+	r.HandleFunc("/auth/refresh", authHandler.Refresh).Methods("POST")
+	r.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST")
+
+	// --- Ручки домофонов (Authorization: Device <key>) ---
+	devices := r.PathPrefix("/device").Subrouter()
+	devices.Use(middleware.DeviceAuth(deviceService))
+	devices.Handle("/access/check", limit("device_access_check", accessHandler.Check)).Methods("POST")
+	devices.HandleFunc("/access/offline", accessHandler.UploadOffline).Methods("POST")
+	devices.HandleFunc("/key-list", keyListHandler.Sync).Methods("GET")
+	devices.HandleFunc("/key-list/ack", keyListHandler.Ack).Methods("POST")
+	devices.HandleFunc("/calls", accessHandler.Call).Methods("POST")
+
+	// --- Защищённые ручки (JWT Auth) ---
+	protected := r.PathPrefix("").Subrouter()
+	protected.Use(middleware.JWTAuth)
+
+	// User endpoints
+	protected.HandleFunc("/users/me", userHandler.GetCurrentUser).Methods("GET")
+	protected.HandleFunc("/users",      userHandler.GetUsers).Methods("GET")
+	protected.HandleFunc("/users",      userHandler.CreateUser).Methods("POST")
+	protected.HandleFunc("/users/{id}", userHandler.UpdateUser).Methods("PUT")
+	protected.HandleFunc("/users/{id}", userHandler.DeleteUser).Methods("DELETE")
+	protected.HandleFunc("/users/me/avatar", userHandler.UploadAvatar).Methods("POST")
+	protected.HandleFunc("/users/me/avatar", userHandler.DeleteAvatar).Methods("DELETE")
+	protected.HandleFunc("/users/me/username", userHandler.ChangeUsername).Methods("POST")
+	protected.HandleFunc("/users/me/fullname", userHandler.UpdateFullName).Methods("POST")
+	protected.HandleFunc("/users/me/email", userHandler.UpdateEmail).Methods("POST")
+
+avatarHandler := http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads")))
+r.PathPrefix("/uploads/").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+    w.Header().Set("Cache-Control", "public, max-age=604800")
+    avatarHandler.ServeHTTP(w, r)
+}))
+
+
+
+	protected.HandleFunc("/auth/change-password", authHandler.ChangePassword).Methods("POST")
+	protected.HandleFunc("/auth/change-phone/request-code", authHandler.RequestNewPhoneCode).Methods("POST")
+	protected.HandleFunc("/auth/change-phone/request-current-code", authHandler.RequestCurrentPhoneCode).Methods("POST")
+	protected.HandleFunc("/auth/change-phone", authHandler.ChangePhone).Methods("POST")
+	protected.HandleFunc("/auth/mfa", authHandler.MFAStatus).Methods("GET")
+	protected.HandleFunc("/auth/mfa/enroll", authHandler.EnrollMFA).Methods("POST")
+	protected.HandleFunc("/auth/mfa/enroll/confirm", authHandler.ConfirmMFAEnrollment).Methods("POST")
+	protected.HandleFunc("/auth/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes).Methods("POST")
+	protected.HandleFunc("/auth/mfa/disable", authHandler.DisableMFA).Methods("POST")
+
+	// Chat
+	protected.HandleFunc("/chats", chatHandler.ListChats).Methods("GET")
+	protected.HandleFunc("/chats/direct", chatHandler.OpenDirect).Methods("POST")
+	protected.HandleFunc("/chats/events", chatHandler.Events).Methods("GET")
+	protected.HandleFunc("/chats/messages/{id:[0-9]+}", chatHandler.EditMessage).Methods("PUT")
+	protected.HandleFunc("/chats/messages/{id:[0-9]+}", chatHandler.DeleteMessage).Methods("DELETE")
+	protected.HandleFunc("/chats/{id:[0-9]+}/members", chatHandler.Members).Methods("GET")
+	protected.HandleFunc("/chats/{id:[0-9]+}/messages", chatHandler.ListMessages).Methods("GET")
+	protected.HandleFunc("/chats/{id:[0-9]+}/messages", chatHandler.SendMessage).Methods("POST")
+	protected.HandleFunc("/chats/{id:[0-9]+}/read", chatHandler.MarkRead).Methods("POST")
+
+	// Push
+	protected.HandleFunc("/push/devices", pushHandler.RegisterDevice).Methods("POST")
+	protected.HandleFunc("/push/devices/current", pushHandler.UnregisterDevice).Methods("DELETE")
+	protected.HandleFunc("/push/preferences", pushHandler.Preferences).Methods("GET")
+	protected.HandleFunc("/push/preferences", pushHandler.UpdatePreferences).Methods("PUT")
+	protected.HandleFunc("/push/quiet-hours", pushHandler.SetQuietHours).Methods("PUT")
+	protected.HandleFunc("/push/quiet-hours", pushHandler.DisableQuietHours).Methods("DELETE")
+	protected.HandleFunc("/push/test", pushHandler.SendTest).Methods("POST")
+
+	// Announcements
+	protected.HandleFunc("/announcements", announcementHandler.ListActive).Methods("GET")
+	protected.HandleFunc("/announcements", announcementHandler.Create).Methods("POST")
+	protected.HandleFunc("/announcements/manage", announcementHandler.List).Methods("GET")
+	protected.HandleFunc("/announcements/{id:[0-9]+}", announcementHandler.Cancel).Methods("DELETE")
+	protected.HandleFunc("/announcements/{id:[0-9]+}/read", announcementHandler.MarkRead).Methods("POST")
+	protected.HandleFunc("/announcements/{id:[0-9]+}/reads", announcementHandler.Reads).Methods("GET")
+	protected.HandleFunc("/announcements/{id:[0-9]+}/attachments", announcementHandler.UploadAttachment).Methods("POST")
+	protected.HandleFunc("/announcements/{id:[0-9]+}/attachments/{attachment_id:[0-9]+}", announcementHandler.DownloadAttachment).Methods("GET")
+
+
+	// Devices
+	protected.HandleFunc("/devices/{id:[0-9]+}/api-key", deviceHandler.IssueKey).Methods("POST")
+	protected.HandleFunc("/devices/key-lists", keyListHandler.Status).Methods("GET")
+
+	// Guest passes
+	protected.HandleFunc("/guest-passes", accessHandler.CreateGuestPass).Methods("POST")
+	protected.HandleFunc("/guest-passes", accessHandler.ListGuestPasses).Methods("GET")
+	protected.HandleFunc("/guest-passes/{id:[0-9]+}", accessHandler.RevokeGuestPass).Methods("DELETE")
+	protected.HandleFunc("/guest-passes/{id:[0-9]+}/history", accessHandler.History).Methods("GET")
+
+	// QR-код жителя
+	protected.HandleFunc("/access/qr", accessHandler.QRCode).Methods("GET")
+
+	// Keys (потеря и перевыпуск)
+	protected.HandleFunc("/keys", accessHandler.ListKeys).Methods("GET")
+	protected.HandleFunc("/keys/{id:[0-9]+}/lost", accessHandler.ReportLost).Methods("POST")
+	protected.HandleFunc("/keys/{id:[0-9]+}/replacement", accessHandler.Reissue).Methods("POST")
+
+	// Security alerts
+	protected.HandleFunc("/security-alerts", anomalyHandler.List).Methods("GET")
+	protected.HandleFunc("/security-alerts/{id:[0-9]+}", anomalyHandler.Get).Methods("GET")
+	protected.HandleFunc("/security-alerts/{id:[0-9]+}/review", anomalyHandler.Review).Methods("POST")
+
+	// Access schedules
+	protected.HandleFunc("/access-schedules", scheduleHandler.List).Methods("GET")
+	protected.HandleFunc("/access-schedules", scheduleHandler.Create).Methods("POST")
+	protected.HandleFunc("/access-schedules/{id:[0-9]+}", scheduleHandler.Get).Methods("GET")
+	protected.HandleFunc("/access-schedules/{id:[0-9]+}", scheduleHandler.Update).Methods("PUT")
+	protected.HandleFunc("/access-schedules/{id:[0-9]+}", scheduleHandler.Delete).Methods("DELETE")
+	protected.HandleFunc("/keys/{id:[0-9]+}/schedule", scheduleHandler.SetKeySchedule).Methods("PUT")
+	protected.HandleFunc("/keys/{id:[0-9]+}/access", scheduleHandler.KeyAccess).Methods("GET")
+	protected.HandleFunc("/resident-type-schedules", scheduleHandler.ListResidentTypeSchedules).Methods("GET")
+	protected.HandleFunc("/resident-type-schedules/{type}", scheduleHandler.SetResidentTypeSchedule).Methods("PUT")
+
+	return r
+}